@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencies accumulates durations, such as connect times, and summarizes them
+type latencies struct {
+	lock   sync.Mutex
+	values []time.Duration
+}
+
+func (l *latencies) add(d time.Duration) {
+	l.lock.Lock()
+	l.values = append(l.values, d)
+	l.lock.Unlock()
+}
+
+// summary is a point-in-time description of a set of latencies
+type summary struct {
+	Count int
+	Min   time.Duration
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+func (s summary) String() string {
+	if s.Count == 0 {
+		return "n=0"
+	}
+
+	return fmt.Sprintf("n=%d min=%s p50=%s p90=%s p99=%s max=%s",
+		s.Count, s.Min, s.P50, s.P90, s.P99, s.Max)
+}
+
+func (l *latencies) summary() summary {
+	l.lock.Lock()
+	sorted := append([]time.Duration(nil), l.values...)
+	l.lock.Unlock()
+
+	if len(sorted) == 0 {
+		return summary{}
+	}
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	percentile := func(p float64) time.Duration {
+		return sorted[int(p*float64(len(sorted)-1))]
+	}
+
+	return summary{
+		Count: len(sorted),
+		Min:   sorted[0],
+		P50:   percentile(0.50),
+		P90:   percentile(0.90),
+		P99:   percentile(0.99),
+		Max:   sorted[len(sorted)-1],
+	}
+}
@@ -0,0 +1,126 @@
+// devicesim simulates a fleet of devices for load testing a device.Manager server such as
+// talaria.  Each simulated device is a deviceagent.Agent which connects, reconnects with backoff,
+// sends periodic events and answers requests.  Churn, in the form of random disconnects and
+// duplicate connections, can be injected to exercise registry, drain and rehash behavior.
+//
+// Usage:
+//
+//	devicesim [flags]
+//
+// With no --url, an embedded server built from device.ConnectHandler is started on --listen.
+// The embedded server honors simulated claims, set with --claim, and can route requests to
+// the simulated devices with --request-interval.
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/xmidt-org/webpa-common/v2/device"
+	"github.com/xmidt-org/webpa-common/v2/device/deviceagent"
+	"go.uber.org/zap"
+)
+
+const applicationName = "devicesim"
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(arguments []string, stdout, stderr io.Writer) int {
+	var (
+		fs  = pflag.NewFlagSet(applicationName, pflag.ContinueOnError)
+		cfg config
+
+		headers    = fs.StringArray("header", nil, "an extra HTTP header sent on connect, in the form Name:value")
+		listen     = fs.String("listen", "127.0.0.1:0", "the address of the embedded server, used when no --url is given")
+		maxDevices = fs.Int("max-devices", 0, "the embedded server's device limit, where 0 means unlimited")
+		debug      = fs.Bool("debug", false, "enable debug logging")
+	)
+
+	fs.StringVar(&cfg.URL, "url", "", "the websocket URL of an external server.  If unset, an embedded server is used.")
+	fs.StringVar(&cfg.DeviceHeader, "device-header", device.DeviceNameHeader, "the HTTP header which carries the device name")
+	fs.IntVarP(&cfg.Devices, "devices", "n", 100, "the number of simulated devices")
+	fs.StringVar(&cfg.IDFormat, "id-format", "mac:%012x", "the printf format used to generate device IDs from an integer")
+	fs.Uint64Var(&cfg.IDStart, "id-start", 1, "the integer used to generate the first device ID")
+	fs.StringToStringVar(&cfg.Convey, "convey", nil, "convey fields sent by each device.  Values may contain {id} and {index}.")
+	fs.StringToStringVar(&cfg.Claims, "claim", nil, "claims for each device, honored by the embedded server.  Values may contain {id} and {index}.")
+	fs.DurationVar(&cfg.Backoff.Initial, "backoff", deviceagent.DefaultInitialBackoff, "the initial reconnect delay")
+	fs.DurationVar(&cfg.Backoff.Max, "max-backoff", deviceagent.DefaultMaxBackoff, "the maximum reconnect delay")
+	fs.Float64Var(&cfg.ConnectRate, "connect-rate", 0, "devices started per second, where 0 starts every device at once")
+	fs.DurationVar(&cfg.EventInterval, "event-interval", 10*time.Second, "how often each device sends an event, where 0 disables events")
+	fs.IntVar(&cfg.PayloadSize, "payload-size", 64, "the size in bytes of each event payload")
+	fs.DurationVar(&cfg.ResponseDelay, "response-delay", 0, "the maximum random delay before a device answers a request")
+	fs.Float64Var(&cfg.ResponseErrorRate, "response-error-rate", 0, "the fraction of requests answered with a handler error")
+	fs.DurationVar(&cfg.RequestInterval, "request-interval", 0, "how often the embedded server routes a request to a random device")
+	fs.DurationVar(&cfg.RequestTimeout, "request-timeout", 10*time.Second, "the timeout for each routed request")
+	fs.DurationVar(&cfg.ChurnInterval, "churn-interval", 0, "how often churn is injected, where 0 disables churn")
+	fs.Float64Var(&cfg.ChurnFraction, "churn-fraction", 0.01, "the fraction of devices disconnected at each churn interval")
+	fs.IntVar(&cfg.Duplicates, "duplicates", 0, "the number of duplicate connections opened at each churn interval")
+	fs.DurationVar(&cfg.DuplicateHold, "duplicate-hold", 5*time.Second, "how long each duplicate connection is held open")
+	fs.DurationVar(&cfg.Duration, "duration", time.Minute, "how long the simulation runs")
+	fs.DurationVar(&cfg.ReportInterval, "report-interval", 5*time.Second, "how often progress is reported, where 0 disables progress")
+
+	fs.SetOutput(stderr)
+	if err := fs.Parse(arguments); err != nil {
+		return 2
+	}
+
+	if fs.NArg() != 0 || cfg.Devices < 1 || len(cfg.IDFormat) == 0 {
+		fmt.Fprintf(stderr, "Usage: %s [flags]\n", applicationName)
+		fs.PrintDefaults()
+		return 2
+	}
+
+	cfg.Header = make(http.Header, len(*headers))
+	for _, h := range *headers {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok {
+			fmt.Fprintf(stderr, "Invalid header: %s\n", h)
+			return 2
+		}
+
+		cfg.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	logger := zap.NewNop()
+	if *debug {
+		logger, _ = zap.NewDevelopment()
+	}
+
+	var embedded *server
+	if len(cfg.URL) == 0 {
+		var err error
+		embedded, err = startServer(*listen, *maxDevices, logger)
+		if err != nil {
+			fmt.Fprintf(stderr, "Unable to start embedded server: %s\n", err)
+			return 1
+		}
+
+		defer embedded.close()
+		cfg.URL = embedded.url()
+		fmt.Fprintf(stdout, "embedded server listening on %s\n", cfg.URL)
+	}
+
+	sim, err := newSimulator(cfg, embedded, logger)
+	if err != nil {
+		fmt.Fprintf(stderr, "Unable to create devices: %s\n", err)
+		return 1
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	ctx, cancelDuration := context.WithTimeout(ctx, cfg.Duration)
+	defer cancelDuration()
+
+	sim.run(ctx, stdout)
+	return 0
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatencies(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		l      latencies
+	)
+
+	assert.Equal("n=0", l.summary().String())
+	for i := 1; i <= 100; i++ {
+		l.add(time.Duration(i) * time.Millisecond)
+	}
+
+	s := l.summary()
+	assert.Equal(100, s.Count)
+	assert.Equal(time.Millisecond, s.Min)
+	assert.Equal(50*time.Millisecond, s.P50)
+	assert.Equal(90*time.Millisecond, s.P90)
+	assert.Equal(99*time.Millisecond, s.P99)
+	assert.Equal(100*time.Millisecond, s.Max)
+}
+
+func TestRunInvalid(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 2, run([]string{"--nosuch"}, &stdout, &stderr))
+	assert.Equal(t, 2, run([]string{"extra"}, &stdout, &stderr))
+	assert.Equal(t, 2, run([]string{"--devices", "0"}, &stdout, &stderr))
+	assert.Equal(t, 2, run([]string{"--header", "invalid"}, &stdout, &stderr))
+	assert.Equal(t, 1, run([]string{"--listen", "invalid address"}, &stdout, &stderr))
+}
+
+func TestRunEmbedded(t *testing.T) {
+	var (
+		assert         = assert.New(t)
+		require        = require.New(t)
+		stdout, stderr bytes.Buffer
+	)
+
+	require.Equal(0, run([]string{
+		"--devices", "5",
+		"--convey", "hw-serial-number={index}",
+		"--claim", "partner-id=comcast",
+		"--event-interval", "20ms",
+		"--request-interval", "20ms",
+		"--response-error-rate", "0.5",
+		"--churn-interval", "150ms",
+		"--churn-fraction", "0.4",
+		"--duplicates", "1",
+		"--duplicate-hold", "50ms",
+		"--backoff", "10ms",
+		"--duration", "1s",
+		"--report-interval", "250ms",
+	}, &stdout, &stderr))
+
+	output := stdout.String()
+	assert.Empty(stderr.String())
+	assert.Contains(output, "embedded server listening on ws://127.0.0.1:")
+	assert.Contains(output, "connected=")
+	assert.Contains(output, "devices:           5\n")
+	assert.Contains(output, "connect latency:   n=")
+	assert.NotContains(output, "connect latency:   n=0\n")
+	assert.NotContains(output, "events sent:       0 ")
+	assert.NotContains(output, "churned:           0\n")
+	assert.Contains(output, "duplicates:        ")
+	assert.NotContains(output, "requests:          0\n")
+	assert.Contains(output, "response statuses: ")
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/justinas/alice"
+	"github.com/xmidt-org/webpa-common/v2/device"
+	"go.uber.org/zap"
+)
+
+const (
+	// ClaimsHeader carries JSON-encoded claims from simulated devices to the embedded server,
+	// standing in for the JWT validation a real talaria performs
+	ClaimsHeader = "X-Devicesim-Claims"
+
+	connectPath = "/api/v2/device"
+	devicesPath = "/api/v2/devices"
+)
+
+// server is an embedded, talaria-style server built from a device.Manager and ConnectHandler
+type server struct {
+	manager  device.Manager
+	listener net.Listener
+	http     *http.Server
+
+	connects    int64
+	disconnects int64
+}
+
+// useClaims attaches any simulated claims to the device metadata in the request context
+func useClaims(logger *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			metadata := new(device.Metadata)
+			if value := request.Header.Get(ClaimsHeader); len(value) > 0 {
+				claims := make(map[string]interface{})
+				if err := json.Unmarshal([]byte(value), &claims); err != nil {
+					logger.Error("invalid simulated claims", zap.Error(err))
+				} else {
+					metadata.SetClaims(claims)
+				}
+			}
+
+			next.ServeHTTP(response, request.WithContext(
+				device.WithDeviceMetadata(request.Context(), metadata),
+			))
+		})
+	}
+}
+
+// startServer listens on the given address and serves device connections and the device list
+func startServer(address string, maxDevices int, logger *zap.Logger) (*server, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &server{listener: listener}
+	s.manager = device.NewManager(&device.Options{
+		MaxDevices: maxDevices,
+		Logger:     logger,
+		Listeners: []device.Listener{
+			func(e *device.Event) {
+				switch e.Type {
+				case device.Connect:
+					atomic.AddInt64(&s.connects, 1)
+				case device.Disconnect:
+					atomic.AddInt64(&s.disconnects, 1)
+				}
+			},
+		},
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle(connectPath, alice.New(device.UseID.FromHeader, useClaims(logger)).Then(
+		&device.ConnectHandler{
+			Logger:    logger,
+			Connector: s.manager,
+		},
+	))
+
+	mux.Handle(devicesPath, &device.ListHandler{
+		Logger:   logger,
+		Registry: s.manager,
+		Refresh:  time.Second,
+	})
+
+	s.http = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go s.http.Serve(listener)
+	return s, nil
+}
+
+// url returns the websocket URL to which simulated devices connect
+func (s *server) url() string {
+	return "ws://" + s.listener.Addr().String() + connectPath
+}
+
+func (s *server) close() {
+	s.http.Close()
+	s.manager.DisconnectAll(device.CloseReason{Text: "simulation-complete"})
+}
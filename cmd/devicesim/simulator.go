@@ -0,0 +1,493 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/xmidt-org/webpa-common/v2/convey"
+	"github.com/xmidt-org/webpa-common/v2/device"
+	"github.com/xmidt-org/webpa-common/v2/device/deviceagent"
+	"github.com/xmidt-org/wrp-go/v3"
+	"go.uber.org/zap"
+)
+
+var errSimulatedFailure = errors.New("simulated handler failure")
+
+// config holds the parsed simulation parameters
+type config struct {
+	URL          string
+	DeviceHeader string
+	Header       http.Header
+
+	Devices  int
+	IDFormat string
+	IDStart  uint64
+	Convey   map[string]string
+	Claims   map[string]string
+	Backoff  deviceagent.Backoff
+
+	ConnectRate       float64
+	EventInterval     time.Duration
+	PayloadSize       int
+	ResponseDelay     time.Duration
+	ResponseErrorRate float64
+
+	RequestInterval time.Duration
+	RequestTimeout  time.Duration
+
+	ChurnInterval time.Duration
+	ChurnFraction float64
+	Duplicates    int
+	DuplicateHold time.Duration
+
+	Duration       time.Duration
+	ReportInterval time.Duration
+}
+
+// timingDialer records how long each successful connection takes to establish
+type timingDialer struct {
+	device.Dialer
+	latencies *latencies
+	failures  int64
+}
+
+func (td *timingDialer) DialDevice(deviceName, url string, extra http.Header) (*websocket.Conn, *http.Response, error) {
+	start := time.Now()
+	conn, response, err := td.Dialer.DialDevice(deviceName, url, extra)
+	if err != nil {
+		atomic.AddInt64(&td.failures, 1)
+	} else {
+		td.latencies.add(time.Since(start))
+	}
+
+	return conn, response, err
+}
+
+// simulator drives a fleet of deviceagent.Agents against a device manager
+type simulator struct {
+	cfg    config
+	logger *zap.Logger
+	server *server
+	dialer *timingDialer
+	agents []*deviceagent.Agent
+
+	randomLock sync.Mutex
+	random     *rand.Rand
+
+	background sync.WaitGroup
+
+	connectLatency latencies
+	requestLatency latencies
+
+	eventsSent    int64
+	eventErrors   int64
+	churned       int64
+	duplicates    int64
+	requests      int64
+	requestErrors int64
+	transactions  int64
+
+	statusLock sync.Mutex
+	statuses   map[int64]int
+
+	// serverDevices is the embedded server's registry size just before the agents are stopped
+	serverDevices int
+}
+
+func newSimulator(cfg config, s *server, logger *zap.Logger) (*simulator, error) {
+	sim := &simulator{
+		cfg:      cfg,
+		logger:   logger,
+		server:   s,
+		random:   rand.New(rand.NewSource(time.Now().UnixNano())), // nolint: gosec
+		statuses: make(map[int64]int),
+	}
+
+	sim.dialer = &timingDialer{
+		Dialer:    device.NewDialer(device.DialerOptions{DeviceHeader: cfg.DeviceHeader}),
+		latencies: &sim.connectLatency,
+	}
+
+	sim.agents = make([]*deviceagent.Agent, cfg.Devices)
+	for i := range sim.agents {
+		agent, err := sim.newAgent(i)
+		if err != nil {
+			return nil, err
+		}
+
+		sim.agents[i] = agent
+	}
+
+	return sim, nil
+}
+
+func (sim *simulator) id(index int) device.ID {
+	return device.ID(fmt.Sprintf(sim.cfg.IDFormat, sim.cfg.IDStart+uint64(index)))
+}
+
+// expand substitutes the {id} and {index} placeholders in a configured value
+func (sim *simulator) expand(value string, index int) string {
+	return strings.NewReplacer(
+		"{id}", string(sim.id(index)),
+		"{index}", strconv.Itoa(index),
+	).Replace(value)
+}
+
+func (sim *simulator) newAgent(index int) (*deviceagent.Agent, error) {
+	header := make(http.Header, len(sim.cfg.Header)+1)
+	for name, values := range sim.cfg.Header {
+		header[name] = append([]string(nil), values...)
+	}
+
+	if len(sim.cfg.Claims) > 0 {
+		claims := make(map[string]string, len(sim.cfg.Claims))
+		for k, v := range sim.cfg.Claims {
+			claims[k] = sim.expand(v, index)
+		}
+
+		encoded, err := json.Marshal(claims)
+		if err != nil {
+			return nil, err
+		}
+
+		header.Set(ClaimsHeader, string(encoded))
+	}
+
+	var c convey.C
+	if len(sim.cfg.Convey) > 0 {
+		c = make(convey.C, len(sim.cfg.Convey))
+		for k, v := range sim.cfg.Convey {
+			c[k] = sim.expand(v, index)
+		}
+	}
+
+	return deviceagent.New(deviceagent.Options{
+		ID:             sim.id(index),
+		URL:            sim.cfg.URL,
+		Dialer:         sim.dialer,
+		Header:         header,
+		Convey:         c,
+		Backoff:        sim.cfg.Backoff,
+		DefaultHandler: deviceagent.HandlerFunc(sim.respond),
+		Logger:         sim.logger,
+	})
+}
+
+func (sim *simulator) float64() float64 {
+	sim.randomLock.Lock()
+	f := sim.random.Float64()
+	sim.randomLock.Unlock()
+	return f
+}
+
+func (sim *simulator) intn(n int) int {
+	sim.randomLock.Lock()
+	i := sim.random.Intn(n)
+	sim.randomLock.Unlock()
+	return i
+}
+
+// sleep waits for the given duration, returning false if the context was canceled first
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// respond is the simulated device behavior for every inbound message
+// nolint: typecheck
+func (sim *simulator) respond(ctx context.Context, m *wrp.Message) (*wrp.Message, error) {
+	if sim.cfg.ResponseDelay > 0 && !sleep(ctx, time.Duration(sim.float64()*float64(sim.cfg.ResponseDelay))) {
+		return nil, ctx.Err()
+	}
+
+	if sim.cfg.ResponseErrorRate > 0 && sim.float64() < sim.cfg.ResponseErrorRate {
+		return nil, errSimulatedFailure
+	}
+
+	return &wrp.Message{ContentType: m.ContentType, Payload: m.Payload}, nil
+}
+
+// run executes the simulation until the context is canceled, writing periodic reports
+func (sim *simulator) run(ctx context.Context, stdout io.Writer) {
+	started := time.Now()
+	sim.goBackground(func() { sim.ramp(ctx) })
+
+	if sim.cfg.ChurnInterval > 0 && (sim.cfg.ChurnFraction > 0 || sim.cfg.Duplicates > 0) {
+		sim.goBackground(func() { sim.churn(ctx) })
+	}
+
+	if sim.server != nil && sim.cfg.RequestInterval > 0 {
+		sim.goBackground(func() { sim.request(ctx) })
+	}
+
+	if sim.cfg.ReportInterval > 0 {
+		ticker := time.NewTicker(sim.cfg.ReportInterval)
+		defer ticker.Stop()
+
+		var lastEvents int64
+		for done := false; !done; {
+			select {
+			case <-ctx.Done():
+				done = true
+			case <-ticker.C:
+				lastEvents = sim.report(stdout, started, lastEvents)
+			}
+		}
+	} else {
+		<-ctx.Done()
+	}
+
+	sim.background.Wait()
+	if sim.server != nil {
+		sim.serverDevices = sim.server.manager.Len()
+	}
+
+	for _, agent := range sim.agents {
+		agent.Stop() // nolint: errcheck
+	}
+
+	sim.summarize(stdout, time.Since(started))
+}
+
+func (sim *simulator) goBackground(f func()) {
+	sim.background.Add(1)
+	go func() {
+		defer sim.background.Done()
+		f()
+	}()
+}
+
+// ramp starts the agents at the configured connect rate, then starts each agent's event loop
+func (sim *simulator) ramp(ctx context.Context) {
+	var interval time.Duration
+	if sim.cfg.ConnectRate > 0 {
+		interval = time.Duration(float64(time.Second) / sim.cfg.ConnectRate)
+	}
+
+	for _, agent := range sim.agents {
+		if ctx.Err() != nil {
+			return
+		}
+
+		agent.Start() // nolint: errcheck
+		if sim.cfg.EventInterval > 0 {
+			agent := agent
+			sim.goBackground(func() { sim.events(ctx, agent) })
+		}
+
+		if !sleep(ctx, interval) {
+			return
+		}
+	}
+}
+
+// events sends a simulated event from the given agent every EventInterval.  The first event
+// is randomly offset so that the fleet's traffic is spread across the interval.
+func (sim *simulator) events(ctx context.Context, agent *deviceagent.Agent) {
+	payload := make([]byte, sim.cfg.PayloadSize)
+	wait := time.Duration(sim.float64() * float64(sim.cfg.EventInterval))
+	for sleep(ctx, wait) {
+		wait = sim.cfg.EventInterval
+		if !agent.Connected() {
+			continue
+		}
+
+		// nolint: typecheck
+		err := agent.Send(&wrp.Message{
+			Type:        wrp.SimpleEventMessageType,
+			Source:      string(agent.ID()),
+			Destination: "event:device-status/" + string(agent.ID()) + "/simulated",
+			ContentType: wrp.MimeTypeOctetStream,
+			Payload:     payload,
+		})
+
+		if err != nil {
+			atomic.AddInt64(&sim.eventErrors, 1)
+		} else {
+			atomic.AddInt64(&sim.eventsSent, 1)
+		}
+	}
+}
+
+// churn periodically disconnects random devices and opens duplicate connections
+func (sim *simulator) churn(ctx context.Context) {
+	for sleep(ctx, sim.cfg.ChurnInterval) {
+		disconnects := int(math.Ceil(sim.cfg.ChurnFraction * float64(len(sim.agents))))
+		for i := 0; i < disconnects; i++ {
+			if sim.agents[sim.intn(len(sim.agents))].Disconnect() {
+				atomic.AddInt64(&sim.churned, 1)
+			}
+		}
+
+		for i := 0; i < sim.cfg.Duplicates; i++ {
+			index := sim.intn(len(sim.agents))
+			duplicate, err := sim.newAgent(index)
+			if err != nil {
+				sim.logger.Error("unable to create duplicate", zap.Error(err))
+				continue
+			}
+
+			atomic.AddInt64(&sim.duplicates, 1)
+			duplicate.Start() // nolint: errcheck
+			sim.goBackground(func() {
+				sleep(ctx, sim.cfg.DuplicateHold)
+				duplicate.Stop() // nolint: errcheck
+			})
+		}
+	}
+}
+
+// request routes a request to a random device through the embedded server every RequestInterval
+func (sim *simulator) request(ctx context.Context) {
+	for sleep(ctx, sim.cfg.RequestInterval) {
+		sim.goBackground(func() {
+			id := sim.id(sim.intn(len(sim.agents)))
+			requestCtx, cancel := context.WithTimeout(ctx, sim.cfg.RequestTimeout)
+			defer cancel()
+
+			start := time.Now()
+			atomic.AddInt64(&sim.requests, 1)
+			response, err := sim.server.manager.Route((&device.Request{
+				// nolint: typecheck
+				Message: &wrp.Message{
+					Type:            wrp.SimpleRequestResponseMessageType,
+					Source:          "dns:devicesim",
+					Destination:     string(id) + "/config",
+					TransactionUUID: fmt.Sprintf("devicesim-%d", atomic.AddInt64(&sim.transactions, 1)),
+					ContentType:     wrp.MimeTypeJson,
+					Payload:         []byte(`{"command":"GET","names":["Device.DeviceInfo.SoftwareVersion"]}`),
+				},
+				// nolint: typecheck
+				Format: wrp.Msgpack,
+			}).WithContext(requestCtx))
+
+			if err != nil {
+				atomic.AddInt64(&sim.requestErrors, 1)
+				return
+			}
+
+			sim.requestLatency.add(time.Since(start))
+			if status := response.Message.Status; status != nil {
+				sim.statusLock.Lock()
+				sim.statuses[*status]++
+				sim.statusLock.Unlock()
+			}
+		})
+	}
+}
+
+// totals aggregates the statistics of every agent
+func (sim *simulator) totals() (connected int, total deviceagent.Statistics) {
+	for _, agent := range sim.agents {
+		s := agent.Statistics()
+		if s.Connected {
+			connected++
+		}
+
+		total.Connects += s.Connects
+		total.ConnectFailures += s.ConnectFailures
+		total.Disconnects += s.Disconnects
+		total.MessagesSent += s.MessagesSent
+		total.MessagesReceived += s.MessagesReceived
+		total.BytesSent += s.BytesSent
+		total.BytesReceived += s.BytesReceived
+		total.Pings += s.Pings
+		total.Responses += s.Responses
+		total.HandlerErrors += s.HandlerErrors
+		total.DecodeErrors += s.DecodeErrors
+	}
+
+	return
+}
+
+// report writes a single progress line, returning the current event count for rate computation
+func (sim *simulator) report(stdout io.Writer, started time.Time, lastEvents int64) int64 {
+	var (
+		connected, total = sim.totals()
+		events           = atomic.LoadInt64(&sim.eventsSent)
+	)
+
+	fmt.Fprintf(stdout, "[%s] connected=%d/%d connects=%d failures=%d disconnects=%d events=%d (%.1f/s) requests=%d errors=%d\n",
+		time.Since(started).Round(time.Second), connected, len(sim.agents),
+		total.Connects, total.ConnectFailures, total.Disconnects,
+		events, float64(events-lastEvents)/sim.cfg.ReportInterval.Seconds(),
+		atomic.LoadInt64(&sim.requests), atomic.LoadInt64(&sim.requestErrors),
+	)
+
+	return events
+}
+
+// summarize writes the final results of the simulation
+func (sim *simulator) summarize(stdout io.Writer, elapsed time.Duration) {
+	_, total := sim.totals()
+	events := atomic.LoadInt64(&sim.eventsSent)
+
+	fmt.Fprintf(stdout, "elapsed:           %s\n", elapsed.Round(time.Millisecond))
+	fmt.Fprintf(stdout, "devices:           %d\n", len(sim.agents))
+	fmt.Fprintf(stdout, "connects:          %d\n", total.Connects)
+	fmt.Fprintf(stdout, "connect failures:  %d\n", total.ConnectFailures)
+	fmt.Fprintf(stdout, "disconnects:       %d\n", total.Disconnects)
+	fmt.Fprintf(stdout, "connect latency:   %s\n", sim.connectLatency.summary())
+	fmt.Fprintf(stdout, "events sent:       %d (%.1f/s)\n", events, float64(events)/elapsed.Seconds())
+	fmt.Fprintf(stdout, "event errors:      %d\n", atomic.LoadInt64(&sim.eventErrors))
+	fmt.Fprintf(stdout, "messages sent:     %d (%d bytes)\n", total.MessagesSent, total.BytesSent)
+	fmt.Fprintf(stdout, "messages received: %d (%d bytes)\n", total.MessagesReceived, total.BytesReceived)
+	fmt.Fprintf(stdout, "responses:         %d\n", total.Responses)
+	fmt.Fprintf(stdout, "handler errors:    %d\n", total.HandlerErrors)
+	fmt.Fprintf(stdout, "decode errors:     %d\n", total.DecodeErrors)
+	fmt.Fprintf(stdout, "churned:           %d\n", atomic.LoadInt64(&sim.churned))
+	fmt.Fprintf(stdout, "duplicates:        %d\n", atomic.LoadInt64(&sim.duplicates))
+
+	if sim.server != nil {
+		fmt.Fprintf(stdout, "requests:          %d\n", atomic.LoadInt64(&sim.requests))
+		fmt.Fprintf(stdout, "request errors:    %d\n", atomic.LoadInt64(&sim.requestErrors))
+		fmt.Fprintf(stdout, "request latency:   %s\n", sim.requestLatency.summary())
+
+		fmt.Fprintf(stdout, "response statuses: %s\n", sim.formatStatuses())
+		fmt.Fprintf(stdout, "server devices:    %d\n", sim.serverDevices)
+		fmt.Fprintf(stdout, "server connects:   %d\n", atomic.LoadInt64(&sim.server.connects))
+		fmt.Fprintf(stdout, "server disconnects: %d\n", atomic.LoadInt64(&sim.server.disconnects))
+	}
+}
+
+// formatStatuses renders the response status counts in status order, e.g. "200=10 500=2"
+func (sim *simulator) formatStatuses() string {
+	sim.statusLock.Lock()
+	defer sim.statusLock.Unlock()
+
+	statuses := make([]int64, 0, len(sim.statuses))
+	for status := range sim.statuses {
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i] < statuses[j] })
+	parts := make([]string, len(statuses))
+	for i, status := range statuses {
+		parts[i] = fmt.Sprintf("%d=%d", status, sim.statuses[status])
+	}
+
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,124 @@
+// hashmove compares how many keys each service hashing algorithm moves when a cluster scales.
+// A set of synthetic device keys is hashed against an initial set of instances, and then against
+// that set with instances added or removed.  For each algorithm, the fraction of keys that moved
+// is reported alongside the smallest fraction that could have moved and the resulting imbalance.
+//
+// Usage:
+//
+//	hashmove [flags] [instance ...]
+//
+// If no instances are given, --instances synthetic instance names are generated.
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/pflag"
+	"github.com/xmidt-org/webpa-common/v2/service"
+)
+
+const applicationName = "hashmove"
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// scenario is a change to the initial set of instances
+type scenario struct {
+	name  string
+	after []string
+}
+
+func run(arguments []string, stdout, stderr io.Writer) int {
+	var (
+		fs = pflag.NewFlagSet(applicationName, pflag.ContinueOnError)
+
+		algorithms      = fs.StringSlice("algorithm", service.Algorithms(), "the hashing algorithms to compare")
+		instanceCount   = fs.Int("instances", 10, "the number of synthetic instances, used when no instances are given")
+		add             = fs.Int("add", 1, "the number of instances to add when scaling up")
+		remove          = fs.Int("remove", 1, "the number of instances to remove when scaling down")
+		keyCount        = fs.Int("keys", 100000, "the number of device keys to hash")
+		vnodeCount      = fs.Int("vnode-count", service.DefaultVnodeCount, "the number of vnodes per instance for ring based algorithms")
+		maglevTableSize = fs.Int("maglev-table-size", service.DefaultMaglevTableSize, "the size of the maglev lookup table")
+		loadBalance     = fs.Float64("load-balance", service.DefaultLoadBalance, "the load bound for the bounded load algorithm")
+		seed            = fs.Int64("seed", 1, "the random seed used to generate keys and pick instances")
+	)
+
+	fs.SetOutput(stderr)
+	if err := fs.Parse(arguments); err != nil {
+		fmt.Fprintf(stderr, "%s\n", err)
+		return 2
+	}
+
+	var (
+		random    = rand.New(rand.NewSource(*seed))
+		instances = fs.Args()
+	)
+
+	if len(instances) == 0 {
+		for i := 0; i < *instanceCount; i++ {
+			instances = append(instances, fmt.Sprintf("talaria-%02d.example.com:8080", i))
+		}
+	}
+
+	if len(instances) == 0 || *keyCount < 1 || *add < 0 || *remove < 0 || *remove >= len(instances) {
+		fmt.Fprintf(stderr, "At least one instance and one key are required, and at least one instance must remain after removal\n")
+		return 2
+	}
+
+	keys := make([][]byte, *keyCount)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("mac:%012x", random.Int63n(1<<48)))
+	}
+
+	var (
+		scenarios []scenario
+		options   = service.HashOptions{
+			VnodeCount:      *vnodeCount,
+			MaglevTableSize: *maglevTableSize,
+			LoadBalance:     *loadBalance,
+		}
+	)
+
+	if *add > 0 {
+		grown := append([]string{}, instances...)
+		for i := 0; i < *add; i++ {
+			grown = append(grown, fmt.Sprintf("talaria-new-%02d.example.com:8080", i))
+		}
+
+		scenarios = append(scenarios, scenario{name: fmt.Sprintf("add %d", *add), after: grown})
+	}
+
+	if *remove > 0 {
+		shrunk := append([]string{}, instances...)
+		random.Shuffle(len(shrunk), func(i, j int) { shrunk[i], shrunk[j] = shrunk[j], shrunk[i] })
+		scenarios = append(scenarios, scenario{name: fmt.Sprintf("remove %d", *remove), after: shrunk[*remove:]})
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "ALGORITHM\tSCENARIO\tMOVED\tIDEAL\tIMBALANCE\n")
+	for _, algorithm := range *algorithms {
+		af, err := service.NewAlgorithmAccessorFactory(algorithm, options)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s\n", err)
+			return 2
+		}
+
+		for _, s := range scenarios {
+			m, err := service.MeasureMovement(af, instances, s.after, keys)
+			if err != nil {
+				fmt.Fprintf(stderr, "Unable to measure %s: %s\n", algorithm, err)
+				return 1
+			}
+
+			fmt.Fprintf(tw, "%s\t%s\t%.2f%%\t%.2f%%\t%.3f\n", algorithm, s.name, 100.0*m.Fraction(), 100.0*m.Ideal(), m.Imbalance())
+		}
+	}
+
+	tw.Flush()
+	return 0
+}
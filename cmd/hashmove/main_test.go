@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/webpa-common/v2/service"
+)
+
+func TestRun(t *testing.T) {
+	var (
+		assert         = assert.New(t)
+		stdout, stderr bytes.Buffer
+	)
+
+	assert.Equal(0, run([]string{"--keys", "1000", "--instances", "5", "--add", "2"}, &stdout, &stderr))
+	assert.Empty(stderr.String())
+	for _, algorithm := range service.Algorithms() {
+		assert.Contains(stdout.String(), algorithm)
+	}
+
+	assert.Contains(stdout.String(), "add 2")
+	assert.Contains(stdout.String(), "remove 1")
+}
+
+func TestRunInstances(t *testing.T) {
+	var (
+		assert         = assert.New(t)
+		stdout, stderr bytes.Buffer
+	)
+
+	assert.Equal(0, run([]string{"--keys", "100", "--algorithm", service.RendezvousAlgorithm, "--add", "0", "host1:80", "host2:80"}, &stdout, &stderr))
+	assert.Contains(stdout.String(), "rendezvous  remove 1")
+	assert.NotContains(stdout.String(), "add")
+}
+
+func TestRunInvalid(t *testing.T) {
+	for _, arguments := range [][]string{
+		{"--nosuch"},
+		{"--instances", "0"},
+		{"--remove", "3", "host1:80", "host2:80"},
+		{"--algorithm", "nosuch"},
+	} {
+		var stdout, stderr bytes.Buffer
+		assert.Equal(t, 2, run(arguments, &stdout, &stderr), "%v", arguments)
+		assert.NotEmpty(t, stderr.String(), "%v", arguments)
+	}
+}
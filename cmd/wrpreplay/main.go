@@ -0,0 +1,144 @@
+// wrpreplay replays a WRP capture file, written by devicecapture.Recorder, against a
+// device.Manager server such as talaria.  Each captured device is connected over a real
+// websocket and its inbound frames are sent with the captured timing.
+//
+// Usage:
+//
+//	wrpreplay [flags] capture-file
+//
+// With --dump, the capture is printed instead of being replayed.
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/xmidt-org/webpa-common/v2/device"
+	"github.com/xmidt-org/webpa-common/v2/device/devicecapture"
+	"github.com/xmidt-org/wrp-go/v3"
+	"go.uber.org/zap"
+)
+
+const applicationName = "wrpreplay"
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(arguments []string, stdout, stderr io.Writer) int {
+	var (
+		fs = pflag.NewFlagSet(applicationName, pflag.ContinueOnError)
+
+		url          = fs.String("url", "ws://localhost:6200/api/v2/device", "the websocket URL to which devices connect")
+		deviceHeader = fs.String("device-header", device.DeviceNameHeader, "the HTTP header which carries the device name")
+		headers      = fs.StringArray("header", nil, "an extra HTTP header sent on connect, in the form Name:value")
+		speed        = fs.Float64("speed", 1.0, "the factor by which replay is sped up")
+		noDelay      = fs.Bool("no-delay", false, "replay frames as fast as possible, ignoring captured timing")
+		linger       = fs.Duration("linger", 5*time.Second, "how long to hold connections open after the last frame")
+		dump         = fs.Bool("dump", false, "print the capture rather than replaying it")
+		debug        = fs.Bool("debug", false, "enable debug logging")
+	)
+
+	fs.SetOutput(stderr)
+	if err := fs.Parse(arguments); err != nil {
+		return 2
+	}
+
+	if fs.NArg() != 1 {
+		fmt.Fprintf(stderr, "Usage: %s [flags] capture-file\n", applicationName)
+		fs.PrintDefaults()
+		return 2
+	}
+
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "Unable to open capture: %s\n", err)
+		return 1
+	}
+
+	defer file.Close()
+	reader, err := devicecapture.NewReader(file)
+	if err != nil {
+		fmt.Fprintf(stderr, "Unable to read capture: %s\n", err)
+		return 1
+	}
+
+	if *dump {
+		return dumpCapture(reader, stdout, stderr)
+	}
+
+	extra := make(http.Header, len(*headers))
+	for _, h := range *headers {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok {
+			fmt.Fprintf(stderr, "Invalid header: %s\n", h)
+			return 2
+		}
+
+		extra.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	logger := zap.NewNop()
+	if *debug {
+		logger, _ = zap.NewDevelopment()
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	result, err := devicecapture.Replay(ctx, reader, devicecapture.ReplayOptions{
+		URL:     *url,
+		Dialer:  device.NewDialer(device.DialerOptions{DeviceHeader: *deviceHeader}),
+		Header:  extra,
+		Speed:   *speed,
+		NoDelay: *noDelay,
+		Linger:  *linger,
+		Logger:  logger,
+	})
+
+	fmt.Fprintf(stdout, "devices: %d, sent: %d, skipped: %d, received: %d, errors: %d\n",
+		result.Devices, result.Sent, result.Skipped, result.Received, result.Errors)
+
+	if err != nil {
+		fmt.Fprintf(stderr, "Replay failed: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// dumpCapture writes one line per record, decoding each frame as a WRP message where possible
+func dumpCapture(reader *devicecapture.Reader, stdout, stderr io.Writer) int {
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return 0
+		} else if err != nil {
+			fmt.Fprintf(stderr, "Unable to read capture: %s\n", err)
+			return 1
+		}
+
+		var (
+			// nolint: typecheck
+			message     wrp.Message
+			description string
+		)
+
+		// nolint: typecheck
+		if err := wrp.NewDecoderBytes(record.Frame, wrp.Msgpack).Decode(&message); err != nil {
+			description = fmt.Sprintf("undecodable: %s", err)
+		} else {
+			description = fmt.Sprintf("%s %s -> %s %s", message.Type, message.Source, message.Destination, message.TransactionUUID)
+		}
+
+		fmt.Fprintf(stdout, "%s %-8s %s [%d bytes] %s\n",
+			record.Timestamp.Format(time.RFC3339Nano), record.Direction, record.ID, len(record.Frame), description)
+	}
+}
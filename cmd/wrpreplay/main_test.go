@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/webpa-common/v2/device"
+	"github.com/xmidt-org/webpa-common/v2/device/devicecapture"
+	"github.com/xmidt-org/wrp-go/v3"
+)
+
+func writeCapture(t *testing.T) string {
+	var (
+		path  = filepath.Join(t.TempDir(), "test.wrpcap")
+		frame []byte
+	)
+
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	// nolint: typecheck
+	require.NoError(t, wrp.NewEncoderBytes(&frame, wrp.Msgpack).Encode(&wrp.Message{
+		Type:        wrp.SimpleEventMessageType,
+		Source:      "mac:112233445566",
+		Destination: "event:test",
+	}))
+
+	w, err := devicecapture.NewWriter(file)
+	require.NoError(t, err)
+	require.NoError(t, w.Write(devicecapture.Record{Timestamp: time.Now(), Direction: device.TraceInbound, ID: "mac:112233445566", Frame: frame}))
+	require.NoError(t, w.Write(devicecapture.Record{Timestamp: time.Now(), Direction: device.TraceOutbound, ID: "mac:112233445566", Frame: []byte{0xc1}}))
+	require.NoError(t, w.Flush())
+	return path
+}
+
+func TestRunDump(t *testing.T) {
+	var (
+		assert         = assert.New(t)
+		stdout, stderr bytes.Buffer
+	)
+
+	assert.Equal(0, run([]string{"--dump", writeCapture(t)}, &stdout, &stderr))
+	assert.Contains(stdout.String(), "mac:112233445566 -> event:test")
+	assert.Contains(stdout.String(), "undecodable")
+	assert.Empty(stderr.String())
+}
+
+func TestRunInvalid(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 2, run(nil, &stdout, &stderr))
+	assert.Equal(t, 2, run([]string{"--nosuch"}, &stdout, &stderr))
+	assert.Equal(t, 1, run([]string{filepath.Join(t.TempDir(), "missing")}, &stdout, &stderr))
+	assert.Equal(t, 2, run([]string{"--header", "invalid", writeCapture(t)}, &stdout, &stderr))
+}
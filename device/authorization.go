@@ -0,0 +1,286 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cast"
+	"github.com/xmidt-org/bascule"
+	"github.com/xmidt-org/wrp-go/v3"
+)
+
+const (
+	// AllowedResourcesClaimKey is the JWT claim holding the resources a caller may access
+	AllowedResourcesClaimKey = "allowedResources"
+
+	// AllowedPartnersClaimKey is the key, within AllowedResourcesClaimKey, of the caller's partner IDs
+	AllowedPartnersClaimKey = "allowedPartners"
+
+	// CapabilitiesClaimKey is the JWT claim holding the caller's capabilities
+	CapabilitiesClaimKey = "capabilities"
+)
+
+// Reasons for which the routing of a request can be denied, used as the reason
+// label of the route denied counter
+const (
+	DenyReasonMissingCaller     = "missing_caller"
+	DenyReasonUnclaimedDevice   = "unclaimed_device"
+	DenyReasonPartnerMismatch   = "partner_mismatch"
+	DenyReasonMissingCapability = "missing_capability"
+	DenyReasonError             = "error"
+)
+
+var ErrorRouteForbidden = errors.New("The caller is not authorized to send messages to that device")
+
+// AuthorizationError describes why a request was not routed to a device.  All AuthorizationErrors
+// match ErrorRouteForbidden with errors.Is.
+type AuthorizationError struct {
+	// Reason is one of the DenyReason constants
+	Reason string
+
+	// Message is a human-readable description of the denial
+	Message string
+}
+
+func (ae *AuthorizationError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrorRouteForbidden, ae.Message)
+}
+
+func (ae *AuthorizationError) Is(target error) bool {
+	return target == ErrorRouteForbidden
+}
+
+// Caller describes the party asking for a message to be routed to a device
+type Caller struct {
+	// PartnerIDs are the partners the caller may act for
+	PartnerIDs []string
+
+	// Capabilities are the caller's capabilities, e.g. "x1:webpa:api:.*:all"
+	Capabilities []string
+}
+
+// CallerFromClaims builds a Caller from JWT claims.  Partner IDs are taken from the allowedPartners
+// member of the allowedResources claim, falling back to the partner-id claim, and capabilities
+// are taken from the capabilities claim.
+func CallerFromClaims(claims map[string]interface{}) Caller {
+	var c Caller
+	if resources, ok := claims[AllowedResourcesClaimKey].(map[string]interface{}); ok {
+		c.PartnerIDs = cast.ToStringSlice(resources[AllowedPartnersClaimKey])
+	}
+
+	if len(c.PartnerIDs) == 0 {
+		if partnerID, ok := claims[PartnerIDClaimKey].(string); ok && len(partnerID) > 0 {
+			c.PartnerIDs = []string{partnerID}
+		}
+	}
+
+	c.Capabilities = cast.ToStringSlice(claims[CapabilitiesClaimKey])
+	return c
+}
+
+// CallerFromRequest is a strategy type for extracting the Caller from an HTTP request.
+// The boolean result is false if the request carries no caller information.
+type CallerFromRequest func(*http.Request) (Caller, bool)
+
+// BasculeCaller is the default CallerFromRequest.  It builds the Caller from the claims of the bascule
+// token that authenticated the request.  Requests that were not authenticated by bascule carry no caller.
+func BasculeCaller(request *http.Request) (Caller, bool) {
+	auth, ok := bascule.FromContext(request.Context())
+	if !ok || auth.Token == nil || auth.Token.Attributes() == nil {
+		return Caller{}, false
+	}
+
+	var (
+		attributes = auth.Token.Attributes()
+		claims     = make(map[string]interface{}, 3)
+	)
+
+	for _, key := range []string{AllowedResourcesClaimKey, PartnerIDClaimKey, CapabilitiesClaimKey} {
+		if value, ok := attributes.Get(key); ok {
+			claims[key] = value
+		}
+	}
+
+	return CallerFromClaims(claims), true
+}
+
+// UseCaller is an Alice-style constructor that inserts the Caller, if any, into the delegate's request Context
+func UseCaller(f CallerFromRequest) func(http.Handler) http.Handler {
+	return func(delegate http.Handler) http.Handler {
+		return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			if caller, ok := f(request); ok {
+				request = request.WithContext(WithCaller(request.Context(), caller))
+			}
+
+			delegate.ServeHTTP(response, request)
+		})
+	}
+}
+
+// Authorizer decides whether a request may be routed to a device
+type Authorizer interface {
+	// Authorize returns nil if the request may be routed to the given device.  The request's
+	// context carries the Caller, if known.  A denial is typically reported with an *AuthorizationError.
+	Authorize(ctx context.Context, d Interface, request *Request) error
+}
+
+// AuthorizerFunc is a function type that implements Authorizer
+type AuthorizerFunc func(context.Context, Interface, *Request) error
+
+func (af AuthorizerFunc) Authorize(ctx context.Context, d Interface, request *Request) error {
+	return af(ctx, d, request)
+}
+
+// CapabilityRule requires a capability for requests to matching WRP destinations
+type CapabilityRule struct {
+	// Destination is a regular expression matched against the WRP destination of each request,
+	// e.g. "^mac:[0-9a-f]+/config".
+	Destination string `json:"destination"`
+
+	// Capability is a regular expression that at least one of the caller's capabilities must match
+	// in full for a request to a matching destination to be allowed.
+	Capability string `json:"capability"`
+}
+
+// AuthorizationConfig is the configurable policy used by NewAuthorizer
+type AuthorizationConfig struct {
+	// WildcardPartners are partner IDs that grant access to every device, such as "*"
+	WildcardPartners []string `json:"wildcardPartners,omitempty"`
+
+	// AllowMissingCaller allows requests that carry no Caller, which are typically internal.  By default,
+	// such requests are denied.  Setting this leaves routing open to any request that was not authenticated.
+	AllowMissingCaller bool `json:"allowMissingCaller"`
+
+	// AllowUnclaimedDevices allows requests from any caller to devices without a partner ID claim,
+	// i.e. devices whose PartnerIDClaim is UnknownPartner
+	AllowUnclaimedDevices bool `json:"allowUnclaimedDevices"`
+
+	// Capabilities are the capability rules.  Every rule whose destination matches a request
+	// must be satisfied by the caller.
+	Capabilities []CapabilityRule `json:"capabilities,omitempty"`
+}
+
+type capabilityRule struct {
+	destination *regexp.Regexp
+	capability  *regexp.Regexp
+}
+
+// policy is the Authorizer built from an AuthorizationConfig
+type policy struct {
+	wildcardPartners      []string
+	allowMissingCaller    bool
+	allowUnclaimedDevices bool
+	rules                 []capabilityRule
+}
+
+// NewAuthorizer compiles an AuthorizationConfig into an Authorizer.  A request is allowed when one of the
+// caller's partner IDs matches the device's partner ID claim, or is a wildcard partner, and the caller holds
+// a capability for every capability rule matching the request's destination.
+func NewAuthorizer(c AuthorizationConfig) (Authorizer, error) {
+	p := &policy{
+		wildcardPartners:      append([]string(nil), c.WildcardPartners...),
+		allowMissingCaller:    c.AllowMissingCaller,
+		allowUnclaimedDevices: c.AllowUnclaimedDevices,
+		rules:                 make([]capabilityRule, 0, len(c.Capabilities)),
+	}
+
+	for _, rc := range c.Capabilities {
+		destination, err := regexp.Compile(rc.Destination)
+		if err != nil {
+			return nil, fmt.Errorf("invalid destination pattern %q: %w", rc.Destination, err)
+		}
+
+		capability, err := regexp.Compile("^(?:" + rc.Capability + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid capability pattern %q: %w", rc.Capability, err)
+		}
+
+		p.rules = append(p.rules, capabilityRule{destination: destination, capability: capability})
+	}
+
+	return p, nil
+}
+
+func containsPartner(partners []string, partner string) bool {
+	for _, p := range partners {
+		if strings.EqualFold(p, partner) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *policy) Authorize(ctx context.Context, d Interface, request *Request) error {
+	caller, ok := GetCaller(ctx)
+	if !ok {
+		if p.allowMissingCaller {
+			return nil
+		}
+
+		return &AuthorizationError{Reason: DenyReasonMissingCaller, Message: "no caller information"}
+	}
+
+	if err := p.authorizePartner(caller, d); err != nil {
+		return err
+	}
+
+	var destination string
+	// nolint: typecheck
+	if routable, ok := request.Message.(wrp.Routable); ok {
+		destination = routable.To()
+	}
+
+	for _, rule := range p.rules {
+		if !rule.destination.MatchString(destination) {
+			continue
+		}
+
+		granted := false
+		for _, capability := range caller.Capabilities {
+			if rule.capability.MatchString(capability) {
+				granted = true
+				break
+			}
+		}
+
+		if !granted {
+			return &AuthorizationError{
+				Reason:  DenyReasonMissingCapability,
+				Message: fmt.Sprintf("no capability matching %s for destination %s", rule.capability, destination),
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *policy) authorizePartner(caller Caller, d Interface) error {
+	for _, partner := range caller.PartnerIDs {
+		if containsPartner(p.wildcardPartners, partner) {
+			return nil
+		}
+	}
+
+	devicePartner := d.Metadata().PartnerIDClaim()
+	if len(devicePartner) == 0 || devicePartner == UnknownPartner {
+		if p.allowUnclaimedDevices {
+			return nil
+		}
+
+		return &AuthorizationError{Reason: DenyReasonUnclaimedDevice, Message: "the device has no partner ID"}
+	}
+
+	if !containsPartner(caller.PartnerIDs, devicePartner) {
+		return &AuthorizationError{
+			Reason:  DenyReasonPartnerMismatch,
+			Message: fmt.Sprintf("the caller cannot access devices for partner %s", devicePartner),
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,280 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/bascule"
+	"github.com/xmidt-org/wrp-go/v3"
+)
+
+func TestCallerFromClaims(t *testing.T) {
+	testData := []struct {
+		name     string
+		claims   map[string]interface{}
+		expected Caller
+	}{
+		{
+			name:     "Empty",
+			claims:   map[string]interface{}{},
+			expected: Caller{},
+		},
+		{
+			name: "AllowedPartners",
+			claims: map[string]interface{}{
+				AllowedResourcesClaimKey: map[string]interface{}{
+					AllowedPartnersClaimKey: []interface{}{"comcast", "sky"},
+				},
+				PartnerIDClaimKey:    "ignored",
+				CapabilitiesClaimKey: []interface{}{"x1:webpa:api:.*:all"},
+			},
+			expected: Caller{PartnerIDs: []string{"comcast", "sky"}, Capabilities: []string{"x1:webpa:api:.*:all"}},
+		},
+		{
+			name: "PartnerID",
+			claims: map[string]interface{}{
+				PartnerIDClaimKey:    "comcast",
+				CapabilitiesClaimKey: []string{"a", "b"},
+			},
+			expected: Caller{PartnerIDs: []string{"comcast"}, Capabilities: []string{"a", "b"}},
+		},
+	}
+
+	for _, record := range testData {
+		t.Run(record.name, func(t *testing.T) {
+			assert.Equal(t, record.expected, CallerFromClaims(record.claims))
+		})
+	}
+}
+
+func TestBasculeCaller(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		request = httptest.NewRequest("GET", "/", nil)
+	)
+
+	_, ok := BasculeCaller(request)
+	assert.False(ok)
+
+	_, ok = BasculeCaller(request.WithContext(bascule.WithAuthentication(request.Context(), bascule.Authentication{})))
+	assert.False(ok)
+
+	token := bascule.NewToken("jwt", "client", bascule.NewAttributes(map[string]interface{}{
+		AllowedResourcesClaimKey: map[string]interface{}{
+			AllowedPartnersClaimKey: []interface{}{"comcast"},
+		},
+		CapabilitiesClaimKey: []interface{}{"x1:webpa:api:.*:all"},
+		"sub":                "ignored",
+	}))
+
+	caller, ok := BasculeCaller(request.WithContext(bascule.WithAuthentication(request.Context(), bascule.Authentication{Token: token})))
+	assert.True(ok)
+	assert.Equal(Caller{PartnerIDs: []string{"comcast"}, Capabilities: []string{"x1:webpa:api:.*:all"}}, caller)
+}
+
+func TestUseCaller(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		present = true
+		handler = UseCaller(func(*http.Request) (Caller, bool) {
+			return Caller{PartnerIDs: []string{"comcast"}}, present
+		})(http.HandlerFunc(func(_ http.ResponseWriter, request *http.Request) {
+			caller, ok := GetCaller(request.Context())
+			assert.Equal(present, ok)
+			if ok {
+				assert.Equal([]string{"comcast"}, caller.PartnerIDs)
+			}
+		}))
+	)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	present = false
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
+
+func TestNewAuthorizerInvalid(t *testing.T) {
+	a, err := NewAuthorizer(AuthorizationConfig{Capabilities: []CapabilityRule{{Destination: "("}}})
+	assert.Nil(t, a)
+	assert.Error(t, err)
+
+	a, err = NewAuthorizer(AuthorizationConfig{Capabilities: []CapabilityRule{{Destination: ".*", Capability: "("}}})
+	assert.Nil(t, a)
+	assert.Error(t, err)
+}
+
+func testDeviceForPartner(partnerID string) *device {
+	metadata := new(Metadata)
+	if len(partnerID) > 0 {
+		metadata.SetClaims(map[string]interface{}{PartnerIDClaimKey: partnerID})
+	}
+
+	return newDevice(deviceOptions{ID: "mac:112233445566", Metadata: metadata})
+}
+
+func TestAuthorizer(t *testing.T) {
+	var (
+		config = AuthorizationConfig{
+			WildcardPartners: []string{"*"},
+			Capabilities: []CapabilityRule{
+				{Destination: "/config", Capability: `x1:webpa:api:device/config:.*`},
+				{Destination: ".*", Capability: `x1:webpa:api:.*`},
+			},
+		}
+
+		allCapabilities = []string{"x1:webpa:api:device/config:all"}
+	)
+
+	testData := []struct {
+		name          string
+		allowMissing  bool
+		unclaimed     bool
+		caller        *Caller
+		devicePartner string
+		destination   string
+		reason        string
+	}{
+		{name: "NoCaller", devicePartner: "comcast", reason: DenyReasonMissingCaller},
+		{name: "NoCallerAllowed", allowMissing: true, devicePartner: "comcast"},
+		{
+			name:          "PartnerMatch",
+			caller:        &Caller{PartnerIDs: []string{"sky", "Comcast"}, Capabilities: allCapabilities},
+			devicePartner: "comcast",
+			destination:   "mac:112233445566/config",
+		},
+		{
+			name:          "PartnerMismatch",
+			caller:        &Caller{PartnerIDs: []string{"sky"}, Capabilities: allCapabilities},
+			devicePartner: "comcast",
+			reason:        DenyReasonPartnerMismatch,
+		},
+		{
+			name:          "Wildcard",
+			caller:        &Caller{PartnerIDs: []string{"*"}, Capabilities: allCapabilities},
+			devicePartner: "comcast",
+			destination:   "mac:112233445566/config",
+		},
+		{
+			name:   "Unclaimed",
+			caller: &Caller{PartnerIDs: []string{"comcast"}, Capabilities: allCapabilities},
+			reason: DenyReasonUnclaimedDevice,
+		},
+		{
+			name:      "UnclaimedAllowed",
+			unclaimed: true,
+			caller:    &Caller{PartnerIDs: []string{"comcast"}, Capabilities: allCapabilities},
+		},
+		{
+			name:          "MissingCapability",
+			caller:        &Caller{PartnerIDs: []string{"comcast"}, Capabilities: []string{"x1:webpa:api:device/stat:all"}},
+			devicePartner: "comcast",
+			destination:   "mac:112233445566/config",
+			reason:        DenyReasonMissingCapability,
+		},
+		{
+			name:          "CapabilityForOtherDestinations",
+			caller:        &Caller{PartnerIDs: []string{"comcast"}, Capabilities: []string{"x1:webpa:api:device/stat:all"}},
+			devicePartner: "comcast",
+			destination:   "mac:112233445566/stat",
+		},
+		{
+			name:          "CapabilityMustMatchFully",
+			caller:        &Caller{PartnerIDs: []string{"comcast"}, Capabilities: []string{"prefix:x1:webpa:api:all"}},
+			devicePartner: "comcast",
+			destination:   "mac:112233445566/stat",
+			reason:        DenyReasonMissingCapability,
+		},
+	}
+
+	for _, record := range testData {
+		t.Run(record.name, func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				require = require.New(t)
+				ctx     = context.Background()
+			)
+
+			c := config
+			c.AllowMissingCaller = record.allowMissing
+			c.AllowUnclaimedDevices = record.unclaimed
+			authorizer, err := NewAuthorizer(c)
+			require.NoError(err)
+
+			if record.caller != nil {
+				ctx = WithCaller(ctx, *record.caller)
+			}
+
+			// nolint: typecheck
+			request := &Request{Message: &wrp.Message{Destination: record.destination}}
+			err = authorizer.Authorize(ctx, testDeviceForPartner(record.devicePartner), request)
+			if len(record.reason) == 0 {
+				assert.NoError(err)
+				return
+			}
+
+			require.Error(err)
+			assert.True(errors.Is(err, ErrorRouteForbidden))
+
+			var ae *AuthorizationError
+			require.True(errors.As(err, &ae))
+			assert.Equal(record.reason, ae.Reason)
+			assert.Contains(err.Error(), ErrorRouteForbidden.Error())
+		})
+	}
+}
+
+func TestManagerRouteAuthorization(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		denied  = newTestCounter()
+		allow   = false
+
+		m = NewManager(&Options{
+			Authorizer: AuthorizerFunc(func(ctx context.Context, d Interface, request *Request) error {
+				if allow {
+					return nil
+				} else if _, ok := GetCaller(ctx); ok {
+					return &AuthorizationError{Reason: DenyReasonPartnerMismatch, Message: "test"}
+				}
+
+				return errors.New("expected")
+			}),
+		}).(*manager)
+
+		d = testDeviceForPartner("comcast")
+	)
+
+	m.measures.RouteDenied = denied
+	require.NoError(m.devices.add(d))
+
+	request := (&Request{
+		// nolint: typecheck
+		Message: &wrp.Message{Type: wrp.SimpleEventMessageType, Destination: "mac:112233445566/config"},
+	}).WithContext(WithCaller(context.Background(), Caller{}))
+
+	response, err := m.Route(request)
+	assert.Nil(response)
+	assert.True(errors.Is(err, ErrorRouteForbidden))
+	assert.Equal(1.0, denied.count)
+	assert.Equal(DenyReasonPartnerMismatch, denied.labelPairs["reason"])
+
+	response, err = m.Route(request.WithContext(context.Background()))
+	assert.Nil(response)
+	assert.EqualError(err, "expected")
+	assert.Equal(2.0, denied.count)
+	assert.Equal(DenyReasonError, denied.labelPairs["reason"])
+
+	// an authorized request proceeds to the device, which isn't running its pumps
+	allow = true
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = m.Route(request.WithContext(ctx))
+	assert.False(errors.Is(err, ErrorRouteForbidden))
+	assert.Equal(2.0, denied.count)
+}
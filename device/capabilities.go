@@ -0,0 +1,267 @@
+package device
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xmidt-org/wrp-go/v3"
+	"go.uber.org/zap"
+)
+
+// CapabilitiesKey is the reserved metadata key under which a device's advertised Capabilities are stored
+const CapabilitiesKey = "capabilities"
+
+var (
+	ErrorServiceNotAdvertised = errors.New("The device has not advertised that service")
+	ErrorNoCapabilityPatterns = errors.New("At least one capability destination pattern is required")
+)
+
+// Capabilities is what a device has advertised about itself, typically in an event sent soon after connecting
+type Capabilities struct {
+	// Services are the WRP services, i.e. the first path segment after the device ID in a
+	// destination, to which the device will respond
+	Services []string `json:"services,omitempty"`
+
+	// Features holds any other advertised information, such as firmware features
+	Features map[string]interface{} `json:"features,omitempty"`
+
+	// UpdatedAt is when these capabilities were last updated
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// HasService tests if the given service was advertised
+func (c Capabilities) HasService(service string) bool {
+	for _, s := range c.Services {
+		if s == service {
+			return true
+		}
+	}
+
+	return false
+}
+
+// merge produces the union of these capabilities with newer ones
+func (c Capabilities) merge(newer Capabilities) Capabilities {
+	merged := Capabilities{
+		Services:  append([]string(nil), c.Services...),
+		Features:  make(map[string]interface{}, len(c.Features)+len(newer.Features)),
+		UpdatedAt: newer.UpdatedAt,
+	}
+
+	for _, s := range newer.Services {
+		if !merged.HasService(s) {
+			merged.Services = append(merged.Services, s)
+		}
+	}
+
+	for k, v := range c.Features {
+		merged.Features[k] = v
+	}
+
+	for k, v := range newer.Features {
+		merged.Features[k] = v
+	}
+
+	return merged
+}
+
+// Capabilities returns the capabilities advertised by the device, if any
+func (m *Metadata) Capabilities() (c Capabilities, ok bool) {
+	c, ok = m.loadData()[CapabilitiesKey].(Capabilities)
+	return
+}
+
+// SetCapabilities updates the capabilities advertised by the device that owns this metadata
+func (m *Metadata) SetCapabilities(c Capabilities) {
+	m.copyAndStore(CapabilitiesKey, c)
+}
+
+// CapabilityParser extracts Capabilities from a WRP message recognized as a capability advertisement
+// nolint: typecheck
+type CapabilityParser func(*wrp.Message) (Capabilities, error)
+
+// ParseJSONCapabilities is the default CapabilityParser.  It expects a JSON payload of the form
+// {"services": ["config", "stat"], "features": {...}}.
+// nolint: typecheck
+func ParseJSONCapabilities(m *wrp.Message) (Capabilities, error) {
+	var c Capabilities
+	err := json.Unmarshal(m.Payload, &c)
+	return c, err
+}
+
+// CapabilityOptions configures a CapabilityExtractor
+type CapabilityOptions struct {
+	// Destinations are regular expressions matched against the destination of each message
+	// received from a device.  Matching messages are parsed as capability advertisements.
+	// At least one pattern is required.
+	Destinations []string
+
+	// Parser extracts capabilities from matching messages.  If unset, ParseJSONCapabilities is used.
+	Parser CapabilityParser
+
+	// Merge combines each advertisement with the device's previous capabilities.  If false,
+	// the latest advertisement replaces any earlier one.
+	Merge bool
+
+	// RefuseUnadvertised causes requests for services a device has not advertised to be refused
+	// with ErrorServiceNotAdvertised
+	RefuseUnadvertised bool
+
+	// AllowUnknown permits any request to a device that has not advertised anything yet, when
+	// RefuseUnadvertised is set
+	AllowUnknown bool
+
+	// Now is the closure used to timestamp capabilities.  If unset, time.Now is used.
+	Now func() time.Time
+}
+
+// CapabilityExtractor recognizes capability advertisements from devices and records them in
+// each device's Metadata, and optionally refuses requests for unadvertised services
+type CapabilityExtractor struct {
+	destinations       []*regexp.Regexp
+	parser             CapabilityParser
+	merge              bool
+	refuseUnadvertised bool
+	allowUnknown       bool
+	now                func() time.Time
+}
+
+// NewCapabilityExtractor creates a CapabilityExtractor from a set of options
+func NewCapabilityExtractor(o CapabilityOptions) (*CapabilityExtractor, error) {
+	if len(o.Destinations) == 0 {
+		return nil, ErrorNoCapabilityPatterns
+	}
+
+	ce := &CapabilityExtractor{
+		destinations:       make([]*regexp.Regexp, 0, len(o.Destinations)),
+		parser:             o.Parser,
+		merge:              o.Merge,
+		refuseUnadvertised: o.RefuseUnadvertised,
+		allowUnknown:       o.AllowUnknown,
+		now:                o.Now,
+	}
+
+	for _, d := range o.Destinations {
+		pattern, err := regexp.Compile(d)
+		if err != nil {
+			return nil, fmt.Errorf("invalid capability destination pattern %q: %w", d, err)
+		}
+
+		ce.destinations = append(ce.destinations, pattern)
+	}
+
+	if ce.parser == nil {
+		ce.parser = ParseJSONCapabilities
+	}
+
+	if ce.now == nil {
+		ce.now = time.Now
+	}
+
+	return ce, nil
+}
+
+// Matches tests if the given destination is a capability advertisement
+func (ce *CapabilityExtractor) Matches(destination string) bool {
+	for _, pattern := range ce.destinations {
+		if pattern.MatchString(destination) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Extract examines a message received from the given device.  If the message is a capability
+// advertisement, the parsed capabilities are stored in the device's Metadata.  This method returns
+// true if the device's capabilities were updated.
+// nolint: typecheck
+func (ce *CapabilityExtractor) Extract(d Interface, m *wrp.Message) (bool, error) {
+	if !ce.Matches(m.Destination) {
+		return false, nil
+	}
+
+	c, err := ce.parser(m)
+	if err != nil {
+		return false, err
+	}
+
+	c.UpdatedAt = ce.now().UTC()
+	sort.Strings(c.Services)
+
+	metadata := d.Metadata()
+	if previous, ok := metadata.Capabilities(); ok && ce.merge {
+		c = previous.merge(c)
+		sort.Strings(c.Services)
+	}
+
+	metadata.SetCapabilities(c)
+	return true, nil
+}
+
+// destinationService extracts the service from a WRP destination, e.g. "config" from "mac:112233445566/config/foo".
+// The empty string is returned if the destination has no service.
+func destinationService(destination string) string {
+	_, rest, ok := strings.Cut(destination, "/")
+	if !ok {
+		return ""
+	}
+
+	service, _, _ := strings.Cut(rest, "/")
+	return service
+}
+
+// Allow checks a request bound for the given device against its advertised services.  Requests whose
+// destination has no service are always allowed.
+func (ce *CapabilityExtractor) Allow(d Interface, request *Request) error {
+	if !ce.refuseUnadvertised {
+		return nil
+	}
+
+	// nolint: typecheck
+	routable, ok := request.Message.(wrp.Routable)
+	if !ok {
+		return nil
+	}
+
+	service := destinationService(routable.To())
+	if len(service) == 0 {
+		return nil
+	}
+
+	c, ok := d.Metadata().Capabilities()
+	if !ok {
+		if ce.allowUnknown {
+			return nil
+		}
+
+		return ErrorServiceNotAdvertised
+	}
+
+	if !c.HasService(service) {
+		return ErrorServiceNotAdvertised
+	}
+
+	return nil
+}
+
+// extractCapabilities applies the configured CapabilityExtractor, if any, to a message from a device
+// nolint: typecheck
+func (m *manager) extractCapabilities(d *device, message *wrp.Message) {
+	if m.capabilities == nil {
+		return
+	}
+
+	updated, err := m.capabilities.Extract(d, message)
+	if err != nil {
+		d.logger.Error("unable to parse capabilities", zap.String("destination", message.Destination), zap.Error(err))
+	} else if updated {
+		c, _ := d.Metadata().Capabilities()
+		d.logger.Debug("capabilities updated", zap.Strings("services", c.Services))
+	}
+}
@@ -0,0 +1,214 @@
+package device
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/wrp-go/v3"
+)
+
+func testNewCapabilityExtractorInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	ce, err := NewCapabilityExtractor(CapabilityOptions{})
+	assert.Nil(ce)
+	assert.Equal(ErrorNoCapabilityPatterns, err)
+
+	ce, err = NewCapabilityExtractor(CapabilityOptions{Destinations: []string{"("}})
+	assert.Nil(ce)
+	assert.Error(err)
+}
+
+func testCapabilityExtractorExtract(t *testing.T, merge bool) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		now     = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+		ce, err = NewCapabilityExtractor(CapabilityOptions{
+			Destinations: []string{"^event:device-status/.*/capabilities$"},
+			Merge:        merge,
+			Now:          func() time.Time { return now },
+		})
+
+		d = newDevice(deviceOptions{ID: "mac:112233445566", Metadata: new(Metadata)})
+	)
+
+	require.NoError(err)
+	require.NotNil(ce)
+
+	// nolint: typecheck
+	updated, err := ce.Extract(d, &wrp.Message{Destination: "event:device-status/mac:112233445566/online"})
+	assert.False(updated)
+	assert.NoError(err)
+	_, ok := d.Metadata().Capabilities()
+	assert.False(ok)
+
+	// nolint: typecheck
+	updated, err = ce.Extract(d, &wrp.Message{
+		Destination: "event:device-status/mac:112233445566/capabilities",
+		Payload:     []byte(`{"services": ["stat", "config"], "features": {"mesh": true}}`),
+	})
+
+	assert.True(updated)
+	assert.NoError(err)
+	c, ok := d.Metadata().Capabilities()
+	require.True(ok)
+	assert.Equal([]string{"config", "stat"}, c.Services)
+	assert.Equal(map[string]interface{}{"mesh": true}, c.Features)
+	assert.Equal(now, c.UpdatedAt)
+
+	// nolint: typecheck
+	updated, err = ce.Extract(d, &wrp.Message{
+		Destination: "event:device-status/mac:112233445566/capabilities",
+		Payload:     []byte(`{"services": ["iot"], "features": {"radios": 2}}`),
+	})
+
+	assert.True(updated)
+	assert.NoError(err)
+	c, ok = d.Metadata().Capabilities()
+	require.True(ok)
+	if merge {
+		assert.Equal([]string{"config", "iot", "stat"}, c.Services)
+		assert.Equal(map[string]interface{}{"mesh": true, "radios": 2.0}, c.Features)
+	} else {
+		assert.Equal([]string{"iot"}, c.Services)
+		assert.Equal(map[string]interface{}{"radios": 2.0}, c.Features)
+	}
+
+	// nolint: typecheck
+	updated, err = ce.Extract(d, &wrp.Message{
+		Destination: "event:device-status/mac:112233445566/capabilities",
+		Payload:     []byte(`this is not JSON`),
+	})
+
+	assert.False(updated)
+	assert.Error(err)
+	_, ok = d.Metadata().Capabilities()
+	assert.True(ok)
+}
+
+func testCapabilityExtractorAllow(t *testing.T) {
+	// nolint: typecheck
+	newRequest := func(destination string) *Request {
+		return &Request{Message: &wrp.Message{Type: wrp.SimpleRequestResponseMessageType, Destination: destination}}
+	}
+
+	testData := []struct {
+		name               string
+		refuseUnadvertised bool
+		allowUnknown       bool
+		services           []string
+		destination        string
+		expected           error
+	}{
+		{name: "Disabled", destination: "mac:112233445566/iot"},
+		{name: "NoService", refuseUnadvertised: true, destination: "mac:112233445566"},
+		{name: "Unknown", refuseUnadvertised: true, destination: "mac:112233445566/iot", expected: ErrorServiceNotAdvertised},
+		{name: "AllowUnknown", refuseUnadvertised: true, allowUnknown: true, destination: "mac:112233445566/iot"},
+		{name: "Advertised", refuseUnadvertised: true, services: []string{"config", "iot"}, destination: "mac:112233445566/iot/path"},
+		{name: "Unadvertised", refuseUnadvertised: true, allowUnknown: true, services: []string{"config"}, destination: "mac:112233445566/iot", expected: ErrorServiceNotAdvertised},
+	}
+
+	for _, record := range testData {
+		t.Run(record.name, func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				require = require.New(t)
+
+				ce, err = NewCapabilityExtractor(CapabilityOptions{
+					Destinations:       []string{"/capabilities$"},
+					RefuseUnadvertised: record.refuseUnadvertised,
+					AllowUnknown:       record.allowUnknown,
+				})
+
+				d = newDevice(deviceOptions{ID: "mac:112233445566", Metadata: new(Metadata)})
+			)
+
+			require.NoError(err)
+			if record.services != nil {
+				d.Metadata().SetCapabilities(Capabilities{Services: record.services})
+			}
+
+			assert.Equal(record.expected, ce.Allow(d, newRequest(record.destination)))
+		})
+	}
+}
+
+func testCapabilitiesMarshalJSON(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		d       = newDevice(deviceOptions{ID: "mac:112233445566", Metadata: new(Metadata)})
+	)
+
+	d.Metadata().SetCapabilities(Capabilities{
+		Services:  []string{"config"},
+		UpdatedAt: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+	})
+
+	data, err := d.MarshalJSON()
+	require.NoError(err)
+
+	var output map[string]interface{}
+	require.NoError(json.Unmarshal(data, &output))
+	assert.Equal(
+		map[string]interface{}{"services": []interface{}{"config"}, "updatedAt": "2024-03-01T12:00:00Z"},
+		output["capabilities"],
+	)
+
+	assert.False(d.Metadata().Store(CapabilitiesKey, "overwrite"))
+}
+
+func TestCapabilityExtractor(t *testing.T) {
+	t.Run("NewInvalid", testNewCapabilityExtractorInvalid)
+	t.Run("Extract", func(t *testing.T) {
+		t.Run("Replace", func(t *testing.T) { testCapabilityExtractorExtract(t, false) })
+		t.Run("Merge", func(t *testing.T) { testCapabilityExtractorExtract(t, true) })
+	})
+
+	t.Run("Allow", testCapabilityExtractorAllow)
+	t.Run("MarshalJSON", testCapabilitiesMarshalJSON)
+}
+
+func TestManagerRouteCapabilities(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		ce, err = NewCapabilityExtractor(CapabilityOptions{
+			Destinations:       []string{"/capabilities$"},
+			RefuseUnadvertised: true,
+		})
+	)
+
+	require.NoError(err)
+
+	var (
+		m = NewManager(&Options{CapabilityExtractor: ce}).(*manager)
+		d = newDevice(deviceOptions{ID: "mac:112233445566", Metadata: new(Metadata)})
+	)
+
+	require.NoError(m.devices.add(d))
+
+	// nolint: typecheck
+	request := &Request{
+		Message: &wrp.Message{Type: wrp.SimpleEventMessageType, Destination: "mac:112233445566/config"},
+	}
+
+	response, err := m.Route(request)
+	assert.Nil(response)
+	assert.Equal(ErrorServiceNotAdvertised, err)
+
+	// an advertised service proceeds to the device, which isn't running its pumps
+	d.Metadata().SetCapabilities(Capabilities{Services: []string{"config"}})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = m.Route(request.WithContext(ctx))
+	assert.False(errors.Is(err, ErrorServiceNotAdvertised))
+}
@@ -0,0 +1,238 @@
+package device
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultCloseAnalyticsWindow     time.Duration = time.Hour
+	DefaultCloseAnalyticsResolution time.Duration = time.Minute
+)
+
+// CloseAnalyticsOptions configures a CloseAnalytics
+type CloseAnalyticsOptions struct {
+	// Window is how far back summaries can reach.  If unset, DefaultCloseAnalyticsWindow is used.
+	Window time.Duration
+
+	// Resolution is the granularity with which disconnections are bucketed.  Older buckets are
+	// discarded as a whole.  If unset, DefaultCloseAnalyticsResolution is used.
+	Resolution time.Duration
+
+	// Now is the closure used to determine the current time.  If unset, time.Now is used.
+	Now func() time.Time
+}
+
+// reasonStats accumulates the disconnections for a single close reason
+type reasonStats struct {
+	count int
+	total time.Duration
+	max   time.Duration
+}
+
+func (rs *reasonStats) add(lifetime time.Duration) {
+	rs.count++
+	rs.total += lifetime
+	if lifetime > rs.max {
+		rs.max = lifetime
+	}
+}
+
+// closeBucket holds the disconnections observed during one Resolution interval
+type closeBucket struct {
+	start   time.Time
+	reasons map[string]*reasonStats
+}
+
+// CloseAnalytics keeps a rolling record of device disconnections by close reason, so that the impact
+// of drains, rehashes and network instability can be quantified.  Use OnDeviceEvent as a Listener.
+type CloseAnalytics struct {
+	window     time.Duration
+	resolution time.Duration
+	now        func() time.Time
+
+	lock    sync.Mutex
+	buckets []closeBucket
+}
+
+// NewCloseAnalytics creates a CloseAnalytics from a set of options
+func NewCloseAnalytics(o CloseAnalyticsOptions) *CloseAnalytics {
+	ca := &CloseAnalytics{
+		window:     o.Window,
+		resolution: o.Resolution,
+		now:        o.Now,
+	}
+
+	if ca.window <= 0 {
+		ca.window = DefaultCloseAnalyticsWindow
+	}
+
+	if ca.resolution <= 0 {
+		ca.resolution = DefaultCloseAnalyticsResolution
+	}
+
+	if ca.resolution > ca.window {
+		ca.resolution = ca.window
+	}
+
+	if ca.now == nil {
+		ca.now = time.Now
+	}
+
+	ca.buckets = make([]closeBucket, (ca.window+ca.resolution-1)/ca.resolution)
+	return ca
+}
+
+// Window returns the maximum period covered by summaries
+func (ca *CloseAnalytics) Window() time.Duration {
+	return ca.window
+}
+
+// OnDeviceEvent is a Listener that records each Disconnect event
+func (ca *CloseAnalytics) OnDeviceEvent(e *Event) {
+	if e.Type == Disconnect {
+		ca.Observe(e.Device.CloseReason().Text, e.Device.Statistics().UpTime())
+	}
+}
+
+// Observe records a single disconnection with the given reason and connection lifetime
+func (ca *CloseAnalytics) Observe(reason string, lifetime time.Duration) {
+	if len(reason) == 0 {
+		reason = "unknown"
+	}
+
+	var (
+		start = ca.now().Truncate(ca.resolution)
+		index = int((start.UnixNano() / int64(ca.resolution)) % int64(len(ca.buckets)))
+	)
+
+	if index < 0 {
+		index += len(ca.buckets)
+	}
+
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+
+	b := &ca.buckets[index]
+	if !b.start.Equal(start) || b.reasons == nil {
+		b.start = start
+		b.reasons = make(map[string]*reasonStats)
+	}
+
+	rs, ok := b.reasons[reason]
+	if !ok {
+		rs = new(reasonStats)
+		b.reasons[reason] = rs
+	}
+
+	rs.add(lifetime)
+}
+
+// ReasonSummary describes the disconnections for one close reason
+type ReasonSummary struct {
+	Reason              string  `json:"reason"`
+	Count               int     `json:"count"`
+	Percent             float64 `json:"percent"`
+	MeanLifetimeSeconds float64 `json:"meanLifetimeSeconds"`
+	MaxLifetimeSeconds  float64 `json:"maxLifetimeSeconds"`
+}
+
+// CloseSummary describes the disconnections within a window of time
+type CloseSummary struct {
+	// Window is the period covered by this summary, e.g. "1h0m0s"
+	Window string `json:"window"`
+
+	// Since is the start of the period covered by this summary
+	Since time.Time `json:"since"`
+
+	// Total is the number of disconnections in the window
+	Total int `json:"total"`
+
+	// Reasons breaks down the disconnections by close reason, most frequent first
+	Reasons []ReasonSummary `json:"reasons"`
+
+	// Description is a human readable summary, e.g. "last 1h0m0s: 40.0% readerror, 30.0% rehash"
+	Description string `json:"description"`
+}
+
+// Summary describes the disconnections within the given window, which is clamped to the
+// window of this CloseAnalytics.  A nonpositive window summarizes the entire window.
+func (ca *CloseAnalytics) Summary(window time.Duration) CloseSummary {
+	if window <= 0 || window > ca.window {
+		window = ca.window
+	}
+
+	var (
+		now    = ca.now()
+		since  = now.Add(-window)
+		oldest = since.Truncate(ca.resolution)
+		totals = make(map[string]*reasonStats)
+		total  int
+	)
+
+	ca.lock.Lock()
+	for _, b := range ca.buckets {
+		if b.reasons == nil || b.start.Before(oldest) || b.start.After(now) {
+			continue
+		}
+
+		for reason, rs := range b.reasons {
+			t, ok := totals[reason]
+			if !ok {
+				t = new(reasonStats)
+				totals[reason] = t
+			}
+
+			t.count += rs.count
+			t.total += rs.total
+			if rs.max > t.max {
+				t.max = rs.max
+			}
+
+			total += rs.count
+		}
+	}
+
+	ca.lock.Unlock()
+
+	summary := CloseSummary{
+		Window:  window.String(),
+		Since:   since.UTC(),
+		Total:   total,
+		Reasons: make([]ReasonSummary, 0, len(totals)),
+	}
+
+	for reason, t := range totals {
+		summary.Reasons = append(summary.Reasons, ReasonSummary{
+			Reason:              reason,
+			Count:               t.count,
+			Percent:             100.0 * float64(t.count) / float64(total),
+			MeanLifetimeSeconds: (t.total / time.Duration(t.count)).Seconds(),
+			MaxLifetimeSeconds:  t.max.Seconds(),
+		})
+	}
+
+	sort.Slice(summary.Reasons, func(i, j int) bool {
+		if summary.Reasons[i].Count != summary.Reasons[j].Count {
+			return summary.Reasons[i].Count > summary.Reasons[j].Count
+		}
+
+		return summary.Reasons[i].Reason < summary.Reasons[j].Reason
+	})
+
+	parts := make([]string, len(summary.Reasons))
+	for i, rs := range summary.Reasons {
+		parts[i] = fmt.Sprintf("%.1f%% %s", rs.Percent, rs.Reason)
+	}
+
+	if len(parts) == 0 {
+		summary.Description = fmt.Sprintf("last %s: no disconnections", window)
+	} else {
+		summary.Description = fmt.Sprintf("last %s: %s", window, strings.Join(parts, ", "))
+	}
+
+	return summary
+}
@@ -0,0 +1,34 @@
+package device
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/xmidt-org/sallust"
+	"github.com/xmidt-org/webpa-common/v2/xhttp"
+	"go.uber.org/zap"
+)
+
+// CloseSummaryWindowParameter is the optional form parameter holding the period to summarize, e.g. 15m
+const CloseSummaryWindowParameter = "window"
+
+// CloseSummaryHandler is an http.Handler that writes the JSON CloseSummary of a CloseAnalytics
+type CloseSummaryHandler struct {
+	Analytics *CloseAnalytics
+}
+
+func (ch *CloseSummaryHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	logger := sallust.Get(request.Context())
+	var window time.Duration
+	if v := request.FormValue(CloseSummaryWindowParameter); len(v) > 0 {
+		var err error
+		window, err = time.ParseDuration(v)
+		if err != nil || window <= 0 {
+			logger.Error("invalid summary window", zap.String("window", v), zap.Error(err))
+			xhttp.WriteErrorf(response, http.StatusBadRequest, "invalid %s parameter: %s", CloseSummaryWindowParameter, v)
+			return
+		}
+	}
+
+	writeJSON(logger, response, ch.Analytics.Summary(window))
+}
@@ -0,0 +1,169 @@
+package device
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	// nolint:staticcheck
+	"github.com/xmidt-org/webpa-common/v2/xmetrics/xmetricstest"
+)
+
+func TestCloseAnalyticsDefaults(t *testing.T) {
+	assert := assert.New(t)
+	ca := NewCloseAnalytics(CloseAnalyticsOptions{})
+	assert.Equal(DefaultCloseAnalyticsWindow, ca.Window())
+	assert.Len(ca.buckets, 60)
+
+	ca = NewCloseAnalytics(CloseAnalyticsOptions{Window: time.Minute, Resolution: time.Hour})
+	assert.Len(ca.buckets, 1)
+
+	summary := ca.Summary(0)
+	assert.Zero(summary.Total)
+	assert.Empty(summary.Reasons)
+	assert.Equal("last 1m0s: no disconnections", summary.Description)
+}
+
+func TestCloseAnalytics(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		now     = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		ca      = NewCloseAnalytics(CloseAnalyticsOptions{
+			Window:     time.Hour,
+			Resolution: time.Minute,
+			Now:        func() time.Time { return now },
+		})
+	)
+
+	// this bucket will have rolled out of the window by the time of the summary
+	ca.Observe("stale", time.Second)
+
+	now = now.Add(30 * time.Minute)
+	ca.Observe("readerror", 10*time.Second)
+	ca.Observe("readerror", 30*time.Second)
+	ca.Observe("", time.Second)
+
+	now = now.Add(40 * time.Minute)
+	ca.Observe("rehash", time.Minute)
+	ca.Observe("readerror", 20*time.Second)
+	ca.Observe("drain", time.Hour)
+	ca.Observe("rehash", 3*time.Minute)
+	ca.Observe("rehash", 2*time.Minute)
+
+	summary := ca.Summary(0)
+	assert.Equal("1h0m0s", summary.Window)
+	assert.Equal(now.Add(-time.Hour), summary.Since)
+	assert.Equal(8, summary.Total)
+	require.Len(summary.Reasons, 4)
+
+	assert.Equal("readerror", summary.Reasons[0].Reason)
+	assert.Equal(3, summary.Reasons[0].Count)
+	assert.Equal(37.5, summary.Reasons[0].Percent)
+	assert.Equal(20.0, summary.Reasons[0].MeanLifetimeSeconds)
+	assert.Equal(30.0, summary.Reasons[0].MaxLifetimeSeconds)
+
+	assert.Equal("rehash", summary.Reasons[1].Reason)
+	assert.Equal(120.0, summary.Reasons[1].MeanLifetimeSeconds)
+	assert.Equal("drain", summary.Reasons[2].Reason)
+	assert.Equal("unknown", summary.Reasons[3].Reason)
+	assert.Equal("last 1h0m0s: 37.5% readerror, 37.5% rehash, 12.5% drain, 12.5% unknown", summary.Description)
+
+	// a shorter window only includes recent buckets
+	summary = ca.Summary(10 * time.Minute)
+	assert.Equal(5, summary.Total)
+	assert.Equal("last 10m0s: 60.0% rehash, 20.0% drain, 20.0% readerror", summary.Description)
+
+	// windows are clamped
+	assert.Equal("1h0m0s", ca.Summary(5*time.Hour).Window)
+
+	// once the window passes, everything is forgotten
+	now = now.Add(2 * time.Hour)
+	assert.Zero(ca.Summary(0).Total)
+}
+
+func TestCloseAnalyticsOnDeviceEvent(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		ca     = NewCloseAnalytics(CloseAnalyticsOptions{})
+		d      = newDevice(deviceOptions{ID: "mac:112233445566", ConnectedAt: time.Now().Add(-time.Minute)})
+	)
+
+	d.requestClose(CloseReason{Text: "test"})
+	ca.OnDeviceEvent(&Event{Type: Connect, Device: d})
+	ca.OnDeviceEvent(&Event{Type: Disconnect, Device: d})
+
+	summary := ca.Summary(0)
+	assert.Equal(1, summary.Total)
+	assert.Equal("test", summary.Reasons[0].Reason)
+	assert.InDelta(60.0, summary.Reasons[0].MeanLifetimeSeconds, 5.0)
+}
+
+func TestCloseSummaryHandler(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		ca      = NewCloseAnalytics(CloseAnalyticsOptions{})
+		handler = &CloseSummaryHandler{Analytics: ca}
+	)
+
+	ca.Observe("rehash", time.Minute)
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(http.StatusOK, response.Code)
+	assert.Equal("application/json", response.Header().Get("Content-Type"))
+
+	var summary CloseSummary
+	require.NoError(json.Unmarshal(response.Body.Bytes(), &summary))
+	assert.Equal("1h0m0s", summary.Window)
+	assert.Equal(1, summary.Total)
+	assert.Equal("rehash", summary.Reasons[0].Reason)
+
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest("GET", "/?window=15m", nil))
+	assert.Equal(http.StatusOK, response.Code)
+	require.NoError(json.Unmarshal(response.Body.Bytes(), &summary))
+	assert.Equal("15m0s", summary.Window)
+
+	for _, invalid := range []string{"invalid", "-1m"} {
+		response = httptest.NewRecorder()
+		handler.ServeHTTP(response, httptest.NewRequest("GET", "/?window="+invalid, nil))
+		assert.Equal(http.StatusBadRequest, response.Code)
+	}
+}
+
+func TestManagerCloseMetrics(t *testing.T) {
+	var (
+		provider = xmetricstest.NewProvider(nil, Metrics).
+				Expect(CloseReasonCounter, "reason", "rehash")(xmetricstest.Value(1.0)).
+				Expect(CloseReasonCounter, "reason", "readerror")(xmetricstest.Value(1.0))
+
+		m = NewManager(&Options{MetricsProvider: provider}).(*manager)
+
+		rehashed = newDevice(deviceOptions{ID: "mac:112233445566"})
+		failed   = newDevice(deviceOptions{ID: "mac:665544332211"})
+	)
+
+	rehashed.conveyClosure = func() {}
+	failed.conveyClosure = func() {}
+	require.NoError(t, m.devices.add(rehashed))
+	require.NoError(t, m.devices.add(failed))
+
+	// a device closed by the application keeps its original close reason
+	m.Disconnect(rehashed.id, CloseReason{Text: "rehash"})
+	m.pumpClose(rehashed, closerFunc(func() error { return nil }), CloseReason{Text: "write-error"})
+	m.pumpClose(failed, closerFunc(func() error { return nil }), CloseReason{Err: errors.New("expected"), Text: "readerror"})
+
+	provider.AssertExpectations(t)
+}
+
+type closerFunc func() error
+
+func (cf closerFunc) Close() error { return cf() }
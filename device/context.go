@@ -10,6 +10,7 @@ type key int
 const (
 	idKey key = iota
 	metadataKey
+	callerKey
 )
 
 // GetID returns the device ID from the context if any.
@@ -40,3 +41,14 @@ func GetDeviceMetadata(ctx context.Context) (metadata *Metadata, ok bool) {
 	metadata, ok = ctx.Value(metadataKey).(*Metadata)
 	return
 }
+
+// WithCaller returns a new context with the given Caller as a value.
+func WithCaller(parent context.Context, caller Caller) context.Context {
+	return context.WithValue(parent, callerKey, caller)
+}
+
+// GetCaller returns the Caller from the context if any.
+func GetCaller(ctx context.Context) (caller Caller, ok bool) {
+	caller, ok = ctx.Value(callerKey).(Caller)
+	return
+}
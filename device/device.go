@@ -120,6 +120,8 @@ type device struct {
 
 	metadata *Metadata
 
+	maxPayloadSize int
+
 	closeReason atomic.Value
 }
 
@@ -131,6 +133,9 @@ type deviceOptions struct {
 	ConnectedAt time.Time
 	Logger      *zap.Logger
 	Metadata    *Metadata
+
+	// MaxPayloadSize is the largest payload Send will accept.  Nonpositive values impose no limit.
+	MaxPayloadSize int
 }
 
 // newDevice is an internal factory function for devices
@@ -158,6 +163,8 @@ func newDevice(o deviceOptions) *device {
 		messages:     make(chan *envelope, o.QueueSize),
 		transactions: NewTransactions(),
 		metadata:     o.Metadata,
+
+		maxPayloadSize: o.MaxPayloadSize,
 	}
 }
 
@@ -170,13 +177,33 @@ func (d *device) MarshalJSON() ([]byte, error) {
 	var output bytes.Buffer
 	_, err := fmt.Fprintf(
 		&output,
-		`{"id": "%s", "pending": %d, "statistics": %s}`,
+		`{"id": "%s", "pending": %d, "statistics": %s`,
 		d.id,
 		len(d.messages),
 		d.statistics,
 	)
 
-	return output.Bytes(), err
+	if err != nil {
+		return nil, err
+	}
+
+	if d.metadata == nil {
+		output.WriteString("}")
+		return output.Bytes(), nil
+	}
+
+	if c, ok := d.metadata.Capabilities(); ok {
+		data, err := json.Marshal(c)
+		if err != nil {
+			return nil, err
+		}
+
+		output.WriteString(`, "capabilities": `)
+		output.Write(data)
+	}
+
+	output.WriteString("}")
+	return output.Bytes(), nil
 }
 
 func (d *device) requestClose(reason CloseReason) error {
@@ -265,6 +292,12 @@ func (d *device) Send(request *Request) (*Response, error) {
 		return nil, ErrorDeviceClosed
 	}
 
+	if d.maxPayloadSize > 0 {
+		if size := payloadSize(request); size > d.maxPayloadSize {
+			return nil, &MessageTooLargeError{Size: size, Max: d.maxPayloadSize}
+		}
+	}
+
 	var (
 		transactionKey, transactional = request.Transactional()
 		result                        <-chan *Response
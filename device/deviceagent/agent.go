@@ -0,0 +1,486 @@
+package deviceagent
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/xmidt-org/sallust"
+	"github.com/xmidt-org/webpa-common/v2/convey"
+	"github.com/xmidt-org/webpa-common/v2/convey/conveyhttp"
+	"github.com/xmidt-org/webpa-common/v2/device"
+	"github.com/xmidt-org/wrp-go/v3"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultWriteTimeout time.Duration = 10 * time.Second
+
+	// StatusNoHandler is the status of the automatic response to a request for which no Handler exists
+	StatusNoHandler int64 = 404
+
+	// StatusHandlerError is the status of the automatic response to a request whose Handler returned an error
+	StatusHandlerError int64 = 500
+
+	// StatusOK is the status of a response whose Handler did not set one
+	StatusOK int64 = 200
+)
+
+var (
+	ErrMissingID      = errors.New("an agent device ID is required")
+	ErrMissingURL     = errors.New("an agent URL is required")
+	ErrNotConnected   = errors.New("the agent is not connected")
+	ErrAlreadyStarted = errors.New("the agent has already been started")
+	ErrNotStarted     = errors.New("the agent has not been started")
+)
+
+// Options configures an Agent
+type Options struct {
+	// ID is the device identifier the agent connects as.  This field is required.
+	ID device.ID
+
+	// URL is the websocket URL of the device manager.  This field is required.
+	URL string
+
+	// Dialer is used to open each connection.  If unset, device.DefaultDialer() is used.
+	Dialer device.Dialer
+
+	// Header holds extra HTTP headers sent on each connection attempt, e.g. an Authorization header
+	Header http.Header
+
+	// Convey, if set, is encoded into the convey header on each connection attempt
+	Convey convey.C
+
+	// Backoff governs the delay between reconnect attempts
+	Backoff Backoff
+
+	// Handlers maps WRP destination services to the Handlers that process messages for them.
+	// See Service.
+	Handlers map[string]Handler
+
+	// DefaultHandler processes messages whose service has no entry in Handlers.  If unset, requests for
+	// unknown services are automatically answered with StatusNoHandler.
+	DefaultHandler Handler
+
+	// WriteTimeout bounds each websocket write.  If unset, DefaultWriteTimeout is used.
+	WriteTimeout time.Duration
+
+	// Logger is the sink for agent output.  If unset, sallust.Default() is used.
+	Logger *zap.Logger
+
+	// Random is the source of jitter for reconnect delays.  If unset, math/rand.Float64 is used.
+	Random func() float64
+
+	// After is the closure used to wait between reconnect attempts.  If unset, time.After is used.
+	After func(time.Duration) <-chan time.Time
+
+	// Now is the closure used to determine the current time.  If unset, time.Now is used.
+	Now func() time.Time
+}
+
+// Statistics is a snapshot of an agent's connection history and traffic
+type Statistics struct {
+	Connected        bool      `json:"connected"`
+	ConnectedAt      time.Time `json:"connectedAt,omitempty"`
+	Connects         int       `json:"connects"`
+	ConnectFailures  int       `json:"connectFailures"`
+	Disconnects      int       `json:"disconnects"`
+	MessagesSent     int       `json:"messagesSent"`
+	MessagesReceived int       `json:"messagesReceived"`
+	BytesSent        int       `json:"bytesSent"`
+	BytesReceived    int       `json:"bytesReceived"`
+	Pings            int       `json:"pings"`
+	Responses        int       `json:"responses"`
+	HandlerErrors    int       `json:"handlerErrors"`
+	DecodeErrors     int       `json:"decodeErrors"`
+	LastError        string    `json:"lastError,omitempty"`
+}
+
+// Agent is a simulated device.  It maintains a websocket connection to a device manager,
+// reconnecting with a jittered backoff whenever the connection is lost, and dispatches inbound
+// WRP messages to Handlers.  Agents are safe for concurrent use.
+type Agent struct {
+	id             device.ID
+	url            string
+	dialer         device.Dialer
+	header         http.Header
+	backoff        Backoff
+	handlers       map[string]Handler
+	defaultHandler Handler
+	writeTimeout   time.Duration
+	logger         *zap.Logger
+	random         func() float64
+	after          func(time.Duration) <-chan time.Time
+	now            func() time.Time
+
+	writeLock sync.Mutex
+	lock      sync.RWMutex
+	conn      *websocket.Conn
+	stats     Statistics
+	started   bool
+	stop      chan struct{}
+	done      chan struct{}
+	handling  sync.WaitGroup
+}
+
+// New creates an Agent.  The agent does not connect until Start is called.
+func New(o Options) (*Agent, error) {
+	if len(o.ID) == 0 {
+		return nil, ErrMissingID
+	}
+
+	if len(o.URL) == 0 {
+		return nil, ErrMissingURL
+	}
+
+	a := &Agent{
+		id:             o.ID,
+		url:            o.URL,
+		dialer:         o.Dialer,
+		header:         make(http.Header, len(o.Header)+1),
+		backoff:        o.Backoff,
+		handlers:       make(map[string]Handler, len(o.Handlers)),
+		defaultHandler: o.DefaultHandler,
+		writeTimeout:   o.WriteTimeout,
+		logger:         o.Logger,
+		random:         o.Random,
+		after:          o.After,
+		now:            o.Now,
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+
+	for name, values := range o.Header {
+		for _, value := range values {
+			a.header.Add(name, value)
+		}
+	}
+
+	if len(o.Convey) > 0 {
+		if err := conveyhttp.NewHeaderTranslator(device.ConveyHeader, nil).ToHeader(a.header, o.Convey); err != nil {
+			return nil, err
+		}
+	}
+
+	for service, h := range o.Handlers {
+		a.handlers[service] = h
+	}
+
+	if a.dialer == nil {
+		a.dialer = device.DefaultDialer()
+	}
+
+	if a.writeTimeout <= 0 {
+		a.writeTimeout = DefaultWriteTimeout
+	}
+
+	if a.logger == nil {
+		a.logger = sallust.Default()
+	}
+
+	a.logger = a.logger.With(zap.String("id", string(a.id)))
+	if a.random == nil {
+		a.random = rand.Float64
+	}
+
+	if a.after == nil {
+		a.after = time.After
+	}
+
+	if a.now == nil {
+		a.now = time.Now
+	}
+
+	return a, nil
+}
+
+// ID returns the device identifier of this agent
+func (a *Agent) ID() device.ID {
+	return a.id
+}
+
+// Start begins connecting in the background.  An agent can only be started once.
+func (a *Agent) Start() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.started {
+		return ErrAlreadyStarted
+	}
+
+	a.started = true
+	go a.run()
+	return nil
+}
+
+// Stop closes any connection, halts reconnection, and waits for the agent's goroutines to exit
+func (a *Agent) Stop() error {
+	a.lock.Lock()
+	if !a.started {
+		a.lock.Unlock()
+		return ErrNotStarted
+	}
+
+	select {
+	case <-a.stop:
+	default:
+		close(a.stop)
+	}
+
+	if a.conn != nil {
+		a.conn.Close()
+	}
+
+	a.lock.Unlock()
+	<-a.done
+	return nil
+}
+
+// Done returns a channel that is closed once a started agent has been stopped
+func (a *Agent) Done() <-chan struct{} {
+	return a.done
+}
+
+// Disconnect closes the current connection, if any, as if the network had dropped it.  The agent
+// reconnects according to its Backoff.  This method returns false if the agent was not connected.
+func (a *Agent) Disconnect() bool {
+	a.lock.RLock()
+	conn := a.conn
+	a.lock.RUnlock()
+	if conn == nil {
+		return false
+	}
+
+	conn.Close()
+	return true
+}
+
+// Connected tests if the agent currently has a connection
+func (a *Agent) Connected() bool {
+	a.lock.RLock()
+	connected := a.conn != nil
+	a.lock.RUnlock()
+	return connected
+}
+
+// Statistics returns a snapshot of this agent's statistics
+func (a *Agent) Statistics() Statistics {
+	a.lock.RLock()
+	s := a.stats
+	a.lock.RUnlock()
+	return s
+}
+
+func (a *Agent) update(f func(*Statistics)) {
+	a.lock.Lock()
+	f(&a.stats)
+	a.lock.Unlock()
+}
+
+func (a *Agent) stopped() bool {
+	select {
+	case <-a.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// run is the agent's connection loop
+func (a *Agent) run() {
+	defer close(a.done)
+	defer a.handling.Wait()
+
+	for attempt := 0; !a.stopped(); {
+		conn, err := a.dial()
+		if err != nil {
+			a.logger.Debug("connect failed", zap.Int("attempt", attempt), zap.Error(err))
+			a.update(func(s *Statistics) {
+				s.ConnectFailures++
+				s.LastError = err.Error()
+			})
+		} else {
+			attempt = 0
+			a.read(conn)
+		}
+
+		select {
+		case <-a.stop:
+			return
+		case <-a.after(a.backoff.Delay(attempt, a.random)):
+			if err != nil {
+				attempt++
+			}
+		}
+	}
+}
+
+func (a *Agent) dial() (*websocket.Conn, error) {
+	conn, response, err := a.dialer.DialDevice(string(a.id), a.url, a.header)
+	if response != nil && response.Body != nil {
+		response.Body.Close()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.stopped() {
+		conn.Close()
+		return nil, ErrNotConnected
+	}
+
+	a.conn = conn
+	a.stats.Connected = true
+	a.stats.ConnectedAt = a.now().UTC()
+	a.stats.Connects++
+	a.logger.Debug("connected")
+	return conn, nil
+}
+
+// read services a single connection until it fails or is closed
+func (a *Agent) read(conn *websocket.Conn) {
+	conn.SetPingHandler(func(data string) error {
+		a.update(func(s *Statistics) { s.Pings++ })
+		err := conn.WriteControl(websocket.PongMessage, []byte(data), a.now().Add(a.writeTimeout))
+		if err == websocket.ErrCloseSent {
+			return nil
+		}
+
+		return err
+	})
+
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			a.disconnected(conn, err)
+			return
+		}
+
+		if messageType != websocket.BinaryMessage {
+			continue
+		}
+
+		a.update(func(s *Statistics) {
+			s.MessagesReceived++
+			s.BytesReceived += len(data)
+		})
+
+		// nolint: typecheck
+		message := new(wrp.Message)
+		// nolint: typecheck
+		if err := wrp.NewDecoderBytes(data, wrp.Msgpack).Decode(message); err != nil {
+			a.logger.Error("unable to decode WRP message", zap.Error(err))
+			a.update(func(s *Statistics) { s.DecodeErrors++ })
+			continue
+		}
+
+		a.handling.Add(1)
+		go a.handle(message)
+	}
+}
+
+func (a *Agent) disconnected(conn *websocket.Conn, err error) {
+	conn.Close()
+	a.lock.Lock()
+	if a.conn == conn {
+		a.conn = nil
+	}
+
+	a.stats.Connected = false
+	a.stats.Disconnects++
+	if !a.stopped() {
+		a.stats.LastError = err.Error()
+	}
+
+	a.lock.Unlock()
+	a.logger.Debug("disconnected", zap.Error(err))
+}
+
+// handle dispatches a message to its Handler and, for requests, sends the response
+// nolint: typecheck
+func (a *Agent) handle(message *wrp.Message) {
+	defer a.handling.Done()
+
+	var (
+		h, ok  = a.handlers[Service(message.Destination)]
+		output *wrp.Message
+		err    error
+		status = StatusOK
+	)
+
+	if !ok {
+		h = a.defaultHandler
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-a.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if h != nil {
+		output, err = h.HandleWRP(ctx, message)
+		if err != nil {
+			a.logger.Error("handler failed", zap.String("destination", message.Destination), zap.Error(err))
+			a.update(func(s *Statistics) { s.HandlerErrors++ })
+			output, status = nil, StatusHandlerError
+		}
+	} else {
+		status = StatusNoHandler
+	}
+
+	if message.Type != wrp.SimpleRequestResponseMessageType || len(message.TransactionUUID) == 0 {
+		return
+	}
+
+	if err := a.Send(response(message, output, status)); err != nil {
+		a.logger.Error("unable to send response", zap.String("transactionUUID", message.TransactionUUID), zap.Error(err))
+		return
+	}
+
+	a.update(func(s *Statistics) { s.Responses++ })
+}
+
+// Send encodes the given message as Msgpack and writes it to the current connection.
+// If the agent is not connected, ErrNotConnected is returned.
+// nolint: typecheck
+func (a *Agent) Send(message *wrp.Message) error {
+	var frame []byte
+	// nolint: typecheck
+	if err := wrp.NewEncoderBytes(&frame, wrp.Msgpack).Encode(message); err != nil {
+		return err
+	}
+
+	a.lock.RLock()
+	conn := a.conn
+	a.lock.RUnlock()
+	if conn == nil {
+		return ErrNotConnected
+	}
+
+	a.writeLock.Lock()
+	conn.SetWriteDeadline(a.now().Add(a.writeTimeout))
+	err := conn.WriteMessage(websocket.BinaryMessage, frame)
+	a.writeLock.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	a.update(func(s *Statistics) {
+		s.MessagesSent++
+		s.BytesSent += len(frame)
+	})
+
+	return nil
+}
@@ -0,0 +1,240 @@
+package deviceagent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/justinas/alice"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/webpa-common/v2/convey"
+	"github.com/xmidt-org/webpa-common/v2/device"
+	"github.com/xmidt-org/wrp-go/v3"
+	"go.uber.org/zap"
+)
+
+const testID device.ID = "mac:112233445566"
+
+// startServer creates a manager behind an httptest server, returning the websocket URL
+func startServer(o *device.Options) (device.Manager, *httptest.Server, string) {
+	o.Logger = zap.NewNop()
+	var (
+		manager = device.NewManager(o)
+		server  = httptest.NewServer(
+			alice.New(device.UseID.FromHeader).Then(
+				&device.ConnectHandler{
+					Logger:    zap.NewNop(),
+					Connector: manager,
+				},
+			),
+		)
+	)
+
+	return manager, server, "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+// immediately is an After closure that never waits
+func immediately(time.Duration) <-chan time.Time {
+	c := make(chan time.Time, 1)
+	c <- time.Now()
+	return c
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(10 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			require.Fail(t, "condition not met within the timeout")
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func testNewInvalid(t *testing.T) {
+	a, err := New(Options{URL: "ws://localhost"})
+	assert.Nil(t, a)
+	assert.Equal(t, ErrMissingID, err)
+
+	a, err = New(Options{ID: testID})
+	assert.Nil(t, a)
+	assert.Equal(t, ErrMissingURL, err)
+}
+
+func testLifecycle(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	a, err := New(Options{ID: testID, URL: "ws://127.0.0.1:1/nosuch", Logger: zap.NewNop(), After: immediately})
+	require.NoError(err)
+	assert.Equal(testID, a.ID())
+	assert.Equal(ErrNotStarted, a.Stop())
+
+	require.NoError(a.Start())
+	assert.Equal(ErrAlreadyStarted, a.Start())
+	waitFor(t, func() bool { return a.Statistics().ConnectFailures > 2 })
+	assert.False(a.Connected())
+	assert.False(a.Disconnect())
+	assert.Equal(ErrNotConnected, a.Send(&wrp.Message{Type: wrp.SimpleEventMessageType}))
+
+	require.NoError(a.Stop())
+	<-a.Done()
+	assert.NotEmpty(a.Statistics().LastError)
+	assert.Zero(a.Statistics().Connects)
+}
+
+func testRequestResponse(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		connects = make(chan []byte, 1)
+
+		manager, server, url = startServer(&device.Options{
+			Listeners: []device.Listener{
+				func(e *device.Event) {
+					if e.Type == device.Connect {
+						connects <- append([]byte(nil), e.Contents...)
+					}
+				},
+			},
+		})
+	)
+
+	defer server.Close()
+	a, err := New(Options{
+		ID:     testID,
+		URL:    url,
+		Logger: zap.NewNop(),
+		Convey: convey.C{"hw-model": "simulated"},
+		Handlers: map[string]Handler{
+			"config": HandlerFunc(func(_ context.Context, m *wrp.Message) (*wrp.Message, error) {
+				return &wrp.Message{Payload: append([]byte("echo:"), m.Payload...)}, nil
+			}),
+			"broken": HandlerFunc(func(context.Context, *wrp.Message) (*wrp.Message, error) {
+				return nil, errors.New("expected")
+			}),
+		},
+	})
+
+	require.NoError(err)
+	require.NoError(a.Start())
+	defer a.Stop()
+
+	var c convey.C
+	require.NoError(json.Unmarshal(<-connects, &c))
+	assert.Equal("simulated", c["hw-model"])
+	waitFor(t, a.Connected)
+
+	route := func(service, transactionUUID string) *wrp.Message {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		response, err := manager.Route((&device.Request{
+			Message: &wrp.Message{
+				Type:            wrp.SimpleRequestResponseMessageType,
+				Source:          "dns:talaria",
+				Destination:     string(testID) + "/" + service,
+				TransactionUUID: transactionUUID,
+				Payload:         []byte("hello"),
+			},
+			Format: wrp.Msgpack,
+		}).WithContext(ctx))
+
+		require.NoError(err)
+		require.NotNil(response)
+		return response.Message
+	}
+
+	response := route("config", "1")
+	assert.Equal([]byte("echo:hello"), response.Payload)
+	assert.Equal("dns:talaria", response.Destination)
+	assert.Equal(string(testID)+"/config", response.Source)
+	require.NotNil(response.Status)
+	assert.Equal(StatusOK, *response.Status)
+
+	response = route("nosuch", "2")
+	require.NotNil(response.Status)
+	assert.Equal(StatusNoHandler, *response.Status)
+
+	response = route("broken", "3")
+	require.NotNil(response.Status)
+	assert.Equal(StatusHandlerError, *response.Status)
+
+	waitFor(t, func() bool { return a.Statistics().Responses == 3 })
+	stats := a.Statistics()
+	assert.True(stats.Connected)
+	assert.Equal(1, stats.Connects)
+	assert.Equal(3, stats.MessagesReceived)
+	assert.Equal(3, stats.MessagesSent)
+	assert.Equal(1, stats.HandlerErrors)
+	assert.True(stats.BytesSent > 0)
+	assert.True(stats.BytesReceived > 0)
+}
+
+func testEventsAndReconnect(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		received = make(chan string, 10)
+
+		manager, server, url = startServer(&device.Options{
+			Listeners: []device.Listener{
+				func(e *device.Event) {
+					if e.Type == device.MessageReceived {
+						received <- e.Message.(*wrp.Message).Destination
+					}
+				},
+			},
+		})
+	)
+
+	defer server.Close()
+	// the short delay gives the manager time to clean up the previous connection, so that the
+	// reconnect is not mistaken for a duplicate
+	a, err := New(Options{ID: testID, URL: url, Logger: zap.NewNop(), Backoff: Backoff{Initial: 50 * time.Millisecond, Jitter: -1}})
+	require.NoError(err)
+	require.NoError(a.Start())
+
+	waitFor(t, a.Connected)
+	require.NoError(a.Send(&wrp.Message{Type: wrp.SimpleEventMessageType, Source: string(testID), Destination: "event:first"}))
+	assert.Equal("event:first", <-received)
+
+	// a server-side disconnect results in a reconnect
+	assert.True(manager.Disconnect(testID, device.CloseReason{Text: "test"}))
+	waitFor(t, func() bool { return a.Statistics().Connects == 2 && a.Connected() })
+
+	// as does a client-side disconnect
+	assert.True(a.Disconnect())
+	waitFor(t, func() bool { return a.Statistics().Connects == 3 && a.Connected() })
+
+	require.NoError(a.Send(&wrp.Message{Type: wrp.SimpleEventMessageType, Source: string(testID), Destination: "event:second"}))
+	assert.Equal("event:second", <-received)
+
+	require.NoError(a.Stop())
+	stats := a.Statistics()
+	assert.False(stats.Connected)
+	assert.Equal(3, stats.Disconnects)
+	assert.False(a.Connected())
+}
+
+func TestService(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("config", Service("mac:112233445566/config"))
+	assert.Equal("config", Service("mac:112233445566/config/some/path"))
+	assert.Equal("", Service("mac:112233445566"))
+	assert.Equal("", Service("mac:112233445566/"))
+}
+
+func TestAgent(t *testing.T) {
+	t.Run("NewInvalid", testNewInvalid)
+	t.Run("Lifecycle", testLifecycle)
+	t.Run("RequestResponse", testRequestResponse)
+	t.Run("EventsAndReconnect", testEventsAndReconnect)
+}
@@ -0,0 +1,88 @@
+package deviceagent
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	DefaultInitialBackoff    time.Duration = time.Second
+	DefaultMaxBackoff        time.Duration = 2 * time.Minute
+	DefaultBackoffMultiplier float64       = 2.0
+	DefaultBackoffJitter     float64       = 0.5
+)
+
+// Backoff describes the delay between reconnect attempts.  The delay grows exponentially
+// from Initial up to Max, and a random portion of each delay, controlled by Jitter, is
+// removed so that a fleet of agents does not reconnect in lockstep.
+type Backoff struct {
+	// Initial is the delay before the first reconnect attempt.  If unset, DefaultInitialBackoff is used.
+	Initial time.Duration `json:"initial,omitempty"`
+
+	// Max is the upper bound on any delay.  If unset, DefaultMaxBackoff is used.
+	Max time.Duration `json:"max,omitempty"`
+
+	// Multiplier is the growth factor applied after each failed attempt.  If less than 1,
+	// DefaultBackoffMultiplier is used.
+	Multiplier float64 `json:"multiplier,omitempty"`
+
+	// Jitter is the fraction, between 0 and 1, of each delay that may be randomly removed.
+	// If zero, DefaultBackoffJitter is used.  A negative value disables jitter.
+	Jitter float64 `json:"jitter,omitempty"`
+}
+
+func (b Backoff) initial() time.Duration {
+	if b.Initial > 0 {
+		return b.Initial
+	}
+
+	return DefaultInitialBackoff
+}
+
+func (b Backoff) max() time.Duration {
+	if b.Max > 0 {
+		return b.Max
+	}
+
+	return DefaultMaxBackoff
+}
+
+func (b Backoff) multiplier() float64 {
+	if b.Multiplier >= 1.0 {
+		return b.Multiplier
+	}
+
+	return DefaultBackoffMultiplier
+}
+
+func (b Backoff) jitter() float64 {
+	switch {
+	case b.Jitter < 0.0:
+		return 0.0
+	case b.Jitter == 0.0:
+		return DefaultBackoffJitter
+	case b.Jitter > 1.0:
+		return 1.0
+	default:
+		return b.Jitter
+	}
+}
+
+// Delay computes the wait before the given attempt, where attempt 0 is the first reconnect.
+// The random closure must return values in [0, 1), as with math/rand.Float64.
+func (b Backoff) Delay(attempt int, random func() float64) time.Duration {
+	var (
+		max   = float64(b.max())
+		delay = float64(b.initial()) * math.Pow(b.multiplier(), float64(attempt))
+	)
+
+	if delay > max || math.IsInf(delay, 0) || math.IsNaN(delay) {
+		delay = max
+	}
+
+	if j := b.jitter(); j > 0.0 && random != nil {
+		delay -= delay * j * random()
+	}
+
+	return time.Duration(delay)
+}
@@ -0,0 +1,40 @@
+package deviceagent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	var (
+		zero = func() float64 { return 0.0 }
+		half = func() float64 { return 0.5 }
+	)
+
+	testData := []struct {
+		name     string
+		backoff  Backoff
+		attempt  int
+		random   func() float64
+		expected time.Duration
+	}{
+		{"Defaults", Backoff{}, 0, zero, DefaultInitialBackoff},
+		{"DefaultsGrowth", Backoff{}, 3, zero, 8 * DefaultInitialBackoff},
+		{"DefaultsMax", Backoff{}, 100, zero, DefaultMaxBackoff},
+		{"DefaultsOverflow", Backoff{}, 100000, zero, DefaultMaxBackoff},
+		{"DefaultJitter", Backoff{}, 0, half, 750 * time.Millisecond},
+		{"NoRandom", Backoff{}, 0, nil, DefaultInitialBackoff},
+		{"Custom", Backoff{Initial: 100 * time.Millisecond, Max: time.Second, Multiplier: 3, Jitter: -1}, 2, half, 900 * time.Millisecond},
+		{"CustomMax", Backoff{Initial: 100 * time.Millisecond, Max: time.Second, Multiplier: 3, Jitter: -1}, 3, half, time.Second},
+		{"FullJitter", Backoff{Initial: time.Second, Jitter: 2}, 0, half, 500 * time.Millisecond},
+		{"SmallMultiplier", Backoff{Initial: time.Second, Multiplier: 0.5, Jitter: 0.25}, 1, half, 1750 * time.Millisecond},
+	}
+
+	for _, record := range testData {
+		t.Run(record.name, func(t *testing.T) {
+			assert.Equal(t, record.expected, record.backoff.Delay(record.attempt, record.random))
+		})
+	}
+}
@@ -0,0 +1,12 @@
+/*
+Package deviceagent provides a reusable, device-side agent built on device.Dialer.
+
+An Agent connects to a device manager as a single device, reconnects with a jittered
+exponential backoff whenever its connection drops, answers pings, decodes inbound WRP
+messages and dispatches them to Handlers keyed by destination service.  Request/response
+messages are always answered, either with the Handler's output or with an error status,
+so that callers routing through the manager never wait on a missing response.
+
+Agents are intended for tests and for simulating fleets of devices.
+*/
+package deviceagent
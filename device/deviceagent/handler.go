@@ -0,0 +1,57 @@
+package deviceagent
+
+import (
+	"context"
+	"strings"
+
+	"github.com/xmidt-org/wrp-go/v3"
+)
+
+// Handler processes a WRP message delivered to an agent.  For request/response messages, the
+// returned message becomes the response, with its routing fields and transaction UUID filled in by
+// the agent.  A nil response results in an empty response with a 200 status.  For any other type of
+// message, the return values are only used for statistics.
+type Handler interface {
+	// nolint: typecheck
+	HandleWRP(context.Context, *wrp.Message) (*wrp.Message, error)
+}
+
+// HandlerFunc is a function type that implements Handler
+// nolint: typecheck
+type HandlerFunc func(context.Context, *wrp.Message) (*wrp.Message, error)
+
+// nolint: typecheck
+func (hf HandlerFunc) HandleWRP(ctx context.Context, m *wrp.Message) (*wrp.Message, error) {
+	return hf(ctx, m)
+}
+
+// Service extracts the service name from a WRP destination, e.g. "config" from
+// "mac:112233445566/config/some/path".  The empty string is returned if the destination
+// has no service.
+func Service(destination string) string {
+	_, rest, ok := strings.Cut(destination, "/")
+	if !ok {
+		return ""
+	}
+
+	service, _, _ := strings.Cut(rest, "/")
+	return service
+}
+
+// response builds the response to a request/response message from the handler's output
+// nolint: typecheck
+func response(request, output *wrp.Message, status int64) *wrp.Message {
+	if output == nil {
+		output = new(wrp.Message)
+	}
+
+	output.Type = wrp.SimpleRequestResponseMessageType
+	output.Source = request.Destination
+	output.Destination = request.Source
+	output.TransactionUUID = request.TransactionUUID
+	if output.Status == nil {
+		output.SetStatus(status)
+	}
+
+	return output
+}
@@ -0,0 +1,8 @@
+/*
+Package devicecapture records the WRP traffic exchanged between a device.Manager and its devices
+into a compact capture file, and replays such captures against a server over real websockets.
+
+A Recorder is attached to a manager through device.Options.Recorders.  Captures can be replayed
+with Replay, or with the wrpreplay command.
+*/
+package devicecapture
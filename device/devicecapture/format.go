@@ -0,0 +1,200 @@
+package devicecapture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/xmidt-org/webpa-common/v2/device"
+)
+
+// Version is the capture file format version written by this package
+const Version byte = 1
+
+const (
+	directionInbound  byte = 'i'
+	directionOutbound byte = 'o'
+
+	// maxRecordLength bounds the size of any single record, to guard against corrupt files
+	maxRecordLength = 64 * 1024 * 1024
+)
+
+var (
+	// magic identifies a capture file.  It is immediately followed by the Version byte.
+	magic = []byte("WRPCAP")
+
+	ErrInvalidHeader    = errors.New("not a WRP capture file")
+	ErrInvalidVersion   = errors.New("unsupported WRP capture file version")
+	ErrInvalidRecord    = errors.New("invalid WRP capture record")
+	ErrInvalidDirection = errors.New("invalid WRP capture direction")
+)
+
+// Record is a single captured frame.
+//
+// On disk, a capture file is the magic bytes "WRPCAP" and a version byte, followed by any number
+// of records.  Each record is a uvarint length followed by that many bytes:
+//
+//	direction    1 byte, 'i' for frames read from the device or 'o' for frames written to it
+//	timestamp    varint, nanoseconds since the Unix epoch
+//	id length    uvarint
+//	id           the canonical device ID
+//	frame        the remaining bytes, exactly as sent over the websocket
+type Record struct {
+	Timestamp time.Time
+	Direction device.TraceDirection
+	ID        device.ID
+	Frame     []byte
+}
+
+func encodeDirection(d device.TraceDirection) (byte, error) {
+	switch d {
+	case device.TraceInbound:
+		return directionInbound, nil
+	case device.TraceOutbound:
+		return directionOutbound, nil
+	default:
+		return 0, ErrInvalidDirection
+	}
+}
+
+func decodeDirection(b byte) (device.TraceDirection, error) {
+	switch b {
+	case directionInbound:
+		return device.TraceInbound, nil
+	case directionOutbound:
+		return device.TraceOutbound, nil
+	default:
+		return "", ErrInvalidDirection
+	}
+}
+
+// Writer appends records to a capture stream.  A Writer is safe for concurrent use.
+type Writer struct {
+	lock    sync.Mutex
+	w       *bufio.Writer
+	scratch []byte
+}
+
+// NewWriter writes the capture header to the given stream and returns a Writer for records.
+// Callers must invoke Flush to ensure buffered records reach the stream.
+func NewWriter(w io.Writer) (*Writer, error) {
+	cw := &Writer{
+		w: bufio.NewWriter(w),
+	}
+
+	cw.w.Write(magic)
+	cw.w.WriteByte(Version)
+	if err := cw.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	return cw, nil
+}
+
+// Write appends a single record
+func (cw *Writer) Write(r Record) error {
+	direction, err := encodeDirection(r.Direction)
+	if err != nil {
+		return err
+	}
+
+	defer cw.lock.Unlock()
+	cw.lock.Lock()
+
+	body := append(cw.scratch[:0], direction)
+	body = binary.AppendVarint(body, r.Timestamp.UnixNano())
+	body = binary.AppendUvarint(body, uint64(len(r.ID)))
+	body = append(body, r.ID...)
+	body = append(body, r.Frame...)
+	cw.scratch = body
+
+	var prefix [binary.MaxVarintLen64]byte
+	if _, err := cw.w.Write(prefix[:binary.PutUvarint(prefix[:], uint64(len(body)))]); err != nil {
+		return err
+	}
+
+	_, err = cw.w.Write(body)
+	return err
+}
+
+// Flush writes any buffered records to the underlying stream
+func (cw *Writer) Flush() error {
+	defer cw.lock.Unlock()
+	cw.lock.Lock()
+	return cw.w.Flush()
+}
+
+// Reader reads records from a capture stream
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader verifies the capture header of the given stream and returns a Reader for its records
+func NewReader(r io.Reader) (*Reader, error) {
+	cr := &Reader{
+		r: bufio.NewReader(r),
+	}
+
+	header := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(cr.r, header); err != nil {
+		return nil, ErrInvalidHeader
+	}
+
+	if string(header[:len(magic)]) != string(magic) {
+		return nil, ErrInvalidHeader
+	}
+
+	if header[len(magic)] != Version {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidVersion, header[len(magic)])
+	}
+
+	return cr, nil
+}
+
+// Read returns the next record.  At the end of the stream, io.EOF is returned.  A stream that
+// ends partway through a record results in io.ErrUnexpectedEOF.
+func (cr *Reader) Read() (Record, error) {
+	length, err := binary.ReadUvarint(cr.r)
+	if err == io.EOF {
+		return Record{}, io.EOF
+	} else if err != nil {
+		return Record{}, io.ErrUnexpectedEOF
+	}
+
+	if length < 3 || length > maxRecordLength {
+		return Record{}, ErrInvalidRecord
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(cr.r, body); err != nil {
+		return Record{}, io.ErrUnexpectedEOF
+	}
+
+	var r Record
+	if r.Direction, err = decodeDirection(body[0]); err != nil {
+		return Record{}, err
+	}
+
+	body = body[1:]
+	timestamp, n := binary.Varint(body)
+	if n <= 0 {
+		return Record{}, ErrInvalidRecord
+	}
+
+	r.Timestamp = time.Unix(0, timestamp).UTC()
+	body = body[n:]
+
+	idLength, n := binary.Uvarint(body)
+	if n <= 0 || idLength > uint64(len(body)-n) {
+		return Record{}, ErrInvalidRecord
+	}
+
+	body = body[n:]
+	r.ID = device.ID(body[:idLength])
+	r.Frame = body[idLength:]
+	return r, nil
+}
@@ -0,0 +1,100 @@
+package devicecapture
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/webpa-common/v2/device"
+)
+
+func testFormatRoundTrip(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		buffer  bytes.Buffer
+		now     = time.Now().UTC()
+
+		records = []Record{
+			{Timestamp: now, Direction: device.TraceInbound, ID: "mac:112233445566", Frame: []byte("inbound")},
+			{Timestamp: now.Add(time.Millisecond), Direction: device.TraceOutbound, ID: "mac:112233445566", Frame: []byte("outbound")},
+			{Timestamp: now.Add(time.Second), Direction: device.TraceInbound, ID: "uuid:1234", Frame: []byte{}},
+		}
+	)
+
+	w, err := NewWriter(&buffer)
+	require.NoError(err)
+	for _, r := range records {
+		require.NoError(w.Write(r))
+	}
+
+	require.NoError(w.Flush())
+	assert.True(bytes.HasPrefix(buffer.Bytes(), append([]byte("WRPCAP"), Version)))
+
+	r, err := NewReader(&buffer)
+	require.NoError(err)
+	for _, expected := range records {
+		actual, err := r.Read()
+		require.NoError(err)
+		assert.True(expected.Timestamp.Equal(actual.Timestamp))
+		assert.Equal(expected.Direction, actual.Direction)
+		assert.Equal(expected.ID, actual.ID)
+		assert.Equal(expected.Frame, actual.Frame)
+	}
+
+	_, err = r.Read()
+	assert.Equal(io.EOF, err)
+}
+
+func testFormatInvalidDirection(t *testing.T) {
+	var buffer bytes.Buffer
+	w, err := NewWriter(&buffer)
+	require.NoError(t, err)
+	assert.Equal(t, ErrInvalidDirection, w.Write(Record{Direction: "sideways"}))
+}
+
+func testFormatInvalidHeader(t *testing.T) {
+	for _, header := range [][]byte{nil, []byte("WRP"), []byte("NOTCAP\x01")} {
+		r, err := NewReader(bytes.NewReader(header))
+		assert.Nil(t, r)
+		assert.Equal(t, ErrInvalidHeader, err)
+	}
+
+	r, err := NewReader(bytes.NewReader([]byte("WRPCAP\x02")))
+	assert.Nil(t, r)
+	assert.True(t, errors.Is(err, ErrInvalidVersion))
+}
+
+func testFormatCorrupt(t *testing.T) {
+	testData := []struct {
+		name     string
+		body     []byte
+		expected error
+	}{
+		{"Truncated", []byte{10, 'i', 0}, io.ErrUnexpectedEOF},
+		{"TooShort", []byte{1, 'i'}, ErrInvalidRecord},
+		{"BadDirection", []byte{3, 'x', 0, 0}, ErrInvalidDirection},
+		{"BadIDLength", []byte{3, 'i', 0, 5}, ErrInvalidRecord},
+	}
+
+	for _, record := range testData {
+		t.Run(record.name, func(t *testing.T) {
+			r, err := NewReader(bytes.NewReader(append([]byte("WRPCAP\x01"), record.body...)))
+			require.NoError(t, err)
+
+			_, err = r.Read()
+			assert.Equal(t, record.expected, err)
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	t.Run("RoundTrip", testFormatRoundTrip)
+	t.Run("InvalidDirection", testFormatInvalidDirection)
+	t.Run("InvalidHeader", testFormatInvalidHeader)
+	t.Run("Corrupt", testFormatCorrupt)
+}
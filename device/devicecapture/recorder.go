@@ -0,0 +1,198 @@
+package devicecapture
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xmidt-org/sallust"
+	"github.com/xmidt-org/webpa-common/v2/device"
+	"go.uber.org/zap"
+)
+
+// DefaultQueueSize is the number of records that may be waiting to be written before
+// a Recorder starts dropping frames
+const DefaultQueueSize = 1000
+
+var ErrRecorderClosed = errors.New("the recorder has been closed")
+
+// RecorderOptions configures a Recorder
+type RecorderOptions struct {
+	// Writer is the capture stream to which records are written.  This field is required.
+	Writer *Writer
+
+	// Logger is the sink for write errors.  If unset, sallust.Default() is used.
+	Logger *zap.Logger
+
+	// QueueSize is the number of records buffered in memory while waiting to be written.
+	// If nonpositive, DefaultQueueSize is used.
+	QueueSize int
+
+	// All indicates that frames for every device should be recorded, regardless of selection
+	All bool
+
+	// IDs is the initial set of selected devices
+	IDs []device.ID
+
+	// Now is the closure used to timestamp records.  If not set, time.Now is used.
+	Now func() time.Time
+}
+
+// Recorder is a device.FrameRecorder that writes the frames of selected devices to a capture
+// stream.  Records are written by a background goroutine so that device pumps never wait on I/O.
+// If the queue fills up, frames are dropped rather than blocking a device.
+type Recorder struct {
+	logger *zap.Logger
+	writer *Writer
+	all    bool
+	now    func() time.Time
+
+	lock     sync.RWMutex
+	selected map[device.ID]bool
+	closed   bool
+
+	queue   chan Record
+	done    chan struct{}
+	dropped int64
+	written int64
+}
+
+var _ device.FrameRecorder = (*Recorder)(nil)
+
+// NewRecorder creates a Recorder and starts its background writer
+func NewRecorder(o RecorderOptions) *Recorder {
+	if o.Writer == nil {
+		panic("A capture Writer is required")
+	}
+
+	if o.QueueSize < 1 {
+		o.QueueSize = DefaultQueueSize
+	}
+
+	r := &Recorder{
+		logger:   o.Logger,
+		writer:   o.Writer,
+		all:      o.All,
+		now:      o.Now,
+		selected: make(map[device.ID]bool, len(o.IDs)),
+		queue:    make(chan Record, o.QueueSize),
+		done:     make(chan struct{}),
+	}
+
+	if r.logger == nil {
+		r.logger = sallust.Default()
+	}
+
+	if r.now == nil {
+		r.now = time.Now
+	}
+
+	for _, id := range o.IDs {
+		r.selected[id] = true
+	}
+
+	go r.run()
+	return r
+}
+
+func (r *Recorder) run() {
+	defer close(r.done)
+	for record := range r.queue {
+		if err := r.writer.Write(record); err != nil {
+			r.logger.Error("unable to write capture record", zap.String("id", string(record.ID)), zap.Error(err))
+			continue
+		}
+
+		atomic.AddInt64(&r.written, 1)
+	}
+
+	if err := r.writer.Flush(); err != nil {
+		r.logger.Error("unable to flush capture", zap.Error(err))
+	}
+}
+
+// Select adds devices to the set being recorded
+func (r *Recorder) Select(ids ...device.ID) {
+	r.lock.Lock()
+	for _, id := range ids {
+		r.selected[id] = true
+	}
+
+	r.lock.Unlock()
+}
+
+// Deselect removes devices from the set being recorded
+func (r *Recorder) Deselect(ids ...device.ID) {
+	r.lock.Lock()
+	for _, id := range ids {
+		delete(r.selected, id)
+	}
+
+	r.lock.Unlock()
+}
+
+// Selected tests if frames for the given device are being recorded
+func (r *Recorder) Selected(id device.ID) bool {
+	if r.all {
+		return true
+	}
+
+	r.lock.RLock()
+	selected := r.selected[id]
+	r.lock.RUnlock()
+	return selected
+}
+
+// Record enqueues a copy of the frame if the device is selected.  This method never blocks.
+func (r *Recorder) Record(id device.ID, direction device.TraceDirection, frame []byte) {
+	if !r.Selected(id) {
+		return
+	}
+
+	record := Record{
+		Timestamp: r.now().UTC(),
+		Direction: direction,
+		ID:        id,
+		Frame:     append([]byte(nil), frame...),
+	}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if r.closed {
+		return
+	}
+
+	select {
+	case r.queue <- record:
+	default:
+		atomic.AddInt64(&r.dropped, 1)
+	}
+}
+
+// Written returns the number of records successfully written so far
+func (r *Recorder) Written() int {
+	return int(atomic.LoadInt64(&r.written))
+}
+
+// Dropped returns the number of frames discarded because the queue was full
+func (r *Recorder) Dropped() int {
+	return int(atomic.LoadInt64(&r.dropped))
+}
+
+// Close stops recording, waits for all queued records to be written, and flushes the Writer.
+// The underlying stream is not closed.
+func (r *Recorder) Close() error {
+	r.lock.Lock()
+	if r.closed {
+		r.lock.Unlock()
+		return ErrRecorderClosed
+	}
+
+	r.closed = true
+	close(r.queue)
+	r.lock.Unlock()
+
+	<-r.done
+	return nil
+}
@@ -0,0 +1,135 @@
+package devicecapture
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/webpa-common/v2/device"
+	"go.uber.org/zap"
+)
+
+func readAll(t *testing.T, source io.Reader) []Record {
+	r, err := NewReader(source)
+	require.NoError(t, err)
+
+	var records []Record
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return records
+		}
+
+		require.NoError(t, err)
+		records = append(records, record)
+	}
+}
+
+func testRecorderSelection(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		buffer  bytes.Buffer
+		now     = time.Now().UTC()
+	)
+
+	w, err := NewWriter(&buffer)
+	require.NoError(err)
+
+	r := NewRecorder(RecorderOptions{
+		Writer: w,
+		Logger: zap.NewNop(),
+		IDs:    []device.ID{"mac:112233445566"},
+		Now:    func() time.Time { return now },
+	})
+
+	frame := []byte("frame")
+	r.Record("mac:112233445566", device.TraceInbound, frame)
+	frame[0] = 'F'
+	r.Record("mac:000000000001", device.TraceInbound, []byte("ignored"))
+
+	assert.False(r.Selected("mac:000000000001"))
+	r.Select("mac:000000000001")
+	assert.True(r.Selected("mac:000000000001"))
+	r.Record("mac:000000000001", device.TraceOutbound, []byte("selected"))
+
+	r.Deselect("mac:112233445566")
+	r.Record("mac:112233445566", device.TraceInbound, []byte("deselected"))
+
+	require.NoError(r.Close())
+	assert.Equal(ErrRecorderClosed, r.Close())
+	r.Record("mac:000000000001", device.TraceOutbound, []byte("closed"))
+
+	assert.Equal(2, r.Written())
+	assert.Zero(r.Dropped())
+
+	records := readAll(t, &buffer)
+	require.Len(records, 2)
+	assert.Equal(device.ID("mac:112233445566"), records[0].ID)
+	assert.Equal([]byte("frame"), records[0].Frame)
+	assert.True(now.Equal(records[0].Timestamp))
+	assert.Equal(device.ID("mac:000000000001"), records[1].ID)
+	assert.Equal(device.TraceOutbound, records[1].Direction)
+}
+
+type blockingWriter struct {
+	unblock chan struct{}
+}
+
+func (bw *blockingWriter) Write(p []byte) (int, error) {
+	<-bw.unblock
+	return len(p), nil
+}
+
+func testRecorderDrops(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		require     = require.New(t)
+		destination = &blockingWriter{unblock: make(chan struct{})}
+	)
+
+	// the header must be written before blocking
+	close(destination.unblock)
+	w, err := NewWriter(destination)
+	require.NoError(err)
+	destination.unblock = make(chan struct{})
+
+	r := NewRecorder(RecorderOptions{Writer: w, Logger: zap.NewNop(), All: true, QueueSize: 1})
+	for i := 0; i < 10; i++ {
+		r.Record("mac:112233445566", device.TraceInbound, bytes.Repeat([]byte{'x'}, 8192))
+	}
+
+	assert.True(r.Dropped() > 0)
+	close(destination.unblock)
+	require.NoError(r.Close())
+	assert.Equal(10, r.Dropped()+r.Written())
+}
+
+func testRecorderQueueSize(t *testing.T) {
+	for _, queueSize := range []int{-1, 0} {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+			buffer  bytes.Buffer
+		)
+
+		w, err := NewWriter(&buffer)
+		require.NoError(err)
+
+		r := NewRecorder(RecorderOptions{Writer: w, Logger: zap.NewNop(), QueueSize: queueSize})
+		assert.Equal(DefaultQueueSize, cap(r.queue))
+		require.NoError(r.Close())
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	t.Run("Selection", testRecorderSelection)
+	t.Run("Drops", testRecorderDrops)
+	t.Run("QueueSize", testRecorderQueueSize)
+	t.Run("MissingWriter", func(t *testing.T) {
+		assert.Panics(t, func() { NewRecorder(RecorderOptions{}) })
+	})
+}
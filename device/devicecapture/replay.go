@@ -0,0 +1,248 @@
+package devicecapture
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/xmidt-org/sallust"
+	"github.com/xmidt-org/webpa-common/v2/device"
+	"go.uber.org/zap"
+)
+
+var ErrMissingURL = errors.New("a replay URL is required")
+
+// ReplayOptions configures the replay of a capture against a server
+type ReplayOptions struct {
+	// URL is the websocket URL to which each captured device connects.  This field is required.
+	URL string
+
+	// Dialer is the device Dialer used to connect each captured device.  If unset,
+	// device.DefaultDialer() is used.
+	Dialer device.Dialer
+
+	// Header holds extra HTTP headers sent when each device connects, e.g. a convey header
+	Header http.Header
+
+	// Speed scales the delays between captured frames.  A value of 2 replays twice as fast as the
+	// original traffic.  If unset, frames are replayed in real time.
+	Speed float64
+
+	// NoDelay replays frames as fast as possible, ignoring capture timestamps
+	NoDelay bool
+
+	// Linger is how long connections are held open after the last frame is sent, so that
+	// responses and any resulting server behavior can be observed
+	Linger time.Duration
+
+	// Logger is the sink for replay output.  If unset, sallust.Default() is used.
+	Logger *zap.Logger
+
+	// After is the closure used to wait between frames.  If unset, time.After is used.
+	After func(time.Duration) <-chan time.Time
+}
+
+// ReplayResult summarizes a replay
+type ReplayResult struct {
+	// Devices is the number of device connections successfully established
+	Devices int
+
+	// Sent is the number of inbound frames written to the server
+	Sent int
+
+	// Skipped is the number of captured outbound frames, which are not replayed
+	Skipped int
+
+	// Received is the number of frames the server sent to replayed devices
+	Received int
+
+	// Errors is the number of failed dials and writes.  Frames for a device that could
+	// not be dialed, or whose connection failed, count as errors.
+	Errors int
+}
+
+type replayConnection struct {
+	conn   *websocket.Conn
+	failed bool
+}
+
+// replayer holds the state of a single Replay invocation
+type replayer struct {
+	o           ReplayOptions
+	logger      *zap.Logger
+	result      ReplayResult
+	connections map[device.ID]*replayConnection
+	readers     sync.WaitGroup
+	received    int64
+}
+
+// Replay connects each device found in a capture and writes that device's inbound frames, that is
+// the frames originally sent by the device, to the server in the order and with the spacing in
+// which they were captured.  Outbound frames are skipped, since the server produces its own.
+//
+// Replay returns when the capture is exhausted and the Linger period has passed, or when the
+// context is canceled.  Every connection opened during the replay is closed before returning.
+func Replay(ctx context.Context, r *Reader, o ReplayOptions) (ReplayResult, error) {
+	if len(o.URL) == 0 {
+		return ReplayResult{}, ErrMissingURL
+	}
+
+	if o.Dialer == nil {
+		o.Dialer = device.DefaultDialer()
+	}
+
+	if o.Speed <= 0 {
+		o.Speed = 1.0
+	}
+
+	if o.After == nil {
+		o.After = time.After
+	}
+
+	rp := &replayer{
+		o:           o,
+		logger:      o.Logger,
+		connections: make(map[device.ID]*replayConnection),
+	}
+
+	if rp.logger == nil {
+		rp.logger = sallust.Default()
+	}
+
+	err := rp.run(ctx, r)
+	if err == nil && o.Linger > 0 {
+		err = rp.wait(ctx, o.Linger)
+	}
+
+	return rp.finish(), err
+}
+
+func (rp *replayer) wait(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-rp.o.After(d):
+		return nil
+	}
+}
+
+func (rp *replayer) run(ctx context.Context, r *Reader) error {
+	var (
+		first    time.Time
+		replayed time.Duration
+	)
+
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if record.Direction != device.TraceInbound {
+			rp.result.Skipped++
+			continue
+		}
+
+		if first.IsZero() {
+			first = record.Timestamp
+		} else if !rp.o.NoDelay {
+			offset := time.Duration(float64(record.Timestamp.Sub(first)) / rp.o.Speed)
+			if err := rp.wait(ctx, offset-replayed); err != nil {
+				return err
+			}
+
+			if offset > replayed {
+				replayed = offset
+			}
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rp.send(record)
+	}
+}
+
+// connection returns the connection for the given device, dialing it if necessary
+func (rp *replayer) connection(id device.ID) *replayConnection {
+	if rc, ok := rp.connections[id]; ok {
+		return rc
+	}
+
+	rc := new(replayConnection)
+	rp.connections[id] = rc
+
+	conn, response, err := rp.o.Dialer.DialDevice(string(id), rp.o.URL, rp.o.Header)
+	if response != nil && response.Body != nil {
+		response.Body.Close()
+	}
+
+	if err != nil {
+		rp.logger.Error("unable to dial device", zap.String("id", string(id)), zap.Error(err))
+		rc.failed = true
+		return rc
+	}
+
+	rp.result.Devices++
+	rc.conn = conn
+	rp.readers.Add(1)
+	go rp.read(conn)
+	return rc
+}
+
+// read consumes frames sent by the server, which also ensures pings are answered
+func (rp *replayer) read(conn *websocket.Conn) {
+	defer rp.readers.Done()
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+
+		atomic.AddInt64(&rp.received, 1)
+	}
+}
+
+func (rp *replayer) send(record Record) {
+	rc := rp.connection(record.ID)
+	if rc.failed {
+		rp.result.Errors++
+		return
+	}
+
+	if err := rc.conn.WriteMessage(websocket.BinaryMessage, record.Frame); err != nil {
+		rp.logger.Error("unable to replay frame", zap.String("id", string(record.ID)), zap.Error(err))
+		rp.result.Errors++
+		rc.failed = true
+		return
+	}
+
+	rp.result.Sent++
+}
+
+func (rp *replayer) close() {
+	for _, rc := range rp.connections {
+		if rc.conn != nil {
+			rc.conn.Close()
+		}
+	}
+
+	rp.readers.Wait()
+}
+
+func (rp *replayer) finish() ReplayResult {
+	rp.close()
+	rp.result.Received = int(atomic.LoadInt64(&rp.received))
+	return rp.result
+}
@@ -0,0 +1,227 @@
+package devicecapture
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/justinas/alice"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/webpa-common/v2/device"
+	"github.com/xmidt-org/wrp-go/v3"
+	"go.uber.org/zap"
+)
+
+// startServer creates a manager behind an httptest server, returning the websocket URL
+func startServer(o *device.Options) (device.Manager, *httptest.Server, string) {
+	var (
+		manager = device.NewManager(o)
+		server  = httptest.NewServer(
+			alice.New(device.UseID.FromHeader).Then(
+				&device.ConnectHandler{
+					Logger:    zap.NewNop(),
+					Connector: manager,
+				},
+			),
+		)
+	)
+
+	return manager, server, "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func encodeMessage(t *testing.T, m *wrp.Message) []byte {
+	var frame []byte
+	// nolint: typecheck
+	require.NoError(t, wrp.NewEncoderBytes(&frame, wrp.Msgpack).Encode(m))
+	return frame
+}
+
+// messageCollector is a device.Listener that gathers the sources of received messages
+type messageCollector struct {
+	lock     sync.Mutex
+	received sync.WaitGroup
+	sources  []string
+}
+
+func (mc *messageCollector) listen(e *device.Event) {
+	if e.Type == device.MessageReceived {
+		mc.lock.Lock()
+		mc.sources = append(mc.sources, e.Message.(*wrp.Message).Source)
+		mc.lock.Unlock()
+		mc.received.Done()
+	}
+}
+
+func testReplayRoundTrip(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		ids     = []device.ID{"mac:112233445566", "mac:665544332211"}
+		capture bytes.Buffer
+	)
+
+	// first, capture live traffic from a manager
+	w, err := NewWriter(&capture)
+	require.NoError(err)
+
+	var (
+		recorder = NewRecorder(RecorderOptions{Writer: w, Logger: zap.NewNop(), All: true})
+		original = new(messageCollector)
+
+		_, server, url = startServer(&device.Options{
+			Logger:    zap.NewNop(),
+			Recorders: []device.FrameRecorder{recorder},
+			Listeners: []device.Listener{original.listen},
+		})
+	)
+
+	original.received.Add(2 * len(ids))
+	for _, id := range ids {
+		conn, _ := device.MustDialDevice(device.DefaultDialer(), string(id), url, nil)
+		for i := 0; i < 2; i++ {
+			// nolint: typecheck
+			require.NoError(conn.WriteMessage(websocket.BinaryMessage, encodeMessage(t, &wrp.Message{
+				Type:        wrp.SimpleEventMessageType,
+				Source:      string(id),
+				Destination: "event:test",
+			})))
+		}
+
+		defer conn.Close()
+	}
+
+	original.received.Wait()
+	server.Close()
+	require.NoError(recorder.Close())
+	assert.Equal(2*len(ids), recorder.Written())
+
+	// now, replay that capture against a fresh manager
+	replayed := new(messageCollector)
+	replayed.received.Add(2 * len(ids))
+	_, replayServer, replayURL := startServer(&device.Options{
+		Logger:    zap.NewNop(),
+		Listeners: []device.Listener{replayed.listen},
+	})
+
+	defer replayServer.Close()
+	reader, err := NewReader(&capture)
+	require.NoError(err)
+
+	var (
+		waits  []time.Duration
+		result ReplayResult
+	)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		result, err = Replay(context.Background(), reader, ReplayOptions{
+			URL:    replayURL,
+			Logger: zap.NewNop(),
+			Speed:  2,
+			Linger: time.Minute,
+			After: func(d time.Duration) <-chan time.Time {
+				waits = append(waits, d)
+				if d == time.Minute {
+					// hold the connections open until the server has seen every message
+					replayed.received.Wait()
+				}
+
+				c := make(chan time.Time, 1)
+				c <- time.Now()
+				return c
+			},
+		})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		require.Fail("replay did not complete within the timeout")
+	}
+
+	require.NoError(err)
+	assert.Equal(len(ids), result.Devices)
+	assert.Equal(2*len(ids), result.Sent)
+	assert.Zero(result.Errors)
+	assert.Zero(result.Skipped)
+	assert.NotEmpty(waits)
+	assert.Equal(time.Minute, waits[len(waits)-1])
+	assert.ElementsMatch(original.sources, replayed.sources)
+}
+
+func testReplaySkipsOutboundAndDialFailures(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		capture bytes.Buffer
+		now     = time.Now()
+	)
+
+	w, err := NewWriter(&capture)
+	require.NoError(err)
+	require.NoError(w.Write(Record{Timestamp: now, Direction: device.TraceOutbound, ID: "mac:112233445566", Frame: []byte("x")}))
+	require.NoError(w.Write(Record{Timestamp: now, Direction: device.TraceInbound, ID: "mac:112233445566", Frame: []byte("x")}))
+	require.NoError(w.Write(Record{Timestamp: now, Direction: device.TraceInbound, ID: "mac:112233445566", Frame: []byte("x")}))
+	require.NoError(w.Flush())
+
+	reader, err := NewReader(&capture)
+	require.NoError(err)
+
+	result, err := Replay(context.Background(), reader, ReplayOptions{
+		URL:     "ws://127.0.0.1:1/nosuch",
+		Logger:  zap.NewNop(),
+		NoDelay: true,
+	})
+
+	assert.NoError(err)
+	assert.Equal(ReplayResult{Skipped: 1, Errors: 2}, result)
+}
+
+func testReplayCanceled(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		capture bytes.Buffer
+		now     = time.Now()
+
+		ctx, cancel = context.WithCancel(context.Background())
+	)
+
+	w, err := NewWriter(&capture)
+	require.NoError(err)
+	require.NoError(w.Write(Record{Timestamp: now, Direction: device.TraceInbound, ID: "mac:112233445566", Frame: []byte("x")}))
+	require.NoError(w.Write(Record{Timestamp: now.Add(time.Hour), Direction: device.TraceInbound, ID: "mac:112233445566", Frame: []byte("x")}))
+	require.NoError(w.Flush())
+
+	reader, err := NewReader(&capture)
+	require.NoError(err)
+
+	_, err = Replay(ctx, reader, ReplayOptions{
+		URL:    "ws://127.0.0.1:1/nosuch",
+		Logger: zap.NewNop(),
+		After: func(time.Duration) <-chan time.Time {
+			cancel()
+			return nil
+		},
+	})
+
+	assert.Equal(context.Canceled, err)
+}
+
+func TestReplay(t *testing.T) {
+	t.Run("MissingURL", func(t *testing.T) {
+		_, err := Replay(context.Background(), nil, ReplayOptions{})
+		assert.Equal(t, ErrMissingURL, err)
+	})
+
+	t.Run("RoundTrip", testReplayRoundTrip)
+	t.Run("SkipsOutboundAndDialFailures", testReplaySkipsOutboundAndDialFailures)
+	t.Run("Canceled", testReplayCanceled)
+}
@@ -0,0 +1,134 @@
+package devicegate
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/xmidt-org/bascule"
+)
+
+// DefaultAuditSize is the number of entries an AuditLog keeps when no size is supplied
+const DefaultAuditSize = 1000
+
+const (
+	AuditSet    = "set"
+	AuditDelete = "delete"
+)
+
+// AuditEntry records a single change to a gate's filters
+type AuditEntry struct {
+	// Time is when the change was made
+	Time time.Time `json:"time"`
+
+	// Principal identifies who made the change
+	Principal string `json:"principal"`
+
+	// Action is either AuditSet or AuditDelete
+	Action string `json:"action"`
+
+	// Key is the filter key that was changed
+	Key string `json:"key"`
+
+	// Old holds the filter values, or the text of the filter expression, before the change
+	Old interface{} `json:"old,omitempty"`
+
+	// New holds the filter values, or the text of the filter expression, after the change
+	New interface{} `json:"new,omitempty"`
+
+	// Mode is the filter's mode after the change
+	Mode FilterMode `json:"mode,omitempty"`
+
+	// Expires is the filter's expiry after the change, if any
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// AuditLog is a bounded, in-memory record of the most recent filter changes.  Once full, each new entry
+// replaces the oldest one.  It is safe for concurrent use.
+type AuditLog struct {
+	lock    sync.Mutex
+	entries []AuditEntry
+	next    int
+	size    int
+}
+
+// NewAuditLog creates an AuditLog which keeps at most size entries.  If size is nonpositive,
+// DefaultAuditSize is used.
+func NewAuditLog(size int) *AuditLog {
+	if size < 1 {
+		size = DefaultAuditSize
+	}
+
+	return &AuditLog{
+		entries: make([]AuditEntry, 0, size),
+		size:    size,
+	}
+}
+
+// Record adds an entry to this log
+func (al *AuditLog) Record(e AuditEntry) {
+	al.lock.Lock()
+	defer al.lock.Unlock()
+
+	if len(al.entries) < al.size {
+		al.entries = append(al.entries, e)
+		return
+	}
+
+	al.entries[al.next] = e
+	al.next = (al.next + 1) % al.size
+}
+
+// Entries returns a copy of the entries in this log, oldest first
+func (al *AuditLog) Entries() []AuditEntry {
+	al.lock.Lock()
+	defer al.lock.Unlock()
+
+	entries := make([]AuditEntry, 0, len(al.entries))
+	entries = append(entries, al.entries[al.next:]...)
+	return append(entries, al.entries[:al.next]...)
+}
+
+// auditValue converts a filter's values or expression into the form kept in an AuditEntry
+func auditValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case Expression:
+		return t.String()
+	case []interface{}:
+		if len(t) == 0 {
+			return nil
+		}
+	}
+
+	return v
+}
+
+// currentFilter returns the values or expression currently held by a filter key, for auditing
+func currentFilter(gate Interface, key string) interface{} {
+	if eg, ok := gate.(ExpressionGate); ok {
+		if e, ok := eg.GetExpression(key); ok {
+			return e
+		}
+	}
+
+	if s, ok := gate.GetFilter(key); ok {
+		return s
+	}
+
+	return nil
+}
+
+// DefaultPrincipal identifies who made a filter change as the principal of the bascule token that
+// authenticated the request, if any.  Otherwise, the basic auth user or, failing that, the request's
+// remote address is used.
+func DefaultPrincipal(request *http.Request) string {
+	if auth, ok := bascule.FromContext(request.Context()); ok && auth.Token != nil && len(auth.Token.Principal()) > 0 {
+		return auth.Token.Principal()
+	}
+
+	if user, _, ok := request.BasicAuth(); ok && len(user) > 0 {
+		return user
+	}
+
+	return request.RemoteAddr
+}
@@ -0,0 +1,126 @@
+package devicegate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/bascule"
+	"github.com/xmidt-org/sallust"
+)
+
+func testAuditLogWrap(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		al     = NewAuditLog(3)
+	)
+
+	assert.Empty(al.Entries())
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		al.Record(AuditEntry{Key: key})
+	}
+
+	var keys []string
+	for _, e := range al.Entries() {
+		keys = append(keys, e.Key)
+	}
+
+	assert.Equal([]string{"c", "d", "e"}, keys)
+	assert.Equal(DefaultAuditSize, NewAuditLog(0).size)
+}
+
+func testAuditLogPrincipal(t *testing.T) {
+	assert := assert.New(t)
+
+	request := httptest.NewRequest("POST", "/", nil)
+	request.RemoteAddr = "10.0.0.1:1234"
+	assert.Equal("10.0.0.1:1234", DefaultPrincipal(request))
+
+	request.SetBasicAuth("operator", "secret")
+	assert.Equal("operator", DefaultPrincipal(request))
+
+	anonymous := bascule.Authentication{Token: bascule.NewToken("jwt", "", bascule.NewAttributes(nil))}
+	assert.Equal("operator", DefaultPrincipal(request.WithContext(bascule.WithAuthentication(request.Context(), anonymous))))
+
+	authenticated := bascule.Authentication{Token: bascule.NewToken("jwt", "client-id", bascule.NewAttributes(nil))}
+	assert.Equal("client-id", DefaultPrincipal(request.WithContext(bascule.WithAuthentication(request.Context(), authenticated))))
+}
+
+func testAuditLogFilterHandler(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		ctx     = sallust.With(context.Background(), sallust.Default())
+
+		f = FilterHandler{
+			Gate:      &FilterGate{FilterStore: make(FilterStore)},
+			Audit:     NewAuditLog(0),
+			Principal: func(*http.Request) string { return "operator" },
+		}
+	)
+
+	for _, body := range []string{
+		`{"key": "partner-id", "values": ["comcast"]}`,
+		`{"key": "partner-id", "values": ["sky"], "mode": "allow", "ttl": "1h"}`,
+		`{"key": "partner-id", "expression": "partner-id == comcast"}`,
+	} {
+		response := httptest.NewRecorder()
+		f.UpdateFilters(response, httptest.NewRequest("POST", "/", bytes.NewBufferString(body)).WithContext(ctx))
+		require.Contains([]int{http.StatusCreated, http.StatusOK}, response.Code)
+	}
+
+	response := httptest.NewRecorder()
+	f.DeleteFilter(response, httptest.NewRequest("DELETE", "/", bytes.NewBufferString(`{"key": "partner-id"}`)).WithContext(ctx))
+	require.Equal(http.StatusOK, response.Code)
+
+	response = httptest.NewRecorder()
+	f.GetAudit(response, httptest.NewRequest("GET", "/", nil))
+	require.Equal(http.StatusOK, response.Code)
+
+	var entries []struct {
+		Principal string      `json:"principal"`
+		Action    string      `json:"action"`
+		Key       string      `json:"key"`
+		Old       interface{} `json:"old"`
+		New       interface{} `json:"new"`
+		Mode      FilterMode  `json:"mode"`
+		Expires   *string     `json:"expires"`
+	}
+
+	require.NoError(json.Unmarshal(response.Body.Bytes(), &entries))
+	require.Len(entries, 4)
+
+	assert.Equal("operator", entries[0].Principal)
+	assert.Equal(AuditSet, entries[0].Action)
+	assert.Equal("partner-id", entries[0].Key)
+	assert.Nil(entries[0].Old)
+	assert.Equal([]interface{}{"comcast"}, entries[0].New)
+
+	assert.Equal([]interface{}{"comcast"}, entries[1].Old)
+	assert.Equal([]interface{}{"sky"}, entries[1].New)
+	assert.Equal(AllowMode, entries[1].Mode)
+	assert.NotNil(entries[1].Expires)
+
+	assert.Equal([]interface{}{"sky"}, entries[2].Old, "an expression replaces the values of its key")
+	assert.Equal(`partner-id == "comcast"`, entries[2].New)
+
+	assert.Equal(AuditDelete, entries[3].Action)
+	assert.Equal(`partner-id == "comcast"`, entries[3].Old)
+	assert.Nil(entries[3].New)
+
+	// without an audit log, the audit trail is empty rather than missing
+	response = httptest.NewRecorder()
+	(&FilterHandler{Gate: f.Gate}).GetAudit(response, httptest.NewRequest("GET", "/", nil))
+	assert.JSONEq(`[]`, response.Body.String())
+}
+
+func TestAuditLog(t *testing.T) {
+	t.Run("Wrap", testAuditLogWrap)
+	t.Run("Principal", testAuditLogPrincipal)
+	t.Run("FilterHandler", testAuditLogFilterHandler)
+}
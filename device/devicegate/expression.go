@@ -0,0 +1,524 @@
+package devicegate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cast"
+	"github.com/xmidt-org/webpa-common/v2/device"
+)
+
+// Field prefixes that select where an expression looks up a value.  A field without one of these
+// prefixes is looked up in the metadata map and then in the claims, just like a set filter.
+const (
+	ConveyPrefix   = "convey."
+	ClaimsPrefix   = "claims."
+	MetadataPrefix = "metadata."
+)
+
+// Operator is a comparison operator used in a filter expression
+type Operator string
+
+const (
+	OpEqual        Operator = "=="
+	OpNotEqual     Operator = "!="
+	OpMatches      Operator = "=~"
+	OpLess         Operator = "<"
+	OpLessEqual    Operator = "<="
+	OpGreater      Operator = ">"
+	OpGreaterEqual Operator = ">="
+	OpIn           Operator = "in"
+	OpExists       Operator = "exists"
+)
+
+var (
+	ErrEmptyExpression  = errors.New("empty filter expression")
+	ErrInvalidOperator  = errors.New("invalid filter expression operator")
+	ErrMissingField     = errors.New("filter expression comparisons require a field")
+	ErrInvalidOperand   = errors.New("invalid filter expression operand")
+	ErrInvalidJSONShape = errors.New("a filter expression must have exactly one of and, or, not or field")
+)
+
+// Expression is a boolean filter expression evaluated against a device.  Expressions can be
+// built programmatically, parsed from text with ParseExpression, or unmarshaled from their JSON
+// representation with UnmarshalExpression.
+type Expression interface {
+	fmt.Stringer
+	json.Marshaler
+
+	// Match tests if the given device satisfies this expression
+	Match(device.Interface) bool
+}
+
+// And is an Expression that matches when all of its operands match
+type And []Expression
+
+func (a And) Match(d device.Interface) bool {
+	for _, e := range a {
+		if !e.Match(d) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (a And) String() string {
+	return joinExpressions(a, " && ")
+}
+
+func (a And) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string][]Expression{"and": a})
+}
+
+// Or is an Expression that matches when any of its operands match
+type Or []Expression
+
+func (o Or) Match(d device.Interface) bool {
+	for _, e := range o {
+		if e.Match(d) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (o Or) String() string {
+	return joinExpressions(o, " || ")
+}
+
+func (o Or) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string][]Expression{"or": o})
+}
+
+// Not is an Expression that negates another Expression
+type Not struct {
+	Expression Expression
+}
+
+func (n Not) Match(d device.Interface) bool {
+	return !n.Expression.Match(d)
+}
+
+func (n Not) String() string {
+	return "!(" + n.Expression.String() + ")"
+}
+
+func (n Not) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]Expression{"not": n.Expression})
+}
+
+func joinExpressions(operands []Expression, separator string) string {
+	parts := make([]string, len(operands))
+	for i, e := range operands {
+		parts[i] = e.String()
+	}
+
+	return "(" + strings.Join(parts, separator) + ")"
+}
+
+// Comparison is an Expression that compares a single field of a device against a value.
+// Comparisons against a field the device does not have never match, so OpExists should be
+// used to test for presence.  If the field holds a list, the comparison matches if any element
+// of the list matches.
+type Comparison struct {
+	Field string
+	Op    Operator
+	Value interface{}
+
+	re *regexp.Regexp
+}
+
+// NewComparison validates and creates a Comparison.  The value must be a string, float64 or bool,
+// except that OpIn requires a []interface{} of those types, OpMatches requires a string holding a
+// regular expression, and OpExists ignores the value.
+func NewComparison(field string, op Operator, value interface{}) (*Comparison, error) {
+	if len(field) == 0 {
+		return nil, ErrMissingField
+	}
+
+	c := &Comparison{Field: field, Op: op, Value: value}
+	switch op {
+	case OpEqual, OpNotEqual:
+		if !isLiteral(value) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidOperand, value)
+		}
+
+	case OpLess, OpLessEqual, OpGreater, OpGreaterEqual:
+		if _, ok := value.(float64); !ok {
+			return nil, fmt.Errorf("%w: %s requires a number", ErrInvalidOperand, op)
+		}
+
+	case OpMatches:
+		pattern, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s requires a string", ErrInvalidOperand, op)
+		}
+
+		var err error
+		if c.re, err = regexp.Compile(pattern); err != nil {
+			return nil, err
+		}
+
+	case OpIn:
+		values, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: %s requires a list", ErrInvalidOperand, op)
+		}
+
+		for _, v := range values {
+			if !isLiteral(v) {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidOperand, v)
+			}
+		}
+
+	case OpExists:
+		c.Value = nil
+
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidOperator, op)
+	}
+
+	return c, nil
+}
+
+// newComparisonExpression wraps NewComparison so that errors never produce a non-nil Expression
+func newComparisonExpression(field string, op Operator, value interface{}) (Expression, error) {
+	c, err := NewComparison(field, op, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func isLiteral(v interface{}) bool {
+	switch v.(type) {
+	case string, float64, bool:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *Comparison) Match(d device.Interface) bool {
+	actual, found := lookupField(d, c.Field)
+	if c.Op == OpExists || !found {
+		return found
+	}
+
+	if c.Op == OpNotEqual {
+		return !anyValue(actual, func(v interface{}) bool { return equalValue(v, c.Value) })
+	}
+
+	return anyValue(actual, c.matchValue)
+}
+
+func (c *Comparison) matchValue(v interface{}) bool {
+	switch c.Op {
+	case OpEqual:
+		return equalValue(v, c.Value)
+
+	case OpMatches:
+		s, ok := v.(string)
+		return ok && c.re.MatchString(s)
+
+	case OpIn:
+		for _, candidate := range c.Value.([]interface{}) {
+			if equalValue(v, candidate) {
+				return true
+			}
+		}
+
+		return false
+
+	default:
+		actual, ok := toNumber(v)
+		if !ok {
+			return false
+		}
+
+		expected := c.Value.(float64)
+		switch c.Op {
+		case OpLess:
+			return actual < expected
+		case OpLessEqual:
+			return actual <= expected
+		case OpGreater:
+			return actual > expected
+		default:
+			return actual >= expected
+		}
+	}
+}
+
+func (c *Comparison) String() string {
+	switch c.Op {
+	case OpExists:
+		return "exists(" + c.Field + ")"
+
+	case OpIn:
+		values := c.Value.([]interface{})
+		parts := make([]string, len(values))
+		for i, v := range values {
+			parts[i] = formatLiteral(v)
+		}
+
+		return c.Field + " in (" + strings.Join(parts, ", ") + ")"
+
+	default:
+		return c.Field + " " + string(c.Op) + " " + formatLiteral(c.Value)
+	}
+}
+
+func (c *Comparison) MarshalJSON() ([]byte, error) {
+	m := map[string]interface{}{
+		"field": c.Field,
+		"op":    c.Op,
+	}
+
+	if c.Op != OpExists {
+		m["value"] = c.Value
+	}
+
+	return json.Marshal(m)
+}
+
+func formatLiteral(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return strconv.Quote(t)
+	case float64:
+		return strconv.FormatFloat(t, 'g', -1, 64)
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
+// lookupField returns the value of a device field, honoring the location prefixes
+func lookupField(d device.Interface, field string) (interface{}, bool) {
+	if strings.HasPrefix(field, ConveyPrefix) {
+		c := d.Convey()
+		if c == nil {
+			return nil, false
+		}
+
+		return c.Get(strings.TrimPrefix(field, ConveyPrefix))
+	}
+
+	m := d.Metadata()
+	if m == nil {
+		return nil, false
+	}
+
+	switch {
+	case strings.HasPrefix(field, ClaimsPrefix):
+		v, ok := m.Claims()[strings.TrimPrefix(field, ClaimsPrefix)]
+		return v, ok
+
+	case strings.HasPrefix(field, MetadataPrefix):
+		v := m.Load(strings.TrimPrefix(field, MetadataPrefix))
+		return v, v != nil
+
+	default:
+		if v := m.Load(field); v != nil {
+			return v, true
+		}
+
+		v, ok := m.Claims()[field]
+		return v, ok
+	}
+}
+
+// anyValue applies a predicate to a field value, or to each element if the value is a list
+func anyValue(actual interface{}, predicate func(interface{}) bool) bool {
+	switch t := actual.(type) {
+	case []interface{}:
+		for _, v := range t {
+			if predicate(v) {
+				return true
+			}
+		}
+
+		return false
+
+	case []string:
+		for _, v := range t {
+			if predicate(v) {
+				return true
+			}
+		}
+
+		return false
+
+	default:
+		return predicate(actual)
+	}
+}
+
+func toNumber(v interface{}) (float64, bool) {
+	switch t := v.(type) {
+	case bool, nil:
+		return 0, false
+
+	case string:
+		f, err := strconv.ParseFloat(t, 64)
+		return f, err == nil
+
+	default:
+		f, err := cast.ToFloat64E(t)
+		return f, err == nil
+	}
+}
+
+// equalValue compares a device field value to a literal, converting the field value to the literal's type
+func equalValue(actual, expected interface{}) bool {
+	switch e := expected.(type) {
+	case float64:
+		f, ok := toNumber(actual)
+		return ok && f == e
+
+	case bool:
+		b, err := cast.ToBoolE(actual)
+		return err == nil && b == e
+
+	case string:
+		if s, ok := actual.(string); ok {
+			return s == e
+		}
+
+		return fmt.Sprint(actual) == e
+
+	default:
+		return false
+	}
+}
+
+// expressionFields returns the sorted, distinct fields referenced by an expression
+func expressionFields(e Expression) []string {
+	fields := make(map[string]bool)
+	var visit func(Expression)
+	visit = func(e Expression) {
+		switch t := e.(type) {
+		case And:
+			for _, operand := range t {
+				visit(operand)
+			}
+
+		case Or:
+			for _, operand := range t {
+				visit(operand)
+			}
+
+		case Not:
+			visit(t.Expression)
+
+		case *Comparison:
+			fields[t.Field] = true
+		}
+	}
+
+	visit(e)
+	result := make([]string, 0, len(fields))
+	for f := range fields {
+		result = append(result, f)
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+// expressionJSON is the JSON representation of any Expression node
+type expressionJSON struct {
+	And   []json.RawMessage `json:"and"`
+	Or    []json.RawMessage `json:"or"`
+	Not   json.RawMessage   `json:"not"`
+	Field string            `json:"field"`
+	Op    Operator          `json:"op"`
+	Value interface{}       `json:"value"`
+}
+
+// UnmarshalExpression parses the JSON representation of an Expression, as produced by
+// marshaling an Expression.
+func UnmarshalExpression(data []byte) (Expression, error) {
+	var (
+		raw    expressionJSON
+		shapes int
+	)
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	for _, present := range []bool{raw.And != nil, raw.Or != nil, raw.Not != nil, len(raw.Field) > 0} {
+		if present {
+			shapes++
+		}
+	}
+
+	if shapes != 1 {
+		return nil, ErrInvalidJSONShape
+	}
+
+	switch {
+	case raw.And != nil:
+		operands, err := unmarshalOperands(raw.And)
+		if err != nil {
+			return nil, err
+		}
+
+		return And(operands), nil
+
+	case raw.Or != nil:
+		operands, err := unmarshalOperands(raw.Or)
+		if err != nil {
+			return nil, err
+		}
+
+		return Or(operands), nil
+
+	case raw.Not != nil:
+		operand, err := UnmarshalExpression(raw.Not)
+		if err != nil {
+			return nil, err
+		}
+
+		return Not{Expression: operand}, nil
+
+	default:
+		return newComparisonExpression(raw.Field, raw.Op, raw.Value)
+	}
+}
+
+func unmarshalOperands(raw []json.RawMessage) ([]Expression, error) {
+	if len(raw) == 0 {
+		return nil, ErrEmptyExpression
+	}
+
+	operands := make([]Expression, len(raw))
+	for i, r := range raw {
+		var err error
+		if operands[i], err = UnmarshalExpression(r); err != nil {
+			return nil, err
+		}
+	}
+
+	return operands, nil
+}
+
+// DecodeExpression decodes an Expression from JSON that is either a string holding the textual form
+// accepted by ParseExpression or an object holding the JSON representation.
+func DecodeExpression(data []byte) (Expression, error) {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		return ParseExpression(text)
+	}
+
+	return UnmarshalExpression(data)
+}
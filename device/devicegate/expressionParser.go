@@ -0,0 +1,353 @@
+package devicegate
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var ErrExpressionSyntax = errors.New("filter expression syntax error")
+
+// MaxExpressionDepth is the deepest nesting of parentheses and negations allowed in a textual filter
+// expression.  This is the same limit that encoding/json places on JSON expressions.
+const MaxExpressionDepth = 10000
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenLeftParen
+	tokenRightParen
+	tokenComma
+	tokenNot
+	tokenAnd
+	tokenOr
+	tokenOperator
+	tokenString
+	tokenWord
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value string
+	pos   int
+}
+
+// isWordRune tests if a rune can appear in a field name or bare literal, e.g. partner-id or convey.hw-model
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_.-:/+", r)
+}
+
+// tokenize splits a filter expression into tokens
+func tokenize(text string) ([]token, error) {
+	var tokens []token
+	for pos := 0; pos < len(text); {
+		r := rune(text[pos])
+		switch {
+		case unicode.IsSpace(r):
+			pos++
+
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLeftParen, text: "(", pos: pos})
+			pos++
+
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRightParen, text: ")", pos: pos})
+			pos++
+
+		case r == ',':
+			tokens = append(tokens, token{kind: tokenComma, text: ",", pos: pos})
+			pos++
+
+		case strings.HasPrefix(text[pos:], "&&"):
+			tokens = append(tokens, token{kind: tokenAnd, text: "&&", pos: pos})
+			pos += 2
+
+		case strings.HasPrefix(text[pos:], "||"):
+			tokens = append(tokens, token{kind: tokenOr, text: "||", pos: pos})
+			pos += 2
+
+		case r == '"':
+			end := pos + 1
+			for ; end < len(text) && text[end] != '"'; end++ {
+				if text[end] == '\\' {
+					end++
+				}
+			}
+
+			if end >= len(text) {
+				return nil, fmt.Errorf("%w: unterminated string at position %d", ErrExpressionSyntax, pos)
+			}
+
+			value, err := strconv.Unquote(text[pos : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid string at position %d", ErrExpressionSyntax, pos)
+			}
+
+			tokens = append(tokens, token{kind: tokenString, text: text[pos : end+1], value: value, pos: pos})
+			pos = end + 1
+
+		case strings.ContainsRune("=!<>", r):
+			op := string(r)
+			if pos+1 < len(text) && strings.ContainsRune("=~", rune(text[pos+1])) {
+				op = text[pos : pos+2]
+			}
+
+			switch Operator(op) {
+			case OpEqual, OpNotEqual, OpMatches, OpLess, OpLessEqual, OpGreater, OpGreaterEqual:
+				tokens = append(tokens, token{kind: tokenOperator, text: op, pos: pos})
+			default:
+				if op != "!" {
+					return nil, fmt.Errorf("%w: invalid operator %q at position %d", ErrExpressionSyntax, op, pos)
+				}
+
+				tokens = append(tokens, token{kind: tokenNot, text: op, pos: pos})
+			}
+
+			pos += len(op)
+
+		case isWordRune(r):
+			end := pos
+			for end < len(text) && isWordRune(rune(text[end])) {
+				end++
+			}
+
+			word := text[pos:end]
+			switch strings.ToLower(word) {
+			case "and":
+				tokens = append(tokens, token{kind: tokenAnd, text: word, pos: pos})
+			case "or":
+				tokens = append(tokens, token{kind: tokenOr, text: word, pos: pos})
+			case "not":
+				tokens = append(tokens, token{kind: tokenNot, text: word, pos: pos})
+			default:
+				tokens = append(tokens, token{kind: tokenWord, text: word, value: word, pos: pos})
+			}
+
+			pos = end
+
+		default:
+			return nil, fmt.Errorf("%w: unexpected character %q at position %d", ErrExpressionSyntax, r, pos)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEnd, text: "end of expression", pos: len(text)}), nil
+}
+
+// parser is a recursive descent parser over a token stream
+type parser struct {
+	tokens []token
+	next   int
+	depth  int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) advance() token {
+	t := p.tokens[p.next]
+	if t.kind != tokenEnd {
+		p.next++
+	}
+
+	return t
+}
+
+func (p *parser) expect(kind tokenKind, description string) (token, error) {
+	t := p.advance()
+	if t.kind != kind {
+		return t, p.unexpected(t, description)
+	}
+
+	return t, nil
+}
+
+func (p *parser) unexpected(t token, description string) error {
+	return fmt.Errorf("%w: expected %s but found %q at position %d", ErrExpressionSyntax, description, t.text, t.pos)
+}
+
+// parseOr handles: and ( "||" and )*
+func (p *parser) parseOr() (Expression, error) {
+	return p.parseBinary(tokenOr, p.parseAnd, func(operands []Expression) Expression { return Or(operands) })
+}
+
+// parseAnd handles: unary ( "&&" unary )*
+func (p *parser) parseAnd() (Expression, error) {
+	return p.parseBinary(tokenAnd, p.parseUnary, func(operands []Expression) Expression { return And(operands) })
+}
+
+func (p *parser) parseBinary(kind tokenKind, operand func() (Expression, error), combine func([]Expression) Expression) (Expression, error) {
+	first, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	operands := []Expression{first}
+	for p.peek().kind == kind {
+		p.advance()
+		next, err := operand()
+		if err != nil {
+			return nil, err
+		}
+
+		operands = append(operands, next)
+	}
+
+	if len(operands) == 1 {
+		return first, nil
+	}
+
+	return combine(operands), nil
+}
+
+// parseUnary handles: "!" unary | primary
+func (p *parser) parseUnary() (Expression, error) {
+	// every level of nesting, whether a negation or parentheses, passes through here
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > MaxExpressionDepth {
+		return nil, fmt.Errorf("%w: expression nested more than %d deep at position %d", ErrExpressionSyntax, MaxExpressionDepth, p.peek().pos)
+	}
+
+	if p.peek().kind == tokenNot {
+		p.advance()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return Not{Expression: operand}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+// parsePrimary handles: "(" or ")" | "exists" "(" field ")" | field operator literal | field "in" "(" literal ( "," literal )* ")"
+func (p *parser) parsePrimary() (Expression, error) {
+	t := p.advance()
+	switch {
+	case t.kind == tokenLeftParen:
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := p.expect(tokenRightParen, ")"); err != nil {
+			return nil, err
+		}
+
+		return e, nil
+
+	case t.kind == tokenWord && strings.EqualFold(t.text, string(OpExists)) && p.peek().kind == tokenLeftParen:
+		p.advance()
+		field, err := p.expect(tokenWord, "a field")
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := p.expect(tokenRightParen, ")"); err != nil {
+			return nil, err
+		}
+
+		return newComparisonExpression(field.text, OpExists, nil)
+
+	case t.kind != tokenWord:
+		return nil, p.unexpected(t, "a field or (")
+	}
+
+	op := p.advance()
+	switch {
+	case op.kind == tokenOperator:
+		value, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+
+		return newComparisonExpression(t.text, Operator(op.text), value)
+
+	case op.kind == tokenWord && strings.EqualFold(op.text, string(OpIn)):
+		if _, err := p.expect(tokenLeftParen, "("); err != nil {
+			return nil, err
+		}
+
+		var values []interface{}
+		for {
+			value, err := p.parseLiteral()
+			if err != nil {
+				return nil, err
+			}
+
+			values = append(values, value)
+			if next := p.advance(); next.kind == tokenRightParen {
+				break
+			} else if next.kind != tokenComma {
+				return nil, p.unexpected(next, ", or )")
+			}
+		}
+
+		return newComparisonExpression(t.text, OpIn, values)
+
+	default:
+		return nil, p.unexpected(op, "an operator")
+	}
+}
+
+// parseLiteral parses a quoted string, true, false, a number, or any other bare word as a string
+func (p *parser) parseLiteral() (interface{}, error) {
+	t := p.advance()
+	switch t.kind {
+	case tokenString:
+		return t.value, nil
+
+	case tokenWord:
+		switch t.text {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+
+		if f, err := strconv.ParseFloat(t.text, 64); err == nil {
+			return f, nil
+		}
+
+		return t.text, nil
+
+	default:
+		return nil, p.unexpected(t, "a value")
+	}
+}
+
+// ParseExpression parses the textual form of a filter expression, for example:
+//
+//	partner-id == "comcast" && (convey.hw-model =~ "^TG" || trust < 1000) && !exists(metadata.session-id)
+//
+// Operators are &&, ||, ! and their case-insensitive keyword forms and, or, not, together with the comparisons
+// ==, !=, =~ (regular expression), <, <=, >, >=, in (a list of values) and exists(field).  Values are quoted
+// strings, numbers, true, false, or bare words which are treated as strings.
+func ParseExpression(text string) (Expression, error) {
+	tokens, err := tokenize(text)
+	if err != nil {
+		return nil, err
+	}
+
+	if tokens[0].kind == tokenEnd {
+		return nil, ErrEmptyExpression
+	}
+
+	p := &parser{tokens: tokens}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind != tokenEnd {
+		return nil, p.unexpected(t, "end of expression")
+	}
+
+	return e, nil
+}
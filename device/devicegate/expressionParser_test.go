@@ -0,0 +1,107 @@
+package devicegate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExpression(t *testing.T) {
+	testData := []struct {
+		text     string
+		expected string
+	}{
+		{`partner-id == "comcast"`, `partner-id == "comcast"`},
+		{`partner-id == comcast`, `partner-id == "comcast"`},
+		{`trust>=1000`, `trust >= 1000`},
+		{`trust < -1.5`, `trust < -1.5`},
+		{`enabled != true`, `enabled != true`},
+		{`convey.hw-model =~ "^TG\\d+"`, `convey.hw-model =~ "^TG\\d+"`},
+		{`partner-id IN ("comcast", sky, 3)`, `partner-id in ("comcast", "sky", 3)`},
+		{`exists(convey.fw-name)`, `exists(convey.fw-name)`},
+		{`a == 1 && b == 2 || c == 3`, `((a == 1 && b == 2) || c == 3)`},
+		{`a == 1 and (b == 2 or c == 3)`, `(a == 1 && (b == 2 || c == 3))`},
+		{`!a == 1 && not exists(b)`, `(!(a == 1) && !(exists(b)))`},
+		{`((a == 1))`, `a == 1`},
+		{`exists == 1`, `exists == 1`},
+	}
+
+	for _, record := range testData {
+		t.Run(record.text, func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				require = require.New(t)
+			)
+
+			e, err := ParseExpression(record.text)
+			require.NoError(err)
+			assert.Equal(record.expected, e.String())
+
+			// the canonical form parses to the same expression
+			reparsed, err := ParseExpression(e.String())
+			require.NoError(err)
+			assert.Equal(e, reparsed)
+		})
+	}
+}
+
+func TestParseExpressionInvalid(t *testing.T) {
+	testData := []struct {
+		text        string
+		expectedErr error
+	}{
+		{``, ErrEmptyExpression},
+		{`   `, ErrEmptyExpression},
+		{`a`, ErrExpressionSyntax},
+		{`a ==`, ErrExpressionSyntax},
+		{`a = 1`, ErrExpressionSyntax},
+		{`a == "unterminated`, ErrExpressionSyntax},
+		{`(a == 1`, ErrExpressionSyntax},
+		{`a == 1)`, ErrExpressionSyntax},
+		{`a == 1 &&`, ErrExpressionSyntax},
+		{`a in (1 2)`, ErrExpressionSyntax},
+		{`a in 1`, ErrExpressionSyntax},
+		{`exists(1 == 2)`, ErrExpressionSyntax},
+		{`a == 1 # comment`, ErrExpressionSyntax},
+		{`a < b`, ErrInvalidOperand},
+		{`a =~ "("`, nil},
+	}
+
+	for _, record := range testData {
+		t.Run(record.text, func(t *testing.T) {
+			e, err := ParseExpression(record.text)
+			assert.Nil(t, e)
+			require.Error(t, err)
+			if record.expectedErr != nil {
+				assert.True(t, errors.Is(err, record.expectedErr), err.Error())
+			}
+		})
+	}
+}
+
+func TestParseExpressionDepth(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	e, err := ParseExpression(strings.Repeat("!", MaxExpressionDepth-1) + "a == 1")
+	require.NoError(err)
+	assert.NotNil(e)
+
+	for _, text := range []string{
+		strings.Repeat("!", MaxExpressionDepth) + "a == 1",
+		strings.Repeat("(", MaxExpressionDepth) + "a == 1" + strings.Repeat(")", MaxExpressionDepth),
+		strings.Repeat("(", 100*MaxExpressionDepth),
+		strings.Repeat("not ", 100*MaxExpressionDepth) + "a == 1",
+	} {
+		e, err = ParseExpression(text)
+		assert.Nil(e)
+		require.Error(err)
+		assert.True(errors.Is(err, ErrExpressionSyntax))
+		assert.Contains(err.Error(), "nested")
+	}
+}
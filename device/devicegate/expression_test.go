@@ -0,0 +1,171 @@
+package devicegate
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/webpa-common/v2/convey"
+	"github.com/xmidt-org/webpa-common/v2/device"
+)
+
+func newExpressionDevice() *device.MockDevice {
+	metadata := new(device.Metadata)
+	metadata.SetClaims(map[string]interface{}{
+		"partner-id": "comcast",
+		"trust":      float64(1000),
+		"groups":     []interface{}{"a", "b"},
+	})
+
+	metadata.Store("region", "1234")
+
+	d := new(device.MockDevice)
+	// nolint: typecheck
+	d.On("Metadata").Return(metadata)
+	// nolint: typecheck
+	d.On("Convey").Return(convey.C{"hw-model": "TG1682", "boot-time": "1500000000", "webpa-protocol": "PARODUS-2.0"})
+	return d
+}
+
+func mustComparison(t *testing.T, field string, op Operator, value interface{}) *Comparison {
+	c, err := NewComparison(field, op, value)
+	require.NoError(t, err)
+	return c
+}
+
+func TestComparisonMatch(t *testing.T) {
+	d := newExpressionDevice()
+	testData := []struct {
+		field    string
+		op       Operator
+		value    interface{}
+		expected bool
+	}{
+		{"partner-id", OpEqual, "comcast", true},
+		{"partner-id", OpEqual, "sky", false},
+		{"partner-id", OpNotEqual, "sky", true},
+		{"claims.partner-id", OpEqual, "comcast", true},
+		{"metadata.partner-id", OpEqual, "comcast", false},
+		{"region", OpEqual, float64(1234), true},
+		{"metadata.region", OpEqual, "1234", true},
+		{"trust", OpGreaterEqual, float64(1000), true},
+		{"trust", OpLess, float64(1000), false},
+		{"trust", OpEqual, "1000", true},
+		{"groups", OpEqual, "b", true},
+		{"groups", OpNotEqual, "b", false},
+		{"groups", OpIn, []interface{}{"c", "a"}, true},
+		{"convey.hw-model", OpMatches, "^TG", true},
+		{"convey.hw-model", OpMatches, "^XB", false},
+		{"convey.boot-time", OpGreater, float64(1400000000), true},
+		{"partner-id", OpGreater, float64(1), false},
+		{"convey.missing", OpExists, nil, false},
+		{"convey.webpa-protocol", OpExists, nil, true},
+		{"missing", OpNotEqual, "anything", false},
+	}
+
+	for _, record := range testData {
+		c := mustComparison(t, record.field, record.op, record.value)
+		t.Run(c.String(), func(t *testing.T) {
+			assert.Equal(t, record.expected, c.Match(d))
+		})
+	}
+}
+
+func TestNewComparisonInvalid(t *testing.T) {
+	testData := []struct {
+		field string
+		op    Operator
+		value interface{}
+	}{
+		{"", OpEqual, "a"},
+		{"a", Operator("~"), "a"},
+		{"a", OpEqual, []interface{}{"a"}},
+		{"a", OpLess, "a"},
+		{"a", OpMatches, float64(1)},
+		{"a", OpMatches, "("},
+		{"a", OpIn, "a"},
+		{"a", OpIn, []interface{}{map[string]interface{}{}}},
+	}
+
+	for _, record := range testData {
+		_, err := NewComparison(record.field, record.op, record.value)
+		assert.Error(t, err, "%s %s %v", record.field, record.op, record.value)
+	}
+}
+
+func TestLogicalExpressions(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = newExpressionDevice()
+
+		yes = mustComparison(t, "partner-id", OpEqual, "comcast")
+		no  = mustComparison(t, "partner-id", OpEqual, "sky")
+	)
+
+	assert.True(And{yes, yes}.Match(d))
+	assert.False(And{yes, no}.Match(d))
+	assert.True(Or{no, yes}.Match(d))
+	assert.False(Or{no, no}.Match(d))
+	assert.True(Not{Expression: no}.Match(d))
+	assert.False(Not{Expression: yes}.Match(d))
+	assert.Equal(`(partner-id == "comcast" || !(partner-id == "sky"))`, Or{yes, Not{Expression: no}}.String())
+}
+
+func TestExpressionJSON(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		expression = And{
+			mustComparison(t, "partner-id", OpIn, []interface{}{"comcast", "sky"}),
+			Or{
+				mustComparison(t, "trust", OpLess, float64(1000)),
+				Not{Expression: mustComparison(t, "convey.hw-model", OpExists, nil)},
+			},
+		}
+	)
+
+	data, err := json.Marshal(expression)
+	require.NoError(err)
+	assert.JSONEq(
+		`{"and": [
+			{"field": "partner-id", "op": "in", "value": ["comcast", "sky"]},
+			{"or": [{"field": "trust", "op": "<", "value": 1000}, {"not": {"field": "convey.hw-model", "op": "exists"}}]}
+		]}`,
+		string(data),
+	)
+
+	actual, err := UnmarshalExpression(data)
+	require.NoError(err)
+	assert.Equal(expression, actual)
+
+	decoded, err := DecodeExpression(data)
+	require.NoError(err)
+	assert.Equal(expression, decoded)
+
+	decoded, err = DecodeExpression([]byte(`"partner-id in (comcast, sky) && (trust < 1000 || !exists(convey.hw-model))"`))
+	require.NoError(err)
+	assert.Equal(expression, decoded)
+}
+
+func TestUnmarshalExpressionInvalid(t *testing.T) {
+	for _, data := range []string{
+		`not json`,
+		`{}`,
+		`{"and": []}`,
+		`{"and": [{"field": "a", "op": "==", "value": "b"}], "field": "a"}`,
+		`{"or": [{"field": "a", "op": "<", "value": "b"}]}`,
+		`{"not": {"field": "a"}}`,
+	} {
+		e, err := UnmarshalExpression([]byte(data))
+		assert.Nil(t, e, data)
+		assert.Error(t, err, data)
+	}
+}
+
+func TestExpressionFields(t *testing.T) {
+	e, err := ParseExpression(`claims.partner-id == comcast && (convey.hw-model =~ "^TG" || !exists(partner-id))`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"claims.partner-id", "convey.hw-model", "partner-id"}, expressionFields(e))
+}
@@ -2,17 +2,51 @@ package devicegate
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/go-kit/kit/metrics"
+	"github.com/xmidt-org/webpa-common/v2/convey"
 	"github.com/xmidt-org/webpa-common/v2/device"
 )
 
 const (
 	metadataMapLocation = "metadata_map"
 	claimsLocation      = "claims"
+	conveyLocation      = "convey"
+	expressionLocation  = "expression"
+	allowListLocation   = "allow_list"
 )
 
-// Interface is a gate interface specifically for filtering devices
+var (
+	ErrInvalidFilterMode = errors.New("filter mode must be either deny or allow")
+	ErrNotSupported      = errors.New("the gate does not support that kind of filter")
+)
+
+// FilterMode determines how a filter affects the devices that match it
+type FilterMode string
+
+const (
+	// DenyMode filters refuse the devices that match them.  This is the default mode of every filter.
+	DenyMode FilterMode = "deny"
+
+	// AllowMode filters form an allow-list.  When any allow filter is set, only devices that match at least
+	// one allow filter, and no deny filter, may connect.
+	AllowMode FilterMode = "allow"
+)
+
+// Valid tests if this is a known mode.  The empty string is valid and is equivalent to DenyMode.
+func (fm FilterMode) Valid() bool {
+	return fm == "" || fm == DenyMode || fm == AllowMode
+}
+
+// Interface is a gate interface specifically for filtering devices.  Gates may also support filter expressions,
+// modes, expiry, and hit statistics by implementing ExpressionGate, ModeGate, ExpiryGate, and HitReporter.
+// FilterGate implements all of them.
 type Interface interface {
 	device.Filter
 
@@ -26,11 +60,12 @@ type Interface interface {
 	// that is true if the key was found, false if it doesn't exist.
 	GetFilter(key string) (Set, bool)
 
-	// SetFilter saves the filter values and filter key to filter by. It returns a Set of the old values and a
-	// bool that is true if the filter key did not previously exist and false if the filter key had existed beforehand.
+	// SetFilter saves the filter values and filter key to filter by, replacing any expression for that key. It returns
+	// a Set of the old values and a bool that is true if the filter key did not previously exist and false if the filter
+	// key had existed beforehand, with either values or an expression.
 	SetFilter(key string, values []interface{}) (Set, bool)
 
-	// DeleteFilter deletes a filter key. This completely removes all filter values associated with that key as well.
+	// DeleteFilter deletes a filter key. This completely removes all filter values and any expression associated with that key as well.
 	// Returns true if key had existed and values actually deleted, and false if key was not found.
 	DeleteFilter(key string) bool
 
@@ -39,6 +74,73 @@ type Interface interface {
 	GetAllowedFilters() (Set, bool)
 }
 
+// ExpressionGate is implemented by gates that support filter expressions
+type ExpressionGate interface {
+	// VisitExpressions applies the given visitor function to each filter expression.  The same restrictions
+	// on calling methods from the visitor apply as for Interface.VisitAll.
+	VisitExpressions(visit func(string, Expression) bool) int
+
+	// GetExpression returns the filter expression associated with a filter key and a bool that is true
+	// if the key has an expression.
+	GetExpression(key string) (Expression, bool)
+
+	// SetExpression saves a filter expression under a filter key, replacing any filter values for that key.  Devices
+	// that match the expression are not allowed to connect.  It returns the old expression and a bool that is true if
+	// the filter key did not previously exist, with either values or an expression.
+	SetExpression(key string, e Expression) (Expression, bool)
+}
+
+// ModeGate is implemented by gates whose filters can form an allow-list as well as deny devices
+type ModeGate interface {
+	// GetFilterMode returns the mode of the filter values and expression associated with a filter key.
+	// Filter keys without an explicit mode use DenyMode.
+	GetFilterMode(key string) FilterMode
+
+	// SetFilterMode changes the mode of the filter values and expression associated with a filter key.  An empty
+	// mode resets the key to DenyMode.  ErrInvalidFilterMode is returned if the mode is not known.
+	SetFilterMode(key string, mode FilterMode) error
+}
+
+// ExpiryGate is implemented by gates whose filters can expire
+type ExpiryGate interface {
+	// GetFilterExpiry returns the time at which the filter values and expression associated with a filter key
+	// expire and a bool that is true if the key expires at all.  Expired filters no longer affect devices.
+	GetFilterExpiry(key string) (time.Time, bool)
+
+	// SetFilterExpiry sets the time at which the filter values and expression associated with a filter key expire.
+	// A zero time means the key never expires.
+	SetFilterExpiry(key string, expires time.Time)
+}
+
+// FilterUpdater is implemented by gates that can change every part of a filter key at once.  FilterHandler
+// prefers it to setting the mode, expiry, and values of a key one at a time.
+type FilterUpdater interface {
+	// UpdateFilter atomically replaces the values or expression, mode, and expiry of the filter key in the given
+	// request.  It returns the Set or Expression the key held beforehand, or nil, and a bool that is true if the
+	// key is new.
+	UpdateFilter(FilterRequest) (interface{}, bool, error)
+
+	// RemoveFilter deletes every part of a filter key.  It returns the Set or Expression the key held, or nil,
+	// and true if the key existed.
+	RemoveFilter(key string) (interface{}, bool)
+}
+
+// HitReporter is implemented by gates that keep statistics about the devices their filters refuse
+type HitReporter interface {
+	// Hits returns a copy of the hit statistics of every filter key that has refused a device, including the
+	// keys of filters that have since been deleted.
+	Hits() map[string]FilterHits
+}
+
+var (
+	_ Interface      = (*FilterGate)(nil)
+	_ ExpressionGate = (*FilterGate)(nil)
+	_ ModeGate       = (*FilterGate)(nil)
+	_ ExpiryGate     = (*FilterGate)(nil)
+	_ FilterUpdater  = (*FilterGate)(nil)
+	_ HitReporter    = (*FilterGate)(nil)
+)
+
 // Set is an interface that represents a read-only hashset
 type Set interface {
 	json.Marshaler
@@ -52,6 +154,9 @@ type Set interface {
 // FilterStore can be used to store filters in the Interface
 type FilterStore map[string]Set
 
+// ExpressionStore can be used to store filter expressions in the Interface
+type ExpressionStore map[string]Expression
+
 // FilterSet is a concrete type that implements the Set interface
 type FilterSet struct {
 	Set  map[interface{}]bool
@@ -60,15 +165,40 @@ type FilterSet struct {
 
 // FilterGate is a concrete implementation of the Interface
 type FilterGate struct {
-	FilterStore    FilterStore `json:"filters"`
-	AllowedFilters Set         `json:"allowedFilters"`
+	FilterStore    FilterStore           `json:"filters"`
+	Expressions    ExpressionStore       `json:"expressions,omitempty"`
+	Modes          map[string]FilterMode `json:"modes,omitempty"`
+	Expires        map[string]time.Time  `json:"expires,omitempty"`
+	AllowedFilters Set                   `json:"allowedFilters"`
+
+	// HitCounter is optional.  When set, it is incremented with the FilterKeyLabel and FilterValueLabel
+	// labels each time a filter refuses a device.
+	HitCounter metrics.Counter `json:"-"`
+
+	lock     sync.RWMutex
+	hitsLock sync.Mutex
+	hits     map[string]*FilterHits
+}
 
-	lock sync.RWMutex
+// FilterHits records the devices refused by a filter key.  Values counts refusals by the device value that matched
+// the filter, and is empty for expressions.  Refusals by the allow-list as a whole are recorded under the key allow_list.
+type FilterHits struct {
+	Count       int            `json:"count"`
+	Values      map[string]int `json:"values,omitempty"`
+	LastMatched time.Time      `json:"lastMatched"`
 }
 
+// FilterRequest describes a filter to add or delete.  Either Values or Expression is set when adding a filter.
+// Expression is either a JSON string holding the textual form of a filter expression or the expression's
+// JSON representation.  Keys that start with ConveyPrefix match Values against the device's convey fields.
+// A filter expires at Expires or, when added through a FilterHandler, after TTL, which is a duration string such as 2h.
 type FilterRequest struct {
-	Key    string        `json:"key"`
-	Values []interface{} `json:"values"`
+	Key        string          `json:"key"`
+	Values     []interface{}   `json:"values"`
+	Expression json.RawMessage `json:"expression,omitempty"`
+	Mode       FilterMode      `json:"mode,omitempty"`
+	TTL        string          `json:"ttl,omitempty"`
+	Expires    *time.Time      `json:"expires,omitempty"`
 }
 
 func (f *FilterGate) VisitAll(visit func(string, Set) bool) int {
@@ -100,6 +230,7 @@ func (f *FilterGate) SetFilter(key string, values []interface{}) (Set, bool) {
 	defer f.lock.Unlock()
 
 	oldValues := f.FilterStore[key]
+	_, hadExpression := f.Expressions[key]
 	newValues := make(map[interface{}]bool)
 
 	for _, v := range values {
@@ -110,42 +241,464 @@ func (f *FilterGate) SetFilter(key string, values []interface{}) (Set, bool) {
 		Set: newValues,
 	}
 
-	if oldValues == nil {
-		return oldValues, true
+	delete(f.Expressions, key)
+	return oldValues, oldValues == nil && !hadExpression
+}
+
+func (f *FilterGate) DeleteFilter(key string) bool {
+	_, ok := f.RemoveFilter(key)
+	return ok
+}
+
+// RemoveFilter deletes the values or expression, mode, and expiry of a filter key.  It returns the Set or
+// Expression the key held, or nil, and true if the key existed.
+func (f *FilterGate) RemoveFilter(key string) (interface{}, bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var old interface{}
+	if e, ok := f.Expressions[key]; ok {
+		old = e
+	} else if s, ok := f.FilterStore[key]; ok {
+		old = s
 	}
 
-	return oldValues, false
+	delete(f.FilterStore, key)
+	delete(f.Expressions, key)
+	delete(f.Modes, key)
+	delete(f.Expires, key)
+	return old, old != nil
+}
 
+func (f *FilterGate) GetFilterMode(key string) FilterMode {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.mode(key)
 }
 
-func (f *FilterGate) DeleteFilter(key string) bool {
+// mode returns the mode of a filter key.  The lock must be held.
+func (f *FilterGate) mode(key string) FilterMode {
+	if mode, ok := f.Modes[key]; ok {
+		return mode
+	}
+
+	return DenyMode
+}
+
+func (f *FilterGate) SetFilterMode(key string, mode FilterMode) error {
+	if !mode.Valid() {
+		return ErrInvalidFilterMode
+	}
+
 	f.lock.Lock()
 	defer f.lock.Unlock()
 
-	_, ok := f.FilterStore[key]
+	if mode == "" || mode == DenyMode {
+		delete(f.Modes, key)
+		return nil
+	}
 
-	if ok {
-		delete(f.FilterStore, key)
-		return true
+	if f.Modes == nil {
+		f.Modes = make(map[string]FilterMode)
 	}
 
-	return false
+	f.Modes[key] = mode
+	return nil
+}
+
+func (f *FilterGate) GetFilterExpiry(key string) (time.Time, bool) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	expires, ok := f.Expires[key]
+	return expires, ok
+}
+
+func (f *FilterGate) SetFilterExpiry(key string, expires time.Time) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if expires.IsZero() {
+		delete(f.Expires, key)
+		return
+	}
+
+	if f.Expires == nil {
+		f.Expires = make(map[string]time.Time)
+	}
+
+	f.Expires[key] = expires
+}
+
+// expired tests if a filter key has expired as of the given time.  The lock must be held.
+func (f *FilterGate) expired(key string, now time.Time) bool {
+	expires, ok := f.Expires[key]
+	return ok && !expires.After(now)
+}
+
+// DeleteExpired deletes every filter key that has expired as of the given time, returning the deleted keys
+func (f *FilterGate) DeleteExpired(now time.Time) []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var deleted []string
+	for key := range f.Expires {
+		if f.expired(key, now) {
+			deleted = append(deleted, key)
+			delete(f.FilterStore, key)
+			delete(f.Expressions, key)
+			delete(f.Modes, key)
+			delete(f.Expires, key)
+		}
+	}
+
+	sort.Strings(deleted)
+	return deleted
+}
+
+func (f *FilterGate) VisitExpressions(visit func(string, Expression) bool) int {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	visited := 0
+	for key, e := range f.Expressions {
+		visited++
+		if !visit(key, e) {
+			break
+		}
+	}
+
+	return visited
+}
+
+func (f *FilterGate) GetExpression(key string) (Expression, bool) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	e, ok := f.Expressions[key]
+	return e, ok
+}
+
+func (f *FilterGate) SetExpression(key string, e Expression) (Expression, bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.Expressions == nil {
+		f.Expressions = make(ExpressionStore)
+	}
+
+	old, ok := f.Expressions[key]
+	_, hadValues := f.FilterStore[key]
+	f.Expressions[key] = e
+	delete(f.FilterStore, key)
+	return old, !ok && !hadValues
+}
+
+// filterBatch holds filters decoded from FilterRequests, ready to be stored in a FilterGate
+type filterBatch struct {
+	filterStore FilterStore
+	expressions ExpressionStore
+	modes       map[string]FilterMode
+	expires     map[string]time.Time
+}
+
+func newFilterBatch() *filterBatch {
+	return &filterBatch{
+		filterStore: make(FilterStore),
+		expressions: make(ExpressionStore),
+		modes:       make(map[string]FilterMode),
+		expires:     make(map[string]time.Time),
+	}
+}
+
+// add decodes a filter into this batch.  Expires is used as the filter's expiry, while TTL is ignored.
+func (b *filterBatch) add(fr FilterRequest) error {
+	if !fr.Mode.Valid() {
+		return fmt.Errorf("filter %s: %w", fr.Key, ErrInvalidFilterMode)
+	}
+
+	if len(fr.Expression) > 0 {
+		e, err := DecodeExpression(fr.Expression)
+		if err != nil {
+			return fmt.Errorf("filter %s: %w", fr.Key, err)
+		}
+
+		b.expressions[fr.Key] = e
+	}
+
+	if len(fr.Values) > 0 {
+		values := make(map[interface{}]bool, len(fr.Values))
+		for _, v := range fr.Values {
+			values[v] = true
+		}
+
+		b.filterStore[fr.Key] = &FilterSet{Set: values}
+	}
+
+	if fr.Mode == AllowMode {
+		b.modes[fr.Key] = fr.Mode
+	}
+
+	if fr.Expires != nil && !fr.Expires.IsZero() {
+		b.expires[fr.Key] = *fr.Expires
+	}
+
+	return nil
+}
+
+// Replace atomically replaces every filter, expression, and mode in this gate with the given filters.  If any
+// filter is invalid, an error is returned and the gate is unchanged.  AllowedFilters is not affected.
+func (f *FilterGate) Replace(filters []FilterRequest) error {
+	b := newFilterBatch()
+	for _, fr := range filters {
+		if err := b.add(fr); err != nil {
+			return err
+		}
+	}
+
+	f.lock.Lock()
+	f.FilterStore = b.filterStore
+	f.Expressions = b.expressions
+	f.Modes = b.modes
+	f.Expires = b.expires
+	f.lock.Unlock()
+	return nil
 }
 
+// UpdateFilter atomically replaces the values or expression, mode, and expiry of a single filter key, so that
+// devices never see a mix of the old and new filter.  Expires is used as the filter's expiry, while TTL is ignored.
+// It returns the Set or Expression the key held beforehand, or nil, and a bool that is true if the key is new.
+// If the filter is invalid, an error is returned and the gate is unchanged.
+func (f *FilterGate) UpdateFilter(fr FilterRequest) (interface{}, bool, error) {
+	b := newFilterBatch()
+	if err := b.add(fr); err != nil {
+		return nil, false, err
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var old interface{}
+	if e, ok := f.Expressions[fr.Key]; ok {
+		old = e
+	} else if s, ok := f.FilterStore[fr.Key]; ok {
+		old = s
+	}
+
+	delete(f.FilterStore, fr.Key)
+	delete(f.Expressions, fr.Key)
+	delete(f.Modes, fr.Key)
+	delete(f.Expires, fr.Key)
+
+	if s, ok := b.filterStore[fr.Key]; ok {
+		if f.FilterStore == nil {
+			f.FilterStore = make(FilterStore)
+		}
+
+		f.FilterStore[fr.Key] = s
+	}
+
+	if e, ok := b.expressions[fr.Key]; ok {
+		if f.Expressions == nil {
+			f.Expressions = make(ExpressionStore)
+		}
+
+		f.Expressions[fr.Key] = e
+	}
+
+	if mode, ok := b.modes[fr.Key]; ok {
+		if f.Modes == nil {
+			f.Modes = make(map[string]FilterMode)
+		}
+
+		f.Modes[fr.Key] = mode
+	}
+
+	if expires, ok := b.expires[fr.Key]; ok {
+		if f.Expires == nil {
+			f.Expires = make(map[string]time.Time)
+		}
+
+		f.Expires[fr.Key] = expires
+	}
+
+	return old, old == nil, nil
+}
+
+// Filters returns every filter in this gate, sorted by key, in the form accepted by Replace
+func (f *FilterGate) Filters() ([]FilterRequest, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	keys := make(map[string]bool, len(f.FilterStore)+len(f.Expressions))
+	for key := range f.FilterStore {
+		keys[key] = true
+	}
+
+	for key := range f.Expressions {
+		keys[key] = true
+	}
+
+	filters := make([]FilterRequest, 0, len(keys))
+	for key := range keys {
+		fr := FilterRequest{Key: key, Mode: f.Modes[key]}
+		if e, ok := f.Expressions[key]; ok {
+			data, err := json.Marshal(e)
+			if err != nil {
+				return nil, fmt.Errorf("filter %s: %w", key, err)
+			}
+
+			fr.Expression = data
+		} else if values := f.FilterStore[key]; values != nil {
+			values.VisitAll(func(v interface{}) {
+				fr.Values = append(fr.Values, v)
+			})
+
+			sort.Slice(fr.Values, func(i, j int) bool {
+				return fmt.Sprint(fr.Values[i]) < fmt.Sprint(fr.Values[j])
+			})
+		}
+
+		if expires, ok := f.Expires[key]; ok {
+			fr.Expires = &expires
+		}
+
+		filters = append(filters, fr)
+	}
+
+	sort.Slice(filters, func(i, j int) bool {
+		return filters[i].Key < filters[j].Key
+	})
+
+	return filters, nil
+}
+
+// AllowConnection tests if a device passes this gate's filters, recording a hit against the filter that refused it
 func (f *FilterGate) AllowConnection(d device.Interface) (bool, device.MatchResult) {
+	return f.allow(d, true)
+}
+
+// Check tests if a device passes this gate's filters just as AllowConnection does, but records nothing.  It is
+// meant for evaluating devices that are already connected, e.g. when enforcing filters, so that doing so does not
+// distort the hit statistics of connection attempts.
+func (f *FilterGate) Check(d device.Interface) (bool, device.MatchResult) {
+	return f.allow(d, false)
+}
+
+// allow evaluates the filters against a device, optionally recording a hit against the filter that refused it
+func (f *FilterGate) allow(d device.Interface, record bool) (bool, device.MatchResult) {
 	f.lock.RLock()
 	defer f.lock.RUnlock()
 
+	var (
+		allowList    = false
+		allowListHit = false
+		now          = time.Now()
+	)
+
 	for filterKey, filterValues := range f.FilterStore {
+		if f.expired(filterKey, now) {
+			continue
+		}
+
 		// check for filter match
-		if found, result := f.FilterStore.metadataMatch(filterKey, filterValues, d.Metadata()); found {
+		var (
+			found   bool
+			result  device.MatchResult
+			matched interface{}
+		)
+
+		if strings.HasPrefix(filterKey, ConveyPrefix) {
+			found, result, matched = f.FilterStore.conveyMatch(filterKey, filterValues, d.Convey())
+		} else {
+			found, result, matched = f.FilterStore.metadataMatch(filterKey, filterValues, d.Metadata())
+		}
+
+		if f.mode(filterKey) == AllowMode {
+			allowList = true
+			allowListHit = allowListHit || found
+		} else if found {
+			if record {
+				f.recordHit(filterKey, fmt.Sprint(matched), now)
+			}
+
 			return false, result
 		}
 	}
 
+	for key, e := range f.Expressions {
+		if f.expired(key, now) {
+			continue
+		}
+
+		found := e.Match(d)
+		if f.mode(key) == AllowMode {
+			allowList = true
+			allowListHit = allowListHit || found
+		} else if found {
+			if record {
+				f.recordHit(key, "", now)
+			}
+
+			return false, device.MatchResult{Location: expressionLocation, Key: key}
+		}
+	}
+
+	if allowList && !allowListHit {
+		if record {
+			f.recordHit(allowListLocation, "", now)
+		}
+
+		return false, device.MatchResult{Location: allowListLocation}
+	}
+
 	return true, device.MatchResult{}
 }
 
+// recordHit records that a filter key refused a device because of the given value
+func (f *FilterGate) recordHit(key, value string, now time.Time) {
+	f.hitsLock.Lock()
+	if f.hits == nil {
+		f.hits = make(map[string]*FilterHits)
+	}
+
+	h, ok := f.hits[key]
+	if !ok {
+		h = &FilterHits{Values: make(map[string]int)}
+		f.hits[key] = h
+	}
+
+	h.Count++
+	h.LastMatched = now
+	if len(value) > 0 {
+		h.Values[value]++
+	}
+
+	f.hitsLock.Unlock()
+
+	if f.HitCounter != nil {
+		f.HitCounter.With(FilterKeyLabel, key, FilterValueLabel, value).Add(1.0)
+	}
+}
+
+func (f *FilterGate) Hits() map[string]FilterHits {
+	f.hitsLock.Lock()
+	defer f.hitsLock.Unlock()
+
+	hits := make(map[string]FilterHits, len(f.hits))
+	for key, h := range f.hits {
+		values := make(map[string]int, len(h.Values))
+		for v, count := range h.Values {
+			values[v] = count
+		}
+
+		hits[key] = FilterHits{Count: h.Count, Values: values, LastMatched: h.LastMatched}
+	}
+
+	return hits
+}
+
 func (f *FilterGate) GetAllowedFilters() (Set, bool) {
 	if f.AllowedFilters == nil {
 		return f.AllowedFilters, false
@@ -183,7 +736,8 @@ func (s *FilterSet) MarshalJSON() ([]byte, error) {
 	return json.Marshal(temp)
 }
 
-func (f *FilterStore) metadataMatch(keyToCheck string, filterValues Set, m *device.Metadata) (bool, device.MatchResult) {
+// metadataMatch tests if a device's metadata or claims hold one of the filter values, returning the value that matched
+func (f *FilterStore) metadataMatch(keyToCheck string, filterValues Set, m *device.Metadata) (bool, device.MatchResult, interface{}) {
 	var val interface{}
 	result := device.MatchResult{
 		Key: keyToCheck,
@@ -199,27 +753,47 @@ func (f *FilterStore) metadataMatch(keyToCheck string, filterValues Set, m *devi
 	if val != nil {
 		switch t := val.(type) {
 		case []interface{}:
-			if filterMatch(filterValues, t...) {
-				return true, result
+			if matched, ok := filterMatch(filterValues, t...); ok {
+				return true, result, matched
 			}
 		case interface{}:
-			if filterMatch(filterValues, t) {
-				return true, result
+			if matched, ok := filterMatch(filterValues, t); ok {
+				return true, result, matched
 			}
 		}
 	}
 
-	return false, device.MatchResult{}
+	return false, device.MatchResult{}, nil
 }
 
-// function to check if any params are in a set
-func filterMatch(filterValues Set, paramsToCheck ...interface{}) bool {
+// conveyMatch tests if a device's convey field holds one of the filter values, returning the value that matched
+func (f *FilterStore) conveyMatch(keyToCheck string, filterValues Set, c convey.Interface) (bool, device.MatchResult, interface{}) {
+	if c == nil {
+		return false, device.MatchResult{}, nil
+	}
+
+	if val, found := c.Get(strings.TrimPrefix(keyToCheck, ConveyPrefix)); found && val != nil {
+		params := []interface{}{val}
+		if values, ok := val.([]interface{}); ok {
+			params = values
+		}
+
+		if matched, ok := filterMatch(filterValues, params...); ok {
+			return true, device.MatchResult{Location: conveyLocation, Key: keyToCheck}, matched
+		}
+	}
+
+	return false, device.MatchResult{}, nil
+}
+
+// function to check if any params are in a set, returning the first param that is
+func filterMatch(filterValues Set, paramsToCheck ...interface{}) (interface{}, bool) {
 	for _, param := range paramsToCheck {
 		if filterValues.Has(param) {
-			return true
+			return param, true
 		}
 	}
 
-	return false
+	return nil, false
 
 }
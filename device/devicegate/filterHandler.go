@@ -5,11 +5,17 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/gorilla/schema"
 	"github.com/xmidt-org/sallust"
+	"github.com/xmidt-org/webpa-common/v2/device"
 	"github.com/xmidt-org/webpa-common/v2/xhttp"
+	"github.com/xmidt-org/webpa-common/v2/xhttp/converter"
 	"go.uber.org/zap"
 )
 
@@ -18,9 +24,40 @@ type ContextKey string
 
 const gateKey ContextKey = "gate"
 
+var ErrNoEnforcer = errors.New("filter enforcement is not configured")
+
+// Enforcer disconnects connected devices that a gate now refuses, so that a new filter applies to existing
+// connections and not just new ones.  drain.Enforcer is the usual implementation.
+type Enforcer interface {
+	// Enforce starts disconnecting the connected devices that the gate refuses, at rate devices per tick, on
+	// behalf of the given filter.  Nonpositive values of rate and tick use the Enforcer's defaults.
+	Enforce(f FilterRequest, gate device.Filter, rate int, tick time.Duration) error
+}
+
+// enforcement holds the query parameters of a filter update that request retroactive enforcement
+type enforcement struct {
+	Enforce bool          `schema:"enforce"`
+	Rate    int           `schema:"rate"`
+	Tick    time.Duration `schema:"tick"`
+}
+
 // FilterHandler is an http.Handler that can get, add, and delete filters from a devicegate Interface
 type FilterHandler struct {
 	Gate Interface
+
+	// Syncer is optional.  When set, changes are saved to the Syncer's Store, which then updates the gate,
+	// so the Syncer must have been created for Gate.
+	Syncer *Syncer
+
+	// Enforcer is optional.  When set, filter updates with the enforce query parameter disconnect the connected
+	// devices that the updated gate refuses, at the rate given by the rate and tick query parameters.
+	Enforcer Enforcer
+
+	// Audit is optional.  When set, every filter change is recorded in it.
+	Audit *AuditLog
+
+	// Principal identifies who made a filter change for the audit trail.  DefaultPrincipal is used if this is nil.
+	Principal func(*http.Request) string
 }
 
 // GateLogger is used to log extra details about the gate
@@ -35,6 +72,51 @@ func (fh *FilterHandler) GetFilters(response http.ResponseWriter, request *http.
 	fmt.Fprintf(response, `%s`, JSON)
 }
 
+// GetHits is a handler function that writes the hit statistics of the gate's filters.  Gates that are not
+// a HitReporter have no statistics.
+func (fh *FilterHandler) GetHits(response http.ResponseWriter, request *http.Request) {
+	hits := map[string]FilterHits{}
+	if hr, ok := fh.Gate.(HitReporter); ok {
+		hits = hr.Hits()
+	}
+
+	writeJSON(response, hits)
+}
+
+// GetAudit is a handler function that writes the audit trail of filter changes, oldest first
+func (fh *FilterHandler) GetAudit(response http.ResponseWriter, request *http.Request) {
+	entries := []AuditEntry{}
+	if fh.Audit != nil {
+		entries = fh.Audit.Entries()
+	}
+
+	writeJSON(response, entries)
+}
+
+// audit records and logs a filter change
+func (fh *FilterHandler) audit(logger *zap.Logger, request *http.Request, e AuditEntry) {
+	principal := fh.Principal
+	if principal == nil {
+		principal = DefaultPrincipal
+	}
+
+	e.Time = time.Now()
+	e.Principal = principal(request)
+	e.Old = auditValue(e.Old)
+	e.New = auditValue(e.New)
+	logger.Info("gate filter changed",
+		zap.String("principal", e.Principal),
+		zap.String("action", e.Action),
+		zap.String("key", e.Key),
+		zap.Any("old", e.Old),
+		zap.Any("new", e.New),
+	)
+
+	if fh.Audit != nil {
+		fh.Audit.Record(e)
+	}
+}
+
 // UpdateFilters is a handler function that updates the filters stored in a gate
 func (fh *FilterHandler) UpdateFilters(response http.ResponseWriter, request *http.Request) {
 	logger := sallust.Get(request.Context())
@@ -53,16 +135,125 @@ func (fh *FilterHandler) UpdateFilters(response http.ResponseWriter, request *ht
 		return
 	}
 
-	if _, created := fh.Gate.SetFilter(message.Key, message.Values); created {
-		response.WriteHeader(http.StatusCreated)
+	enforce, err := fh.decodeEnforcement(request)
+	if err != nil {
+		logger.Error("invalid filter enforcement", zap.Error(err))
+		xhttp.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+
+	var e Expression
+	if len(message.Expression) > 0 {
+		if e, err = decodeRequestExpression(message, fh.Gate); err != nil {
+			logger.Error("invalid filter expression", zap.Error(err))
+			xhttp.WriteError(response, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	expires, err := requestExpiry(message, time.Now())
+	if err != nil {
+		logger.Error("invalid filter expiry", zap.Error(err))
+		xhttp.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := checkSupported(message, expires, fh.Gate); err != nil {
+		logger.Error("unsupported filter", zap.Error(err))
+		xhttp.WriteError(response, http.StatusNotImplemented, err)
+		return
+	}
+
+	var (
+		created bool
+		old     interface{}
+	)
+
+	// the expiry is made absolute, so that a stored filter does not restart its ttl
+	message.TTL, message.Expires = "", nil
+	if !expires.IsZero() {
+		message.Expires = &expires
+	}
+
+	if fh.Syncer != nil {
+		if old, created, err = fh.Syncer.SetFilter(message); err != nil {
+			logger.Error("unable to save filter", zap.Error(err))
+			writeSyncError(response, err)
+			return
+		}
+	} else if fu, ok := fh.Gate.(FilterUpdater); ok {
+		if old, created, err = fu.UpdateFilter(message); err != nil {
+			logger.Error("invalid filter", zap.Error(err))
+			xhttp.WriteError(response, http.StatusBadRequest, err)
+			return
+		}
 	} else {
-		response.WriteHeader(http.StatusOK)
+		// the mode is set first, so that a new allow filter never briefly denies the devices it matches
+		if mg, ok := fh.Gate.(ModeGate); ok {
+			if err := mg.SetFilterMode(message.Key, message.Mode); err != nil {
+				logger.Error("invalid filter mode", zap.Error(err))
+				xhttp.WriteError(response, http.StatusBadRequest, err)
+				return
+			}
+		}
+
+		if eg, ok := fh.Gate.(ExpiryGate); ok {
+			eg.SetFilterExpiry(message.Key, expires)
+		}
+
+		if e != nil {
+			// checkSupported has already refused expressions for gates without them
+			old, created = fh.Gate.(ExpressionGate).SetExpression(message.Key, e)
+		} else {
+			old, created = fh.Gate.SetFilter(message.Key, message.Values)
+		}
+	}
+
+	entry := AuditEntry{Action: AuditSet, Key: message.Key, Old: old, New: message.Values, Mode: message.Mode}
+	if e != nil {
+		entry.New = e
+	}
+
+	if !expires.IsZero() {
+		entry.Expires = &expires
+	}
+
+	fh.audit(logger, request, entry)
+
+	// the filter has already been applied, so a failure to enforce it only affects existing connections
+	if enforce.Enforce {
+		if err := fh.Enforcer.Enforce(message, fh.Gate, enforce.Rate, enforce.Tick); err != nil {
+			logger.Error("filter updated but unable to enforce it", zap.Error(err))
+			xhttp.WriteErrorf(response, http.StatusConflict, "filter updated but unable to enforce it: %s", err)
+			return
+		}
 	}
 
+	writeUpdateStatus(response, created)
 	newCtx := context.WithValue(request.Context(), gateKey, fh.Gate)
 	*request = *request.WithContext(newCtx)
 }
 
+// decodeEnforcement parses the enforcement query parameters of a filter update
+func (fh *FilterHandler) decodeEnforcement(request *http.Request) (enforcement, error) {
+	var (
+		decoder = schema.NewDecoder()
+		enforce enforcement
+	)
+
+	decoder.IgnoreUnknownKeys(true)
+	decoder.RegisterConverter(time.Duration(0), converter.Duration)
+	if err := decoder.Decode(&enforce, request.URL.Query()); err != nil {
+		return enforce, err
+	}
+
+	if enforce.Enforce && fh.Enforcer == nil {
+		return enforce, ErrNoEnforcer
+	}
+
+	return enforce, nil
+}
+
 // DeleteFilter is a handler function used to delete a particular filter stored in the gate
 func (fh *FilterHandler) DeleteFilter(response http.ResponseWriter, request *http.Request) {
 	logger := sallust.Get(request.Context())
@@ -81,7 +272,23 @@ func (fh *FilterHandler) DeleteFilter(response http.ResponseWriter, request *htt
 		return
 	}
 
-	fh.Gate.DeleteFilter(message.Key)
+	var old interface{}
+	if fh.Syncer != nil {
+		if old, _, err = fh.Syncer.DeleteFilter(message.Key); err != nil {
+			logger.Error("unable to save filter deletion", zap.Error(err))
+			writeSyncError(response, err)
+			return
+		}
+	} else if fu, ok := fh.Gate.(FilterUpdater); ok {
+		old, _ = fu.RemoveFilter(message.Key)
+	} else {
+		// Interface.DeleteFilter does not report what it removed, so the key is read beforehand
+		old = currentFilter(fh.Gate, message.Key)
+		fh.Gate.DeleteFilter(message.Key)
+	}
+
+	fh.audit(logger, request, AuditEntry{Action: AuditDelete, Key: message.Key, Old: old})
+
 	response.WriteHeader(http.StatusOK)
 
 	newCtx := context.WithValue(request.Context(), gateKey, fh.Gate)
@@ -109,10 +316,55 @@ func (gl GateLogger) LogFilters(next http.Handler) http.Handler {
 
 }
 
+func writeJSON(response http.ResponseWriter, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		xhttp.WriteError(response, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.Header().Set("Content-Type", "application/json")
+	response.Write(data)
+}
+
+func writeUpdateStatus(response http.ResponseWriter, created bool) {
+	if created {
+		response.WriteHeader(http.StatusCreated)
+	} else {
+		response.WriteHeader(http.StatusOK)
+	}
+}
+
+// writeSyncError reports a failure to save a change, using 409 when the change kept losing version races
+func writeSyncError(response http.ResponseWriter, err error) {
+	if errors.Is(err, ErrVersionConflict) {
+		xhttp.WriteError(response, http.StatusConflict, err)
+	} else {
+		xhttp.WriteError(response, http.StatusInternalServerError, err)
+	}
+}
+
+// MaxFilterRequestSize is the largest request body, in bytes, read by the handlers that accept a FilterRequest
+const MaxFilterRequestSize = 1 << 20
+
+var ErrFilterRequestTooLarge = fmt.Errorf("filter requests cannot exceed %d bytes", MaxFilterRequestSize)
+
+// ReadFilterRequest reads a request body holding a FilterRequest, refusing bodies larger than MaxFilterRequestSize
+func ReadFilterRequest(body io.Reader) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(body, MaxFilterRequestSize+1))
+	if err != nil {
+		return nil, err
+	} else if len(data) > MaxFilterRequestSize {
+		return nil, ErrFilterRequestTooLarge
+	}
+
+	return data, nil
+}
+
 // check that a message body is can be read and unmarshalled
 func validateRequestBody(request *http.Request) (FilterRequest, error) {
 	var message FilterRequest
-	msgBytes, err := ioutil.ReadAll(request.Body)
+	msgBytes, err := ReadFilterRequest(request.Body)
 	request.Body.Close()
 
 	if err != nil {
@@ -134,12 +386,25 @@ func checkRequestDetails(f FilterRequest, gate Interface, checkFilterValues bool
 	}
 
 	if checkFilterValues {
-		if len(f.Values) == 0 {
+		if len(f.Values) == 0 && len(f.Expression) == 0 {
 			return false, errors.New("missing filter values")
 		}
 
+		if len(f.Values) > 0 && len(f.Expression) > 0 {
+			return false, errors.New("filter values and a filter expression cannot both be set")
+		}
+
+		if !f.Mode.Valid() {
+			return false, ErrInvalidFilterMode
+		}
+
+		// the fields of an expression are checked once the expression is decoded
+		if len(f.Expression) > 0 {
+			return true, nil
+		}
+
 		if allowedFilters, allowedFiltersFound := gate.GetAllowedFilters(); allowedFiltersFound {
-			if !allowedFilters.Has(f.Key) {
+			if !filterAllowed(allowedFilters, f.Key) {
 				allowedFiltersJSON, _ := json.Marshal(allowedFilters)
 				return false, fmt.Errorf("filter key %s is not allowed. Allowed filters: %s", f.Key, allowedFiltersJSON)
 			}
@@ -148,3 +413,85 @@ func checkRequestDetails(f FilterRequest, gate Interface, checkFilterValues bool
 
 	return true, nil
 }
+
+// checkSupported tests if a gate supports every part of a requested filter, returning an error wrapping
+// ErrNotSupported if it does not
+func checkSupported(f FilterRequest, expires time.Time, gate Interface) error {
+	if _, ok := gate.(ExpressionGate); !ok && len(f.Expression) > 0 {
+		return fmt.Errorf("%w: filter expressions", ErrNotSupported)
+	}
+
+	if _, ok := gate.(ModeGate); !ok && f.Mode == AllowMode {
+		return fmt.Errorf("%w: allow mode", ErrNotSupported)
+	}
+
+	if _, ok := gate.(ExpiryGate); !ok && !expires.IsZero() {
+		return fmt.Errorf("%w: filter expiry", ErrNotSupported)
+	}
+
+	return nil
+}
+
+// requestExpiry returns the absolute expiry of a requested filter, or the zero time if the filter never expires
+func requestExpiry(f FilterRequest, now time.Time) (time.Time, error) {
+	var expires time.Time
+	switch {
+	case len(f.TTL) > 0 && f.Expires != nil:
+		return expires, errors.New("a filter ttl and expires cannot both be set")
+
+	case len(f.TTL) > 0:
+		ttl, err := time.ParseDuration(f.TTL)
+		if err != nil {
+			return expires, fmt.Errorf("invalid filter ttl: %w", err)
+		}
+
+		if ttl <= 0 {
+			return expires, errors.New("a filter ttl must be positive")
+		}
+
+		expires = now.Add(ttl)
+
+	case f.Expires != nil:
+		expires = *f.Expires
+		if !expires.After(now) {
+			return time.Time{}, errors.New("a filter cannot expire in the past")
+		}
+	}
+
+	return expires, nil
+}
+
+// decodeRequestExpression decodes the expression in a request, checking that every field it refers to is allowed
+func decodeRequestExpression(f FilterRequest, gate Interface) (Expression, error) {
+	e, err := DecodeExpression(f.Expression)
+	if err != nil {
+		return nil, err
+	}
+
+	if allowedFilters, allowedFiltersFound := gate.GetAllowedFilters(); allowedFiltersFound {
+		for _, field := range expressionFields(e) {
+			if !filterAllowed(allowedFilters, field) {
+				allowedFiltersJSON, _ := json.Marshal(allowedFilters)
+				return nil, fmt.Errorf("filter key %s is not allowed. Allowed filters: %s", field, allowedFiltersJSON)
+			}
+		}
+	}
+
+	return e, nil
+}
+
+// filterAllowed tests if a filter key is in the set of allowed filters, either exactly or once any location
+// prefix such as ConveyPrefix is removed.  This lets an allowed filter of hw-model permit convey.hw-model.
+func filterAllowed(allowedFilters Set, key string) bool {
+	if allowedFilters.Has(key) {
+		return true
+	}
+
+	for _, prefix := range []string{ConveyPrefix, ClaimsPrefix, MetadataPrefix} {
+		if strings.HasPrefix(key, prefix) {
+			return allowedFilters.Has(strings.TrimPrefix(key, prefix))
+		}
+	}
+
+	return false
+}
@@ -3,12 +3,15 @@ package devicegate
 import (
 	"bytes"
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"github.com/xmidt-org/sallust"
 )
 
@@ -61,6 +64,12 @@ func TestBadRequest(t *testing.T) {
 			expectedStatusCode: http.StatusBadRequest,
 			testDelete:         true,
 		},
+		{
+			description:        "Request too large",
+			reqBody:            bytes.Repeat([]byte(" "), MaxFilterRequestSize+1),
+			expectedStatusCode: http.StatusBadRequest,
+			testDelete:         true,
+		},
 		{
 			description:        "No filter key parameter",
 			reqBody:            []byte(`{"test": "test"}`),
@@ -77,6 +86,26 @@ func TestBadRequest(t *testing.T) {
 			reqBody:            []byte(`{"key": "test", "values": ["test", "test1"]}`),
 			expectedStatusCode: http.StatusBadRequest,
 		},
+		{
+			description:        "Invalid filter mode",
+			reqBody:            []byte(`{"key": "test", "values": ["test"], "mode": "maybe"}`),
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			description:        "Filter values and expression",
+			reqBody:            []byte(`{"key": "test", "values": ["test"], "expression": "test == 1"}`),
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			description:        "Invalid expression",
+			reqBody:            []byte(`{"key": "test", "expression": "test =="}`),
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			description:        "Expression field not allowed",
+			reqBody:            []byte(`{"key": "test", "expression": "test == 1"}`),
+			expectedStatusCode: http.StatusBadRequest,
+		},
 	}
 
 	// nolint: typecheck
@@ -119,6 +148,8 @@ func TestSuccessfulAdd(t *testing.T) {
 		expectedStatusCode int
 		allowedFilters     *FilterSet
 		allowedFiltersSet  bool
+		mode               FilterMode
+		expires            bool
 	}{
 		{
 			description:        "Successful POST",
@@ -150,6 +181,35 @@ func TestSuccessfulAdd(t *testing.T) {
 			allowedFilters:     &FilterSet{Set: map[interface{}]bool{"test": true}},
 			allowedFiltersSet:  true,
 		},
+		{
+			description:        "Successful Allow Mode",
+			request:            httptest.NewRequest("POST", "/", bytes.NewBuffer([]byte(`{"key": "test", "values": ["test1"], "mode": "allow"}`))).WithContext(ctx),
+			newKey:             true,
+			expectedStatusCode: http.StatusCreated,
+			mode:               AllowMode,
+		},
+		{
+			description:        "Successful Convey Key with Allowed Filters",
+			request:            httptest.NewRequest("POST", "/", bytes.NewBuffer([]byte(`{"key": "convey.hw-model", "values": ["TG1682"]}`))).WithContext(ctx),
+			newKey:             true,
+			expectedStatusCode: http.StatusCreated,
+			allowedFilters:     &FilterSet{Set: map[interface{}]bool{"hw-model": true}},
+			allowedFiltersSet:  true,
+		},
+		{
+			description:        "Successful TTL",
+			request:            httptest.NewRequest("POST", "/", bytes.NewBuffer([]byte(`{"key": "test", "values": ["test1"], "ttl": "2h"}`))).WithContext(ctx),
+			newKey:             true,
+			expectedStatusCode: http.StatusCreated,
+			expires:            true,
+		},
+		{
+			description:        "Successful Expires",
+			request:            httptest.NewRequest("POST", "/", bytes.NewBuffer([]byte(`{"key": "test", "values": ["test1"], "expires": "2999-01-01T00:00:00Z"}`))).WithContext(ctx),
+			newKey:             true,
+			expectedStatusCode: http.StatusCreated,
+			expires:            true,
+		},
 	}
 
 	for _, tc := range tests {
@@ -165,6 +225,16 @@ func TestSuccessfulAdd(t *testing.T) {
 			// nolint: typecheck
 			mockDeviceGate.On("GetAllowedFilters").Return(tc.allowedFilters, tc.allowedFiltersSet).Once()
 			// nolint: typecheck
+			mockDeviceGate.On("SetFilterMode", mock.AnythingOfType("string"), tc.mode).Return(nil).Once()
+			// nolint: typecheck
+			mockDeviceGate.On("SetFilterExpiry", mock.AnythingOfType("string"), mock.MatchedBy(func(expires time.Time) bool {
+				return expires.IsZero() != tc.expires
+			})).Once()
+			// nolint: typecheck
+			mockDeviceGate.On("GetExpression", mock.AnythingOfType("string")).Return(nil, false).Once()
+			// nolint: typecheck
+			mockDeviceGate.On("GetFilter", mock.AnythingOfType("string")).Return(nil, false).Once()
+			// nolint: typecheck
 			mockDeviceGate.On("SetFilter", mock.AnythingOfType("string"), mock.Anything).Return(nil, tc.newKey).Once()
 			// nolint: typecheck
 			mockDeviceGate.On("VisitAll", mock.Anything).Return(0).Once()
@@ -191,6 +261,10 @@ func TestDelete(t *testing.T) {
 		}
 	)
 
+	// nolint: typecheck
+	mockDeviceGate.On("GetExpression", "test").Return(nil, false).Once()
+	// nolint: typecheck
+	mockDeviceGate.On("GetFilter", "test").Return(&FilterSet{Set: map[interface{}]bool{"test1": true}}, true).Once()
 	// nolint: typecheck
 	mockDeviceGate.On("DeleteFilter", "test").Return(true).Once()
 	// nolint: typecheck
@@ -259,3 +333,223 @@ func TestGateLogger(t *testing.T) {
 	}
 
 }
+
+func TestSuccessfulAddExpression(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		ctx    = sallust.With(context.Background(), sallust.Default())
+
+		gate = &FilterGate{
+			FilterStore:    make(FilterStore),
+			AllowedFilters: &FilterSet{Set: map[interface{}]bool{"partner-id": true, "hw-model": true}},
+		}
+
+		f = FilterHandler{
+			Gate: gate,
+		}
+	)
+
+	for _, body := range []string{
+		`{"key": "test", "expression": "partner-id == comcast && convey.hw-model =~ \"^TG\""}`,
+		`{"key": "test", "expression": {"field": "partner-id", "op": "in", "value": ["comcast", "sky"]}}`,
+	} {
+		response := httptest.NewRecorder()
+		f.UpdateFilters(response, httptest.NewRequest("POST", "/", bytes.NewBufferString(body)).WithContext(ctx))
+		assert.Contains([]int{http.StatusCreated, http.StatusOK}, response.Code)
+	}
+
+	e, ok := gate.GetExpression("test")
+	assert.True(ok)
+	assert.Equal(`partner-id in ("comcast", "sky")`, e.String())
+
+	response := httptest.NewRecorder()
+	f.DeleteFilter(response, httptest.NewRequest("DELETE", "/", bytes.NewBufferString(`{"key": "test"}`)).WithContext(ctx))
+	assert.Equal(http.StatusOK, response.Code)
+
+	_, ok = gate.GetExpression("test")
+	assert.False(ok)
+}
+
+func TestReplaceFilterKind(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		ctx     = sallust.With(context.Background(), sallust.Default())
+
+		gate = &FilterGate{
+			FilterStore:    make(FilterStore),
+			AllowedFilters: &FilterSet{Set: map[interface{}]bool{"partner-id": true}},
+		}
+
+		auditLog = NewAuditLog(10)
+		f        = FilterHandler{Gate: gate, Audit: auditLog}
+
+		update = func(body string) int {
+			response := httptest.NewRecorder()
+			f.UpdateFilters(response, httptest.NewRequest("POST", "/", bytes.NewBufferString(body)).WithContext(ctx))
+			return response.Code
+		}
+	)
+
+	assert.Equal(http.StatusCreated, update(`{"key": "partner-id", "values": ["comcast"]}`))
+
+	// values are replaced by an expression
+	assert.Equal(http.StatusOK, update(`{"key": "partner-id", "expression": "partner-id == sky"}`))
+	_, hasValues := gate.GetFilter("partner-id")
+	assert.False(hasValues)
+	e, hasExpression := gate.GetExpression("partner-id")
+	require.True(hasExpression)
+	assert.Equal(`partner-id == "sky"`, e.String())
+
+	// an expression is replaced by values
+	assert.Equal(http.StatusOK, update(`{"key": "partner-id", "values": ["comcast"]}`))
+	_, hasExpression = gate.GetExpression("partner-id")
+	assert.False(hasExpression)
+	values, hasValues := gate.GetFilter("partner-id")
+	require.True(hasValues)
+	assert.True(values.Has("comcast"))
+
+	// an update replaces the mode and expiry along with the values
+	assert.Equal(http.StatusOK, update(`{"key": "partner-id", "values": ["comcast"], "mode": "allow", "ttl": "1h"}`))
+	assert.Equal(AllowMode, gate.GetFilterMode("partner-id"))
+	_, expires := gate.GetFilterExpiry("partner-id")
+	assert.True(expires)
+
+	assert.Equal(http.StatusOK, update(`{"key": "partner-id", "values": ["sky"]}`))
+	assert.Equal(DenyMode, gate.GetFilterMode("partner-id"))
+	_, expires = gate.GetFilterExpiry("partner-id")
+	assert.False(expires)
+
+	// each change is audited with the filter it replaced, whatever its kind
+	entries := auditLog.Entries()
+	require.Len(entries, 5)
+	assert.Nil(entries[0].Old)
+	require.IsType(&FilterSet{}, entries[1].Old)
+	assert.True(entries[1].Old.(Set).Has("comcast"))
+	assert.Equal(`partner-id == "sky"`, entries[2].Old)
+}
+
+func TestBadExpiry(t *testing.T) {
+	var (
+		ctx = sallust.With(context.Background(), sallust.Default())
+		f   = FilterHandler{
+			Gate: &FilterGate{FilterStore: make(FilterStore)},
+		}
+	)
+
+	for _, body := range []string{
+		`{"key": "test", "values": ["test"], "ttl": "forever"}`,
+		`{"key": "test", "values": ["test"], "ttl": "-1h"}`,
+		`{"key": "test", "values": ["test"], "expires": "2000-01-01T00:00:00Z"}`,
+		`{"key": "test", "values": ["test"], "ttl": "1h", "expires": "2999-01-01T00:00:00Z"}`,
+	} {
+		t.Run(body, func(t *testing.T) {
+			response := httptest.NewRecorder()
+			f.UpdateFilters(response, httptest.NewRequest("POST", "/", bytes.NewBufferString(body)).WithContext(ctx))
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+		})
+	}
+}
+
+func TestEnforce(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		ctx    = sallust.With(context.Background(), sallust.Default())
+		gate   = &FilterGate{FilterStore: make(FilterStore)}
+
+		enforcer = new(mockEnforcer)
+		f        = FilterHandler{
+			Gate:     gate,
+			Enforcer: enforcer,
+		}
+
+		body = `{"key": "test", "values": ["test"]}`
+	)
+
+	// nolint: typecheck
+	enforcer.On("Enforce", FilterRequest{Key: "test", Values: []interface{}{"test"}}, gate, 100, time.Minute).Return(nil).Once()
+	response := httptest.NewRecorder()
+	f.UpdateFilters(response, httptest.NewRequest("POST", "/?enforce=true&rate=100&tick=1m", bytes.NewBufferString(body)).WithContext(ctx))
+	assert.Equal(http.StatusCreated, response.Code)
+
+	// nolint: typecheck
+	enforcer.On("Enforce", mock.Anything, gate, 0, time.Duration(0)).Return(errors.New("expected")).Once()
+	response = httptest.NewRecorder()
+	f.UpdateFilters(response, httptest.NewRequest("POST", "/?enforce=true", bytes.NewBufferString(body)).WithContext(ctx))
+	assert.Equal(http.StatusConflict, response.Code)
+
+	// without the enforce parameter, nothing is enforced
+	response = httptest.NewRecorder()
+	f.UpdateFilters(response, httptest.NewRequest("POST", "/", bytes.NewBufferString(body)).WithContext(ctx))
+	assert.Equal(http.StatusOK, response.Code)
+
+	response = httptest.NewRecorder()
+	f.UpdateFilters(response, httptest.NewRequest("POST", "/?enforce=true&rate=fast", bytes.NewBufferString(body)).WithContext(ctx))
+	assert.Equal(http.StatusBadRequest, response.Code)
+
+	unconfigured := FilterHandler{Gate: gate}
+	response = httptest.NewRecorder()
+	unconfigured.UpdateFilters(response, httptest.NewRequest("POST", "/?enforce=true", bytes.NewBufferString(`{"key": "other", "values": ["test"]}`)).WithContext(ctx))
+	assert.Equal(http.StatusBadRequest, response.Code)
+
+	_, ok := gate.GetFilter("other")
+	assert.False(ok, "a filter must not be applied when enforcement cannot be configured")
+
+	// nolint: typecheck
+	enforcer.AssertExpectations(t)
+}
+
+func TestGetHits(t *testing.T) {
+	var (
+		assert         = assert.New(t)
+		response       = httptest.NewRecorder()
+		mockDeviceGate = new(mockDeviceGate)
+		f              = FilterHandler{
+			Gate: mockDeviceGate,
+		}
+	)
+
+	// nolint: typecheck
+	mockDeviceGate.On("Hits").Return(map[string]FilterHits{"partner-id": {Count: 3, Values: map[string]int{"comcast": 3}}}).Once()
+	f.GetHits(response, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(http.StatusOK, response.Code)
+	assert.JSONEq(`{"partner-id": {"count": 3, "values": {"comcast": 3}, "lastMatched": "0001-01-01T00:00:00Z"}}`, response.Body.String())
+}
+
+func TestBasicGate(t *testing.T) {
+	var (
+		assert         = assert.New(t)
+		ctx            = sallust.With(context.Background(), sallust.Default())
+		mockDeviceGate = new(mockDeviceGate)
+		f              = FilterHandler{
+			Gate: basicGate{mockDeviceGate},
+		}
+	)
+
+	// nolint: typecheck
+	mockDeviceGate.On("GetAllowedFilters").Return(nil, false)
+	// nolint: typecheck
+	mockDeviceGate.On("SetFilter", "test", []interface{}{"test1"}).Return(nil, true).Once()
+
+	response := httptest.NewRecorder()
+	f.UpdateFilters(response, httptest.NewRequest("POST", "/", bytes.NewBufferString(`{"key": "test", "values": ["test1"]}`)).WithContext(ctx))
+	assert.Equal(http.StatusCreated, response.Code)
+
+	// filters the gate cannot hold are refused without touching it
+	for _, body := range []string{
+		`{"key": "test", "expression": "test == test1"}`,
+		`{"key": "test", "values": ["test1"], "mode": "allow"}`,
+		`{"key": "test", "values": ["test1"], "ttl": "1h"}`,
+	} {
+		response = httptest.NewRecorder()
+		f.UpdateFilters(response, httptest.NewRequest("POST", "/", bytes.NewBufferString(body)).WithContext(ctx))
+		assert.Equal(http.StatusNotImplemented, response.Code, body)
+	}
+
+	response = httptest.NewRecorder()
+	f.GetHits(response, httptest.NewRequest("GET", "/", nil))
+	assert.JSONEq(`{}`, response.Body.String())
+
+	// nolint: typecheck
+	mockDeviceGate.AssertExpectations(t)
+}
@@ -4,9 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/xmidt-org/webpa-common/v2/device"
+	"github.com/xmidt-org/webpa-common/v2/xmetrics/xmetricstest"
 )
 
 func TestFilterGateAllowConnection(t *testing.T) {
@@ -87,6 +90,233 @@ func TestFilterGateAllowConnection(t *testing.T) {
 	}
 }
 
+func TestFilterGateAllowConnectionExpression(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		d       = newExpressionDevice()
+		fg      = FilterGate{FilterStore: make(FilterStore)}
+	)
+
+	canPass, matchResult := fg.AllowConnection(d)
+	assert.True(canPass)
+	assert.Equal(device.MatchResult{}, matchResult)
+
+	e, err := ParseExpression(`partner-id == sky || convey.hw-model =~ "^XB"`)
+	require.NoError(err)
+	old, created := fg.SetExpression("other", e)
+	assert.Nil(old)
+	assert.True(created)
+
+	canPass, _ = fg.AllowConnection(d)
+	assert.True(canPass)
+
+	e, err = ParseExpression(`partner-id == comcast && convey.hw-model =~ "^TG"`)
+	require.NoError(err)
+	_, created = fg.SetExpression("tg", e)
+	assert.True(created)
+
+	canPass, matchResult = fg.AllowConnection(d)
+	assert.False(canPass)
+	assert.Equal(device.MatchResult{Location: expressionLocation, Key: "tg"}, matchResult)
+
+	visited := make(map[string]string)
+	assert.Equal(2, fg.VisitExpressions(func(key string, e Expression) bool {
+		visited[key] = e.String()
+		return true
+	}))
+
+	assert.Equal(map[string]string{
+		"other": `(partner-id == "sky" || convey.hw-model =~ "^XB")`,
+		"tg":    `(partner-id == "comcast" && convey.hw-model =~ "^TG")`,
+	}, visited)
+
+	data, err := json.Marshal(&fg)
+	require.NoError(err)
+	assert.Contains(string(data), `"expressions":{`)
+
+	assert.True(fg.DeleteFilter("tg"))
+	assert.False(fg.DeleteFilter("tg"))
+	canPass, _ = fg.AllowConnection(d)
+	assert.True(canPass)
+}
+
+func TestFilterGateAllowList(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		d       = newExpressionDevice()
+		fg      = FilterGate{FilterStore: make(FilterStore)}
+	)
+
+	assert.Equal(DenyMode, fg.GetFilterMode("convey.hw-model"))
+	assert.Equal(ErrInvalidFilterMode, fg.SetFilterMode("convey.hw-model", FilterMode("maybe")))
+
+	// convey fields are matched by set filters
+	fg.SetFilter("convey.hw-model", []interface{}{"TG1682"})
+	canPass, matchResult := fg.AllowConnection(d)
+	assert.False(canPass)
+	assert.Equal(device.MatchResult{Location: conveyLocation, Key: "convey.hw-model"}, matchResult)
+
+	// as an allow filter, the same filter lets the device through
+	require.NoError(fg.SetFilterMode("convey.hw-model", AllowMode))
+	assert.Equal(AllowMode, fg.GetFilterMode("convey.hw-model"))
+	canPass, matchResult = fg.AllowConnection(d)
+	assert.True(canPass)
+	assert.Equal(device.MatchResult{}, matchResult)
+
+	// deny filters still apply to devices on the allow-list
+	fg.SetFilter("partner-id", []interface{}{"comcast"})
+	canPass, matchResult = fg.AllowConnection(d)
+	assert.False(canPass)
+	assert.Equal(device.MatchResult{Location: claimsLocation, Key: "partner-id"}, matchResult)
+	fg.DeleteFilter("partner-id")
+
+	// devices not on the allow-list are refused
+	fg.SetFilter("convey.hw-model", []interface{}{"XB3"})
+	canPass, matchResult = fg.AllowConnection(d)
+	assert.False(canPass)
+	assert.Equal(device.MatchResult{Location: allowListLocation}, matchResult)
+
+	// any allow filter, including an expression, can admit a device
+	e, err := ParseExpression(`trust >= 1000`)
+	require.NoError(err)
+	fg.SetExpression("trusted", e)
+	require.NoError(fg.SetFilterMode("trusted", AllowMode))
+	canPass, _ = fg.AllowConnection(d)
+	assert.True(canPass)
+
+	require.NoError(fg.SetFilterMode("trusted", ""))
+	assert.Equal(DenyMode, fg.GetFilterMode("trusted"))
+	canPass, matchResult = fg.AllowConnection(d)
+	assert.False(canPass)
+	assert.Equal(device.MatchResult{Location: expressionLocation, Key: "trusted"}, matchResult)
+
+	assert.True(fg.DeleteFilter("convey.hw-model"))
+	assert.Equal(DenyMode, fg.GetFilterMode("convey.hw-model"))
+}
+
+func TestFilterGateExpiry(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		metadata = new(device.Metadata)
+		expired  = time.Now().Add(-time.Minute)
+
+		fg = FilterGate{
+			FilterStore: make(FilterStore),
+		}
+	)
+
+	metadata.SetClaims(map[string]interface{}{"partner-id": "comcast"})
+	mockDevice := new(device.MockDevice)
+	// nolint: typecheck
+	mockDevice.On("Metadata").Return(metadata)
+
+	fg.SetFilter("partner-id", []interface{}{"comcast"})
+	fg.SetFilterExpiry("partner-id", expired)
+	e, err := ParseExpression(`partner-id == comcast`)
+	require.NoError(err)
+	fg.SetExpression("expression", e)
+	fg.SetFilterExpiry("expression", expired)
+
+	canPass, _ := fg.AllowConnection(mockDevice)
+	assert.True(canPass, "expired filters must not block devices")
+
+	data, err := json.Marshal(&fg)
+	require.NoError(err)
+	assert.Contains(string(data), `"expires":{`)
+
+	fg.SetFilterExpiry("expression", time.Time{})
+	_, ok := fg.GetFilterExpiry("expression")
+	assert.False(ok)
+
+	canPass, _ = fg.AllowConnection(mockDevice)
+	assert.False(canPass)
+
+	assert.Equal([]string{"partner-id"}, fg.DeleteExpired(time.Now()))
+	_, ok = fg.GetFilter("partner-id")
+	assert.False(ok)
+	assert.Empty(fg.DeleteExpired(time.Now()))
+
+	fg.SetFilterExpiry("expression", time.Now().Add(time.Hour))
+	assert.True(fg.DeleteFilter("expression"))
+	_, ok = fg.GetFilterExpiry("expression")
+	assert.False(ok)
+}
+
+func TestFilterGateHits(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		provider = xmetricstest.NewProvider(nil, Metrics)
+
+		comcast = new(device.Metadata)
+		sky     = new(device.Metadata)
+
+		fg = FilterGate{
+			FilterStore: make(FilterStore),
+			HitCounter:  provider.NewCounter(FilterHitCounter),
+		}
+	)
+
+	comcast.SetClaims(map[string]interface{}{"partner-id": "comcast", "trust": 0})
+	sky.SetClaims(map[string]interface{}{"partner-id": "sky", "trust": 1000})
+
+	newDevice := func(m *device.Metadata) device.Interface {
+		d := new(device.MockDevice)
+		// nolint: typecheck
+		d.On("Metadata").Return(m)
+		return d
+	}
+
+	fg.SetFilter("partner-id", []interface{}{"comcast"})
+	e, err := ParseExpression("trust >= 1000")
+	require.NoError(err)
+	fg.SetExpression("trusted", e)
+
+	// Check refuses devices just as AllowConnection does, but records nothing
+	allowed, result := fg.Check(newDevice(comcast))
+	assert.False(allowed)
+	assert.Equal("partner-id", result.Key)
+	allowed, _ = fg.Check(newDevice(sky))
+	assert.False(allowed)
+	assert.Empty(fg.Hits())
+
+	before := time.Now()
+	fg.AllowConnection(newDevice(comcast))
+	fg.AllowConnection(newDevice(comcast))
+	fg.AllowConnection(newDevice(sky))
+
+	hits := fg.Hits()
+	require.Len(hits, 2)
+	assert.Equal(2, hits["partner-id"].Count)
+	assert.Equal(map[string]int{"comcast": 2}, hits["partner-id"].Values)
+	assert.False(hits["partner-id"].LastMatched.Before(before))
+	assert.Equal(1, hits["trusted"].Count)
+	assert.Empty(hits["trusted"].Values)
+
+	provider.Assert(t, FilterHitCounter, FilterKeyLabel, "partner-id", FilterValueLabel, "comcast")(xmetricstest.Value(2.0))
+	provider.Assert(t, FilterHitCounter, FilterKeyLabel, "trusted", FilterValueLabel, "")(xmetricstest.Value(1.0))
+
+	// refusals by the allow-list as a whole are recorded separately
+	fg.DeleteFilter("partner-id")
+	fg.DeleteFilter("trusted")
+	fg.SetFilter("partner-id", []interface{}{"sky"})
+	require.NoError(fg.SetFilterMode("partner-id", AllowMode))
+	fg.AllowConnection(newDevice(comcast))
+	fg.AllowConnection(newDevice(sky))
+
+	hits = fg.Hits()
+	assert.Equal(1, hits[allowListLocation].Count)
+	assert.Equal(2, hits["partner-id"].Count, "deleted filters keep their hits")
+
+	// the copy returned by Hits is not affected by later hits
+	hits["partner-id"].Values["comcast"] = 100
+	assert.Equal(2, fg.Hits()["partner-id"].Values["comcast"])
+}
+
 func TestGetSetFilter(t *testing.T) {
 	assert := assert.New(t)
 	fg := FilterGate{
@@ -174,6 +404,137 @@ func TestDeleteFilter(t *testing.T) {
 	}
 }
 
+func TestReplace(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		fg = FilterGate{
+			FilterStore: make(FilterStore),
+		}
+	)
+
+	expires := time.Now().Add(time.Hour)
+	fg.SetFilter("old", []interface{}{"value"})
+	fg.SetFilterExpiry("old", expires)
+	require.NoError(fg.Replace([]FilterRequest{
+		{Key: "partner-id", Values: []interface{}{"comcast"}, Mode: AllowMode, Expires: &expires},
+		{Key: "model", Expression: json.RawMessage(`"convey.hw-model == TG1682"`)},
+	}))
+
+	_, ok := fg.GetFilter("old")
+	assert.False(ok)
+
+	set, ok := fg.GetFilter("partner-id")
+	require.True(ok)
+	assert.True(set.Has("comcast"))
+	assert.Equal(AllowMode, fg.GetFilterMode("partner-id"))
+	_, ok = fg.GetFilterExpiry("old")
+	assert.False(ok)
+	actual, ok := fg.GetFilterExpiry("partner-id")
+	assert.True(ok)
+	assert.True(expires.Equal(actual))
+
+	e, ok := fg.GetExpression("model")
+	require.True(ok)
+	assert.Equal(`convey.hw-model == "TG1682"`, e.String())
+	assert.Equal(DenyMode, fg.GetFilterMode("model"))
+
+	assert.Error(fg.Replace([]FilterRequest{{Key: "bad", Expression: json.RawMessage(`"partner-id =="`)}}))
+	assert.Error(fg.Replace([]FilterRequest{{Key: "bad", Values: []interface{}{"x"}, Mode: "maybe"}}))
+
+	_, ok = fg.GetFilter("partner-id")
+	assert.True(ok, "a failed replace must leave the gate unchanged")
+}
+
+func TestUpdateFilter(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		fg      FilterGate
+		expires = time.Now().Add(time.Hour)
+	)
+
+	old, created, err := fg.UpdateFilter(FilterRequest{Key: "partner-id", Values: []interface{}{"comcast"}, Mode: AllowMode, Expires: &expires})
+	require.NoError(err)
+	assert.Nil(old)
+	assert.True(created)
+	assert.Equal(AllowMode, fg.GetFilterMode("partner-id"))
+	actual, ok := fg.GetFilterExpiry("partner-id")
+	assert.True(ok)
+	assert.True(expires.Equal(actual))
+
+	// every part of the key is replaced, including the mode and expiry the update leaves out
+	old, created, err = fg.UpdateFilter(FilterRequest{Key: "partner-id", Values: []interface{}{"sky"}})
+	require.NoError(err)
+	assert.False(created)
+	require.Implements((*Set)(nil), old)
+	assert.True(old.(Set).Has("comcast"))
+	assert.Equal(DenyMode, fg.GetFilterMode("partner-id"))
+	_, ok = fg.GetFilterExpiry("partner-id")
+	assert.False(ok)
+
+	set, ok := fg.GetFilter("partner-id")
+	require.True(ok)
+	assert.True(set.Has("sky"))
+	assert.False(set.Has("comcast"))
+
+	old, created, err = fg.UpdateFilter(FilterRequest{Key: "partner-id", Expression: json.RawMessage(`"partner-id == comcast"`)})
+	require.NoError(err)
+	assert.False(created)
+	assert.True(old.(Set).Has("sky"))
+	_, ok = fg.GetFilter("partner-id")
+	assert.False(ok)
+
+	old, _, err = fg.UpdateFilter(FilterRequest{Key: "partner-id", Values: []interface{}{"sky"}})
+	require.NoError(err)
+	require.Implements((*Expression)(nil), old)
+	assert.Equal(`partner-id == "comcast"`, old.(Expression).String())
+
+	// an invalid filter leaves the gate unchanged
+	_, _, err = fg.UpdateFilter(FilterRequest{Key: "partner-id", Values: []interface{}{"comcast"}, Mode: "maybe"})
+	assert.ErrorIs(err, ErrInvalidFilterMode)
+	_, _, err = fg.UpdateFilter(FilterRequest{Key: "partner-id", Expression: json.RawMessage(`"partner-id =="`)})
+	assert.Error(err)
+
+	set, ok = fg.GetFilter("partner-id")
+	require.True(ok)
+	assert.True(set.Has("sky"))
+}
+
+func TestRemoveFilter(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		fg = FilterGate{FilterStore: make(FilterStore)}
+	)
+
+	e, err := ParseExpression("partner-id == comcast")
+	require.NoError(err)
+	fg.SetExpression("partner-id", e)
+	require.NoError(fg.SetFilterMode("partner-id", AllowMode))
+	fg.SetFilterExpiry("partner-id", time.Now().Add(time.Hour))
+
+	old, ok := fg.RemoveFilter("partner-id")
+	assert.True(ok)
+	assert.Equal(e, old)
+	assert.Equal(DenyMode, fg.GetFilterMode("partner-id"))
+	_, ok = fg.GetFilterExpiry("partner-id")
+	assert.False(ok)
+
+	fg.SetFilter("model", []interface{}{"TG1682"})
+	old, ok = fg.RemoveFilter("model")
+	assert.True(ok)
+	require.Implements((*Set)(nil), old)
+	assert.True(old.(Set).Has("TG1682"))
+
+	old, ok = fg.RemoveFilter("model")
+	assert.False(ok)
+	assert.Nil(old)
+}
+
 func TestGetAllowedFilters(t *testing.T) {
 	assert := assert.New(t)
 
@@ -323,7 +684,7 @@ func TestMetadataMatch(t *testing.T) {
 				tc.filterKey: tc.filterValues,
 			})
 
-			match, result := fs.metadataMatch(tc.filterKey, tc.filterValues, m)
+			match, result, _ := fs.metadataMatch(tc.filterKey, tc.filterValues, m)
 			assert.Equal(tc.expectedMatch, match)
 			assert.Equal(tc.expectedMatchResult, result)
 		})
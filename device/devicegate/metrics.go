@@ -0,0 +1,30 @@
+package devicegate
+
+import (
+	// nolint:staticcheck
+	"github.com/xmidt-org/webpa-common/v2/xmetrics"
+)
+
+const (
+	FilterExpiredCounter = "gate_filter_expired_count"
+	FilterHitCounter     = "gate_filter_hit_count"
+
+	FilterKeyLabel   = "key"
+	FilterValueLabel = "value"
+)
+
+// Metrics is the module function that adds the devicegate metrics
+func Metrics() []xmetrics.Metric {
+	return []xmetrics.Metric{
+		{
+			Name:       FilterExpiredCounter,
+			Type:       "counter",
+			LabelNames: []string{FilterKeyLabel},
+		},
+		{
+			Name:       FilterHitCounter,
+			Type:       "counter",
+			LabelNames: []string{FilterKeyLabel, FilterValueLabel},
+		},
+	}
+}
@@ -1,10 +1,18 @@
 package devicegate
 
 import (
+	"time"
+
 	"github.com/stretchr/testify/mock"
 	"github.com/xmidt-org/webpa-common/v2/device"
 )
 
+// basicGate exposes only the Interface methods of a gate, like implementations that predate
+// ExpressionGate, ModeGate, ExpiryGate, and HitReporter
+type basicGate struct {
+	Interface
+}
+
 type mockDeviceGate struct {
 	mock.Mock
 }
@@ -35,6 +43,51 @@ func (m *mockDeviceGate) DeleteFilter(key string) bool {
 	return args.Bool(0)
 }
 
+func (m *mockDeviceGate) VisitExpressions(visit func(string, Expression) bool) int {
+	// nolint: typecheck
+	args := m.Called(visit)
+	return args.Int(0)
+}
+
+func (m *mockDeviceGate) GetExpression(key string) (Expression, bool) {
+	// nolint: typecheck
+	args := m.Called(key)
+	e, _ := args.Get(0).(Expression)
+	return e, args.Bool(1)
+}
+
+func (m *mockDeviceGate) SetExpression(key string, e Expression) (Expression, bool) {
+	// nolint: typecheck
+	args := m.Called(key, e)
+	old, _ := args.Get(0).(Expression)
+	return old, args.Bool(1)
+}
+
+func (m *mockDeviceGate) GetFilterMode(key string) FilterMode {
+	// nolint: typecheck
+	args := m.Called(key)
+	mode, _ := args.Get(0).(FilterMode)
+	return mode
+}
+
+func (m *mockDeviceGate) SetFilterMode(key string, mode FilterMode) error {
+	// nolint: typecheck
+	args := m.Called(key, mode)
+	return args.Error(0)
+}
+
+func (m *mockDeviceGate) GetFilterExpiry(key string) (time.Time, bool) {
+	// nolint: typecheck
+	args := m.Called(key)
+	expires, _ := args.Get(0).(time.Time)
+	return expires, args.Bool(1)
+}
+
+func (m *mockDeviceGate) SetFilterExpiry(key string, expires time.Time) {
+	// nolint: typecheck
+	m.Called(key, expires)
+}
+
 func (m *mockDeviceGate) GetAllowedFilters() (Set, bool) {
 	// nolint: typecheck
 	args := m.Called()
@@ -42,6 +95,13 @@ func (m *mockDeviceGate) GetAllowedFilters() (Set, bool) {
 	return set, args.Bool(1)
 }
 
+func (m *mockDeviceGate) Hits() map[string]FilterHits {
+	// nolint: typecheck
+	args := m.Called()
+	hits, _ := args.Get(0).(map[string]FilterHits)
+	return hits
+}
+
 func (m *mockDeviceGate) AllowConnection(d device.Interface) (bool, device.MatchResult) {
 	// nolint: typecheck
 	args := m.Called(d)
@@ -55,3 +115,13 @@ func (m *mockDeviceGate) MarshalJSON() ([]byte, error) {
 	json, _ := args.Get(0).([]byte)
 	return json, args.Error(1)
 }
+
+type mockEnforcer struct {
+	mock.Mock
+}
+
+func (m *mockEnforcer) Enforce(f FilterRequest, gate device.Filter, rate int, tick time.Duration) error {
+	// nolint: typecheck
+	args := m.Called(f, gate, rate, tick)
+	return args.Error(0)
+}
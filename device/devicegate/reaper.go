@@ -0,0 +1,170 @@
+package devicegate
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/provider"
+	"go.uber.org/zap"
+)
+
+// DefaultReapInterval is how often a Reaper looks for expired filters when no interval is supplied
+const DefaultReapInterval = time.Minute
+
+var (
+	ErrReaperStarted   = errors.New("the filter reaper has already been started")
+	ErrReaperNotActive = errors.New("the filter reaper has not been started")
+)
+
+// ReaperOption configures a Reaper
+type ReaperOption func(*Reaper)
+
+// WithReapInterval sets how often expired filters are removed.  A nonpositive interval uses DefaultReapInterval.
+func WithReapInterval(i time.Duration) ReaperOption {
+	return func(r *Reaper) {
+		if i > 0 {
+			r.interval = i
+		} else {
+			r.interval = DefaultReapInterval
+		}
+	}
+}
+
+// WithReaperLogger sets the logger used to report expired filters
+func WithReaperLogger(l *zap.Logger) ReaperOption {
+	return func(r *Reaper) {
+		if l != nil {
+			r.logger = l
+		} else {
+			r.logger = zap.NewNop()
+		}
+	}
+}
+
+// WithReaperSyncer removes expired filters through a Syncer, so that they are also removed from its Store.
+// The Syncer must have been created for the Reaper's gate.
+func WithReaperSyncer(s *Syncer) ReaperOption {
+	return func(r *Reaper) {
+		r.syncer = s
+	}
+}
+
+// WithReaperMetricsProvider configures the metrics subsystem used to count expired filters.  A nil provider
+// discards all metrics.
+func WithReaperMetricsProvider(p provider.Provider) ReaperOption {
+	return func(r *Reaper) {
+		if p == nil {
+			p = provider.NewDiscardProvider()
+		}
+
+		r.expired = p.NewCounter(FilterExpiredCounter)
+	}
+}
+
+// Reaper periodically removes expired filters from a FilterGate.  Expired filters stop affecting devices as soon
+// as they expire, whether or not they have been reaped, so the interval only controls how long they remain visible.
+type Reaper struct {
+	gate     *FilterGate
+	syncer   *Syncer
+	interval time.Duration
+	logger   *zap.Logger
+	expired  metrics.Counter
+	now      func() time.Time
+
+	controlLock sync.Mutex
+	shutdown    chan struct{}
+	done        chan struct{}
+}
+
+// NewReaper creates a Reaper for the given gate
+func NewReaper(gate *FilterGate, o ...ReaperOption) (*Reaper, error) {
+	if gate == nil {
+		return nil, ErrNoFilterGate
+	}
+
+	r := &Reaper{
+		gate:     gate,
+		interval: DefaultReapInterval,
+		logger:   zap.NewNop(),
+		expired:  provider.NewDiscardProvider().NewCounter(FilterExpiredCounter),
+		now:      time.Now,
+	}
+
+	for _, f := range o {
+		f(r)
+	}
+
+	return r, nil
+}
+
+// Reap removes the filters that have expired and returns their keys
+func (r *Reaper) Reap() ([]string, error) {
+	var (
+		now     = r.now()
+		deleted []string
+		err     error
+	)
+
+	if r.syncer != nil {
+		deleted, err = r.syncer.DeleteExpired(now)
+	} else {
+		deleted = r.gate.DeleteExpired(now)
+	}
+
+	for _, key := range deleted {
+		r.logger.Info("gate filter expired", zap.String("key", key))
+		r.expired.With(FilterKeyLabel, key).Add(1.0)
+	}
+
+	return deleted, err
+}
+
+// Start begins removing expired filters in the background
+func (r *Reaper) Start() error {
+	r.controlLock.Lock()
+	defer r.controlLock.Unlock()
+
+	if r.shutdown != nil {
+		return ErrReaperStarted
+	}
+
+	r.shutdown = make(chan struct{})
+	r.done = make(chan struct{})
+	go r.run(r.shutdown, r.done)
+	return nil
+}
+
+// Stop halts the background removal of expired filters and waits for it to finish
+func (r *Reaper) Stop() error {
+	r.controlLock.Lock()
+	defer r.controlLock.Unlock()
+
+	if r.shutdown == nil {
+		return ErrReaperNotActive
+	}
+
+	close(r.shutdown)
+	<-r.done
+	r.shutdown, r.done = nil, nil
+	return nil
+}
+
+func (r *Reaper) run(shutdown <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-shutdown:
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := r.Reap(); err != nil {
+			r.logger.Error("unable to remove expired gate filters", zap.Error(err))
+		}
+	}
+}
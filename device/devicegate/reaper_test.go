@@ -0,0 +1,137 @@
+package devicegate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/webpa-common/v2/xmetrics/xmetricstest"
+)
+
+func testNewReaperMissing(t *testing.T) {
+	assert := assert.New(t)
+	r, err := NewReaper(nil)
+	assert.Nil(r)
+	assert.Equal(ErrNoFilterGate, err)
+}
+
+func testReaperReap(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		provider = xmetricstest.NewProvider(nil, Metrics)
+		now      = time.Now()
+
+		gate = &FilterGate{FilterStore: make(FilterStore)}
+	)
+
+	gate.SetFilter("expired", []interface{}{"value"})
+	gate.SetFilterExpiry("expired", now.Add(-time.Minute))
+	gate.SetFilter("active", []interface{}{"value"})
+	gate.SetFilterExpiry("active", now.Add(time.Hour))
+	gate.SetFilter("permanent", []interface{}{"value"})
+
+	r, err := NewReaper(gate, WithReaperMetricsProvider(provider), WithReaperLogger(nil), WithReapInterval(0))
+	require.NoError(err)
+	r.now = func() time.Time { return now }
+
+	provider.Expect(FilterExpiredCounter, FilterKeyLabel, "expired")(xmetricstest.Counter, xmetricstest.Value(1.0))
+	deleted, err := r.Reap()
+	require.NoError(err)
+	assert.Equal([]string{"expired"}, deleted)
+	provider.AssertExpectations(t)
+
+	_, ok := gate.GetFilter("expired")
+	assert.False(ok)
+	_, ok = gate.GetFilterExpiry("expired")
+	assert.False(ok)
+	_, ok = gate.GetFilter("active")
+	assert.True(ok)
+	_, ok = gate.GetFilter("permanent")
+	assert.True(ok)
+}
+
+func testReaperSyncer(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		store   = NewMemoryStore()
+		now     = time.Now()
+		past    = now.Add(-time.Minute)
+		future  = now.Add(time.Hour)
+
+		gate, s = newTestSyncer(t, store)
+	)
+
+	_, _, err := s.SetFilter(FilterRequest{Key: "expired", Values: []interface{}{"value"}, Expires: &past})
+	require.NoError(err)
+	_, _, err = s.SetFilter(FilterRequest{Key: "active", Values: []interface{}{"value"}, Expires: &future})
+	require.NoError(err)
+
+	r, err := NewReaper(gate, WithReaperSyncer(s))
+	require.NoError(err)
+
+	deleted, err := r.Reap()
+	require.NoError(err)
+	assert.Equal([]string{"expired"}, deleted)
+
+	snapshot, err := store.Load()
+	require.NoError(err)
+	assert.Equal(-1, snapshot.Find("expired"))
+	assert.Equal(0, snapshot.Find("active"))
+
+	_, ok := gate.GetFilter("expired")
+	assert.False(ok)
+	expires, ok := gate.GetFilterExpiry("active")
+	assert.True(ok)
+	assert.True(future.Equal(expires))
+
+	// nothing left to reap means nothing is saved
+	deleted, err = r.Reap()
+	require.NoError(err)
+	assert.Empty(deleted)
+	assert.Equal(uint64(3), s.Version())
+
+	_, failing := newTestSyncer(t, new(failingStore))
+	r, err = NewReaper(gate, WithReaperSyncer(failing))
+	require.NoError(err)
+	_, err = r.Reap()
+	assert.Error(err)
+}
+
+func testReaperStartStop(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		gate    = &FilterGate{FilterStore: make(FilterStore)}
+	)
+
+	r, err := NewReaper(gate, WithReapInterval(10*time.Millisecond))
+	require.NoError(err)
+
+	assert.Equal(ErrReaperNotActive, r.Stop())
+	require.NoError(r.Start())
+	assert.Equal(ErrReaperStarted, r.Start())
+
+	gate.SetFilter("expiring", []interface{}{"value"})
+	gate.SetFilterExpiry("expiring", time.Now().Add(20*time.Millisecond))
+	assert.Eventually(
+		func() bool {
+			_, ok := gate.GetFilter("expiring")
+			return !ok
+		},
+		time.Second,
+		10*time.Millisecond,
+	)
+
+	require.NoError(r.Stop())
+	assert.Equal(ErrReaperNotActive, r.Stop())
+}
+
+func TestReaper(t *testing.T) {
+	t.Run("Missing", testNewReaperMissing)
+	t.Run("Reap", testReaperReap)
+	t.Run("Syncer", testReaperSyncer)
+	t.Run("StartStop", testReaperStartStop)
+}
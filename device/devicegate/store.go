@@ -0,0 +1,191 @@
+package devicegate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrVersionConflict is returned by a Store when a snapshot is saved against a version that is no longer current
+var ErrVersionConflict = errors.New("filter snapshot version conflict")
+
+// Snapshot is the persisted form of every filter in a gate.  Version starts at 0 for a store that has never
+// been saved and is incremented by the Store on each successful Save.
+type Snapshot struct {
+	Version uint64          `json:"version"`
+	Filters []FilterRequest `json:"filters"`
+}
+
+// Find returns the index of the filter with the given key, or -1 if there is no such filter
+func (s Snapshot) Find(key string) int {
+	for i, f := range s.Filters {
+		if f.Key == key {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// copy returns a Snapshot that shares no filter slice with this one
+func (s Snapshot) copy() Snapshot {
+	c := Snapshot{Version: s.Version}
+	if s.Filters != nil {
+		c.Filters = append([]FilterRequest(nil), s.Filters...)
+	}
+
+	return c
+}
+
+// Store persists the filters of a gate so that they survive restarts and, for shared implementations, so that
+// every node in a cluster sees the same filters.  Implementations must be safe for concurrent use.
+type Store interface {
+	// Load returns the current snapshot.  A store that has never been saved returns an empty Snapshot.
+	Load() (Snapshot, error)
+
+	// Save replaces the stored snapshot, but only if s.Version is still the stored version.  Otherwise,
+	// ErrVersionConflict is returned and nothing is changed.  On success, the saved snapshot is returned
+	// with its new version.
+	Save(s Snapshot) (Snapshot, error)
+}
+
+// Watcher is an optional interface for a Store that can signal when its contents change, so that a Syncer
+// does not have to wait for its next poll
+type Watcher interface {
+	// Watch returns a channel that receives a value whenever the store changes.  Signals may be coalesced.
+	Watch() <-chan struct{}
+}
+
+// FileStore is the default Store, which keeps filters in a local JSON file.  Writes are atomic: a new file
+// is written alongside the old one and then renamed over it.  Version checks are only enforced between
+// users of the same FileStore, so a file should not be shared by several processes.
+type FileStore struct {
+	path string
+	lock sync.Mutex
+}
+
+// NewFileStore creates a FileStore which persists filters at the given path.  The file need not exist.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+func (fs *FileStore) Load() (Snapshot, error) {
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+
+	return fs.load()
+}
+
+func (fs *FileStore) load() (Snapshot, error) {
+	var s Snapshot
+	data, err := ioutil.ReadFile(fs.path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return s, err
+	}
+
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("unable to parse filter file %s: %w", fs.path, err)
+	}
+
+	return s, nil
+}
+
+func (fs *FileStore) Save(s Snapshot) (Snapshot, error) {
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+
+	current, err := fs.load()
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	if current.Version != s.Version {
+		return Snapshot{}, ErrVersionConflict
+	}
+
+	s = s.copy()
+	s.Version++
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	temp, err := ioutil.TempFile(filepath.Dir(fs.path), filepath.Base(fs.path)+".*.tmp")
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	defer os.Remove(temp.Name())
+	if _, err = temp.Write(data); err == nil {
+		err = temp.Sync()
+	}
+
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(temp.Name(), fs.path)
+	}
+
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	return s, nil
+}
+
+// MemoryStore is an in-memory Store.  A single MemoryStore shared by several gates stands in for a
+// cluster-wide backend, such as a key/value store, in tests and local development.
+type MemoryStore struct {
+	lock     sync.Mutex
+	snapshot Snapshot
+	watchers []chan struct{}
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return new(MemoryStore)
+}
+
+func (ms *MemoryStore) Load() (Snapshot, error) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+
+	return ms.snapshot.copy(), nil
+}
+
+func (ms *MemoryStore) Save(s Snapshot) (Snapshot, error) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+
+	if ms.snapshot.Version != s.Version {
+		return Snapshot{}, ErrVersionConflict
+	}
+
+	ms.snapshot = s.copy()
+	ms.snapshot.Version++
+	for _, w := range ms.watchers {
+		select {
+		case w <- struct{}{}:
+		default:
+		}
+	}
+
+	return ms.snapshot.copy(), nil
+}
+
+func (ms *MemoryStore) Watch() <-chan struct{} {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+
+	w := make(chan struct{}, 1)
+	ms.watchers = append(ms.watchers, w)
+	return w
+}
@@ -0,0 +1,113 @@
+package devicegate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testStoreVersioning(t *testing.T, s Store) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	initial, err := s.Load()
+	require.NoError(err)
+	assert.Zero(initial.Version)
+	assert.Empty(initial.Filters)
+
+	initial.Filters = append(initial.Filters, FilterRequest{Key: "partner-id", Values: []interface{}{"comcast"}})
+	saved, err := s.Save(initial)
+	require.NoError(err)
+	assert.Equal(uint64(1), saved.Version)
+
+	loaded, err := s.Load()
+	require.NoError(err)
+	assert.Equal(saved, loaded)
+
+	// a writer that still holds version 0 must not clobber the saved filters
+	stale := Snapshot{Filters: []FilterRequest{{Key: "other", Values: []interface{}{"x"}}}}
+	_, err = s.Save(stale)
+	assert.Equal(ErrVersionConflict, err)
+
+	loaded, err = s.Load()
+	require.NoError(err)
+	assert.Equal(uint64(1), loaded.Version)
+	assert.Equal(0, loaded.Find("partner-id"))
+	assert.Equal(-1, loaded.Find("other"))
+}
+
+func TestFileStore(t *testing.T) {
+	t.Run("Versioning", func(t *testing.T) {
+		testStoreVersioning(t, NewFileStore(filepath.Join(t.TempDir(), "filters.json")))
+	})
+
+	t.Run("Restart", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+			path    = filepath.Join(t.TempDir(), "filters.json")
+		)
+
+		_, err := NewFileStore(path).Save(Snapshot{Filters: []FilterRequest{{Key: "partner-id", Values: []interface{}{"comcast"}}}})
+		require.NoError(err)
+
+		loaded, err := NewFileStore(path).Load()
+		require.NoError(err)
+		assert.Equal(uint64(1), loaded.Version)
+		assert.Equal([]FilterRequest{{Key: "partner-id", Values: []interface{}{"comcast"}}}, loaded.Filters)
+
+		entries, err := ioutil.ReadDir(filepath.Dir(path))
+		require.NoError(err)
+		assert.Len(entries, 1, "no temporary files should be left behind")
+	})
+
+	t.Run("Corrupt", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+			path    = filepath.Join(t.TempDir(), "filters.json")
+		)
+
+		require.NoError(os.WriteFile(path, []byte("not json"), 0600))
+		_, err := NewFileStore(path).Load()
+		assert.Error(err)
+
+		_, err = NewFileStore(path).Save(Snapshot{})
+		assert.Error(err)
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	t.Run("Versioning", func(t *testing.T) {
+		testStoreVersioning(t, NewMemoryStore())
+	})
+
+	t.Run("Watch", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+			s       = NewMemoryStore()
+			first   = s.Watch()
+			second  = s.Watch()
+		)
+
+		_, err := s.Save(Snapshot{})
+		require.NoError(err)
+		_, err = s.Save(Snapshot{Version: 1})
+		require.NoError(err)
+
+		for _, w := range []<-chan struct{}{first, second} {
+			select {
+			case <-w:
+			default:
+				assert.Fail("a watcher was not signaled")
+			}
+		}
+	})
+}
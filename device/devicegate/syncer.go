@@ -0,0 +1,323 @@
+package devicegate
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultSyncInterval is how often a Syncer polls its Store when no interval is supplied
+const DefaultSyncInterval = 30 * time.Second
+
+// maxSaveAttempts bounds how many times a Syncer retries a change that loses a version race
+const maxSaveAttempts = 10
+
+var (
+	ErrNoFilterGate    = errors.New("a filter gate is required")
+	ErrNoFilterStore   = errors.New("a filter store is required")
+	ErrSyncerStarted   = errors.New("the filter syncer has already been started")
+	ErrSyncerNotActive = errors.New("the filter syncer has not been started")
+)
+
+// SyncerOption configures a Syncer
+type SyncerOption func(*Syncer)
+
+// WithSyncInterval sets how often the Store is polled for changes made elsewhere.  A nonpositive
+// interval uses DefaultSyncInterval.
+func WithSyncInterval(i time.Duration) SyncerOption {
+	return func(s *Syncer) {
+		if i > 0 {
+			s.interval = i
+		} else {
+			s.interval = DefaultSyncInterval
+		}
+	}
+}
+
+// WithSyncLogger sets the logger used for background synchronization errors
+func WithSyncLogger(l *zap.Logger) SyncerOption {
+	return func(s *Syncer) {
+		if l != nil {
+			s.logger = l
+		} else {
+			s.logger = zap.NewNop()
+		}
+	}
+}
+
+// Syncer keeps a FilterGate in step with a Store.  Changes made through a Syncer are written to the Store
+// first and only then applied to the gate, so every gate sharing the Store converges on the same filters.
+// Each change is a compare-and-swap against the Store's version and is retried against the latest
+// snapshot when another writer got there first, so concurrent updates to different keys never clobber
+// each other.
+type Syncer struct {
+	gate     *FilterGate
+	store    Store
+	interval time.Duration
+	logger   *zap.Logger
+
+	lock    sync.Mutex
+	version uint64
+
+	controlLock sync.Mutex
+	shutdown    chan struct{}
+	done        chan struct{}
+}
+
+// NewSyncer creates a Syncer for the given gate and store.  The Syncer does not touch the gate until
+// Load or Start is called.
+func NewSyncer(gate *FilterGate, store Store, o ...SyncerOption) (*Syncer, error) {
+	if gate == nil {
+		return nil, ErrNoFilterGate
+	}
+
+	if store == nil {
+		return nil, ErrNoFilterStore
+	}
+
+	s := &Syncer{
+		gate:     gate,
+		store:    store,
+		interval: DefaultSyncInterval,
+		logger:   zap.NewNop(),
+	}
+
+	for _, f := range o {
+		f(s)
+	}
+
+	return s, nil
+}
+
+// Version returns the version of the Store's snapshot that was last applied to the gate
+func (s *Syncer) Version() uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.version
+}
+
+// Load reads the Store and applies its filters to the gate if they are newer than what the gate has.
+// A Store that has never been saved is first seeded with the gate's existing filters, e.g. those from configuration.
+func (s *Syncer) Load() error {
+	return s.update(func(*Snapshot) bool { return false })
+}
+
+// seed fills a snapshot of a Store that has never been saved with the gate's current filters, so that the first
+// change saved does not discard them.  It returns true if the snapshot was changed.
+func (s *Syncer) seed(snapshot *Snapshot) (bool, error) {
+	if snapshot.Version > 0 || len(snapshot.Filters) > 0 {
+		return false, nil
+	}
+
+	filters, err := s.gate.Filters()
+	if err != nil {
+		return false, err
+	}
+
+	snapshot.Filters = filters
+	return len(filters) > 0, nil
+}
+
+// apply replaces the gate's filters with a snapshot, unless a snapshot at least as new was already applied
+func (s *Syncer) apply(snapshot Snapshot) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if snapshot.Version <= s.version {
+		return nil
+	}
+
+	if err := s.gate.Replace(snapshot.Filters); err != nil {
+		return err
+	}
+
+	s.version = snapshot.Version
+	return nil
+}
+
+// update applies a change to the latest snapshot, saves it, and applies the result to the gate.
+// The change function returns false if it made no change, in which case nothing is saved.
+func (s *Syncer) update(change func(*Snapshot) bool) error {
+	for attempt := 0; attempt < maxSaveAttempts; attempt++ {
+		snapshot, err := s.store.Load()
+		if err != nil {
+			return err
+		}
+
+		seeded, err := s.seed(&snapshot)
+		if err != nil {
+			return err
+		}
+
+		if changed := change(&snapshot); !changed && !seeded {
+			return s.apply(snapshot)
+		}
+
+		saved, err := s.store.Save(snapshot)
+		if errors.Is(err, ErrVersionConflict) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		return s.apply(saved)
+	}
+
+	return ErrVersionConflict
+}
+
+// SetFilter stores a filter, replacing any filter with the same key.  It returns the Set or Expression the key
+// held beforehand, or nil, and true if the key is new.  The filter is validated before it is saved.
+func (s *Syncer) SetFilter(fr FilterRequest) (interface{}, bool, error) {
+	if err := new(FilterGate).Replace([]FilterRequest{fr}); err != nil {
+		return nil, false, err
+	}
+
+	var (
+		old     interface{}
+		created bool
+	)
+
+	err := s.update(func(snapshot *Snapshot) bool {
+		i := snapshot.Find(fr.Key)
+		if created = i < 0; created {
+			old = nil
+			snapshot.Filters = append(snapshot.Filters, fr)
+		} else {
+			old = storedFilter(snapshot.Filters[i])
+			snapshot.Filters[i] = fr
+		}
+
+		return true
+	})
+
+	return old, created, err
+}
+
+// DeleteFilter removes the filter with the given key.  It returns the Set or Expression the key held, or nil,
+// and true if the key existed.
+func (s *Syncer) DeleteFilter(key string) (interface{}, bool, error) {
+	var (
+		old     interface{}
+		deleted bool
+	)
+
+	err := s.update(func(snapshot *Snapshot) bool {
+		old = nil
+		i := snapshot.Find(key)
+		if deleted = i >= 0; deleted {
+			old = storedFilter(snapshot.Filters[i])
+			snapshot.Filters = append(snapshot.Filters[:i], snapshot.Filters[i+1:]...)
+		}
+
+		return deleted
+	})
+
+	return old, deleted, err
+}
+
+// storedFilter returns the Set or Expression held by a filter in a Snapshot, or nil if it holds neither
+func storedFilter(fr FilterRequest) interface{} {
+	b := newFilterBatch()
+	if err := b.add(fr); err != nil {
+		return nil
+	}
+
+	if e, ok := b.expressions[fr.Key]; ok {
+		return e
+	}
+
+	if s, ok := b.filterStore[fr.Key]; ok {
+		return s
+	}
+
+	return nil
+}
+
+// DeleteExpired removes every filter that has expired as of the given time, returning the deleted keys
+func (s *Syncer) DeleteExpired(now time.Time) ([]string, error) {
+	var deleted []string
+	err := s.update(func(snapshot *Snapshot) bool {
+		deleted = nil
+		kept := snapshot.Filters[:0]
+		for _, fr := range snapshot.Filters {
+			if fr.Expires != nil && !fr.Expires.IsZero() && !fr.Expires.After(now) {
+				deleted = append(deleted, fr.Key)
+			} else {
+				kept = append(kept, fr)
+			}
+		}
+
+		snapshot.Filters = kept
+		return len(deleted) > 0
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(deleted)
+	return deleted, nil
+}
+
+// Start loads the Store and then polls it in the background, as well as watching it if it implements Watcher
+func (s *Syncer) Start() error {
+	s.controlLock.Lock()
+	defer s.controlLock.Unlock()
+
+	if s.shutdown != nil {
+		return ErrSyncerStarted
+	}
+
+	if err := s.Load(); err != nil {
+		return err
+	}
+
+	var changes <-chan struct{}
+	if w, ok := s.store.(Watcher); ok {
+		changes = w.Watch()
+	}
+
+	s.shutdown = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.run(s.shutdown, s.done, changes)
+	return nil
+}
+
+// Stop halts background synchronization and waits for it to finish
+func (s *Syncer) Stop() error {
+	s.controlLock.Lock()
+	defer s.controlLock.Unlock()
+
+	if s.shutdown == nil {
+		return ErrSyncerNotActive
+	}
+
+	close(s.shutdown)
+	<-s.done
+	s.shutdown, s.done = nil, nil
+	return nil
+}
+
+func (s *Syncer) run(shutdown <-chan struct{}, done chan<- struct{}, changes <-chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-shutdown:
+			return
+		case <-ticker.C:
+		case <-changes:
+		}
+
+		if err := s.Load(); err != nil {
+			s.logger.Error("unable to synchronize gate filters", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package devicegate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/sallust"
+)
+
+// conflictStore is a Store whose saves always lose the version race
+type conflictStore struct {
+	MemoryStore
+	saves int
+}
+
+func (cs *conflictStore) Save(Snapshot) (Snapshot, error) {
+	cs.saves++
+	return Snapshot{}, ErrVersionConflict
+}
+
+// failingStore is a Store that cannot be read
+type failingStore struct {
+	MemoryStore
+}
+
+func (fs *failingStore) Load() (Snapshot, error) {
+	return Snapshot{}, errors.New("expected")
+}
+
+func newTestSyncer(t *testing.T, store Store, o ...SyncerOption) (*FilterGate, *Syncer) {
+	gate := &FilterGate{FilterStore: make(FilterStore)}
+	s, err := NewSyncer(gate, store, o...)
+	require.NoError(t, err)
+	require.NotNil(t, s)
+	return gate, s
+}
+
+func testNewSyncerMissing(t *testing.T) {
+	assert := assert.New(t)
+
+	s, err := NewSyncer(nil, NewMemoryStore())
+	assert.Nil(s)
+	assert.Equal(ErrNoFilterGate, err)
+
+	s, err = NewSyncer(new(FilterGate), nil)
+	assert.Nil(s)
+	assert.Equal(ErrNoFilterStore, err)
+}
+
+func testSyncerLoad(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		store   = NewMemoryStore()
+	)
+
+	gate, s := newTestSyncer(t, store)
+	gate.SetFilter("preconfigured", []interface{}{"value"})
+
+	// an empty store is seeded with the existing filters
+	require.NoError(s.Load())
+	_, ok := gate.GetFilter("preconfigured")
+	assert.True(ok)
+	assert.Equal(uint64(1), s.Version())
+
+	snapshot, err := store.Load()
+	require.NoError(err)
+	assert.Equal(Snapshot{Version: 1, Filters: []FilterRequest{{Key: "preconfigured", Values: []interface{}{"value"}}}}, snapshot)
+
+	_, err = store.Save(Snapshot{Version: 1, Filters: []FilterRequest{{Key: "partner-id", Values: []interface{}{"comcast"}}}})
+	require.NoError(err)
+	require.NoError(s.Load())
+	assert.Equal(uint64(2), s.Version())
+
+	_, ok = gate.GetFilter("preconfigured")
+	assert.False(ok)
+	_, ok = gate.GetFilter("partner-id")
+	assert.True(ok)
+
+	_, err = store.Save(Snapshot{Version: 2, Filters: []FilterRequest{{Key: "bad", Expression: []byte(`"=="`)}}})
+	require.NoError(err)
+	assert.Error(s.Load())
+	assert.Equal(uint64(2), s.Version())
+
+	_, failing := newTestSyncer(t, new(failingStore))
+	assert.Error(failing.Load())
+}
+
+func testSyncerSeed(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		store   = NewMemoryStore()
+		expires = time.Date(2999, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		gate, s = newTestSyncer(t, store)
+	)
+
+	e, err := ParseExpression("convey.hw-model == TG1682")
+	require.NoError(err)
+
+	gate.SetFilter("partner-id", []interface{}{"sky", "comcast"})
+	gate.SetExpression("model", e)
+	require.NoError(gate.SetFilterMode("model", AllowMode))
+	gate.SetFilterExpiry("model", expires)
+
+	// the first synced change keeps the filters the gate already had, without Load or Start
+	old, created, err := s.SetFilter(FilterRequest{Key: "firmware", Values: []interface{}{"1.0"}})
+	require.NoError(err)
+	assert.Nil(old)
+	assert.True(created)
+	assert.Equal(uint64(1), s.Version())
+
+	values, ok := gate.GetFilter("partner-id")
+	require.True(ok)
+	assert.True(values.Has("comcast"))
+	assert.True(values.Has("sky"))
+
+	actual, ok := gate.GetExpression("model")
+	require.True(ok)
+	assert.Equal(e.String(), actual.String())
+	assert.Equal(AllowMode, gate.GetFilterMode("model"))
+
+	actualExpires, ok := gate.GetFilterExpiry("model")
+	assert.True(ok)
+	assert.True(expires.Equal(actualExpires))
+
+	_, ok = gate.GetFilter("firmware")
+	assert.True(ok)
+
+	snapshot, err := store.Load()
+	require.NoError(err)
+	require.Len(snapshot.Filters, 3)
+	assert.Equal("model", snapshot.Filters[0].Key)
+	assert.Equal(
+		FilterRequest{Key: "partner-id", Values: []interface{}{"comcast", "sky"}},
+		snapshot.Filters[1],
+	)
+	assert.Equal("firmware", snapshot.Filters[2].Key)
+
+	// a store that has already been saved is not seeded again
+	otherGate, other := newTestSyncer(t, store)
+	otherGate.SetFilter("ignored", []interface{}{"value"})
+	require.NoError(other.Start())
+	require.NoError(other.Stop())
+	_, ok = otherGate.GetFilter("ignored")
+	assert.False(ok)
+	_, ok = otherGate.GetFilter("firmware")
+	assert.True(ok)
+}
+
+func testSyncerShared(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		store   = NewMemoryStore()
+
+		firstGate, first   = newTestSyncer(t, store)
+		secondGate, second = newTestSyncer(t, store)
+	)
+
+	old, created, err := first.SetFilter(FilterRequest{Key: "partner-id", Values: []interface{}{"comcast"}})
+	require.NoError(err)
+	assert.Nil(old)
+	assert.True(created)
+
+	// the second node has not seen the first change, but its change must not clobber it
+	_, created, err = second.SetFilter(FilterRequest{Key: "model", Expression: []byte(`"convey.hw-model == TG1682"`), Mode: AllowMode})
+	require.NoError(err)
+	assert.True(created)
+	assert.Equal(uint64(2), second.Version())
+
+	_, ok := secondGate.GetFilter("partner-id")
+	assert.True(ok)
+	assert.Equal(AllowMode, secondGate.GetFilterMode("model"))
+
+	require.NoError(first.Load())
+	_, ok = firstGate.GetExpression("model")
+	assert.True(ok)
+
+	// the old filter is the one the store held, even though the first node's gate was reloaded
+	old, created, err = first.SetFilter(FilterRequest{Key: "partner-id", Values: []interface{}{"sky"}})
+	require.NoError(err)
+	require.IsType(&FilterSet{}, old)
+	assert.True(old.(Set).Has("comcast"))
+	assert.False(created)
+
+	old, deleted, err := second.DeleteFilter("partner-id")
+	require.NoError(err)
+	require.IsType(&FilterSet{}, old)
+	assert.True(old.(Set).Has("sky"))
+	assert.True(deleted)
+
+	old, deleted, err = second.DeleteFilter("partner-id")
+	require.NoError(err)
+	assert.Nil(old)
+	assert.False(deleted)
+
+	snapshot, err := store.Load()
+	require.NoError(err)
+	assert.Equal(uint64(4), snapshot.Version)
+	assert.Equal([]FilterRequest{{Key: "model", Expression: []byte(`"convey.hw-model == TG1682"`), Mode: AllowMode}}, snapshot.Filters)
+
+	old, created, err = second.SetFilter(FilterRequest{Key: "model", Values: []interface{}{"TG1682"}})
+	require.NoError(err)
+	require.Implements((*Expression)(nil), old)
+	assert.Equal(`convey.hw-model == "TG1682"`, old.(Expression).String())
+	assert.False(created)
+
+	_, _, err = first.SetFilter(FilterRequest{Key: "bad", Values: []interface{}{"x"}, Mode: "maybe"})
+	assert.Error(err)
+}
+
+func testSyncerConflict(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		store  = new(conflictStore)
+		_, s   = newTestSyncer(t, store)
+	)
+
+	_, _, err := s.SetFilter(FilterRequest{Key: "partner-id", Values: []interface{}{"comcast"}})
+	assert.Equal(ErrVersionConflict, err)
+	assert.Equal(maxSaveAttempts, store.saves)
+}
+
+func testSyncerStartStop(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		store   = NewMemoryStore()
+
+		gate, s = newTestSyncer(t, store, WithSyncInterval(time.Hour), WithSyncLogger(nil))
+	)
+
+	assert.Equal(ErrSyncerNotActive, s.Stop())
+	require.NoError(s.Start())
+	assert.Equal(ErrSyncerStarted, s.Start())
+
+	// the store's watch signal, rather than the hourly poll, must pick up this change
+	_, err := store.Save(Snapshot{Filters: []FilterRequest{{Key: "partner-id", Values: []interface{}{"comcast"}}}})
+	require.NoError(err)
+	assert.Eventually(
+		func() bool {
+			_, ok := gate.GetFilter("partner-id")
+			return ok
+		},
+		time.Second,
+		10*time.Millisecond,
+	)
+
+	require.NoError(s.Stop())
+	assert.Equal(ErrSyncerNotActive, s.Stop())
+
+	_, failing := newTestSyncer(t, new(failingStore))
+	assert.Error(failing.Start())
+}
+
+func testSyncerHandler(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		ctx     = sallust.With(context.Background(), sallust.Default())
+		store   = NewMemoryStore()
+
+		gate, s = newTestSyncer(t, store)
+		f       = FilterHandler{Gate: gate, Syncer: s}
+	)
+
+	response := httptest.NewRecorder()
+	f.UpdateFilters(response, httptest.NewRequest("POST", "/", bytes.NewBufferString(`{"key": "partner-id", "values": ["comcast"]}`)).WithContext(ctx))
+	assert.Equal(http.StatusCreated, response.Code)
+
+	_, ok := gate.GetFilter("partner-id")
+	assert.True(ok)
+
+	snapshot, err := store.Load()
+	require.NoError(err)
+	assert.Equal(0, snapshot.Find("partner-id"))
+
+	response = httptest.NewRecorder()
+	f.DeleteFilter(response, httptest.NewRequest("DELETE", "/", bytes.NewBufferString(`{"key": "partner-id"}`)).WithContext(ctx))
+	assert.Equal(http.StatusOK, response.Code)
+
+	_, ok = gate.GetFilter("partner-id")
+	assert.False(ok)
+
+	// a ttl is saved as an absolute expiry, so that restarts do not extend it
+	response = httptest.NewRecorder()
+	f.UpdateFilters(response, httptest.NewRequest("POST", "/", bytes.NewBufferString(`{"key": "partner-id", "values": ["comcast"], "ttl": "1h"}`)).WithContext(ctx))
+	assert.Equal(http.StatusCreated, response.Code)
+
+	snapshot, err = store.Load()
+	require.NoError(err)
+	require.Len(snapshot.Filters, 1)
+	assert.Empty(snapshot.Filters[0].TTL)
+	require.NotNil(snapshot.Filters[0].Expires)
+	assert.WithinDuration(time.Now().Add(time.Hour), *snapshot.Filters[0].Expires, time.Minute)
+
+	_, ok = gate.GetFilterExpiry("partner-id")
+	assert.True(ok)
+
+	conflicted := FilterHandler{Gate: gate}
+	_, conflicted.Syncer = newTestSyncer(t, new(conflictStore))
+	response = httptest.NewRecorder()
+	conflicted.UpdateFilters(response, httptest.NewRequest("POST", "/", bytes.NewBufferString(`{"key": "partner-id", "values": ["comcast"]}`)).WithContext(ctx))
+	assert.Equal(http.StatusConflict, response.Code)
+
+	failed := FilterHandler{Gate: gate}
+	_, failed.Syncer = newTestSyncer(t, new(failingStore))
+	response = httptest.NewRecorder()
+	failed.DeleteFilter(response, httptest.NewRequest("DELETE", "/", bytes.NewBufferString(`{"key": "partner-id"}`)).WithContext(ctx))
+	assert.Equal(http.StatusInternalServerError, response.Code)
+}
+
+func TestSyncer(t *testing.T) {
+	t.Run("Missing", testNewSyncerMissing)
+	t.Run("Load", testSyncerLoad)
+	t.Run("Seed", testSyncerSeed)
+	t.Run("Shared", testSyncerShared)
+	t.Run("Conflict", testSyncerConflict)
+	t.Run("StartStop", testSyncerStartStop)
+	t.Run("Handler", testSyncerHandler)
+}
@@ -0,0 +1,64 @@
+package devicetest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/xmidt-org/webpa-common/v2/convey"
+	"github.com/xmidt-org/webpa-common/v2/convey/conveyhttp"
+	"github.com/xmidt-org/webpa-common/v2/device"
+)
+
+var ErrAttachNotSupported = errors.New("the connector does not implement device.Attacher")
+
+// ConnectOptions describes a device attached with Connect
+type ConnectOptions struct {
+	// Pipe configures the in-memory connection
+	Pipe PipeOptions
+
+	// Metadata is the device's metadata, e.g. its claims.  If unset, empty metadata is used.
+	Metadata *device.Metadata
+
+	// Convey is encoded into the convey header presented to the manager
+	Convey convey.C
+
+	// Header holds any other headers presented to the manager
+	Header http.Header
+}
+
+// Connect attaches a device with the given ID to a connector, typically a device.Manager, over an
+// in-memory connection.  No HTTP server or websocket upgrade is involved.  The returned Conn is the
+// device's end of the connection.
+func Connect(connector device.Connector, id device.ID, o ConnectOptions) (device.Interface, *Conn, error) {
+	attacher, ok := connector.(device.Attacher)
+	if !ok {
+		return nil, nil, ErrAttachNotSupported
+	}
+
+	header := make(http.Header, len(o.Header)+1)
+	for name, values := range o.Header {
+		header[name] = append([]string(nil), values...)
+	}
+
+	if len(o.Convey) > 0 {
+		if err := conveyhttp.NewHeaderTranslator(device.ConveyHeader, nil).ToHeader(header, o.Convey); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	ctx := device.WithID(context.Background(), id)
+	if o.Metadata != nil {
+		ctx = device.WithDeviceMetadata(ctx, o.Metadata)
+	}
+
+	server, client := Pipe(o.Pipe)
+	d, err := attacher.Attach(ctx, server, header)
+	if err != nil {
+		server.Close()
+		client.Close()
+		return nil, nil, err
+	}
+
+	return d, client, nil
+}
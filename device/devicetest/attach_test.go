@@ -0,0 +1,259 @@
+package devicetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/webpa-common/v2/convey"
+	"github.com/xmidt-org/webpa-common/v2/device"
+	"github.com/xmidt-org/wrp-go/v3"
+	"go.uber.org/zap"
+)
+
+const testID device.ID = "mac:112233445566"
+
+func testConnectNotSupported(t *testing.T) {
+	d, c, err := Connect(new(device.MockConnector), testID, ConnectOptions{})
+	assert.Nil(t, d)
+	assert.Nil(t, c)
+	assert.Equal(t, ErrAttachNotSupported, err)
+}
+
+func testConnectFiltered(t *testing.T) {
+	manager := device.NewManager(&device.Options{
+		Logger: zap.NewNop(),
+		Filter: device.FilterFunc(func(device.Interface) (bool, device.MatchResult) {
+			return false, device.MatchResult{}
+		}),
+	})
+
+	d, c, err := Connect(manager, testID, ConnectOptions{})
+	assert.Nil(t, d)
+	assert.Nil(t, c)
+	assert.Equal(t, device.ErrorDeviceFilteredOut, err)
+	assert.Zero(t, manager.Len())
+}
+
+func testConnectRoute(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		events  = make(chan *device.Event, 10)
+
+		manager = device.NewManager(&device.Options{
+			Logger: zap.NewNop(),
+			Listeners: []device.Listener{
+				func(e *device.Event) {
+					if e.Type != device.MessageSent {
+						events <- e
+					}
+				},
+			},
+		})
+
+		metadata = new(device.Metadata)
+	)
+
+	metadata.SetClaims(map[string]interface{}{device.PartnerIDClaimKey: "comcast"})
+	d, c, err := Connect(manager, testID, ConnectOptions{
+		Metadata: metadata,
+		Convey:   convey.C{"hw-model": "test"},
+	})
+
+	require.NoError(err)
+	require.NotNil(d)
+	assert.Equal(testID, d.ID())
+	assert.Equal("comcast", d.Metadata().PartnerIDClaim())
+	model, _ := d.Convey().GetString("hw-model")
+	assert.Equal("test", model)
+
+	e := <-events
+	assert.Equal(device.Connect, e.Type)
+	assert.Equal(1, manager.Len())
+
+	// device to server
+	// nolint: typecheck
+	require.NoError(c.WriteWRP(&wrp.Message{Type: wrp.SimpleEventMessageType, Source: string(testID), Destination: "event:test"}))
+	e = <-events
+	assert.Equal(device.MessageReceived, e.Type)
+	// nolint: typecheck
+	assert.Equal([]string{"comcast"}, e.Message.(*wrp.Message).PartnerIDs)
+
+	// a request routed to the device, answered over the in-memory connection
+	go func() {
+		request, err := c.ReadWRP()
+		if err != nil {
+			return
+		}
+
+		// nolint: typecheck
+		c.WriteWRP(&wrp.Message{
+			Type:            wrp.SimpleRequestResponseMessageType,
+			Source:          request.Destination,
+			Destination:     request.Source,
+			TransactionUUID: request.TransactionUUID,
+			Payload:         []byte("response"),
+		})
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	response, err := manager.Route((&device.Request{
+		// nolint: typecheck
+		Message: &wrp.Message{
+			Type:            wrp.SimpleRequestResponseMessageType,
+			Source:          "dns:test",
+			Destination:     string(testID) + "/config",
+			TransactionUUID: "test-transaction",
+		},
+		// nolint: typecheck
+		Format: wrp.Msgpack,
+	}).WithContext(ctx))
+
+	require.NoError(err)
+	assert.Equal([]byte("response"), response.Message.Payload)
+	assert.Equal(device.TransactionComplete, (<-events).Type)
+
+	// server-side disconnection closes the device's end
+	assert.True(manager.Disconnect(testID, device.CloseReason{Text: "test"}))
+	assert.Equal(device.Disconnect, (<-events).Type)
+	_, _, err = c.ReadMessage()
+	require.IsType(&websocket.CloseError{}, err)
+	assert.Equal(websocket.CloseAbnormalClosure, err.(*websocket.CloseError).Code)
+	assert.True(d.Closed())
+}
+
+func testConnectPingsAndIdle(t *testing.T) {
+	var (
+		assert       = assert.New(t)
+		require      = require.New(t)
+		clock        = NewManualClock(time.Now())
+		disconnected = make(chan *device.Event, 1)
+
+		manager = device.NewManager(&device.Options{
+			Logger:     zap.NewNop(),
+			PingPeriod: time.Minute,
+			IdlePeriod: 3 * time.Minute,
+			Now:        clock.Now,
+			NewTicker:  clock.NewTicker,
+			Listeners: []device.Listener{
+				func(e *device.Event) {
+					if e.Type == device.Disconnect {
+						disconnected <- e
+					}
+				},
+			},
+		})
+
+		pings = make(chan struct{}, 10)
+		pong  = make(chan bool, 10)
+	)
+
+	_, c, err := Connect(manager, testID, ConnectOptions{Pipe: PipeOptions{Clock: clock}})
+	require.NoError(err)
+
+	c.SetPingHandler(func(data string) error {
+		pings <- struct{}{}
+		if <-pong {
+			return c.WriteControl(websocket.PongMessage, []byte(data), time.Time{})
+		}
+
+		return nil
+	})
+
+	go func() {
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	// wait for the write pump's ping ticker
+	require.True(clock.WaitForWaiters(1, 10*time.Second))
+
+	// answering a ping establishes a read deadline of IdlePeriod
+	pong <- true
+	clock.Add(time.Minute)
+	<-pings
+
+	// wait for the read pump to begin waiting on the new deadline, along with the ping ticker
+	require.True(clock.WaitForWaiters(2, 10*time.Second))
+
+	// unanswered pings leave the deadline alone, so the device is disconnected once idle
+	pong <- false
+	pong <- false
+	clock.Add(time.Minute)
+	clock.Add(time.Minute)
+	assert.Len(disconnected, 0)
+	assert.Equal(1, manager.Len())
+
+	clock.Add(time.Minute)
+	e := <-disconnected
+	assert.Equal(testID, e.Device.ID())
+	assert.Zero(manager.Len())
+}
+
+func testConnectMessageSizeLimits(t *testing.T) {
+	var (
+		assert       = assert.New(t)
+		require      = require.New(t)
+		disconnected = make(chan *device.Event, 1)
+
+		manager = device.NewManager(&device.Options{
+			Logger:                 zap.NewNop(),
+			MaxInboundFrameSize:    64,
+			MaxOutboundPayloadSize: 8,
+			Listeners: []device.Listener{
+				func(e *device.Event) {
+					if e.Type == device.Disconnect {
+						disconnected <- e
+					}
+				},
+			},
+		})
+	)
+
+	d, c, err := Connect(manager, testID, ConnectOptions{})
+	require.NoError(err)
+
+	// oversize requests are rejected before being queued for the device
+	response, err := manager.Route(&device.Request{
+		// nolint: typecheck
+		Message: &wrp.Message{
+			Type:        wrp.SimpleEventMessageType,
+			Destination: string(testID) + "/config",
+			Payload:     []byte("this payload is too large"),
+		},
+	})
+
+	assert.Nil(response)
+	assert.ErrorIs(err, device.ErrorMessageTooLarge)
+	var mtle *device.MessageTooLargeError
+	require.ErrorAs(err, &mtle)
+	assert.Equal(25, mtle.Size)
+	assert.Equal(8, mtle.Max)
+
+	// an oversize frame from the device closes the connection with 1009
+	require.NoError(c.WriteMessage(websocket.BinaryMessage, make([]byte, 65)))
+	_, _, err = c.ReadMessage()
+	require.IsType(&websocket.CloseError{}, err)
+	assert.Equal(websocket.CloseMessageTooBig, err.(*websocket.CloseError).Code)
+
+	<-disconnected
+	assert.True(d.Closed())
+	assert.Equal(device.MessageTooLargeCloseText, d.CloseReason().Text)
+	assert.ErrorIs(d.CloseReason().Err, websocket.ErrReadLimit)
+}
+
+func TestConnect(t *testing.T) {
+	t.Run("NotSupported", testConnectNotSupported)
+	t.Run("Filtered", testConnectFiltered)
+	t.Run("Route", testConnectRoute)
+	t.Run("PingsAndIdle", testConnectPingsAndIdle)
+	t.Run("MessageSizeLimits", testConnectMessageSizeLimits)
+}
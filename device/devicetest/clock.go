@@ -0,0 +1,178 @@
+package devicetest
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time for in-memory connections.  NewTimer and NewTicker return
+// a channel together with a function that stops the timer or ticker, which is the same
+// shape as device.Options.NewTicker.
+type Clock interface {
+	Now() time.Time
+	NewTimer(time.Duration) (<-chan time.Time, func())
+	NewTicker(time.Duration) (<-chan time.Time, func())
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) (<-chan time.Time, func()) {
+	t := time.NewTimer(d)
+	return t.C, func() { t.Stop() }
+}
+
+func (systemClock) NewTicker(d time.Duration) (<-chan time.Time, func()) {
+	t := time.NewTicker(d)
+	return t.C, t.Stop
+}
+
+// SystemClock returns the Clock backed by the time package
+func SystemClock() Clock {
+	return systemClock{}
+}
+
+// waiter is a pending timer or ticker on a ManualClock
+type waiter struct {
+	at     time.Time
+	period time.Duration
+	c      chan time.Time
+}
+
+// ManualClock is a Clock whose time only changes when Add or Set is called.  Timers and tickers
+// fire as time passes them.  As with the time package, a ticker that falls behind drops ticks.
+//
+// To drive a device.Manager with a ManualClock, set both device.Options.Now and
+// device.Options.NewTicker from the clock.
+type ManualClock struct {
+	lock    sync.Mutex
+	now     time.Time
+	waiters map[*waiter]bool
+	changed chan struct{}
+}
+
+// NewManualClock creates a ManualClock whose current time is the given start
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{
+		now:     start,
+		waiters: make(map[*waiter]bool),
+		changed: make(chan struct{}),
+	}
+}
+
+// Now returns the clock's current time
+func (mc *ManualClock) Now() time.Time {
+	mc.lock.Lock()
+	now := mc.now
+	mc.lock.Unlock()
+	return now
+}
+
+func (mc *ManualClock) add(w *waiter) func() {
+	mc.lock.Lock()
+	mc.waiters[w] = true
+	mc.notify()
+	mc.lock.Unlock()
+
+	return func() {
+		mc.lock.Lock()
+		delete(mc.waiters, w)
+		mc.lock.Unlock()
+	}
+}
+
+// notify wakes up any goroutines blocked in WaitForWaiters.  The lock must be held.
+func (mc *ManualClock) notify() {
+	close(mc.changed)
+	mc.changed = make(chan struct{})
+}
+
+// NewTimer creates a timer that fires once the clock reaches the current time plus d
+func (mc *ManualClock) NewTimer(d time.Duration) (<-chan time.Time, func()) {
+	w := &waiter{at: mc.Now().Add(d), c: make(chan time.Time, 1)}
+	return w.c, mc.add(w)
+}
+
+// NewTicker creates a ticker that fires each time the clock passes a multiple of d.
+// This method panics if d is not positive, as does time.NewTicker.
+func (mc *ManualClock) NewTicker(d time.Duration) (<-chan time.Time, func()) {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+
+	w := &waiter{at: mc.Now().Add(d), period: d, c: make(chan time.Time, 1)}
+	return w.c, mc.add(w)
+}
+
+// Waiters returns the number of timers and tickers that are pending
+func (mc *ManualClock) Waiters() int {
+	mc.lock.Lock()
+	n := len(mc.waiters)
+	mc.lock.Unlock()
+	return n
+}
+
+// WaitForWaiters blocks until at least n timers or tickers are pending or the timeout elapses,
+// returning true if the waiters are present.  Tests use this to avoid advancing the clock
+// before the code under test has started waiting on it.
+func (mc *ManualClock) WaitForWaiters(n int, timeout time.Duration) bool {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		mc.lock.Lock()
+		count, changed := len(mc.waiters), mc.changed
+		mc.lock.Unlock()
+
+		if count >= n {
+			return true
+		}
+
+		select {
+		case <-changed:
+		case <-deadline.C:
+			return false
+		}
+	}
+}
+
+// Add advances the clock by d, firing any timers and tickers that are due, in time order
+func (mc *ManualClock) Add(d time.Duration) {
+	mc.Set(mc.Now().Add(d))
+}
+
+// Set moves the clock to the given time, firing any timers and tickers that are due.
+// Moving the clock backwards fires nothing.
+func (mc *ManualClock) Set(t time.Time) {
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+
+	mc.now = t
+	due := make([]*waiter, 0, len(mc.waiters))
+	for w := range mc.waiters {
+		if !w.at.After(t) {
+			due = append(due, w)
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool { return due[i].at.Before(due[j].at) })
+	for _, w := range due {
+		select {
+		case w.c <- w.at:
+		default:
+			// the receiver hasn't consumed the previous tick
+		}
+
+		if w.period > 0 {
+			for !w.at.After(t) {
+				w.at = w.at.Add(w.period)
+			}
+		} else {
+			delete(mc.waiters, w)
+		}
+	}
+}
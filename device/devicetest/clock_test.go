@@ -0,0 +1,72 @@
+package devicetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSystemClock(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		clock  = SystemClock()
+	)
+
+	assert.WithinDuration(time.Now(), clock.Now(), time.Second)
+
+	timer, stop := clock.NewTimer(time.Millisecond)
+	<-timer
+	stop()
+
+	ticker, stop := clock.NewTicker(time.Millisecond)
+	<-ticker
+	<-ticker
+	stop()
+}
+
+func TestManualClock(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		start  = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		clock  = NewManualClock(start)
+	)
+
+	assert.Equal(start, clock.Now())
+	assert.Zero(clock.Waiters())
+	assert.False(clock.WaitForWaiters(1, time.Millisecond))
+
+	timer, _ := clock.NewTimer(10 * time.Second)
+	ticker, stopTicker := clock.NewTicker(3 * time.Second)
+	stopped, stop := clock.NewTimer(time.Second)
+	stop()
+
+	assert.Equal(2, clock.Waiters())
+	assert.True(clock.WaitForWaiters(2, time.Second))
+
+	clock.Add(2 * time.Second)
+	assert.Len(timer, 0)
+	assert.Len(ticker, 0)
+	assert.Len(stopped, 0)
+
+	clock.Add(time.Second)
+	assert.Equal(start.Add(3*time.Second), <-ticker)
+
+	// ticks are dropped when the receiver falls behind
+	clock.Add(7 * time.Second)
+	assert.Equal(start.Add(6*time.Second), <-ticker)
+	assert.Len(ticker, 0)
+	assert.Equal(start.Add(10*time.Second), <-timer)
+	assert.Equal(1, clock.Waiters())
+
+	clock.Set(start.Add(12 * time.Second))
+	assert.Equal(start.Add(12*time.Second), <-ticker)
+
+	// moving backwards fires nothing
+	clock.Set(start)
+	assert.Len(ticker, 0)
+
+	stopTicker()
+	assert.Zero(clock.Waiters())
+	assert.Panics(func() { clock.NewTicker(0) })
+}
@@ -0,0 +1,361 @@
+package devicetest
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/xmidt-org/webpa-common/v2/device"
+	"github.com/xmidt-org/wrp-go/v3"
+)
+
+// DefaultBufferSize is the number of frames that may be in flight in each direction of a Pipe
+// before writes block
+const DefaultBufferSize = 100
+
+// frame is a single websocket message in flight
+type frame struct {
+	messageType int
+	data        []byte
+}
+
+// Conn is one end of an in-memory websocket connection created by Pipe.  It implements
+// device.Connection, so the server end can be handed to a device.Manager.  As with gorilla
+// websockets, control frames are handled within ReadMessage: pings are answered with pongs,
+// pongs are passed to the pong handler, and a close frame results in a *websocket.CloseError.
+//
+// Reads and writes honor deadlines measured by the pipe's Clock.  A deadline that passes results
+// in os.ErrDeadlineExceeded, which is a timeout net.Error.  Operations on a closed Conn return
+// net.ErrClosed, while reading from a Conn whose peer has closed results in an abnormal closure.
+type Conn struct {
+	clock    Clock
+	peer     *Conn
+	inbound  chan frame
+	closed   chan struct{}
+	shutdown sync.Once
+
+	lock          sync.Mutex
+	readDeadline  time.Time
+	readLimit     int64
+	writeDeadline time.Time
+	pingHandler   func(string) error
+	pongHandler   func(string) error
+}
+
+var _ device.Connection = (*Conn)(nil)
+
+// PipeOptions configures the pair of connections created by Pipe
+type PipeOptions struct {
+	// Clock measures read and write deadlines.  If unset, SystemClock() is used.
+	Clock Clock
+
+	// BufferSize is the number of frames buffered in each direction.  If unset, DefaultBufferSize is used.
+	BufferSize int
+}
+
+// Pipe creates a connected pair of in-memory websocket connections.  By convention, the first
+// is the server end, given to a device.Manager, and the second is the device end.
+func Pipe(o PipeOptions) (*Conn, *Conn) {
+	if o.Clock == nil {
+		o.Clock = SystemClock()
+	}
+
+	if o.BufferSize < 1 {
+		o.BufferSize = DefaultBufferSize
+	}
+
+	var (
+		server = newConn(o)
+		client = newConn(o)
+	)
+
+	server.peer, client.peer = client, server
+	return server, client
+}
+
+func newConn(o PipeOptions) *Conn {
+	return &Conn{
+		clock:   o.Clock,
+		inbound: make(chan frame, o.BufferSize),
+		closed:  make(chan struct{}),
+	}
+}
+
+// deadline returns a channel that fires when the given deadline passes, along with its stop function.
+// A zero deadline never fires.
+func (c *Conn) deadline(t time.Time) (<-chan time.Time, func(), bool) {
+	if t.IsZero() {
+		return nil, func() {}, true
+	}
+
+	wait := t.Sub(c.clock.Now())
+	if wait <= 0 {
+		return nil, nil, false
+	}
+
+	timer, stop := c.clock.NewTimer(wait)
+	return timer, stop, true
+}
+
+func (c *Conn) isClosed() bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// ReadMessage returns the next data frame sent by the peer, handling any control frames first
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	for {
+		if c.isClosed() {
+			return 0, nil, net.ErrClosed
+		}
+
+		c.lock.Lock()
+		deadline := c.readDeadline
+		c.lock.Unlock()
+
+		timeout, stop, ok := c.deadline(deadline)
+		if !ok {
+			return 0, nil, os.ErrDeadlineExceeded
+		}
+
+		var f frame
+		select {
+		case f = <-c.inbound:
+		case <-c.closed:
+			stop()
+			return 0, nil, net.ErrClosed
+		case <-c.peer.closed:
+			stop()
+			// deliver anything the peer wrote before closing
+			select {
+			case f = <-c.inbound:
+			default:
+				return 0, nil, &websocket.CloseError{Code: websocket.CloseAbnormalClosure, Text: "unexpected EOF"}
+			}
+		case <-timeout:
+			// the deadline may have been moved while waiting, so recheck it
+			continue
+		}
+
+		stop()
+		switch f.messageType {
+		case websocket.PingMessage:
+			if err := c.handlePing(string(f.data)); err != nil {
+				return 0, nil, err
+			}
+
+		case websocket.PongMessage:
+			if err := c.handlePong(string(f.data)); err != nil {
+				return 0, nil, err
+			}
+
+		case websocket.CloseMessage:
+			return 0, nil, closeError(f.data)
+
+		default:
+			c.lock.Lock()
+			limit := c.readLimit
+			c.lock.Unlock()
+
+			if limit > 0 && int64(len(f.data)) > limit {
+				// mimic gorilla, which tells the peer why it is being disconnected
+				c.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseMessageTooBig, ""), time.Time{}) // nolint: errcheck
+				return 0, nil, websocket.ErrReadLimit
+			}
+
+			return f.messageType, f.data, nil
+		}
+	}
+}
+
+// closeError parses the payload of a close frame
+func closeError(data []byte) *websocket.CloseError {
+	if len(data) < 2 {
+		return &websocket.CloseError{Code: websocket.CloseNoStatusReceived}
+	}
+
+	return &websocket.CloseError{
+		Code: int(binary.BigEndian.Uint16(data)),
+		Text: string(data[2:]),
+	}
+}
+
+func (c *Conn) handlePing(data string) error {
+	c.lock.Lock()
+	h := c.pingHandler
+	c.lock.Unlock()
+
+	if h != nil {
+		return h(data)
+	}
+
+	err := c.WriteControl(websocket.PongMessage, []byte(data), time.Time{})
+	if err == net.ErrClosed {
+		return nil
+	}
+
+	return err
+}
+
+func (c *Conn) handlePong(data string) error {
+	c.lock.Lock()
+	h := c.pongHandler
+	c.lock.Unlock()
+
+	if h != nil {
+		return h(data)
+	}
+
+	return nil
+}
+
+// SetReadDeadline sets the deadline for ReadMessage.  A zero time means no deadline.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.lock.Lock()
+	c.readDeadline = t
+	c.lock.Unlock()
+	return nil
+}
+
+// SetReadLimit sets the maximum size of a data frame.  As with gorilla's websocket.Conn, a larger frame
+// causes a close frame with status 1009 to be sent to the peer and ReadMessage to return websocket.ErrReadLimit.
+// A nonpositive limit means no limit.
+func (c *Conn) SetReadLimit(limit int64) {
+	c.lock.Lock()
+	c.readLimit = limit
+	c.lock.Unlock()
+}
+
+// SetPingHandler sets the handler for ping frames.  A nil handler restores the default,
+// which answers each ping with a pong.
+func (c *Conn) SetPingHandler(h func(string) error) {
+	c.lock.Lock()
+	c.pingHandler = h
+	c.lock.Unlock()
+}
+
+// SetPongHandler sets the handler for pong frames.  A nil handler ignores pongs.
+func (c *Conn) SetPongHandler(h func(string) error) {
+	c.lock.Lock()
+	c.pongHandler = h
+	c.lock.Unlock()
+}
+
+// SetWriteDeadline sets the deadline for writes.  A zero time means no deadline.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	c.lock.Lock()
+	c.writeDeadline = t
+	c.lock.Unlock()
+	return nil
+}
+
+// WriteMessage sends a frame of the given type to the peer.  The data is copied, so callers may reuse it.
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	c.lock.Lock()
+	deadline := c.writeDeadline
+	c.lock.Unlock()
+
+	return c.write(frame{messageType: messageType, data: append([]byte(nil), data...)}, deadline)
+}
+
+// WriteControl sends a control frame with its own deadline, as with gorilla's websocket.Conn
+func (c *Conn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	return c.write(frame{messageType: messageType, data: append([]byte(nil), data...)}, deadline)
+}
+
+// WritePreparedMessage sends a ping to the peer.  The contents of a websocket.PreparedMessage
+// cannot be inspected, and device.Manager only uses prepared messages for pings, so every
+// prepared message is delivered as a ping with no data.
+func (c *Conn) WritePreparedMessage(*websocket.PreparedMessage) error {
+	c.lock.Lock()
+	deadline := c.writeDeadline
+	c.lock.Unlock()
+
+	return c.write(frame{messageType: websocket.PingMessage}, deadline)
+}
+
+func (c *Conn) write(f frame, deadline time.Time) error {
+	if c.isClosed() {
+		return net.ErrClosed
+	} else if c.peer.isClosed() {
+		return websocket.ErrCloseSent
+	}
+
+	timeout, stop, ok := c.deadline(deadline)
+	if !ok {
+		return os.ErrDeadlineExceeded
+	}
+
+	defer stop()
+	select {
+	case c.peer.inbound <- f:
+		return nil
+	case <-c.closed:
+		return net.ErrClosed
+	case <-c.peer.closed:
+		return websocket.ErrCloseSent
+	case <-timeout:
+		return os.ErrDeadlineExceeded
+	}
+}
+
+// Close closes this end of the connection.  Pending and subsequent reads by the peer fail with an
+// abnormal closure once any frames already written have been consumed.  Closing more than once
+// returns net.ErrClosed.
+func (c *Conn) Close() error {
+	err := net.ErrClosed
+	c.shutdown.Do(func() {
+		close(c.closed)
+		err = nil
+	})
+
+	return err
+}
+
+// Closed returns a channel that is closed when this end of the connection is closed
+func (c *Conn) Closed() <-chan struct{} {
+	return c.closed
+}
+
+// WriteWRP encodes the given message as Msgpack and sends it as a binary frame
+// nolint: typecheck
+func (c *Conn) WriteWRP(m *wrp.Message) error {
+	var data []byte
+	// nolint: typecheck
+	if err := wrp.NewEncoderBytes(&data, wrp.Msgpack).Encode(m); err != nil {
+		return err
+	}
+
+	return c.WriteMessage(websocket.BinaryMessage, data)
+}
+
+// ReadWRP reads the next binary frame and decodes it as a Msgpack WRP message.  Text frames are skipped.
+// nolint: typecheck
+func (c *Conn) ReadWRP() (*wrp.Message, error) {
+	for {
+		messageType, data, err := c.ReadMessage()
+		if err != nil {
+			return nil, err
+		}
+
+		if messageType != websocket.BinaryMessage {
+			continue
+		}
+
+		// nolint: typecheck
+		m := new(wrp.Message)
+		// nolint: typecheck
+		if err := wrp.NewDecoderBytes(data, wrp.Msgpack).Decode(m); err != nil {
+			return nil, err
+		}
+
+		return m, nil
+	}
+}
@@ -0,0 +1,187 @@
+package devicetest
+
+import (
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/wrp-go/v3"
+)
+
+func testConnReadWrite(t *testing.T) {
+	var (
+		assert         = assert.New(t)
+		require        = require.New(t)
+		server, client = Pipe(PipeOptions{})
+		data           = []byte("hello")
+	)
+
+	require.NoError(client.WriteMessage(websocket.TextMessage, data))
+	data[0] = 'j'
+
+	messageType, actual, err := server.ReadMessage()
+	require.NoError(err)
+	assert.Equal(websocket.TextMessage, messageType)
+	assert.Equal([]byte("hello"), actual)
+
+	// nolint: typecheck
+	require.NoError(server.WriteWRP(&wrp.Message{Type: wrp.SimpleEventMessageType, Destination: "event:test"}))
+	require.NoError(server.WriteMessage(websocket.TextMessage, []byte("skipped")))
+	// nolint: typecheck
+	require.NoError(server.WriteWRP(&wrp.Message{Type: wrp.SimpleEventMessageType, Destination: "event:second"}))
+
+	m, err := client.ReadWRP()
+	require.NoError(err)
+	assert.Equal("event:test", m.Destination)
+
+	// text frames are skipped by ReadWRP
+	m, err = client.ReadWRP()
+	require.NoError(err)
+	assert.Equal("event:second", m.Destination)
+
+	require.NoError(server.WriteMessage(websocket.BinaryMessage, []byte{0xc1}))
+	_, err = client.ReadWRP()
+	assert.Error(err)
+}
+
+func testConnControl(t *testing.T) {
+	var (
+		assert         = assert.New(t)
+		require        = require.New(t)
+		server, client = Pipe(PipeOptions{})
+		pongs          = make(chan string, 2)
+	)
+
+	server.SetPongHandler(func(data string) error {
+		pongs <- data
+		return nil
+	})
+
+	require.NoError(server.WriteControl(websocket.PingMessage, []byte("ping"), time.Time{}))
+	require.NoError(server.WritePreparedMessage(nil))
+	require.NoError(server.WriteMessage(websocket.BinaryMessage, []byte("data")))
+
+	// the client answers both pings before returning the data frame
+	_, data, err := client.ReadMessage()
+	require.NoError(err)
+	assert.Equal([]byte("data"), data)
+
+	require.NoError(client.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "bye")))
+	_, _, err = server.ReadMessage()
+	require.IsType(&websocket.CloseError{}, err)
+	assert.Equal("ping", <-pongs)
+	assert.Equal("", <-pongs)
+	assert.Equal(websocket.CloseGoingAway, err.(*websocket.CloseError).Code)
+	assert.Equal("bye", err.(*websocket.CloseError).Text)
+
+	pings := make(chan string, 1)
+	client.SetPingHandler(func(data string) error {
+		pings <- data
+		return nil
+	})
+
+	require.NoError(server.WriteControl(websocket.PingMessage, []byte("custom"), time.Time{}))
+	require.NoError(server.WriteMessage(websocket.CloseMessage, nil))
+	_, _, err = client.ReadMessage()
+	assert.Equal(websocket.CloseNoStatusReceived, err.(*websocket.CloseError).Code)
+	assert.Equal("custom", <-pings)
+}
+
+func testConnDeadlines(t *testing.T) {
+	var (
+		assert         = assert.New(t)
+		require        = require.New(t)
+		clock          = NewManualClock(time.Now())
+		server, client = Pipe(PipeOptions{Clock: clock, BufferSize: 1})
+		result         = make(chan error, 1)
+	)
+
+	require.NoError(server.SetReadDeadline(clock.Now().Add(time.Minute)))
+	go func() {
+		_, _, err := server.ReadMessage()
+		result <- err
+	}()
+
+	require.True(clock.WaitForWaiters(1, 10*time.Second))
+	clock.Add(59 * time.Second)
+	assert.Len(result, 0)
+	clock.Add(time.Second)
+	assert.Equal(os.ErrDeadlineExceeded, <-result)
+	_, _, err := server.ReadMessage()
+	assert.Equal(os.ErrDeadlineExceeded, err)
+
+	// the buffer holds one frame, so the second write blocks until the deadline
+	require.NoError(client.SetWriteDeadline(clock.Now().Add(time.Second)))
+	require.NoError(client.WriteMessage(websocket.BinaryMessage, []byte("first")))
+	go func() {
+		result <- client.WriteMessage(websocket.BinaryMessage, []byte("second"))
+	}()
+
+	require.True(clock.WaitForWaiters(1, 10*time.Second))
+	clock.Add(time.Second)
+	assert.Equal(os.ErrDeadlineExceeded, <-result)
+	assert.Equal(os.ErrDeadlineExceeded, client.WriteMessage(websocket.BinaryMessage, []byte("third")))
+	assert.Zero(clock.Waiters())
+}
+
+func testConnClose(t *testing.T) {
+	var (
+		assert         = assert.New(t)
+		require        = require.New(t)
+		server, client = Pipe(PipeOptions{})
+	)
+
+	require.NoError(client.WriteMessage(websocket.BinaryMessage, []byte("last")))
+	require.NoError(client.Close())
+	assert.Equal(net.ErrClosed, client.Close())
+	<-client.Closed()
+
+	assert.Equal(net.ErrClosed, client.WriteMessage(websocket.BinaryMessage, nil))
+	_, _, err := client.ReadMessage()
+	assert.Equal(net.ErrClosed, err)
+
+	// frames written before the close are still delivered
+	_, data, err := server.ReadMessage()
+	require.NoError(err)
+	assert.Equal([]byte("last"), data)
+
+	_, _, err = server.ReadMessage()
+	require.IsType(&websocket.CloseError{}, err)
+	assert.Equal(websocket.CloseAbnormalClosure, err.(*websocket.CloseError).Code)
+	assert.Equal(websocket.ErrCloseSent, server.WriteMessage(websocket.BinaryMessage, nil))
+}
+
+func testConnReadLimit(t *testing.T) {
+	var (
+		assert         = assert.New(t)
+		require        = require.New(t)
+		server, client = Pipe(PipeOptions{})
+	)
+
+	server.SetReadLimit(4)
+	require.NoError(client.WriteMessage(websocket.BinaryMessage, []byte("fits")))
+	require.NoError(client.WriteMessage(websocket.BinaryMessage, []byte("too large")))
+
+	_, data, err := server.ReadMessage()
+	require.NoError(err)
+	assert.Equal([]byte("fits"), data)
+
+	_, _, err = server.ReadMessage()
+	assert.Equal(websocket.ErrReadLimit, err)
+
+	_, _, err = client.ReadMessage()
+	require.IsType(&websocket.CloseError{}, err)
+	assert.Equal(websocket.CloseMessageTooBig, err.(*websocket.CloseError).Code)
+}
+
+func TestConn(t *testing.T) {
+	t.Run("ReadWrite", testConnReadWrite)
+	t.Run("Control", testConnControl)
+	t.Run("Deadlines", testConnDeadlines)
+	t.Run("Close", testConnClose)
+	t.Run("ReadLimit", testConnReadLimit)
+}
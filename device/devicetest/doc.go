@@ -0,0 +1,7 @@
+/*
+Package devicetest provides test support for code built on the device package.  Its in-memory
+connections stand in for websockets, so that a device.Manager's pumps, listeners and transactions
+can be exercised without an HTTP server, and its manual clock makes ping and deadline behavior
+deterministic.
+*/
+package devicetest
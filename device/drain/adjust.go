@@ -0,0 +1,65 @@
+package drain
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/schema"
+	"github.com/xmidt-org/sallust"
+	"github.com/xmidt-org/webpa-common/v2/xhttp"
+	"github.com/xmidt-org/webpa-common/v2/xhttp/converter"
+	"go.uber.org/zap"
+)
+
+// adjustment is the set of form parameters accepted by Adjust
+type adjustment struct {
+	Rate int           `schema:"rate"`
+	Tick time.Duration `schema:"tick"`
+}
+
+// Adjust is an HTTP handler that changes the rate and/or tick of the running drain job.  The response
+// describes the job as adjusted.  The Drainer must be a Controller.
+type Adjust struct {
+	Drainer Interface
+}
+
+func (a *Adjust) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	logger := sallust.Get(request.Context())
+	c, ok := a.Drainer.(Controller)
+	if !ok {
+		writeDrainError(response, http.StatusConflict, ErrNotSupported)
+		return
+	}
+
+	if err := request.ParseForm(); err != nil {
+		logger.Error("unable to parse form", zap.Error(err))
+		xhttp.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+
+	var (
+		decoder = schema.NewDecoder()
+		input   adjustment
+	)
+
+	decoder.RegisterConverter(time.Duration(0), converter.Duration)
+	if err := decoder.Decode(&input, request.Form); err != nil {
+		logger.Error("unable to decode request", zap.Error(err))
+		xhttp.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+
+	if input.Rate <= 0 && input.Tick <= 0 {
+		xhttp.WriteErrorf(response, http.StatusBadRequest, "a positive rate or tick is required")
+		return
+	}
+
+	output, err := c.Adjust(input.Rate, input.Tick)
+	if err != nil {
+		logger.Error("unable to adjust drain job", zap.Error(err))
+		writeDrainError(response, http.StatusConflict, err)
+		return
+	}
+
+	writeJSON(logger, response, output.ToMap())
+}
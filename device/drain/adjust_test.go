@@ -0,0 +1,86 @@
+package drain
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testAdjustBadRequest(t *testing.T, target string) {
+	var (
+		assert = assert.New(t)
+
+		d      = new(mockDrainer)
+		adjust = Adjust{d}
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("POST", target, nil)
+	)
+
+	adjust.ServeHTTP(response, request)
+	assert.Equal(http.StatusBadRequest, response.Code)
+
+	// nolint: typecheck
+	d.AssertExpectations(t)
+}
+
+func testAdjustConflict(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		d      = new(mockDrainer)
+		adjust = Adjust{d}
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("POST", "/foo?rate=10", nil)
+	)
+
+	// nolint: typecheck
+	d.On("Adjust", 10, time.Duration(0)).Return(Job{}, ErrNotRateLimited).Once()
+	adjust.ServeHTTP(response, request)
+	assert.Equal(http.StatusConflict, response.Code)
+
+	// nolint: typecheck
+	d.AssertExpectations(t)
+}
+
+func testAdjustSuccess(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		d      = new(mockDrainer)
+		adjust = Adjust{d}
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("POST", "/foo?rate=10&tick=1m", nil)
+	)
+
+	// nolint: typecheck
+	d.On("Adjust", 10, time.Minute).Return(Job{Count: 100, Rate: 10, Tick: time.Minute}, error(nil)).Once()
+	adjust.ServeHTTP(response, request)
+	assert.Equal(http.StatusOK, response.Code)
+
+	var output map[string]interface{}
+	require.NoError(json.Unmarshal(response.Body.Bytes(), &output))
+	assert.Equal(float64(10), output["rate"])
+	assert.Equal("1m0s", output["tick"])
+
+	// nolint: typecheck
+	d.AssertExpectations(t)
+}
+
+func TestAdjust(t *testing.T) {
+	t.Run("NoParameters", func(t *testing.T) { testAdjustBadRequest(t, "/foo") })
+	t.Run("InvalidRate", func(t *testing.T) { testAdjustBadRequest(t, "/foo?rate=abc") })
+	t.Run("Conflict", testAdjustConflict)
+	t.Run("Success", testAdjustSuccess)
+	t.Run("NotSupported", func(t *testing.T) {
+		testControllerNotSupported(t, func(d Interface) http.Handler { return &Adjust{d} })
+	})
+}
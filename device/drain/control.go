@@ -0,0 +1,107 @@
+package drain
+
+import (
+	"sync"
+	"time"
+)
+
+// control holds the mutable state of a running job, allowing it to be paused,
+// resumed, or have its rate adjusted
+type control struct {
+	lock     sync.Mutex
+	j        Job
+	resumed  chan struct{}
+	adjusted chan struct{}
+}
+
+func newControl(j Job) *control {
+	return &control{
+		j:        j,
+		adjusted: make(chan struct{}, 1),
+	}
+}
+
+// job returns the job as currently configured, including any adjustments
+func (c *control) job() Job {
+	defer c.lock.Unlock()
+	c.lock.Lock()
+	return c.j
+}
+
+func (c *control) isPaused() bool {
+	defer c.lock.Unlock()
+	c.lock.Lock()
+	return c.resumed != nil
+}
+
+// pause returns false if the job was already paused
+func (c *control) pause() bool {
+	defer c.lock.Unlock()
+	c.lock.Lock()
+
+	if c.resumed != nil {
+		return false
+	}
+
+	c.resumed = make(chan struct{})
+	return true
+}
+
+// resume returns false if the job was not paused
+func (c *control) resume() bool {
+	defer c.lock.Unlock()
+	c.lock.Lock()
+
+	if c.resumed == nil {
+		return false
+	}
+
+	close(c.resumed)
+	c.resumed = nil
+	return true
+}
+
+// waitForResume blocks while the job is paused.  This method returns false if the
+// cancel channel was closed while waiting.
+func (c *control) waitForResume(cancel <-chan struct{}) bool {
+	c.lock.Lock()
+	resumed := c.resumed
+	c.lock.Unlock()
+
+	if resumed == nil {
+		return true
+	}
+
+	select {
+	case <-resumed:
+		return true
+	case <-cancel:
+		return false
+	}
+}
+
+// adjust changes the rate and tick of a rate-limited job.  Nonpositive values leave
+// the current setting unchanged.
+func (c *control) adjust(rate int, tick time.Duration) (Job, error) {
+	defer c.lock.Unlock()
+	c.lock.Lock()
+
+	if c.j.Rate <= 0 {
+		return Job{}, ErrNotRateLimited
+	}
+
+	if rate > 0 {
+		c.j.Rate = rate
+	}
+
+	if tick > 0 {
+		c.j.Tick = tick
+	}
+
+	select {
+	case c.adjusted <- struct{}{}:
+	default:
+	}
+
+	return c.j, nil
+}
@@ -0,0 +1,153 @@
+package drain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/sallust"
+	"github.com/xmidt-org/webpa-common/v2/xmetrics/xmetricstest"
+)
+
+func waitForVisited(t *testing.T, d Interface, expected int) {
+	require.Eventually(t, func() bool {
+		_, _, p := d.Status()
+		return p.Visited == expected
+	}, 5*time.Second, time.Millisecond, "expected %d devices to be visited", expected)
+}
+
+func testDrainerPauseResumeAdjust(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		provider = xmetricstest.NewProvider(nil)
+		manager  = generateManager(assert, 100)
+
+		ticks   = make(chan time.Duration, 10)
+		tickers = make(chan chan time.Time, 10)
+
+		d = New(
+			WithLogger(sallust.Default()),
+			WithManager(manager),
+			WithStateGauge(provider.NewGauge("state")),
+		).(*drainer)
+	)
+
+	close(manager.pauseDisconnect)
+	close(manager.pauseVisit)
+	d.newTicker = func(d time.Duration) (<-chan time.Time, func()) {
+		ticker := make(chan time.Time)
+		ticks <- d
+		tickers <- ticker
+		return ticker, func() {}
+	}
+
+	assert.Equal(ErrNotActive, d.Pause())
+	assert.Equal(ErrNotActive, d.Resume())
+	_, err := d.Adjust(10, time.Second)
+	assert.Equal(ErrNotActive, err)
+
+	done, job, err := d.Start(Job{Rate: 10, Tick: time.Second})
+	require.NoError(err)
+	assert.Equal(Job{Count: 100, Rate: 10, Tick: time.Second}, job)
+	assert.Equal(time.Second, <-ticks)
+	ticker := <-tickers
+
+	ticker <- time.Time{}
+	waitForVisited(t, d, 10)
+
+	// ticks are ignored while paused
+	require.NoError(d.Pause())
+	assert.Equal(ErrPaused, d.Pause())
+	provider.Assert(t, "state")(xmetricstest.Value(MetricPaused))
+
+	ticker <- time.Time{}
+	ticker <- time.Time{}
+	active, _, progress := d.Status()
+	assert.True(active)
+	assert.Equal(10, progress.Visited)
+	assert.NotNil(progress.Paused)
+
+	require.NoError(d.Resume())
+	assert.Equal(ErrNotPaused, d.Resume())
+	provider.Assert(t, "state")(xmetricstest.Value(MetricDraining))
+	_, _, progress = d.Status()
+	assert.Nil(progress.Paused)
+
+	// changing the tick replaces the ticker
+	job, err = d.Adjust(25, time.Minute)
+	require.NoError(err)
+	assert.Equal(Job{Count: 100, Rate: 25, Tick: time.Minute}, job)
+	assert.Equal(time.Minute, <-ticks)
+	ticker = <-tickers
+
+	_, job, _ = d.Status()
+	assert.Equal(Job{Count: 100, Rate: 25, Tick: time.Minute}, job)
+
+	ticker <- time.Time{}
+	waitForVisited(t, d, 35)
+
+	// changing only the rate keeps the ticker
+	job, err = d.Adjust(5, 0)
+	require.NoError(err)
+	assert.Equal(Job{Count: 100, Rate: 5, Tick: time.Minute}, job)
+
+	ticker <- time.Time{}
+	ticker <- time.Time{}
+	waitForVisited(t, d, 45)
+	assert.Len(ticks, 0)
+
+	_, err = d.Cancel()
+	require.NoError(err)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		assert.Fail("Drain failed to complete")
+	}
+
+	provider.Assert(t, "state")(xmetricstest.Value(MetricNotDraining))
+}
+
+func testDrainerPauseDisconnect(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		manager = generateManager(assert, 100)
+
+		d = New(
+			WithLogger(sallust.Default()),
+			WithManager(manager),
+		).(*drainer)
+	)
+
+	close(manager.pauseDisconnect)
+	done, _, err := d.Start(Job{})
+	require.NoError(err)
+
+	// a job without a rate cannot be adjusted
+	_, err = d.Adjust(10, time.Second)
+	assert.Equal(ErrNotRateLimited, err)
+
+	// a paused job can still be canceled
+	<-manager.visit
+	require.NoError(d.Pause())
+	_, err = d.Cancel()
+	require.NoError(err)
+	close(manager.pauseVisit)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		assert.Fail("Drain failed to complete")
+	}
+
+	_, _, progress := d.Status()
+	assert.Nil(progress.Paused)
+	assert.NotNil(progress.Finished)
+}
+
+func TestDrainerControl(t *testing.T) {
+	t.Run("PauseResumeAdjust", testDrainerPauseResumeAdjust)
+	t.Run("PauseDisconnect", testDrainerPauseDisconnect)
+}
@@ -14,12 +14,20 @@ import (
 	"github.com/xmidt-org/sallust"
 	"github.com/xmidt-org/webpa-common/v2/device"
 	"github.com/xmidt-org/webpa-common/v2/device/devicegate"
+	"github.com/xmidt-org/webpa-common/v2/xhttp/gate"
 	"github.com/xmidt-org/webpa-common/v2/xmetrics"
 )
 
 var (
-	ErrActive    error = errors.New("a drain operation is already running")
-	ErrNotActive error = errors.New("no drain operation is running")
+	ErrActive           error = errors.New("a drain operation is already running")
+	ErrNotActive        error = errors.New("no drain operation is running")
+	ErrPaused           error = errors.New("the drain operation is already paused")
+	ErrNotPaused        error = errors.New("the drain operation is not paused")
+	ErrNotRateLimited   error = errors.New("the drain operation has no rate to adjust")
+	ErrNoClusterAverage error = errors.New("a cluster average is required to drain to a target percentage")
+	ErrNoGate           error = errors.New("a gate is required to close the gate while draining")
+	ErrInvalidTarget    error = errors.New("drain targets cannot be negative")
+	ErrNotSupported     error = errors.New("the drainer does not support that operation")
 )
 
 const (
@@ -28,6 +36,7 @@ const (
 
 	MetricNotDraining float64 = 0.0
 	MetricDraining    float64 = 1.0
+	MetricPaused      float64 = 2.0
 
 	Drained = "drained"
 
@@ -99,6 +108,23 @@ func WithDrainCounter(a xmetrics.Adder) Option {
 	}
 }
 
+// WithGate configures the gate that is closed for jobs which set CloseGate.  Without a gate,
+// such jobs cannot be started.
+func WithGate(g gate.Interface) Option {
+	return func(dr *drainer) {
+		dr.gate = g
+	}
+}
+
+// WithClusterAverage configures the strategy used to obtain the average number of devices
+// connected to each node in the cluster.  Without this option, jobs which set TargetPercent
+// cannot be started.
+func WithClusterAverage(f func() (int, error)) Option {
+	return func(dr *drainer) {
+		dr.clusterAverage = f
+	}
+}
+
 // DrainFilter contains the filter information for a drain job
 type DrainFilter interface {
 	device.Filter
@@ -126,6 +152,27 @@ type Job struct {
 
 	// DrainFilter holds the filter to drain devices by. If this is set for the job, only devices that match the filter will be drained
 	DrainFilter DrainFilter `json:"filter,omitempty" schema:"filter"`
+
+	// Target is the maximum number of devices this node should hold.  If this field is positive, the job drains
+	// until the number of connected devices is at or below this value, re-evaluating the connected device count
+	// as it runs.  Count and Percent are ignored, and Count is set to the number of devices above the target
+	// at the time the job starts.
+	Target int `json:"target,omitempty" schema:"target"`
+
+	// TargetPercent is the maximum number of devices this node should hold, expressed as a percentage of the
+	// average number of devices per node in the cluster.  The cluster average is re-evaluated as the job runs.
+	// This field is ignored if Target is set.
+	TargetPercent int `json:"targetPercent,omitempty" schema:"targetPercent"`
+
+	// CloseGate indicates that the drainer's gate is closed for the duration of the job, so that new devices
+	// are refused while draining.  The gate is reopened when the job finishes or is canceled, unless it was
+	// already closed when the job started.
+	CloseGate bool `json:"closeGate,omitempty" schema:"closeGate"`
+}
+
+// targeted tests if this Job drains down to a target rather than disconnecting a fixed count
+func (j Job) targeted() bool {
+	return j.Target > 0 || j.TargetPercent > 0
 }
 
 // ToMap returns a map representation of this Job appropriate for marshaling to formats like JSON.
@@ -151,6 +198,18 @@ func (j Job) ToMap() map[string]interface{} {
 		m["filter"] = j.DrainFilter.GetFilterRequest()
 	}
 
+	if j.Target > 0 {
+		m["target"] = j.Target
+	}
+
+	if j.TargetPercent > 0 {
+		m["targetPercent"] = j.TargetPercent
+	}
+
+	if j.CloseGate {
+		m["closeGate"] = true
+	}
+
 	return m
 }
 
@@ -192,6 +251,39 @@ type Interface interface {
 	Cancel() (<-chan struct{}, error)
 }
 
+// Controller is implemented by drainers which can change a drain job while it runs.  Handlers and the Scheduler
+// detect it with a type assertion, so that implementations of Interface need not support it.
+type Controller interface {
+	// Pause suspends the running drain job without losing its progress.  ErrNotActive is returned if no
+	// job is running, and ErrPaused is returned if the job is already paused.
+	Pause() error
+
+	// Resume continues a paused drain job.  ErrNotActive is returned if no job is running, and
+	// ErrNotPaused is returned if the job is not paused.
+	Resume() error
+
+	// Adjust changes the Rate and Tick of the running drain job, returning the job as adjusted.  Nonpositive
+	// values leave the corresponding setting unchanged.  ErrNotRateLimited is returned if the running job
+	// has no Rate, i.e. it is disconnecting devices as fast as possible.
+	Adjust(rate int, tick time.Duration) (Job, error)
+}
+
+// Previewer is implemented by drainers which can report what a drain job would do without running it
+type Previewer interface {
+	// DryRun reports which devices the given Job would disconnect if it were started now, without disconnecting
+	// any device.  The returned Job is the normalized Job that Start would execute.  A dry run can be performed
+	// whether or not a job is running.
+	DryRun(Job) (Job, *device.Preview, error)
+}
+
+// Validator is implemented by drainers which can check a Job before it is started
+type Validator interface {
+	// Validate checks that the given Job could ever be started by this drainer, independent of the devices
+	// currently connected.  The errors returned are permanent, e.g. ErrNoGate, so a Job that fails validation
+	// should not be retried.
+	Validate(Job) error
+}
+
 func defaultNewTicker(d time.Duration) (<-chan time.Time, func()) {
 	ticker := time.NewTicker(d)
 	return ticker.C, ticker.Stop
@@ -238,6 +330,8 @@ type jobContext struct {
 	logger    *zap.Logger
 	t         *tracker
 	j         Job
+	ctl       *control
+	lowered   bool
 	batchSize int
 	ticker    <-chan time.Time
 	stop      func()
@@ -245,7 +339,13 @@ type jobContext struct {
 	done      chan struct{}
 }
 
-// drainer is the internal implementation of Interface
+var (
+	_ Controller = (*drainer)(nil)
+	_ Previewer  = (*drainer)(nil)
+	_ Validator  = (*drainer)(nil)
+)
+
+// drainer is the internal implementation of Interface, Controller, Previewer, and Validator
 type drainer struct {
 	logger    *zap.Logger
 	connector device.Connector
@@ -254,6 +354,9 @@ type drainer struct {
 	newTicker func(time.Duration) (<-chan time.Time, func())
 	m         metrics
 
+	gate           gate.Interface
+	clusterAverage func() (int, error)
+
 	controlLock sync.RWMutex
 	active      uint32
 	currentID   uint32
@@ -349,6 +452,9 @@ func (dr *drainer) jobFinished(jc jobContext) {
 	}
 
 	jc.t.done(dr.now().UTC())
+	if jc.lowered {
+		dr.gate.Raise()
+	}
 
 	// we need to contend on the control lock to avoid clobbering state from Start/Cancel code
 	dr.controlLock.Lock()
@@ -365,28 +471,112 @@ func (dr *drainer) jobFinished(jc jobContext) {
 	jc.logger.Info("drain complete", zap.Int("visited", p.Visited), zap.Int("drained", p.Drained))
 }
 
+// target computes the number of devices a targeted job drains down to
+func (dr *drainer) target(j Job) (int, error) {
+	if j.Target > 0 {
+		return j.Target, nil
+	}
+
+	if dr.clusterAverage == nil {
+		return 0, ErrNoClusterAverage
+	}
+
+	average, err := dr.clusterAverage()
+	if err != nil {
+		return 0, err
+	}
+
+	return int((float64(average) / 100.0) * float64(j.TargetPercent)), nil
+}
+
+// remaining computes the number of devices the job has left to disconnect, given the number visited so far.
+// Targeted jobs are re-evaluated against the number of devices currently connected.
+func (dr *drainer) remaining(jc jobContext, visited int) int {
+	if !jc.j.targeted() {
+		return jc.j.Count - visited
+	}
+
+	target, err := dr.target(jc.j)
+	if err != nil {
+		// keep draining toward the last known target
+		jc.logger.Error("unable to compute drain target", zap.Error(err))
+		target = jc.t.Progress().Target
+	} else {
+		jc.t.setTarget(target)
+	}
+
+	return dr.registry.Len() - target
+}
+
 // drain is run as a goroutine to drain devices at a particular rate
 func (dr *drainer) drain(jc jobContext) {
 	defer dr.jobFinished(jc)
 	jc.logger.Info("drain starting", zap.Int("count", jc.j.Count), zap.Int("rate", jc.j.Rate), zap.Duration("tick", jc.j.Tick))
 
 	var (
-		remaining = jc.j.Count
-		visited   = 0
-		skipped   = 0
-		more      = true
-		batch     = make(chan device.ID, jc.j.Rate)
+		total   = 0
+		visited = 0
+		skipped = 0
+		more    = true
+		rate    = jc.j.Rate
+		tick    = jc.j.Tick
+		ticker  = jc.ticker
+		stop    = jc.stop
+		batch   = make(chan device.ID, rate)
 	)
 
-	for more && remaining > 0 {
-		if remaining < jc.j.Rate {
-			batch = make(chan device.ID, remaining)
+	// the original ticker is stopped by jobFinished, but an adjustment can replace it
+	defer func() {
+		stop()
+	}()
+
+	adjust := func() {
+		j := jc.ctl.job()
+		jc.logger.Info("drain adjusted", zap.Int("rate", j.Rate), zap.Duration("tick", j.Tick))
+		if j.Tick != tick {
+			stop()
+			tick = j.Tick
+			ticker, stop = dr.newTicker(tick)
 		}
 
+		rate = j.Rate
+	}
+
+	for more && dr.remaining(jc, total) > 0 {
 		select {
-		case <-jc.ticker:
+		case <-jc.ctl.adjusted:
+			adjust()
+
+		case <-ticker:
+			if jc.ctl.isPaused() {
+				continue
+			}
+
+			// make sure any adjustment made while waiting for this tick applies to this batch
+			select {
+			case <-jc.ctl.adjusted:
+				adjust()
+			default:
+			}
+
+			// targeted jobs can reach their target while waiting for a tick
+			remaining := dr.remaining(jc, total)
+			if remaining <= 0 {
+				more = false
+				continue
+			}
+
+			size := rate
+			if remaining < size {
+				size = remaining
+			}
+
+			if cap(batch) != size {
+				batch = make(chan device.ID, size)
+			}
+
 			more, visited, skipped = dr.nextBatch(jc, batch)
-			remaining -= visited
+			total += visited
 
 			// If the number skipped is the number remaining in the registry,
 			// then there are no more devices that need to be disconnected.
@@ -406,24 +596,79 @@ func (dr *drainer) disconnect(jc jobContext) {
 	jc.logger.Info("drain starting", zap.Int("count", jc.j.Count))
 
 	var (
-		remaining = jc.j.Count
-		visited   = 0
-		more      = true
-		batch     = make(chan device.ID, jc.batchSize)
+		total   = 0
+		visited = 0
+		more    = true
+		batch   = make(chan device.ID, jc.batchSize)
 	)
 
-	for more && remaining > 0 {
-		if remaining < jc.batchSize {
+	for more {
+		if !jc.ctl.waitForResume(jc.cancel) {
+			jc.logger.Error("job canceled", zap.Error(nil))
+			break
+		}
+
+		remaining := dr.remaining(jc, total)
+		if remaining <= 0 {
+			break
+		}
+
+		if remaining < cap(batch) {
 			batch = make(chan device.ID, remaining)
 		}
 
 		more, visited, _ = dr.nextBatch(jc, batch)
-		remaining -= visited
+		total += visited
 	}
 }
 
+func (dr *drainer) Validate(j Job) error {
+	switch {
+	case j.Target < 0 || j.TargetPercent < 0:
+		return ErrInvalidTarget
+
+	case j.Target == 0 && j.TargetPercent > 0 && dr.clusterAverage == nil:
+		return ErrNoClusterAverage
+
+	case j.CloseGate && dr.gate == nil:
+		return ErrNoGate
+
+	default:
+		return nil
+	}
+}
+
+// prepare normalizes a job against the devices currently connected, returning the normalized job along with
+// its initial target, if any
+func (dr *drainer) prepare(j Job) (Job, int, error) {
+	if err := dr.Validate(j); err != nil {
+		return Job{}, 0, err
+	}
+
+	deviceCount := dr.registry.Len()
+	j.normalize(deviceCount)
+
+	target := 0
+	if j.targeted() {
+		var err error
+		if target, err = dr.target(j); err != nil {
+			return Job{}, 0, err
+		}
+
+		j.Count = deviceCount - target
+		if j.Count < 0 {
+			j.Count = 0
+		}
+	}
+
+	return j, target, nil
+}
+
 func (dr *drainer) Start(j Job) (<-chan struct{}, Job, error) {
-	j.normalize(dr.registry.Len())
+	j, target, err := dr.prepare(j)
+	if err != nil {
+		return nil, Job{}, err
+	}
 
 	defer dr.controlLock.Unlock()
 	dr.controlLock.Lock()
@@ -438,13 +683,20 @@ func (dr *drainer) Start(j Job) (<-chan struct{}, Job, error) {
 		logger: dr.logger.With(zap.Uint32("id", dr.currentID)),
 		t: &tracker{
 			started: dr.now().UTC(),
+			target:  int32(target),
 			counter: dr.m.counter,
 		},
 		j:      j,
+		ctl:    newControl(j),
 		cancel: make(chan struct{}),
 		done:   make(chan struct{}),
 	}
 
+	if j.CloseGate {
+		// only reopen the gate afterward if this job is what closed it
+		jc.lowered = dr.gate.Lower()
+	}
+
 	if jc.j.Rate > 0 {
 		jc.ticker, jc.stop = dr.newTicker(j.Tick)
 		go dr.drain(jc)
@@ -464,7 +716,7 @@ func (dr *drainer) Status() (bool, Job, Progress) {
 
 	if jc, ok := dr.current.Load().(jobContext); ok {
 		return atomic.LoadUint32(&dr.active) == StateActive,
-			jc.j,
+			jc.ctl.job(),
 			jc.t.Progress()
 	}
 
@@ -485,3 +737,89 @@ func (dr *drainer) Cancel() (<-chan struct{}, error) {
 	close(jc.cancel)
 	return jc.done, nil
 }
+
+func (dr *drainer) DryRun(j Job) (Job, *device.Preview, error) {
+	j, _, err := dr.prepare(j)
+	if err != nil {
+		return Job{}, nil, err
+	}
+
+	preview := device.NewPreview(0)
+	dr.registry.VisitAll(func(d device.Interface) bool {
+		if preview.Count >= j.Count {
+			return false
+		}
+
+		if j.DrainFilter != nil {
+			// nolint: typecheck
+			if allow, _ := j.DrainFilter.AllowConnection(d); allow {
+				preview.Visit()
+				return true
+			}
+		}
+
+		preview.Add(d, Drained)
+		return true
+	})
+
+	return j, preview, nil
+}
+
+// activeJob returns the running job's context.  The control lock must be held.
+func (dr *drainer) activeJob() (jobContext, error) {
+	if atomic.LoadUint32(&dr.active) != StateActive {
+		return jobContext{}, ErrNotActive
+	}
+
+	return dr.current.Load().(jobContext), nil
+}
+
+func (dr *drainer) Pause() error {
+	defer dr.controlLock.Unlock()
+	dr.controlLock.Lock()
+
+	jc, err := dr.activeJob()
+	if err != nil {
+		return err
+	}
+
+	if !jc.ctl.pause() {
+		return ErrPaused
+	}
+
+	jc.t.pause(dr.now().UTC())
+	dr.m.state.Set(MetricPaused)
+	jc.logger.Info("drain paused")
+	return nil
+}
+
+func (dr *drainer) Resume() error {
+	defer dr.controlLock.Unlock()
+	dr.controlLock.Lock()
+
+	jc, err := dr.activeJob()
+	if err != nil {
+		return err
+	}
+
+	if !jc.ctl.resume() {
+		return ErrNotPaused
+	}
+
+	jc.t.resume()
+	dr.m.state.Set(MetricDraining)
+	jc.logger.Info("drain resumed")
+	return nil
+}
+
+func (dr *drainer) Adjust(rate int, tick time.Duration) (Job, error) {
+	defer dr.controlLock.Unlock()
+	dr.controlLock.Lock()
+
+	jc, err := dr.activeJob()
+	if err != nil {
+		return Job{}, err
+	}
+
+	return jc.ctl.adjust(rate, tick)
+}
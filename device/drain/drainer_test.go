@@ -12,6 +12,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/xmidt-org/webpa-common/v2/device/devicegate"
+	"github.com/xmidt-org/webpa-common/v2/xhttp/gate"
 	"github.com/xmidt-org/webpa-common/v2/xmetrics/xmetricstest"
 )
 
@@ -627,6 +628,191 @@ func TestDrainer(t *testing.T) {
 	t.Run("VisitCancel", testDrainerVisitCancel)
 	t.Run("DisconnectCancel", testDrainerDisconnectCancel)
 	t.Run("DrainCancel", testDrainerDrainCancel)
+	t.Run("DryRun", testDrainerDryRun)
+}
+
+func testDrainerTargetErrors(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		manager = generateManager(assert, 100)
+		d       = New(WithManager(manager))
+	)
+
+	done, job, err := d.Start(Job{TargetPercent: 50})
+	assert.Nil(done)
+	assert.Equal(Job{}, job)
+	assert.Equal(ErrNoClusterAverage, err)
+
+	done, job, err = d.Start(Job{CloseGate: true})
+	assert.Nil(done)
+	assert.Equal(Job{}, job)
+	assert.Equal(ErrNoGate, err)
+
+	done, job, err = d.Start(Job{Target: -1})
+	assert.Nil(done)
+	assert.Equal(Job{}, job)
+	assert.Equal(ErrInvalidTarget, err)
+
+	assert.Equal(ErrNoClusterAverage, d.(Validator).Validate(Job{TargetPercent: 50}))
+	assert.Equal(ErrNoGate, d.(Validator).Validate(Job{CloseGate: true}))
+	assert.Equal(ErrInvalidTarget, d.(Validator).Validate(Job{TargetPercent: -1}))
+	assert.NoError(d.(Validator).Validate(Job{Target: 10, TargetPercent: 50}))
+	assert.NoError(New(WithManager(manager), WithClusterAverage(func() (int, error) { return 0, nil })).(Validator).Validate(Job{TargetPercent: 50}))
+
+	active, _, _ := d.Status()
+	assert.False(active)
+}
+
+func testDrainerDisconnectTarget(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		manager = generateManager(assert, 100)
+		g       = gate.New(true)
+
+		d = New(
+			WithManager(manager),
+			WithGate(g),
+		)
+	)
+
+	close(manager.pauseDisconnect)
+	close(manager.pauseVisit)
+	done, job, err := d.Start(Job{Count: 5, Target: 30, CloseGate: true})
+	require.NoError(err)
+	assert.Equal(Job{Count: 70, Target: 30, CloseGate: true}, job)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		assert.Fail("Disconnect failed to complete")
+		return
+	}
+
+	assert.Equal(30, manager.Len())
+	assert.True(g.Open())
+
+	active, _, progress := d.Status()
+	assert.False(active)
+	assert.Equal(70, progress.Visited)
+	assert.Equal(30, progress.Target)
+}
+
+func testDrainerDrainTargetPercent(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		manager = generateManager(assert, 100)
+		ticker  = make(chan time.Time)
+
+		// the gate starts closed, so the job must leave it closed
+		g = gate.New(false)
+
+		d = New(
+			WithManager(manager),
+			WithGate(g),
+			WithClusterAverage(func() (int, error) { return 200, nil }),
+		)
+	)
+
+	close(manager.pauseDisconnect)
+	close(manager.pauseVisit)
+	d.(*drainer).newTicker = func(time.Duration) (<-chan time.Time, func()) {
+		return ticker, func() {}
+	}
+
+	done, job, err := d.Start(Job{Rate: 20, TargetPercent: 25, CloseGate: true})
+	require.NoError(err)
+	assert.Equal(Job{Count: 50, Rate: 20, Tick: time.Second, TargetPercent: 25, CloseGate: true}, job)
+
+	ticker <- time.Time{}
+	waitForVisited(t, d, 20)
+	assert.Equal(80, manager.Len())
+
+	// devices that connect during the drain are drained as well
+	manager.connect(100, 20)
+
+	go func() {
+		for {
+			select {
+			case ticker <- time.Time{}:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		assert.Fail("Drain failed to complete")
+		return
+	}
+
+	assert.Equal(50, manager.Len())
+	assert.False(g.Open())
+
+	_, _, progress := d.Status()
+	assert.Equal(70, progress.Visited)
+	assert.Equal(50, progress.Target)
+}
+
+func testDrainerDryRun(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		manager = generateManagerWithDifferentDevices(assert, map[string]interface{}{"partner-id": "comcast"}, 30, map[string]interface{}{"partner-id": "sky"}, 70)
+
+		df = &drainFilter{
+			filter: &devicegate.FilterGate{
+				FilterStore: devicegate.FilterStore(map[string]devicegate.Set{
+					"partner-id": &devicegate.FilterSet{Set: map[interface{}]bool{"comcast": true}},
+				}),
+			},
+		}
+
+		d = New(WithManager(manager))
+	)
+
+	close(manager.pauseDisconnect)
+	close(manager.pauseVisit)
+	for _, v := range manager.devices {
+		// nolint: typecheck
+		v.(*device.MockDevice).On("Convey").Return(nil)
+	}
+
+	job, preview, err := d.(Previewer).DryRun(Job{Percent: 50, DrainFilter: df})
+	require.NoError(err)
+	require.NotNil(preview)
+	assert.Equal(Job{Count: 50, Percent: 50, DrainFilter: df}, job)
+
+	// only devices matching the filter would be drained, and nothing is disconnected
+	assert.Equal(100, preview.Visited)
+	assert.Equal(30, preview.Count)
+	assert.Equal(map[string]int{Drained: 30}, preview.Reasons)
+	assert.Equal(map[string]int{"comcast": 30}, preview.Partners)
+	assert.Equal(map[string]int{device.UnknownModel: 30}, preview.Models)
+	assert.Len(preview.Sample, device.DefaultPreviewSampleSize)
+	assert.Equal(100, manager.Len())
+
+	job, preview, err = d.(Previewer).DryRun(Job{Count: 10})
+	require.NoError(err)
+	assert.Equal(Job{Count: 10}, job)
+	assert.Equal(10, preview.Count)
+	assert.Equal(10, preview.Visited)
+
+	_, preview, err = d.(Previewer).DryRun(Job{TargetPercent: 10})
+	assert.Nil(preview)
+	assert.Equal(ErrNoClusterAverage, err)
+
+	active, _, _ := d.Status()
+	assert.False(active)
+}
+
+func TestDrainerTarget(t *testing.T) {
+	t.Run("Errors", testDrainerTargetErrors)
+	t.Run("DisconnectTarget", testDrainerDisconnectTarget)
+	t.Run("DrainTargetPercent", testDrainerDrainTargetPercent)
 }
 
 func testDrainFilter(t *testing.T, deviceTypeOne deviceInfo, deviceTypeTwo deviceInfo, df DrainFilter, expectedSkipped int, count int) {
@@ -0,0 +1,40 @@
+package drain
+
+import (
+	"net/http"
+
+	"github.com/xmidt-org/sallust"
+	"go.uber.org/zap"
+)
+
+// DryRun is an HTTP handler that previews the devices a drain job would disconnect.  It accepts
+// the same parameters and filter body as Start, but never disconnects any device.  The Drainer must be a Previewer.
+type DryRun struct {
+	Drainer Interface
+}
+
+func (dr *DryRun) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	logger := sallust.Get(request.Context())
+	p, ok := dr.Drainer.(Previewer)
+	if !ok {
+		writeDrainError(response, http.StatusBadRequest, ErrNotSupported)
+		return
+	}
+
+	input, ok := decodeJob(logger, response, request)
+	if !ok {
+		return
+	}
+
+	job, preview, err := p.DryRun(input)
+	if err != nil {
+		logger.Error("unable to preview drain job", zap.Error(err))
+		writeDrainError(response, http.StatusBadRequest, err)
+		return
+	}
+
+	writeJSON(logger, response, map[string]interface{}{
+		"job":     job.ToMap(),
+		"preview": preview,
+	})
+}
@@ -0,0 +1,93 @@
+package drain
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/webpa-common/v2/device"
+)
+
+func testDryRunBadRequest(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		d      = new(mockDrainer)
+		dryRun = DryRun{d}
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("POST", "/foo?count=abc", nil)
+	)
+
+	dryRun.ServeHTTP(response, request)
+	assert.Equal(http.StatusBadRequest, response.Code)
+
+	// nolint: typecheck
+	d.AssertExpectations(t)
+}
+
+func testDryRunError(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		d      = new(mockDrainer)
+		dryRun = DryRun{d}
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("POST", "/foo?targetPercent=50", nil)
+	)
+
+	// nolint: typecheck
+	d.On("DryRun", Job{TargetPercent: 50}).Return(Job{}, nil, ErrNoClusterAverage).Once()
+	dryRun.ServeHTTP(response, request)
+	assert.Equal(http.StatusBadRequest, response.Code)
+
+	// nolint: typecheck
+	d.AssertExpectations(t)
+}
+
+func testDryRunSuccess(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		d      = new(mockDrainer)
+		dryRun = DryRun{d}
+
+		preview = device.NewPreview(1)
+		mock    = new(device.MockDevice)
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("POST", "/foo?count=10", nil)
+	)
+
+	// nolint: typecheck
+	mock.On("ID").Return(device.ID("mac:112233445566"))
+	// nolint: typecheck
+	mock.On("Metadata").Return(nil)
+	// nolint: typecheck
+	mock.On("Convey").Return(nil)
+	preview.Add(mock, Drained)
+
+	// nolint: typecheck
+	d.On("DryRun", Job{Count: 10}).Return(Job{Count: 10}, preview, error(nil)).Once()
+	dryRun.ServeHTTP(response, request)
+	assert.Equal(http.StatusOK, response.Code)
+	assert.Equal("application/json", response.Header().Get("Content-Type"))
+	assert.JSONEq(
+		`{"job": {"count": 10}, "preview": {"visited": 1, "count": 1, "reasons": {"drained": 1}, "partners": {"unknown": 1}, "models": {"unknown": 1}, "sample": ["mac:112233445566"]}}`,
+		response.Body.String(),
+	)
+
+	// nolint: typecheck
+	d.AssertExpectations(t)
+}
+
+func TestDryRun(t *testing.T) {
+	t.Run("BadRequest", testDryRunBadRequest)
+	t.Run("Error", testDryRunError)
+	t.Run("Success", testDryRunSuccess)
+	t.Run("NotSupported", func(t *testing.T) {
+		testControllerNotSupported(t, func(d Interface) http.Handler { return &DryRun{d} })
+	})
+}
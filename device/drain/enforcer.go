@@ -0,0 +1,55 @@
+package drain
+
+import (
+	"time"
+
+	"github.com/xmidt-org/webpa-common/v2/device"
+	"github.com/xmidt-org/webpa-common/v2/device/devicegate"
+)
+
+// Enforcer is a devicegate.Enforcer that disconnects the devices a gate refuses by starting a drain job.  The job
+// is an ordinary drain job, so its progress is reported by Status and it can be paused, adjusted, or canceled.
+// Because only one drain job runs at a time, enforcement fails with ErrActive while another job is running.
+type Enforcer struct {
+	Drainer Interface
+
+	// Rate is the number of devices disconnected per Tick when a request supplies no rate.  If nonpositive,
+	// devices are disconnected as fast as possible.
+	Rate int
+
+	// Tick is the time unit for the rate when a request supplies no tick
+	Tick time.Duration
+}
+
+// checker is implemented by gates, such as devicegate.FilterGate, that can evaluate a device without
+// recording anything about it
+type checker interface {
+	Check(device.Interface) (bool, device.MatchResult)
+}
+
+// Enforce starts a drain job that disconnects every connected device the gate refuses.  If the gate has a
+// Check method, that is used instead of AllowConnection, so that enforcement does not count as filter hits.
+func (e *Enforcer) Enforce(f devicegate.FilterRequest, gate device.Filter, rate int, tick time.Duration) error {
+	if rate < 1 {
+		rate = e.Rate
+	}
+
+	if tick <= 0 {
+		tick = e.Tick
+	}
+
+	if c, ok := gate.(checker); ok {
+		gate = device.FilterFunc(c.Check)
+	}
+
+	_, _, err := e.Drainer.Start(Job{
+		Rate: rate,
+		Tick: tick,
+		DrainFilter: &drainFilter{
+			filter:        gate,
+			filterRequest: f,
+		},
+	})
+
+	return err
+}
@@ -0,0 +1,117 @@
+package drain
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/sallust"
+	"github.com/xmidt-org/webpa-common/v2/device"
+	"github.com/xmidt-org/webpa-common/v2/device/devicegate"
+)
+
+func testEnforcerDefaults(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		gate   = &devicegate.FilterGate{FilterStore: make(devicegate.FilterStore)}
+		f      = devicegate.FilterRequest{Key: "partner-id", Values: []interface{}{"comcast"}}
+		done   = make(chan struct{})
+
+		d = new(mockDrainer)
+		e = Enforcer{Drainer: d, Rate: 50, Tick: time.Minute}
+	)
+
+	// gates are checked without recording hits, so the job's filter wraps the gate rather than being the gate
+	expectJob := func(rate int, tick time.Duration) interface{} {
+		return mock.MatchedBy(func(j Job) bool {
+			df, ok := j.DrainFilter.(*drainFilter)
+			return ok && j.Rate == rate && j.Tick == tick && df.filterRequest.Key == f.Key && df.filter != device.Filter(gate)
+		})
+	}
+
+	// nolint: typecheck
+	d.On("Start", expectJob(50, time.Minute)).
+		Return((<-chan struct{})(done), Job{}, nil).Once()
+	assert.NoError(e.Enforce(f, gate, 0, 0))
+
+	// nolint: typecheck
+	d.On("Start", expectJob(10, time.Second)).
+		Return((<-chan struct{})(nil), Job{}, ErrActive).Once()
+	assert.Equal(ErrActive, e.Enforce(f, gate, 10, time.Second))
+
+	// other filters are used as is
+	other := device.FilterFunc(func(device.Interface) (bool, device.MatchResult) { return true, device.MatchResult{} })
+	// nolint: typecheck
+	d.On("Start", mock.MatchedBy(func(j Job) bool {
+		df, ok := j.DrainFilter.(*drainFilter)
+		return ok && reflect.ValueOf(df.filter).Pointer() == reflect.ValueOf(other).Pointer()
+	})).Return((<-chan struct{})(done), Job{}, nil).Once()
+	assert.NoError(e.Enforce(f, other, 0, 0))
+
+	// nolint: typecheck
+	d.AssertExpectations(t)
+}
+
+func testEnforcerFilterHandler(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		ctx     = sallust.With(context.Background(), sallust.Default())
+		manager = generateManagerWithDifferentDevices(assert, map[string]interface{}{"partner-id": "comcast"}, 30, map[string]interface{}{"partner-id": "sky"}, 70)
+		gate    = &devicegate.FilterGate{FilterStore: make(devicegate.FilterStore)}
+
+		d = New(WithManager(manager))
+		f = devicegate.FilterHandler{
+			Gate:     gate,
+			Enforcer: &Enforcer{Drainer: d},
+		}
+	)
+
+	close(manager.pauseDisconnect)
+	close(manager.pauseVisit)
+
+	response := httptest.NewRecorder()
+	f.UpdateFilters(response, httptest.NewRequest("POST", "/?enforce=true", bytes.NewBufferString(`{"key": "partner-id", "values": ["comcast"]}`)).WithContext(ctx))
+	require.Equal(http.StatusCreated, response.Code)
+
+	assert.Eventually(
+		func() bool {
+			active, _, _ := d.Status()
+			return !active
+		},
+		5*time.Second,
+		10*time.Millisecond,
+	)
+
+	// only the devices the updated gate refuses are disconnected, and the job is reported as a drain job
+	assert.Equal(70, manager.Len())
+	_, job, progress := d.Status()
+	require.NotNil(job.DrainFilter)
+	assert.Equal("partner-id", job.DrainFilter.GetFilterRequest().Key)
+	assert.Equal(30, progress.Drained)
+
+	// enforcement does not count as the gate refusing devices
+	assert.Empty(gate.Hits())
+
+	// a running job prevents enforcement, though the filter itself is still applied
+	busy := devicegate.FilterHandler{Gate: gate, Enforcer: &Enforcer{Drainer: &stubDrainer{active: true}}}
+	response = httptest.NewRecorder()
+	busy.UpdateFilters(response, httptest.NewRequest("POST", "/?enforce=true&rate=10&tick=1s", bytes.NewBufferString(`{"key": "partner-id", "values": ["sky"]}`)).WithContext(ctx))
+	assert.Equal(http.StatusConflict, response.Code)
+
+	set, ok := gate.GetFilter("partner-id")
+	require.True(ok)
+	assert.True(set.Has("sky"))
+}
+
+func TestEnforcer(t *testing.T) {
+	t.Run("Defaults", testEnforcerDefaults)
+	t.Run("FilterHandler", testEnforcerFilterHandler)
+}
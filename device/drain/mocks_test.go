@@ -3,12 +3,19 @@ package drain
 import (
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/xmidt-org/webpa-common/v2/device"
 )
 
+// basicDrainer exposes only the Interface methods of a drainer, like implementations that
+// predate Controller, Previewer, and Validator
+type basicDrainer struct {
+	Interface
+}
+
 type mockDrainer struct {
 	mock.Mock
 }
@@ -31,6 +38,37 @@ func (m *mockDrainer) Cancel() (<-chan struct{}, error) {
 	return arguments.Get(0).(<-chan struct{}), arguments.Error(1)
 }
 
+func (m *mockDrainer) Pause() error {
+	// nolint: typecheck
+	arguments := m.Called()
+	return arguments.Error(0)
+}
+
+func (m *mockDrainer) Resume() error {
+	// nolint: typecheck
+	arguments := m.Called()
+	return arguments.Error(0)
+}
+
+func (m *mockDrainer) Adjust(rate int, tick time.Duration) (Job, error) {
+	// nolint: typecheck
+	arguments := m.Called(rate, tick)
+	return arguments.Get(0).(Job), arguments.Error(1)
+}
+
+func (m *mockDrainer) DryRun(j Job) (Job, *device.Preview, error) {
+	// nolint: typecheck
+	arguments := m.Called(j)
+	first, _ := arguments.Get(1).(*device.Preview)
+	return arguments.Get(0).(Job), first, arguments.Error(2)
+}
+
+func (m *mockDrainer) Validate(j Job) error {
+	// nolint: typecheck
+	arguments := m.Called(j)
+	return arguments.Error(0)
+}
+
 type stubManager struct {
 	lock    sync.RWMutex
 	assert  *assert.Assertions
@@ -85,6 +123,8 @@ func (sm *stubManager) GetFilter() device.Filter {
 }
 
 func (sm *stubManager) Len() int {
+	defer sm.lock.RUnlock()
+	sm.lock.RLock()
 	return len(sm.devices)
 }
 
@@ -118,17 +158,12 @@ func (sm *stubManager) Route(*device.Request) (*device.Response, error) {
 	return nil, nil
 }
 
-func generateManager(assert *assert.Assertions, count uint64) *stubManager {
-	sm := &stubManager{
-		assert:          assert,
-		devices:         make(map[device.ID]device.Interface, count),
-		disconnect:      make(chan struct{}, 10),
-		pauseDisconnect: make(chan struct{}),
-		visit:           make(chan struct{}, 10),
-		pauseVisit:      make(chan struct{}),
-	}
+// connect simulates count devices connecting, with MACs starting at the given value
+func (sm *stubManager) connect(start, count uint64) {
+	defer sm.lock.Unlock()
+	sm.lock.Lock()
 
-	for mac := uint64(0); mac < count; mac++ {
+	for mac := start; mac < start+count; mac++ {
 		var (
 			id = device.IntToMAC(mac)
 			d  = new(device.MockDevice)
@@ -140,7 +175,19 @@ func generateManager(assert *assert.Assertions, count uint64) *stubManager {
 		d.On("String").Return("mockDevice(" + string(id) + ")")
 		sm.devices[id] = d
 	}
+}
 
+func generateManager(assert *assert.Assertions, count uint64) *stubManager {
+	sm := &stubManager{
+		assert:          assert,
+		devices:         make(map[device.ID]device.Interface, count),
+		disconnect:      make(chan struct{}, 10),
+		pauseDisconnect: make(chan struct{}),
+		visit:           make(chan struct{}, 10),
+		pauseVisit:      make(chan struct{}),
+	}
+
+	sm.connect(0, count)
 	return sm
 }
 
@@ -185,3 +232,111 @@ func generateManagerWithDifferentDevices(assert *assert.Assertions, metadataOneC
 
 	return sm
 }
+
+// stubDrainer is a drain Interface whose jobs run until the test finishes or cancels them
+type stubDrainer struct {
+	lock     sync.Mutex
+	active   bool
+	job      Job
+	progress Progress
+	done     chan struct{}
+	started  chan Job
+
+	// startErr, if set, is returned by Start for jobs that pass validation
+	startErr error
+}
+
+func newStubDrainer() *stubDrainer {
+	return &stubDrainer{started: make(chan Job, 10)}
+}
+
+func (sd *stubDrainer) Start(j Job) (<-chan struct{}, Job, error) {
+	defer sd.lock.Unlock()
+	sd.lock.Lock()
+
+	if sd.active {
+		return nil, Job{}, ErrActive
+	}
+
+	if err := sd.Validate(j); err != nil {
+		return nil, Job{}, err
+	} else if sd.startErr != nil {
+		return nil, Job{}, sd.startErr
+	}
+
+	j.normalize(100)
+	sd.active = true
+	sd.job = j
+	sd.progress = Progress{Visited: j.Count}
+	sd.done = make(chan struct{})
+	sd.started <- j
+	return sd.done, j, nil
+}
+
+func (sd *stubDrainer) Status() (bool, Job, Progress) {
+	defer sd.lock.Unlock()
+	sd.lock.Lock()
+	return sd.active, sd.job, sd.progress
+}
+
+func (sd *stubDrainer) Cancel() (<-chan struct{}, error) {
+	defer sd.lock.Unlock()
+	sd.lock.Lock()
+
+	if !sd.active {
+		return nil, ErrNotActive
+	}
+
+	sd.active = false
+	close(sd.done)
+	return sd.done, nil
+}
+
+func (sd *stubDrainer) Pause() error {
+	return ErrNotActive
+}
+
+func (sd *stubDrainer) Resume() error {
+	return ErrNotActive
+}
+
+func (sd *stubDrainer) Adjust(int, time.Duration) (Job, error) {
+	return Job{}, ErrNotActive
+}
+
+func (sd *stubDrainer) DryRun(j Job) (Job, *device.Preview, error) {
+	j.normalize(100)
+	return j, device.NewPreview(0), nil
+}
+
+// Validate refuses jobs with negative targets
+func (sd *stubDrainer) Validate(j Job) error {
+	if j.Target < 0 || j.TargetPercent < 0 {
+		return ErrInvalidTarget
+	}
+
+	return nil
+}
+
+func (sd *stubDrainer) setStartErr(err error) {
+	defer sd.lock.Unlock()
+	sd.lock.Lock()
+	sd.startErr = err
+}
+
+// finish completes the active job
+func (sd *stubDrainer) finish() {
+	defer sd.lock.Unlock()
+	sd.lock.Lock()
+
+	sd.active = false
+	sd.progress.Drained = sd.progress.Visited
+	close(sd.done)
+}
+
+// setActive simulates a job started without the Scheduler's knowledge
+func (sd *stubDrainer) setActive(active bool) {
+	defer sd.lock.Unlock()
+	sd.lock.Lock()
+	sd.active = active
+}
@@ -0,0 +1,44 @@
+package drain
+
+import (
+	"net/http"
+
+	"github.com/xmidt-org/sallust"
+	"go.uber.org/zap"
+)
+
+// Pause is an HTTP handler that suspends the running drain job.  The Drainer must be a Controller.
+type Pause struct {
+	Drainer Interface
+}
+
+func (p *Pause) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	c, ok := p.Drainer.(Controller)
+	if !ok {
+		writeDrainError(response, http.StatusConflict, ErrNotSupported)
+		return
+	}
+
+	if err := c.Pause(); err != nil {
+		sallust.Get(request.Context()).Error("unable to pause drain job", zap.Error(err))
+		writeDrainError(response, http.StatusConflict, err)
+	}
+}
+
+// Resume is an HTTP handler that continues a paused drain job.  The Drainer must be a Controller.
+type Resume struct {
+	Drainer Interface
+}
+
+func (r *Resume) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	c, ok := r.Drainer.(Controller)
+	if !ok {
+		writeDrainError(response, http.StatusConflict, ErrNotSupported)
+		return
+	}
+
+	if err := c.Resume(); err != nil {
+		sallust.Get(request.Context()).Error("unable to resume drain job", zap.Error(err))
+		writeDrainError(response, http.StatusConflict, err)
+	}
+}
@@ -0,0 +1,82 @@
+package drain
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testPause(t *testing.T, err error, expectedCode int) {
+	var (
+		assert = assert.New(t)
+
+		d     = new(mockDrainer)
+		pause = Pause{d}
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("POST", "/", nil)
+	)
+
+	// nolint: typecheck
+	d.On("Pause").Return(err).Once()
+	pause.ServeHTTP(response, request)
+	assert.Equal(expectedCode, response.Code)
+
+	// nolint: typecheck
+	d.AssertExpectations(t)
+}
+
+func testResume(t *testing.T, err error, expectedCode int) {
+	var (
+		assert = assert.New(t)
+
+		d      = new(mockDrainer)
+		resume = Resume{d}
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("POST", "/", nil)
+	)
+
+	// nolint: typecheck
+	d.On("Resume").Return(err).Once()
+	resume.ServeHTTP(response, request)
+	assert.Equal(expectedCode, response.Code)
+
+	// nolint: typecheck
+	d.AssertExpectations(t)
+}
+
+func testControllerNotSupported(t *testing.T, handler func(Interface) http.Handler) {
+	for _, d := range []Interface{basicDrainer{new(mockDrainer)}, NewScheduler(basicDrainer{new(mockDrainer)})} {
+		var (
+			response = httptest.NewRecorder()
+			request  = httptest.NewRequest("POST", "/foo?rate=10", nil)
+		)
+
+		handler(d).ServeHTTP(response, request)
+		assert.Equal(t, http.StatusNotImplemented, response.Code)
+		if s, ok := d.(Scheduler); ok {
+			s.Stop()
+		}
+	}
+}
+
+func TestPause(t *testing.T) {
+	t.Run("Success", func(t *testing.T) { testPause(t, nil, http.StatusOK) })
+	t.Run("NotActive", func(t *testing.T) { testPause(t, ErrNotActive, http.StatusConflict) })
+	t.Run("Paused", func(t *testing.T) { testPause(t, ErrPaused, http.StatusConflict) })
+	t.Run("NotSupported", func(t *testing.T) {
+		testControllerNotSupported(t, func(d Interface) http.Handler { return &Pause{d} })
+	})
+}
+
+func TestResume(t *testing.T) {
+	t.Run("Success", func(t *testing.T) { testResume(t, nil, http.StatusOK) })
+	t.Run("NotActive", func(t *testing.T) { testResume(t, ErrNotActive, http.StatusConflict) })
+	t.Run("NotPaused", func(t *testing.T) { testResume(t, ErrNotPaused, http.StatusConflict) })
+	t.Run("NotSupported", func(t *testing.T) {
+		testControllerNotSupported(t, func(d Interface) http.Handler { return &Resume{d} })
+	})
+}
@@ -0,0 +1,209 @@
+package drain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/xmidt-org/sallust"
+	"github.com/xmidt-org/webpa-common/v2/xhttp"
+	"go.uber.org/zap"
+)
+
+const (
+	// AtParameter is the form parameter holding the RFC3339 time at which a scheduled job should start
+	AtParameter = "at"
+
+	// DelayParameter is the form parameter holding a duration, e.g. 2h, after which a scheduled job should start
+	DelayParameter = "delay"
+)
+
+func writeJSON(logger *zap.Logger, response http.ResponseWriter, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		logger.Error("unable to marshal response", zap.Error(err))
+		xhttp.WriteError(response, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.Header().Set("Content-Type", "application/json")
+	response.Write(data)
+}
+
+// writeDrainError writes an error returned by a drainer.  ErrNotSupported produces http.StatusNotImplemented,
+// while any other error produces the given status.
+func writeDrainError(response http.ResponseWriter, status int, err error) {
+	if errors.Is(err, ErrNotSupported) {
+		status = http.StatusNotImplemented
+	}
+
+	xhttp.WriteError(response, status, err)
+}
+
+// jobID extracts a scheduled job identifier from the gorilla path variable with the given name
+func jobID(logger *zap.Logger, variable string, response http.ResponseWriter, request *http.Request) (uint64, bool) {
+	value := mux.Vars(request)[variable]
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		logger.Error("invalid job identifier", zap.String("variable", variable), zap.String("value", value), zap.Error(err))
+		xhttp.WriteErrorf(response, http.StatusBadRequest, "invalid job identifier: %q", value)
+		return 0, false
+	}
+
+	return id, true
+}
+
+// scheduleTime determines when a job should start from the at or delay form parameters.  The
+// parameters are removed from the form so that the remainder can be decoded as a Job.
+func scheduleTime(form map[string][]string, now time.Time) (time.Time, error) {
+	var (
+		at    = first(form, AtParameter)
+		delay = first(form, DelayParameter)
+	)
+
+	delete(form, AtParameter)
+	delete(form, DelayParameter)
+
+	switch {
+	case len(at) > 0 && len(delay) > 0:
+		return time.Time{}, fmt.Errorf("only one of %s or %s may be supplied", AtParameter, DelayParameter)
+
+	case len(at) > 0:
+		return time.Parse(time.RFC3339, at)
+
+	case len(delay) > 0:
+		d, err := time.ParseDuration(delay)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		return now.Add(d).UTC(), nil
+	}
+
+	return time.Time{}, nil
+}
+
+func first(form map[string][]string, key string) string {
+	if values := form[key]; len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+// Schedule is an HTTP handler that submits a drain job to a Scheduler.  The job is described just
+// as with Start, and the optional at or delay parameters set when the job runs.  Without either parameter,
+// the job is queued to run once any running or earlier jobs finish.
+type Schedule struct {
+	Scheduler Scheduler
+
+	// Now is the optional closure used to resolve the delay parameter.  If unset, time.Now is used.
+	Now func() time.Time
+}
+
+func (s *Schedule) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	logger := sallust.Get(request.Context())
+	if err := request.ParseForm(); err != nil {
+		logger.Error("unable to parse form", zap.Error(err))
+		xhttp.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+
+	at, err := scheduleTime(request.Form, now())
+	if err != nil {
+		logger.Error("unable to determine the job start time", zap.Error(err))
+		xhttp.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+
+	input, ok := decodeJob(logger, response, request)
+	if !ok {
+		return
+	}
+
+	sj, err := s.Scheduler.Schedule(input, at)
+	if err != nil {
+		logger.Error("unable to schedule drain job", zap.Error(err))
+		xhttp.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+
+	writeJSON(logger, response, sj)
+}
+
+// Jobs is an HTTP handler that lists the running, pending, and finished jobs of a Scheduler
+type Jobs struct {
+	Scheduler Scheduler
+}
+
+func (j *Jobs) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	output := map[string]interface{}{
+		"pending": j.Scheduler.Pending(),
+		"history": j.Scheduler.History(),
+	}
+
+	if running, ok := j.Scheduler.Running(); ok {
+		output["running"] = running
+	}
+
+	writeJSON(sallust.Get(request.Context()), response, output)
+}
+
+// GetJob is an HTTP handler that returns the scheduled job named by a gorilla path variable
+type GetJob struct {
+	Scheduler Scheduler
+	Variable  string
+}
+
+func (gj *GetJob) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	logger := sallust.Get(request.Context())
+	id, ok := jobID(logger, gj.Variable, response, request)
+	if !ok {
+		return
+	}
+
+	sj, ok := gj.Scheduler.Get(id)
+	if !ok {
+		xhttp.WriteError(response, http.StatusNotFound, ErrJobNotFound)
+		return
+	}
+
+	writeJSON(logger, response, sj)
+}
+
+// RemoveJob is an HTTP handler that deletes a pending job, or cancels a running job, named by a
+// gorilla path variable
+type RemoveJob struct {
+	Scheduler Scheduler
+	Variable  string
+}
+
+func (rj *RemoveJob) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	logger := sallust.Get(request.Context())
+	id, ok := jobID(logger, rj.Variable, response, request)
+	if !ok {
+		return
+	}
+
+	sj, err := rj.Scheduler.Remove(id)
+	switch {
+	case errors.Is(err, ErrJobNotFound):
+		xhttp.WriteError(response, http.StatusNotFound, err)
+
+	case err != nil:
+		logger.Error("unable to remove drain job", zap.Uint64("jobID", id), zap.Error(err))
+		xhttp.WriteError(response, http.StatusConflict, err)
+
+	default:
+		writeJSON(logger, response, sj)
+	}
+}
@@ -0,0 +1,196 @@
+package drain
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/sallust"
+)
+
+func newScheduleRequest(method, uri string) *http.Request {
+	return httptest.NewRequest(method, uri, nil).WithContext(
+		sallust.With(context.Background(), sallust.Default()),
+	)
+}
+
+func testScheduleServeHTTP(t *testing.T) {
+	testData := []struct {
+		uri          string
+		expectedCode int
+		expectedJob  Job
+		expectedAt   time.Time
+	}{
+		{
+			uri:          "/schedule?count=10",
+			expectedCode: http.StatusOK,
+			expectedJob:  Job{Count: 10},
+		},
+		{
+			uri:          "/schedule?count=10&rate=5&tick=1m&at=2024-06-01T05:00:00Z",
+			expectedCode: http.StatusOK,
+			expectedJob:  Job{Count: 10, Rate: 5, Tick: time.Minute},
+			expectedAt:   time.Date(2024, 6, 1, 5, 0, 0, 0, time.UTC),
+		},
+		{
+			uri:          "/schedule?percent=20&delay=2h",
+			expectedCode: http.StatusOK,
+			expectedJob:  Job{Percent: 20},
+			expectedAt:   time.Date(2024, 6, 1, 4, 0, 0, 0, time.UTC),
+		},
+		{
+			uri:          "/schedule?at=2024-06-01T05:00:00Z&delay=2h",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			uri:          "/schedule?at=tomorrow",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			uri:          "/schedule?delay=soon",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			uri:          "/schedule?count=asdf",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			uri:          "/schedule?target=-1",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, record := range testData {
+		t.Run(record.uri, func(t *testing.T) {
+			var (
+				assert      = assert.New(t)
+				require     = require.New(t)
+				s, _, clock = newTestScheduler(t)
+				handler     = Schedule{Scheduler: s, Now: clock.now}
+				response    = httptest.NewRecorder()
+			)
+
+			handler.ServeHTTP(response, newScheduleRequest("POST", record.uri))
+			assert.Equal(record.expectedCode, response.Code)
+			if record.expectedCode != http.StatusOK {
+				assert.Empty(s.Pending())
+				return
+			}
+
+			assert.Equal("application/json", response.Header().Get("Content-Type"))
+			var output map[string]interface{}
+			require.NoError(json.Unmarshal(response.Body.Bytes(), &output))
+
+			sj, ok := s.Get(uint64(output["id"].(float64)))
+			require.True(ok)
+			assert.Equal(record.expectedJob, sj.Job)
+			assert.True(record.expectedAt.Equal(sj.At))
+		})
+	}
+}
+
+func testJobsServeHTTP(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		require     = require.New(t)
+		s, _, clock = newTestScheduler(t)
+		handler     = Jobs{Scheduler: s}
+		response    = httptest.NewRecorder()
+
+		first  = mustSchedule(t, s, Job{Count: 1}, clock.now().Add(time.Hour))
+		second = mustSchedule(t, s, Job{Count: 2}, clock.now().Add(2*time.Hour))
+	)
+
+	_, err := s.Remove(second.ID)
+	require.NoError(err)
+
+	handler.ServeHTTP(response, newScheduleRequest("GET", "/jobs"))
+	assert.Equal(http.StatusOK, response.Code)
+
+	var output struct {
+		Running *map[string]interface{}  `json:"running"`
+		Pending []map[string]interface{} `json:"pending"`
+		History []map[string]interface{} `json:"history"`
+	}
+
+	require.NoError(json.Unmarshal(response.Body.Bytes(), &output))
+	assert.Nil(output.Running)
+	require.Len(output.Pending, 1)
+	assert.Equal(float64(first.ID), output.Pending[0]["id"])
+	require.Len(output.History, 1)
+	assert.Equal(float64(second.ID), output.History[0]["id"])
+	assert.Equal(JobCanceled, output.History[0]["state"])
+}
+
+func testGetJobServeHTTP(t *testing.T) {
+	var (
+		s, _, clock = newTestScheduler(t)
+		handler     = GetJob{Scheduler: s, Variable: "id"}
+		sj          = mustSchedule(t, s, Job{Count: 1}, clock.now().Add(time.Hour))
+	)
+
+	testData := []struct {
+		id           string
+		expectedCode int
+	}{
+		{id: "1", expectedCode: http.StatusOK},
+		{id: "2", expectedCode: http.StatusNotFound},
+		{id: "abc", expectedCode: http.StatusBadRequest},
+	}
+
+	for _, record := range testData {
+		t.Run(record.id, func(t *testing.T) {
+			var (
+				assert   = assert.New(t)
+				response = httptest.NewRecorder()
+				request  = mux.SetURLVars(newScheduleRequest("GET", "/jobs/"+record.id), map[string]string{"id": record.id})
+			)
+
+			handler.ServeHTTP(response, request)
+			assert.Equal(record.expectedCode, response.Code)
+			if record.expectedCode == http.StatusOK {
+				assert.True(strings.Contains(response.Body.String(), `"state":"scheduled"`))
+				assert.Equal(uint64(1), sj.ID)
+			}
+		})
+	}
+}
+
+func testRemoveJobServeHTTP(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		s, _, clock = newTestScheduler(t)
+		handler     = RemoveJob{Scheduler: s, Variable: "id"}
+		sj          = mustSchedule(t, s, Job{Count: 1}, clock.now().Add(time.Hour))
+	)
+
+	remove := func(id string) *httptest.ResponseRecorder {
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, mux.SetURLVars(newScheduleRequest("DELETE", "/jobs/"+id), map[string]string{"id": id}))
+		return response
+	}
+
+	response := remove("1")
+	assert.Equal(http.StatusOK, response.Code)
+	assert.Contains(response.Body.String(), `"state":"canceled"`)
+	assert.Empty(s.Pending())
+
+	assert.Equal(http.StatusConflict, remove("1").Code)
+	assert.Equal(http.StatusNotFound, remove("2").Code)
+	assert.Equal(http.StatusBadRequest, remove("").Code)
+	assert.Equal(uint64(1), sj.ID)
+}
+
+func TestScheduleHandlers(t *testing.T) {
+	t.Run("Schedule", testScheduleServeHTTP)
+	t.Run("Jobs", testJobsServeHTTP)
+	t.Run("GetJob", testGetJobServeHTTP)
+	t.Run("RemoveJob", testRemoveJobServeHTTP)
+}
@@ -0,0 +1,568 @@
+package drain
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/xmidt-org/sallust"
+	"github.com/xmidt-org/webpa-common/v2/device"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrJobNotFound = errors.New("no such drain job")
+	ErrJobFinished = errors.New("that drain job has already finished")
+)
+
+// The states of a ScheduledJob
+const (
+	JobScheduled = "scheduled"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobCanceled  = "canceled"
+	JobFailed    = "failed"
+)
+
+const (
+	// DefaultHistorySize is the number of finished jobs a Scheduler remembers when no size is configured
+	DefaultHistorySize = 100
+
+	// DefaultRetryInterval is how long a Scheduler waits before trying again to start a due job
+	// when the drainer is busy with a job started elsewhere.  Jobs that fail to start for any other
+	// reason are not retried.
+	DefaultRetryInterval = 10 * time.Second
+)
+
+// ScheduledJob describes a Job submitted to a Scheduler, along with its state
+type ScheduledJob struct {
+	// ID is the Scheduler-assigned identifier for this job
+	ID uint64
+
+	// Job is the drain job.  Once the job starts, this will reflect the normalized
+	// Job returned by the drainer.
+	Job Job
+
+	// State is one of the Job* state constants
+	State string
+
+	// Submitted is the UTC time at which the job was handed to the Scheduler
+	Submitted time.Time
+
+	// At is the time at which the job should start.  If zero, the job is queued
+	// to start as soon as no other job is running.
+	At time.Time
+
+	// Progress is the job's progress.  This field is nil for jobs that have not started.
+	Progress *Progress
+
+	// Error describes why the job could not be started.  This field is only set for failed jobs.
+	Error string
+}
+
+// MarshalJSON renders this ScheduledJob using Job.ToMap, so that fields like Tick are human-readable
+func (sj ScheduledJob) MarshalJSON() ([]byte, error) {
+	m := map[string]interface{}{
+		"id":        sj.ID,
+		"job":       sj.Job.ToMap(),
+		"state":     sj.State,
+		"submitted": sj.Submitted,
+	}
+
+	if !sj.At.IsZero() {
+		m["at"] = sj.At
+	}
+
+	if sj.Progress != nil {
+		m["progress"] = sj.Progress
+	}
+
+	if len(sj.Error) > 0 {
+		m["error"] = sj.Error
+	}
+
+	return json.Marshal(m)
+}
+
+// due returns the time at which this job becomes eligible to run
+func (sj ScheduledJob) due() time.Time {
+	if sj.At.IsZero() {
+		return sj.Submitted
+	}
+
+	return sj.At
+}
+
+// Scheduler is a drain Interface that also queues jobs and schedules them for a future time.
+// Jobs run one at a time, in order of their start time.  Jobs started directly via Start are
+// also tracked, and all finished jobs are kept in a bounded history.
+//
+// A Scheduler is also a Controller, Previewer, and Validator.  These forward to the scheduled drainer, returning
+// ErrNotSupported for a drainer that does not implement them, except that Validate accepts every Job in that case.
+type Scheduler interface {
+	Interface
+	Controller
+	Previewer
+	Validator
+
+	// Schedule submits a job to start at the given time.  A zero time queues the job to start
+	// as soon as any running job finishes.  A job that fails Validate is refused with that error.
+	Schedule(j Job, at time.Time) (ScheduledJob, error)
+
+	// Get returns the job with the given identifier, whether pending, running, or finished
+	Get(id uint64) (ScheduledJob, bool)
+
+	// Remove deletes a pending job or cancels the running job.  ErrJobNotFound is returned if no such job
+	// exists, and ErrJobFinished is returned if the job is in the history.
+	Remove(id uint64) (ScheduledJob, error)
+
+	// Running returns the job that is currently running, if any
+	Running() (ScheduledJob, bool)
+
+	// Pending returns the jobs waiting to run, in the order they will run
+	Pending() []ScheduledJob
+
+	// History returns finished jobs, most recent first
+	History() []ScheduledJob
+
+	// Stop halts the scheduling goroutine.  Pending jobs will no longer be started, but any
+	// running job is not canceled.
+	Stop()
+}
+
+// SchedulerOption configures a Scheduler
+type SchedulerOption func(*scheduler)
+
+// WithHistorySize sets the number of finished jobs a Scheduler remembers.  If nonpositive,
+// DefaultHistorySize is used.
+func WithHistorySize(n int) SchedulerOption {
+	return func(s *scheduler) {
+		if n > 0 {
+			s.historySize = n
+		} else {
+			s.historySize = DefaultHistorySize
+		}
+	}
+}
+
+// WithRetryInterval sets how long a Scheduler waits to retry a due job when the drainer is busy.
+// If nonpositive, DefaultRetryInterval is used.
+func WithRetryInterval(d time.Duration) SchedulerOption {
+	return func(s *scheduler) {
+		if d > 0 {
+			s.retryInterval = d
+		} else {
+			s.retryInterval = DefaultRetryInterval
+		}
+	}
+}
+
+// WithSchedulerLogger sets the logger for a Scheduler
+func WithSchedulerLogger(l *zap.Logger) SchedulerOption {
+	return func(s *scheduler) {
+		if l != nil {
+			s.logger = l
+		} else {
+			s.logger = sallust.Default()
+		}
+	}
+}
+
+func defaultNewTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	timer := time.NewTimer(d)
+	return timer.C, timer.Stop
+}
+
+// NewScheduler creates a Scheduler that runs jobs using the given drainer.  The returned Scheduler
+// has already started its goroutine.
+func NewScheduler(d Interface, options ...SchedulerOption) Scheduler {
+	if d == nil {
+		panic("A drain Interface is required")
+	}
+
+	s := &scheduler{
+		drainer:       d,
+		logger:        sallust.Default(),
+		now:           time.Now,
+		newTimer:      defaultNewTimer,
+		historySize:   DefaultHistorySize,
+		retryInterval: DefaultRetryInterval,
+		wake:          make(chan struct{}, 1),
+		shutdown:      make(chan struct{}),
+	}
+
+	for _, f := range options {
+		f(s)
+	}
+
+	go s.run()
+	return s
+}
+
+// runningJob is the job currently executing in the drainer
+type runningJob struct {
+	sj       *ScheduledJob
+	done     <-chan struct{}
+	canceled bool
+}
+
+// scheduler is the internal Scheduler implementation
+type scheduler struct {
+	drainer       Interface
+	logger        *zap.Logger
+	now           func() time.Time
+	newTimer      func(time.Duration) (<-chan time.Time, func() bool)
+	historySize   int
+	retryInterval time.Duration
+
+	lock    sync.Mutex
+	nextID  uint64
+	pending []*ScheduledJob
+	running *runningJob
+	history []ScheduledJob
+
+	wake     chan struct{}
+	shutdown chan struct{}
+	stopOnce sync.Once
+}
+
+// signal nudges the scheduling goroutine to reevaluate its state
+func (s *scheduler) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *scheduler) newJob(j Job, at time.Time) *ScheduledJob {
+	s.nextID++
+	return &ScheduledJob{
+		ID:        s.nextID,
+		Job:       j,
+		Submitted: s.now().UTC(),
+		At:        at,
+	}
+}
+
+// snapshot returns a copy of the given job, filling in the live progress if it is running
+func (s *scheduler) snapshot(sj *ScheduledJob) ScheduledJob {
+	c := *sj
+	if c.State == JobRunning {
+		_, _, p := s.drainer.Status()
+		c.Progress = &p
+	}
+
+	return c
+}
+
+func (s *scheduler) addHistory(sj ScheduledJob) {
+	s.history = append(s.history, sj)
+	if len(s.history) > s.historySize {
+		s.history = s.history[len(s.history)-s.historySize:]
+	}
+}
+
+func (s *scheduler) Schedule(j Job, at time.Time) (ScheduledJob, error) {
+	if err := s.Validate(j); err != nil {
+		return ScheduledJob{}, err
+	}
+
+	s.lock.Lock()
+	sj := s.newJob(j, at)
+	sj.State = JobScheduled
+
+	s.pending = append(s.pending, sj)
+	sort.SliceStable(s.pending, func(i, k int) bool {
+		return s.pending[i].due().Before(s.pending[k].due())
+	})
+
+	c := *sj
+	s.lock.Unlock()
+
+	s.logger.Info("drain job scheduled", zap.Uint64("jobID", c.ID), zap.Time("at", c.due()))
+	s.signal()
+	return c, nil
+}
+
+func (s *scheduler) Get(id uint64) (ScheduledJob, bool) {
+	defer s.lock.Unlock()
+	s.lock.Lock()
+
+	if s.running != nil && s.running.sj.ID == id {
+		return s.snapshot(s.running.sj), true
+	}
+
+	for _, sj := range s.pending {
+		if sj.ID == id {
+			return *sj, true
+		}
+	}
+
+	for _, sj := range s.history {
+		if sj.ID == id {
+			return sj, true
+		}
+	}
+
+	return ScheduledJob{}, false
+}
+
+func (s *scheduler) Remove(id uint64) (ScheduledJob, error) {
+	s.lock.Lock()
+	if s.running != nil && s.running.sj.ID == id {
+		s.lock.Unlock()
+		if _, err := s.Cancel(); err != nil {
+			return ScheduledJob{}, err
+		}
+
+		// the job is recorded as canceled once the drainer actually stops
+		sj, _ := s.Get(id)
+		sj.State = JobCanceled
+		return sj, nil
+	}
+
+	defer s.lock.Unlock()
+	for i, sj := range s.pending {
+		if sj.ID == id {
+			s.pending = append(s.pending[:i], s.pending[i+1:]...)
+			sj.State = JobCanceled
+			s.addHistory(*sj)
+			s.logger.Info("drain job removed", zap.Uint64("jobID", id))
+			return *sj, nil
+		}
+	}
+
+	for _, sj := range s.history {
+		if sj.ID == id {
+			return ScheduledJob{}, ErrJobFinished
+		}
+	}
+
+	return ScheduledJob{}, ErrJobNotFound
+}
+
+func (s *scheduler) Running() (ScheduledJob, bool) {
+	defer s.lock.Unlock()
+	s.lock.Lock()
+
+	if s.running != nil {
+		return s.snapshot(s.running.sj), true
+	}
+
+	return ScheduledJob{}, false
+}
+
+func (s *scheduler) Pending() []ScheduledJob {
+	defer s.lock.Unlock()
+	s.lock.Lock()
+
+	pending := make([]ScheduledJob, len(s.pending))
+	for i, sj := range s.pending {
+		pending[i] = *sj
+	}
+
+	return pending
+}
+
+func (s *scheduler) History() []ScheduledJob {
+	defer s.lock.Unlock()
+	s.lock.Lock()
+
+	history := make([]ScheduledJob, len(s.history))
+	for i, sj := range s.history {
+		history[len(history)-1-i] = sj
+	}
+
+	return history
+}
+
+// Start immediately starts a job, bypassing any pending jobs.  The job is tracked like any other.
+func (s *scheduler) Start(j Job) (<-chan struct{}, Job, error) {
+	s.lock.Lock()
+	if s.running != nil {
+		s.lock.Unlock()
+		return nil, Job{}, ErrActive
+	}
+
+	done, actual, err := s.drainer.Start(j)
+	if err != nil {
+		s.lock.Unlock()
+		return nil, Job{}, err
+	}
+
+	sj := s.newJob(actual, time.Time{})
+	s.startedLocked(sj, done)
+	s.lock.Unlock()
+
+	s.signal()
+	return done, actual, nil
+}
+
+func (s *scheduler) Status() (bool, Job, Progress) {
+	return s.drainer.Status()
+}
+
+// Cancel cancels the running job, recording it as canceled
+func (s *scheduler) Cancel() (<-chan struct{}, error) {
+	defer s.lock.Unlock()
+	s.lock.Lock()
+
+	done, err := s.drainer.Cancel()
+	if err == nil && s.running != nil {
+		s.running.canceled = true
+	}
+
+	return done, err
+}
+
+// Pause pauses the running job, if the drainer is a Controller
+func (s *scheduler) Pause() error {
+	if c, ok := s.drainer.(Controller); ok {
+		return c.Pause()
+	}
+
+	return ErrNotSupported
+}
+
+// Resume resumes the running job, if the drainer is a Controller
+func (s *scheduler) Resume() error {
+	if c, ok := s.drainer.(Controller); ok {
+		return c.Resume()
+	}
+
+	return ErrNotSupported
+}
+
+// Adjust adjusts the running job, if the drainer is a Controller
+func (s *scheduler) Adjust(rate int, tick time.Duration) (Job, error) {
+	if c, ok := s.drainer.(Controller); ok {
+		return c.Adjust(rate, tick)
+	}
+
+	return Job{}, ErrNotSupported
+}
+
+// DryRun previews a job, if the drainer is a Previewer
+func (s *scheduler) DryRun(j Job) (Job, *device.Preview, error) {
+	if p, ok := s.drainer.(Previewer); ok {
+		return p.DryRun(j)
+	}
+
+	return Job{}, nil, ErrNotSupported
+}
+
+// Validate checks a job, if the drainer is a Validator.  Jobs are not checked by other drainers.
+func (s *scheduler) Validate(j Job) error {
+	if v, ok := s.drainer.(Validator); ok {
+		return v.Validate(j)
+	}
+
+	return nil
+}
+
+func (s *scheduler) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.shutdown)
+	})
+}
+
+// startedLocked records a job as running.  The lock must be held.
+func (s *scheduler) startedLocked(sj *ScheduledJob, done <-chan struct{}) {
+	sj.State = JobRunning
+	s.running = &runningJob{sj: sj, done: done}
+	s.logger.Info("drain job started", zap.Uint64("jobID", sj.ID), zap.Any("job", sj.Job.ToMap()))
+}
+
+// finish moves the running job into the history once the drainer signals that it is done
+func (s *scheduler) finish() {
+	defer s.lock.Unlock()
+	s.lock.Lock()
+
+	if s.running == nil {
+		return
+	}
+
+	sj := s.running.sj
+	_, _, p := s.drainer.Status()
+	sj.Progress = &p
+	if s.running.canceled {
+		sj.State = JobCanceled
+	} else {
+		sj.State = JobCompleted
+	}
+
+	s.running = nil
+	s.addHistory(*sj)
+	s.logger.Info("drain job finished", zap.Uint64("jobID", sj.ID), zap.String("state", sj.State), zap.Int("drained", p.Drained))
+}
+
+// dispatch starts the next pending job if it is due and nothing is running.  It returns the done channel
+// of the running job, if any, and how long to wait before trying again.  A zero wait means there is
+// nothing to wait for.  Due jobs which fail to start because the drainer is busy are retried, while jobs
+// which fail for any other reason are moved into the history as failed.
+func (s *scheduler) dispatch() (<-chan struct{}, time.Duration) {
+	defer s.lock.Unlock()
+	s.lock.Lock()
+
+	for {
+		if s.running != nil {
+			return s.running.done, 0
+		}
+
+		if len(s.pending) == 0 {
+			return nil, 0
+		}
+
+		next := s.pending[0]
+		if wait := next.due().Sub(s.now()); wait > 0 {
+			return nil, wait
+		}
+
+		done, actual, err := s.drainer.Start(next.Job)
+		if errors.Is(err, ErrActive) {
+			s.logger.Info("drainer busy, will retry drain job", zap.Uint64("jobID", next.ID), zap.Duration("retryInterval", s.retryInterval))
+			return nil, s.retryInterval
+		}
+
+		s.pending = s.pending[1:]
+		if err != nil {
+			next.State = JobFailed
+			next.Error = err.Error()
+			s.addHistory(*next)
+			s.logger.Error("unable to start drain job", zap.Uint64("jobID", next.ID), zap.Error(err))
+			continue
+		}
+
+		next.Job = actual
+		s.startedLocked(next, done)
+	}
+}
+
+// run is the scheduling goroutine
+func (s *scheduler) run() {
+	for {
+		var (
+			done, wait = s.dispatch()
+			timer      <-chan time.Time
+			stop       = func() bool { return true }
+		)
+
+		if wait > 0 {
+			timer, stop = s.newTimer(wait)
+		}
+
+		select {
+		case <-done:
+			s.finish()
+		case <-timer:
+		case <-s.wake:
+		case <-s.shutdown:
+			stop()
+			return
+		}
+
+		stop()
+	}
+}
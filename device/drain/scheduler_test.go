@@ -0,0 +1,353 @@
+package drain
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// testSchedulerClock drives a scheduler's notion of time
+type testSchedulerClock struct {
+	lock    sync.Mutex
+	current time.Time
+	waits   chan time.Duration
+	fire    chan time.Time
+}
+
+func newTestSchedulerClock() *testSchedulerClock {
+	return &testSchedulerClock{
+		current: time.Date(2024, 6, 1, 2, 0, 0, 0, time.UTC),
+		waits:   make(chan time.Duration, 100),
+		fire:    make(chan time.Time),
+	}
+}
+
+func (c *testSchedulerClock) now() time.Time {
+	defer c.lock.Unlock()
+	c.lock.Lock()
+	return c.current
+}
+
+func (c *testSchedulerClock) add(d time.Duration) {
+	defer c.lock.Unlock()
+	c.lock.Lock()
+	c.current = c.current.Add(d)
+}
+
+func (c *testSchedulerClock) newTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	c.waits <- d
+	return c.fire, func() bool { return true }
+}
+
+func (c *testSchedulerClock) option() SchedulerOption {
+	return func(s *scheduler) {
+		s.now = c.now
+		s.newTimer = c.newTimer
+	}
+}
+
+func newTestScheduler(t *testing.T, options ...SchedulerOption) (Scheduler, *stubDrainer, *testSchedulerClock) {
+	var (
+		sd    = newStubDrainer()
+		clock = newTestSchedulerClock()
+		s     = NewScheduler(sd, append([]SchedulerOption{WithSchedulerLogger(zap.NewNop()), clock.option()}, options...)...)
+	)
+
+	t.Cleanup(s.Stop)
+	return s, sd, clock
+}
+
+func waitForState(t *testing.T, s Scheduler, id uint64, state string) ScheduledJob {
+	var sj ScheduledJob
+	require.Eventually(t, func() bool {
+		sj, _ = s.Get(id)
+		return sj.State == state
+	}, 5*time.Second, time.Millisecond, "job %d never reached state %s", id, state)
+
+	return sj
+}
+
+func mustSchedule(t *testing.T, s Scheduler, j Job, at time.Time) ScheduledJob {
+	sj, err := s.Schedule(j, at)
+	require.NoError(t, err)
+	return sj
+}
+
+func testNewSchedulerNilDrainer(t *testing.T) {
+	assert.Panics(t, func() {
+		NewScheduler(nil)
+	})
+}
+
+func testSchedulerQueueAndSchedule(t *testing.T) {
+	var (
+		assert          = assert.New(t)
+		require         = require.New(t)
+		s, sd, clock    = newTestScheduler(t)
+		start           = clock.now()
+		later           = mustSchedule(t, s, Job{Count: 10}, start.Add(time.Hour))
+		scheduledLater  = <-clock.waits
+		queued          = mustSchedule(t, s, Job{Count: 20, Rate: 5}, time.Time{})
+		startedJob      = <-sd.started
+		running, active = s.Running()
+	)
+
+	assert.Equal(time.Hour, scheduledLater)
+	assert.Equal(JobScheduled, later.State)
+	assert.Equal(start.Add(time.Hour), later.At)
+	assert.Equal(Job{Count: 20, Rate: 5, Tick: time.Second}, startedJob)
+
+	// the queued job runs first, as it is due now
+	if !active {
+		running = waitForState(t, s, queued.ID, JobRunning)
+	}
+
+	assert.Equal(queued.ID, running.ID)
+	assert.Equal(Job{Count: 20, Rate: 5, Tick: time.Second}, running.Job)
+	require.NotNil(running.Progress)
+	assert.Equal(20, running.Progress.Visited)
+
+	pending := s.Pending()
+	require.Len(pending, 1)
+	assert.Equal(later.ID, pending[0].ID)
+
+	// a job started while another is running is refused
+	_, _, err := s.Start(Job{})
+	assert.Equal(ErrActive, err)
+
+	// once the queued job finishes, the scheduled job waits for its time
+	sd.finish()
+	finished := waitForState(t, s, queued.ID, JobCompleted)
+	require.NotNil(finished.Progress)
+	assert.Equal(20, finished.Progress.Drained)
+	assert.Equal(time.Hour, <-clock.waits)
+
+	clock.add(time.Hour)
+	clock.fire <- time.Time{}
+	assert.Equal(Job{Count: 10}, <-sd.started)
+	waitForState(t, s, later.ID, JobRunning)
+	assert.Empty(s.Pending())
+
+	// removing the running job cancels it
+	removed, err := s.Remove(later.ID)
+	assert.NoError(err)
+	assert.Equal(JobCanceled, removed.State)
+	waitForState(t, s, later.ID, JobCanceled)
+
+	history := s.History()
+	require.Len(history, 2)
+	assert.Equal(later.ID, history[0].ID)
+	assert.Equal(queued.ID, history[1].ID)
+}
+
+func testSchedulerRemove(t *testing.T) {
+	var (
+		assert       = assert.New(t)
+		require      = require.New(t)
+		s, _, clock  = newTestScheduler(t, WithHistorySize(2))
+		first        = mustSchedule(t, s, Job{Count: 1}, clock.now().Add(time.Hour))
+		second       = mustSchedule(t, s, Job{Count: 2}, clock.now().Add(2*time.Hour))
+		third        = mustSchedule(t, s, Job{Count: 3}, clock.now().Add(3*time.Hour))
+		removed, err = s.Remove(second.ID)
+	)
+
+	require.NoError(err)
+	assert.Equal(second.ID, removed.ID)
+	assert.Equal(JobCanceled, removed.State)
+
+	pending := s.Pending()
+	require.Len(pending, 2)
+	assert.Equal(first.ID, pending[0].ID)
+	assert.Equal(third.ID, pending[1].ID)
+
+	_, err = s.Remove(second.ID)
+	assert.Equal(ErrJobFinished, err)
+
+	_, err = s.Remove(12345)
+	assert.Equal(ErrJobNotFound, err)
+
+	_, ok := s.Get(12345)
+	assert.False(ok)
+
+	// the history is bounded
+	_, err = s.Remove(first.ID)
+	require.NoError(err)
+	_, err = s.Remove(third.ID)
+	require.NoError(err)
+
+	history := s.History()
+	require.Len(history, 2)
+	assert.Equal(third.ID, history[0].ID)
+	assert.Equal(first.ID, history[1].ID)
+
+	_, ok = s.Get(second.ID)
+	assert.False(ok)
+}
+
+func testSchedulerRetry(t *testing.T) {
+	var (
+		assert       = assert.New(t)
+		s, sd, clock = newTestScheduler(t, WithRetryInterval(time.Minute))
+	)
+
+	// simulate a job started directly on the drainer
+	sd.setActive(true)
+	queued := mustSchedule(t, s, Job{Count: 5}, time.Time{})
+	assert.Equal(time.Minute, <-clock.waits)
+
+	sd.setActive(false)
+	clock.fire <- time.Time{}
+	assert.Equal(Job{Count: 5}, <-sd.started)
+	waitForState(t, s, queued.ID, JobRunning)
+}
+
+func testSchedulerInvalid(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		s, _, _ = newTestScheduler(t)
+	)
+
+	sj, err := s.Schedule(Job{Target: -1}, time.Time{})
+	assert.Equal(ErrInvalidTarget, err)
+	assert.Equal(ScheduledJob{}, sj)
+	assert.Empty(s.Pending())
+	assert.Empty(s.History())
+}
+
+func testSchedulerNotSupported(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		clock   = newTestSchedulerClock()
+		sd      = newStubDrainer()
+		s       = NewScheduler(basicDrainer{sd}, WithSchedulerLogger(zap.NewNop()), clock.option())
+	)
+
+	defer s.Stop()
+	assert.Equal(ErrNotSupported, s.Pause())
+	assert.Equal(ErrNotSupported, s.Resume())
+
+	_, err := s.Adjust(10, time.Second)
+	assert.Equal(ErrNotSupported, err)
+
+	_, preview, err := s.DryRun(Job{})
+	assert.Nil(preview)
+	assert.Equal(ErrNotSupported, err)
+
+	// without a Validator, jobs are accepted as is
+	assert.NoError(s.Validate(Job{Target: -1}))
+	sj, err := s.Schedule(Job{Target: -1}, time.Time{})
+	require.NoError(err)
+	assert.NotZero(sj.ID)
+}
+
+func testSchedulerFailed(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		require       = require.New(t)
+		s, sd, clock  = newTestScheduler(t)
+		expectedError = errors.New("expected")
+	)
+
+	// a job that cannot start is not retried, and does not block the jobs behind it
+	sd.setStartErr(expectedError)
+	failed := mustSchedule(t, s, Job{Count: 1}, time.Time{})
+	failed = waitForState(t, s, failed.ID, JobFailed)
+	assert.Equal(expectedError.Error(), failed.Error)
+	assert.Nil(failed.Progress)
+	assert.Empty(s.Pending())
+
+	sd.setStartErr(nil)
+	later := mustSchedule(t, s, Job{Count: 2}, clock.now().Add(time.Hour))
+	assert.Equal(time.Hour, <-clock.waits)
+	clock.add(time.Hour)
+	clock.fire <- time.Time{}
+	assert.Equal(Job{Count: 2}, <-sd.started)
+	waitForState(t, s, later.ID, JobRunning)
+
+	history := s.History()
+	require.Len(history, 1)
+	assert.Equal(failed, history[0])
+}
+
+func testSchedulerStartAndCancel(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		s, _, _ = newTestScheduler(t)
+	)
+
+	done, actual, err := s.Start(Job{Count: 7})
+	require.NoError(err)
+	assert.Equal(Job{Count: 7}, actual)
+
+	active, job, _ := s.Status()
+	assert.True(active)
+	assert.Equal(Job{Count: 7}, job)
+
+	running, ok := s.Running()
+	require.True(ok)
+	assert.Equal(JobRunning, running.State)
+
+	cancelDone, err := s.Cancel()
+	require.NoError(err)
+	assert.Equal(done, cancelDone)
+	waitForState(t, s, running.ID, JobCanceled)
+
+	_, err = s.Cancel()
+	assert.Equal(ErrNotActive, err)
+}
+
+func testScheduledJobMarshalJSON(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		at      = time.Date(2024, 6, 1, 3, 0, 0, 0, time.UTC)
+	)
+
+	data, err := json.Marshal(ScheduledJob{
+		ID:        3,
+		Job:       Job{Count: 100, Rate: 10, Tick: time.Minute},
+		State:     JobScheduled,
+		Submitted: at.Add(-time.Hour),
+		At:        at,
+	})
+
+	require.NoError(err)
+	assert.JSONEq(
+		`{"id": 3, "job": {"count": 100, "rate": 10, "tick": "1m0s"}, "state": "scheduled", "submitted": "2024-06-01T02:00:00Z", "at": "2024-06-01T03:00:00Z"}`,
+		string(data),
+	)
+
+	data, err = json.Marshal(ScheduledJob{
+		ID:        4,
+		Job:       Job{Count: 100},
+		State:     JobFailed,
+		Submitted: at,
+		Error:     "expected",
+	})
+
+	require.NoError(err)
+	assert.JSONEq(
+		`{"id": 4, "job": {"count": 100}, "state": "failed", "submitted": "2024-06-01T03:00:00Z", "error": "expected"}`,
+		string(data),
+	)
+}
+
+func TestScheduler(t *testing.T) {
+	t.Run("NilDrainer", testNewSchedulerNilDrainer)
+	t.Run("QueueAndSchedule", testSchedulerQueueAndSchedule)
+	t.Run("Remove", testSchedulerRemove)
+	t.Run("Retry", testSchedulerRetry)
+	t.Run("Invalid", testSchedulerInvalid)
+	t.Run("NotSupported", testSchedulerNotSupported)
+	t.Run("Failed", testSchedulerFailed)
+	t.Run("StartAndCancel", testSchedulerStartAndCancel)
+	t.Run("MarshalJSON", testScheduledJobMarshalJSON)
+}
@@ -2,7 +2,6 @@ package drain
 
 import (
 	"encoding/json"
-	"io/ioutil"
 	"net/http"
 	"time"
 
@@ -18,12 +17,13 @@ type Start struct {
 	Drainer Interface
 }
 
-func (s *Start) ServeHTTP(response http.ResponseWriter, request *http.Request) {
-	logger := sallust.Get(request.Context())
+// decodeJob parses a Job from a request's form along with an optional JSON filter in the body.
+// Any error is written to the response.
+func decodeJob(logger *zap.Logger, response http.ResponseWriter, request *http.Request) (Job, bool) {
 	if err := request.ParseForm(); err != nil {
 		logger.Error("unable to parse form", zap.Error(err))
 		xhttp.WriteError(response, http.StatusBadRequest, err)
-		return
+		return Job{}, false
 	}
 
 	var (
@@ -36,26 +36,41 @@ func (s *Start) ServeHTTP(response http.ResponseWriter, request *http.Request) {
 	if err := decoder.Decode(&input, request.Form); err != nil {
 		logger.Error("unable to decode request", zap.Error(err))
 		xhttp.WriteError(response, http.StatusBadRequest, err)
-		return
+		return Job{}, false
 	}
 
-	msgBytes, err := ioutil.ReadAll(request.Body)
+	msgBytes, err := devicegate.ReadFilterRequest(request.Body)
 	defer request.Body.Close()
 
 	if err != nil {
 		logger.Error("unable to read request body", zap.Error(err))
 		xhttp.WriteError(response, http.StatusBadRequest, err)
-		return
+		return Job{}, false
 	}
 
 	if len(msgBytes) > 0 {
 		if err := json.Unmarshal(msgBytes, &reqBody); err != nil {
 			logger.Error("unable to unmarshal request body", zap.Error(err))
 			xhttp.WriteError(response, http.StatusBadRequest, err)
-			return
+			return Job{}, false
 		}
 
-		if len(reqBody.Key) > 0 && len(reqBody.Values) > 0 {
+		if len(reqBody.Expression) > 0 {
+			e, err := devicegate.DecodeExpression(reqBody.Expression)
+			if err != nil {
+				logger.Error("unable to decode filter expression", zap.Error(err))
+				xhttp.WriteError(response, http.StatusBadRequest, err)
+				return Job{}, false
+			}
+
+			fg := devicegate.FilterGate{FilterStore: make(devicegate.FilterStore)}
+			fg.SetExpression(reqBody.Key, e)
+
+			input.DrainFilter = &drainFilter{
+				filter:        &fg,
+				filterRequest: reqBody,
+			}
+		} else if len(reqBody.Key) > 0 && len(reqBody.Values) > 0 {
 			fg := devicegate.FilterGate{FilterStore: make(devicegate.FilterStore)}
 			fg.SetFilter(reqBody.Key, reqBody.Values)
 
@@ -66,6 +81,16 @@ func (s *Start) ServeHTTP(response http.ResponseWriter, request *http.Request) {
 		}
 	}
 
+	return input, true
+}
+
+func (s *Start) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	logger := sallust.Get(request.Context())
+	input, ok := decodeJob(logger, response, request)
+	if !ok {
+		return
+	}
+
 	_, output, err := s.Drainer.Start(input)
 	if err != nil {
 		logger.Error("unable to start drain job", zap.Error(err))
@@ -3,9 +3,11 @@ package drain
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -13,6 +15,7 @@ import (
 	"github.com/xmidt-org/webpa-common/v2/device/devicegate"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func testStartServeHTTPDefaultLogger(t *testing.T) {
@@ -66,6 +69,14 @@ func testStartServeHTTPValid(t *testing.T) {
 			uri:      "/foo?count=22&rate=10&tick=20s",
 			expected: Job{Count: 22, Rate: 10, Tick: 20 * time.Second},
 		},
+		{
+			uri:      "/foo?target=1000&closeGate=true",
+			expected: Job{Target: 1000, CloseGate: true},
+		},
+		{
+			uri:      "/foo?targetPercent=90&rate=10",
+			expected: Job{TargetPercent: 90, Rate: 10},
+		},
 	}
 
 	for _, record := range testData {
@@ -113,6 +124,19 @@ func testStartServeHTTPWithBody(t *testing.T) {
 		},
 	}
 
+	expression, err := devicegate.ParseExpression(`partner-id == "comcast" && trust < 1000`)
+	require.NoError(t, err)
+
+	expressionFilter := &drainFilter{
+		filter: &devicegate.FilterGate{
+			FilterStore: make(devicegate.FilterStore),
+			Expressions: devicegate.ExpressionStore{"": expression},
+		},
+		filterRequest: devicegate.FilterRequest{
+			Expression: json.RawMessage(`"partner-id == \"comcast\" && trust < 1000"`),
+		},
+	}
+
 	testData := []struct {
 		description        string
 		body               []byte
@@ -127,6 +151,19 @@ func testStartServeHTTPWithBody(t *testing.T) {
 			expectedJSON:       `{"count": 47192, "percent": 57, "rate": 500, "tick": "37s", "filter":{"key": "test", "values": ["test1", "test2"]}}`,
 			expectedStatusCode: http.StatusOK,
 		},
+		{
+			description:        "Success with expression",
+			body:               []byte(`{"expression": "partner-id == \"comcast\" && trust < 1000"}`),
+			expected:           Job{Count: 22, Rate: 10, Tick: 20 * time.Second, DrainFilter: expressionFilter},
+			expectedJSON:       `{"count": 47192, "percent": 57, "rate": 500, "tick": "37s", "filter":{"key": "", "values": null, "expression": "partner-id == \"comcast\" && trust < 1000"}}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			description:        "Invalid expression",
+			body:               []byte(`{"expression": "partner-id =="}`),
+			expected:           Job{Count: 22, Rate: 10, Tick: 20 * time.Second},
+			expectedStatusCode: http.StatusBadRequest,
+		},
 		{
 			description:        "Unmarshal error",
 			body:               []byte(`this is not a filter request`),
@@ -234,6 +271,25 @@ func testStartServeHTTPInvalidQuery(t *testing.T) {
 	d.AssertExpectations(t)
 }
 
+func testStartServeHTTPBodyTooLarge(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		d     = new(mockDrainer)
+		start = Start{d}
+
+		ctx      = sallust.With(context.Background(), sallust.Default())
+		response = httptest.NewRecorder()
+		body     = strings.Repeat(" ", devicegate.MaxFilterRequestSize+1)
+		request  = httptest.NewRequest("POST", "/foo", strings.NewReader(body)).WithContext(ctx)
+	)
+
+	start.ServeHTTP(response, request)
+	assert.Equal(http.StatusBadRequest, response.Code)
+	// nolint: typecheck
+	d.AssertExpectations(t)
+}
+
 func testStartServeHTTPStartError(t *testing.T) {
 	var (
 		assert = assert.New(t)
@@ -263,6 +319,7 @@ func TestStart(t *testing.T) {
 		t.Run("WithBody", testStartServeHTTPWithBody)
 		t.Run("ParseFormError", testStartServeHTTPParseFormError)
 		t.Run("InvalidQuery", testStartServeHTTPInvalidQuery)
+		t.Run("BodyTooLarge", testStartServeHTTPBodyTooLarge)
 		t.Run("StartError", testStartServeHTTPStartError)
 	})
 }
@@ -10,8 +10,8 @@ import (
 
 // Progress represents a snapshot of what a drain job has done so far.
 type Progress struct {
-	// Visited is the number of devices handled so far.  This value will not
-	// exceed the Job.Count value.
+	// Visited is the number of devices handled so far.  Except for targeted jobs,
+	// this value will not exceed the Job.Count value.
 	Visited int `json:"visited"`
 
 	// Drained is the count of visited devices that have actually been disconnected
@@ -25,6 +25,14 @@ type Progress struct {
 	// Finished is the UTC system time at which the drain job finished or was canceled.
 	// If the job is running, this field will be nil.
 	Finished *time.Time `json:"finished,omitempty"`
+
+	// Paused is the UTC system time at which the drain job was paused.  If the job
+	// is not paused, this field will be nil.
+	Paused *time.Time `json:"paused,omitempty"`
+
+	// Target is the most recently computed number of devices a targeted job is draining
+	// down to.  This field is zero for jobs that disconnect a fixed count.
+	Target int `json:"target,omitempty"`
 }
 
 type tracker struct {
@@ -32,6 +40,8 @@ type tracker struct {
 	drained  int32
 	started  time.Time
 	finished atomic.Value
+	paused   atomic.Value
+	target   int32
 	counter  xmetrics.Adder
 }
 
@@ -40,12 +50,17 @@ func (t *tracker) Progress() Progress {
 		Visited: int(atomic.LoadInt32(&t.visited)),
 		Drained: int(atomic.LoadInt32(&t.drained)),
 		Started: t.started,
+		Target:  int(atomic.LoadInt32(&t.target)),
 	}
 
 	if finished, ok := t.finished.Load().(time.Time); ok && !finished.IsZero() {
 		p.Finished = &finished
 	}
 
+	if paused, ok := t.paused.Load().(time.Time); ok && !paused.IsZero() {
+		p.Paused = &paused
+	}
+
 	return p
 }
 
@@ -60,4 +75,17 @@ func (t *tracker) addDrained(delta int) {
 
 func (t *tracker) done(timestamp time.Time) {
 	t.finished.Store(timestamp)
+	t.paused.Store(time.Time{})
+}
+
+func (t *tracker) setTarget(target int) {
+	atomic.StoreInt32(&t.target, int32(target))
+}
+
+func (t *tracker) pause(timestamp time.Time) {
+	t.paused.Store(timestamp)
+}
+
+func (t *tracker) resume() {
+	t.paused.Store(time.Time{})
 }
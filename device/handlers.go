@@ -2,6 +2,8 @@ package device
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"sync"
@@ -100,6 +102,10 @@ type MessageHandler struct {
 
 	// Router is the device message Router to use.  This field is required.
 	Router Router
+
+	// Caller extracts the Caller that the Router's Authorizer checks from each request, unless one
+	// is already present in the request's context.  If not set, BasculeCaller is used.
+	Caller CallerFromRequest
 }
 
 func (mh *MessageHandler) logger() *zap.Logger {
@@ -120,12 +126,31 @@ func (mh *MessageHandler) decodeRequest(httpRequest *http.Request) (deviceReques
 
 	deviceRequest, err = DecodeRequest(httpRequest.Body, format)
 	if err == nil {
-		deviceRequest = deviceRequest.WithContext(httpRequest.Context())
+		deviceRequest = deviceRequest.WithContext(mh.withCaller(httpRequest))
 	}
 
 	return
 }
 
+// withCaller returns the request's context, with the request's Caller added if it has none
+func (mh *MessageHandler) withCaller(httpRequest *http.Request) context.Context {
+	ctx := httpRequest.Context()
+	if _, ok := GetCaller(ctx); ok {
+		return ctx
+	}
+
+	f := mh.Caller
+	if f == nil {
+		f = BasculeCaller
+	}
+
+	if caller, ok := f(httpRequest); ok {
+		ctx = WithCaller(ctx, caller)
+	}
+
+	return ctx
+}
+
 func (mh *MessageHandler) ServeHTTP(httpResponse http.ResponseWriter, httpRequest *http.Request) {
 	deviceRequest, err := mh.decodeRequest(httpRequest)
 	if err != nil {
@@ -157,6 +182,13 @@ func (mh *MessageHandler) ServeHTTP(httpResponse http.ResponseWriter, httpReques
 	// deviceRequest carries the context through the routing infrastructure
 	if deviceResponse, err := mh.Router.Route(deviceRequest); err != nil {
 		code := http.StatusGatewayTimeout
+		switch {
+		case errors.Is(err, ErrorRouteForbidden):
+			code = http.StatusForbidden
+		case errors.Is(err, ErrorMessageTooLarge):
+			code = http.StatusRequestEntityTooLarge
+		}
+
 		switch err {
 		case ErrorInvalidDeviceName:
 			code = http.StatusBadRequest
@@ -168,6 +200,8 @@ func (mh *MessageHandler) ServeHTTP(httpResponse http.ResponseWriter, httpReques
 			code = http.StatusBadRequest
 		case ErrorTransactionAlreadyRegistered:
 			code = http.StatusBadRequest
+		case ErrorServiceNotAdvertised:
+			code = http.StatusNotImplemented
 		}
 
 		mh.logger().Error("Could not process device request", zap.Error(err), zap.Int("code", code))
@@ -316,6 +316,67 @@ func testMessageHandlerServeHTTPEvent(t *testing.T, requestFormat wrp.Format) {
 	router.AssertExpectations(t)
 }
 
+func testMessageHandlerServeHTTPCaller(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		// nolint: typecheck
+		event = &wrp.SimpleEvent{Source: "test.com", Destination: "mac:123412341234"}
+
+		requestContents []byte
+		actualCallers   []Caller
+		missingCallers  int
+
+		router = new(mockRouter)
+
+		newRequest = func() *http.Request {
+			request := httptest.NewRequest("POST", "/foo", bytes.NewReader(requestContents))
+			// nolint: typecheck
+			request.Header.Set("Content-Type", wrp.Msgpack.ContentType())
+			return request
+		}
+	)
+
+	// nolint: typecheck
+	require.NoError(wrp.NewEncoderBytes(&requestContents, wrp.Msgpack).Encode(event))
+
+	router.On(
+		"Route",
+		mock.MatchedBy(func(candidate *Request) bool {
+			if caller, ok := GetCaller(candidate.Context()); ok {
+				actualCallers = append(actualCallers, caller)
+			} else {
+				missingCallers++
+			}
+
+			return true
+		}),
+	).Times(3).Return(nil, nil)
+
+	// the default strategy finds no bascule token
+	handler := MessageHandler{Router: router}
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest())
+	assert.Equal(1, missingCallers)
+
+	handler.Caller = func(*http.Request) (Caller, bool) {
+		return Caller{PartnerIDs: []string{"comcast"}}, true
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest())
+
+	// a caller already in the context takes precedence
+	request := newRequest()
+	handler.ServeHTTP(httptest.NewRecorder(), request.WithContext(WithCaller(request.Context(), Caller{PartnerIDs: []string{"sky"}})))
+
+	assert.Equal(
+		[]Caller{{PartnerIDs: []string{"comcast"}}, {PartnerIDs: []string{"sky"}}},
+		actualCallers,
+	)
+
+	router.AssertExpectations(t)
+}
+
 // nolint: typecheck
 func testMessageHandlerServeHTTPRequestResponse(t *testing.T, responseFormat, requestFormat wrp.Format) {
 	const transactionKey = "transaction-key"
@@ -493,8 +554,14 @@ func TestMessageHandler(t *testing.T) {
 			testMessageHandlerServeHTTPRouteError(t, ErrorInvalidTransactionKey, http.StatusBadRequest)
 			testMessageHandlerServeHTTPRouteError(t, ErrorTransactionAlreadyRegistered, http.StatusBadRequest)
 			testMessageHandlerServeHTTPRouteError(t, errors.New("random error"), http.StatusGatewayTimeout)
+			testMessageHandlerServeHTTPRouteError(t, ErrorRouteForbidden, http.StatusForbidden)
+			testMessageHandlerServeHTTPRouteError(t, &AuthorizationError{Reason: DenyReasonPartnerMismatch}, http.StatusForbidden)
+			testMessageHandlerServeHTTPRouteError(t, ErrorServiceNotAdvertised, http.StatusNotImplemented)
+			testMessageHandlerServeHTTPRouteError(t, &MessageTooLargeError{Size: 10, Max: 5}, http.StatusRequestEntityTooLarge)
 		})
 
+		t.Run("Caller", testMessageHandlerServeHTTPCaller)
+
 		t.Run("Event", func(t *testing.T) {
 			// nolint: typecheck
 			for _, requestFormat := range []wrp.Format{wrp.Msgpack, wrp.JSON} {
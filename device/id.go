@@ -3,11 +3,6 @@ package device
 import (
 	"fmt"
 	"net/http"
-	"regexp"
-	"strings"
-
-	// nolint: typecheck
-	"unicode"
 )
 
 // ID represents a normalized identifier for a device.
@@ -25,15 +20,7 @@ const (
 	macLength     = 12
 )
 
-var (
-	invalidID = ID("")
-
-	// idPattern is the precompiled regular expression that all device identifiers must match.
-	// Matching is partial, as everything after the service is ignored.
-	idPattern = regexp.MustCompile(
-		`^(?P<prefix>(?i)mac|uuid|dns|serial):(?P<id>[^/]+)(?P<service>/[^/]+)?`,
-	)
-)
+var invalidID = ID("")
 
 // IntToMAC accepts a 64-bit integer and formats that as a device MAC address identifier
 // The returned ID will be of the form mac:XXXXXXXXXXXX, where X is a hexadecimal digit using
@@ -42,41 +29,10 @@ func IntToMAC(value uint64) ID {
 	return ID(fmt.Sprintf("mac:%012x", value&0x0000FFFFFFFFFFFF))
 }
 
-// ParseID parses a raw device name into a canonicalized identifier.
+// ParseID parses a raw device name into a canonicalized identifier using the
+// currently configured IDSchemes.  See SetIDSchemes.
 func ParseID(deviceName string) (ID, error) {
-	match := idPattern.FindStringSubmatch(deviceName)
-	if match == nil {
-		return invalidID, ErrorInvalidDeviceName
-	}
-
-	var (
-		prefix = strings.ToLower(match[1])
-		idPart = match[2]
-	)
-
-	if prefix == macPrefix {
-		var invalidCharacter rune = -1
-		idPart = strings.Map(
-			func(r rune) rune {
-				switch {
-				case strings.ContainsRune(hexDigits, r):
-					return unicode.ToLower(r)
-				case strings.ContainsRune(macDelimiters, r):
-					return -1
-				default:
-					invalidCharacter = r
-					return -1
-				}
-			},
-			idPart,
-		)
-
-		if invalidCharacter != -1 || len(idPart) != macLength {
-			return invalidID, ErrorInvalidDeviceName
-		}
-	}
-
-	return ID(fmt.Sprintf("%s:%s", prefix, idPart)), nil
+	return CurrentIDSchemes().ParseID(deviceName)
 }
 
 // IDHashParser is a parsing function that examines an HTTP request to produce
@@ -0,0 +1,365 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"unicode"
+)
+
+// Built-in IDScheme types, used in IDSchemeConfig.Type
+const (
+	IDSchemeTypeMAC     = "mac"
+	IDSchemeTypeIMEI    = "imei"
+	IDSchemeTypeOpaque  = "opaque"
+	IDSchemeTypePattern = "pattern"
+)
+
+const (
+	imeiLength     = 15
+	imeiDelimiters = " -."
+)
+
+var (
+	ErrorInvalidIDScheme   = errors.New("Invalid device ID scheme")
+	ErrorDuplicateIDScheme = errors.New("Duplicate device ID scheme prefix")
+
+	// idSchemePrefixPattern restricts the prefixes that may be registered.  Prefixes are
+	// matched case-insensitively, so they are always stored lowercased.
+	idSchemePrefixPattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+	// currentIDSchemes holds the *IDSchemes used by ParseID
+	currentIDSchemes atomic.Value
+)
+
+func init() {
+	currentIDSchemes.Store(DefaultIDSchemes())
+}
+
+// IDScheme describes one kind of device identifier, e.g. mac:112233445566.  Each scheme
+// owns a prefix and supplies its own validation and canonicalization of the value that follows it.
+type IDScheme interface {
+	// Prefix returns the lowercased scheme name that precedes the colon in a device name
+	Prefix() string
+
+	// Canonicalize validates the portion of the device name after the prefix, with any
+	// service suffix already removed, and returns its canonical form.
+	Canonicalize(value string) (string, error)
+}
+
+type idScheme struct {
+	prefix       string
+	canonicalize func(string) (string, error)
+}
+
+func (s idScheme) Prefix() string {
+	return s.prefix
+}
+
+func (s idScheme) Canonicalize(value string) (string, error) {
+	return s.canonicalize(value)
+}
+
+// NewIDScheme produces an IDScheme from a prefix and a canonicalization closure.
+// The prefix is lowercased.
+func NewIDScheme(prefix string, canonicalize func(string) (string, error)) IDScheme {
+	return idScheme{
+		prefix:       strings.ToLower(prefix),
+		canonicalize: canonicalize,
+	}
+}
+
+// MACScheme returns the IDScheme for MAC addresses.  Delimiters are removed and hexadecimal
+// digits are lowercased, so that mac:11-AA-BB-44-55-66 becomes mac:11aabb445566.
+func MACScheme() IDScheme {
+	return NewIDScheme(macPrefix, canonicalizeMAC)
+}
+
+// OpaqueScheme returns an IDScheme that accepts any nonempty value as is.  The uuid, dns,
+// and serial schemes are opaque.
+func OpaqueScheme(prefix string) IDScheme {
+	return NewIDScheme(prefix, func(value string) (string, error) {
+		return value, nil
+	})
+}
+
+// IMEIScheme returns an IDScheme for 15 digit IMEI numbers, which must carry a valid Luhn check digit.
+// Spaces, dashes, and periods are removed.
+func IMEIScheme(prefix string) IDScheme {
+	return NewIDScheme(prefix, canonicalizeIMEI)
+}
+
+// PatternScheme returns an IDScheme whose values must fully match the given regular expression.
+// If transform is non-nil, it is applied to the value prior to matching, e.g. strings.ToUpper.
+func PatternScheme(prefix string, pattern *regexp.Regexp, transform func(string) string) IDScheme {
+	return NewIDScheme(prefix, func(value string) (string, error) {
+		if transform != nil {
+			value = transform(value)
+		}
+
+		if !pattern.MatchString(value) {
+			return "", ErrorInvalidDeviceName
+		}
+
+		return value, nil
+	})
+}
+
+func canonicalizeMAC(value string) (string, error) {
+	var invalidCharacter rune = -1
+	value = strings.Map(
+		func(r rune) rune {
+			switch {
+			case strings.ContainsRune(hexDigits, r):
+				return unicode.ToLower(r)
+			case strings.ContainsRune(macDelimiters, r):
+				return -1
+			default:
+				invalidCharacter = r
+				return -1
+			}
+		},
+		value,
+	)
+
+	if invalidCharacter != -1 || len(value) != macLength {
+		return "", ErrorInvalidDeviceName
+	}
+
+	return value, nil
+}
+
+func canonicalizeIMEI(value string) (string, error) {
+	var invalidCharacter rune = -1
+	value = strings.Map(
+		func(r rune) rune {
+			switch {
+			case r >= '0' && r <= '9':
+				return r
+			case strings.ContainsRune(imeiDelimiters, r):
+				return -1
+			default:
+				invalidCharacter = r
+				return -1
+			}
+		},
+		value,
+	)
+
+	if invalidCharacter != -1 || len(value) != imeiLength || !luhnValid(value) {
+		return "", ErrorInvalidDeviceName
+	}
+
+	return value, nil
+}
+
+// luhnValid checks the trailing check digit of a string of ASCII digits
+func luhnValid(digits string) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+
+		sum += d
+		double = !double
+	}
+
+	return sum%10 == 0
+}
+
+// IDSchemes is an immutable set of IDSchemes, keyed by prefix.  It is the strategy
+// used to turn raw device names into canonical IDs.
+type IDSchemes struct {
+	schemes map[string]IDScheme
+}
+
+// NewIDSchemes builds an IDSchemes from the given schemes.  An error is returned if any
+// scheme has an invalid prefix or if two schemes share the same prefix.
+func NewIDSchemes(schemes ...IDScheme) (*IDSchemes, error) {
+	s := &IDSchemes{
+		schemes: make(map[string]IDScheme, len(schemes)),
+	}
+
+	for _, scheme := range schemes {
+		if scheme == nil || !idSchemePrefixPattern.MatchString(scheme.Prefix()) {
+			return nil, ErrorInvalidIDScheme
+		}
+
+		if _, ok := s.schemes[scheme.Prefix()]; ok {
+			return nil, fmt.Errorf("%w: %s", ErrorDuplicateIDScheme, scheme.Prefix())
+		}
+
+		s.schemes[scheme.Prefix()] = scheme
+	}
+
+	return s, nil
+}
+
+// DefaultIDSchemes returns the schemes that are recognized when nothing else is configured:
+// mac, uuid, dns, and serial.
+func DefaultIDSchemes() *IDSchemes {
+	s, _ := NewIDSchemes(
+		MACScheme(),
+		OpaqueScheme("uuid"),
+		OpaqueScheme("dns"),
+		OpaqueScheme("serial"),
+	)
+
+	return s
+}
+
+// Prefixes returns the sorted set of prefixes recognized by these schemes
+func (s *IDSchemes) Prefixes() []string {
+	prefixes := make([]string, 0, len(s.schemes))
+	for prefix := range s.schemes {
+		prefixes = append(prefixes, prefix)
+	}
+
+	sort.Strings(prefixes)
+	return prefixes
+}
+
+// ParseID parses a raw device name into a canonicalized identifier.  Everything after the
+// first slash following the prefix, such as a service name, is ignored.
+func (s *IDSchemes) ParseID(deviceName string) (ID, error) {
+	prefix, value, ok := strings.Cut(deviceName, ":")
+	if !ok || len(prefix) == 0 {
+		return invalidID, ErrorInvalidDeviceName
+	}
+
+	if i := strings.IndexByte(value, '/'); i >= 0 {
+		value = value[:i]
+	}
+
+	if len(value) == 0 {
+		return invalidID, ErrorInvalidDeviceName
+	}
+
+	prefix = strings.ToLower(prefix)
+	scheme, ok := s.schemes[prefix]
+	if !ok {
+		return invalidID, ErrorInvalidDeviceName
+	}
+
+	canonical, err := scheme.Canonicalize(value)
+	if err != nil || len(canonical) == 0 {
+		return invalidID, ErrorInvalidDeviceName
+	}
+
+	return ID(prefix + ":" + canonical), nil
+}
+
+// SetIDSchemes replaces the schemes used by ParseID, and thus by IDHashParser, UseID, and
+// the WRP source check.  Passing nil restores DefaultIDSchemes.  This function is typically
+// called once at startup.
+func SetIDSchemes(s *IDSchemes) {
+	if s == nil {
+		s = DefaultIDSchemes()
+	}
+
+	currentIDSchemes.Store(s)
+}
+
+// CurrentIDSchemes returns the schemes currently used by ParseID
+func CurrentIDSchemes() *IDSchemes {
+	return currentIDSchemes.Load().(*IDSchemes)
+}
+
+// IDSchemeConfig is the external configuration for a single IDScheme
+type IDSchemeConfig struct {
+	// Prefix is the scheme name, e.g. "imei".  Prefixes are case-insensitive.  This field is required.
+	Prefix string
+
+	// Type is one of mac, imei, opaque, or pattern.  If unset, opaque is used unless
+	// Pattern is set, in which case pattern is used.
+	Type string
+
+	// Pattern is the regular expression a value must match when Type is pattern.
+	// The expression is anchored at both ends.
+	Pattern string
+
+	// Case is either "lower" or "upper", and applies to pattern schemes prior to matching.
+	// If unset, the value's case is preserved.
+	Case string
+}
+
+// NewIDScheme produces the IDScheme described by this configuration
+func (c IDSchemeConfig) NewIDScheme() (IDScheme, error) {
+	schemeType := strings.ToLower(c.Type)
+	if len(schemeType) == 0 {
+		schemeType = IDSchemeTypeOpaque
+		if len(c.Pattern) > 0 {
+			schemeType = IDSchemeTypePattern
+		}
+	}
+
+	switch schemeType {
+	case IDSchemeTypeMAC:
+		return NewIDScheme(c.Prefix, canonicalizeMAC), nil
+
+	case IDSchemeTypeIMEI:
+		return IMEIScheme(c.Prefix), nil
+
+	case IDSchemeTypeOpaque:
+		return OpaqueScheme(c.Prefix), nil
+
+	case IDSchemeTypePattern:
+		pattern, err := regexp.Compile(`^(?:` + c.Pattern + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %s", ErrorInvalidIDScheme, c.Prefix, err)
+		}
+
+		var transform func(string) string
+		switch strings.ToLower(c.Case) {
+		case "":
+		case "lower":
+			transform = strings.ToLower
+		case "upper":
+			transform = strings.ToUpper
+		default:
+			return nil, fmt.Errorf("%w: %s: unsupported case %q", ErrorInvalidIDScheme, c.Prefix, c.Case)
+		}
+
+		return PatternScheme(c.Prefix, pattern, transform), nil
+
+	default:
+		return nil, fmt.Errorf("%w: %s: unsupported type %q", ErrorInvalidIDScheme, c.Prefix, c.Type)
+	}
+}
+
+// NewIDSchemesFromConfig builds an IDSchemes from external configuration.  The default
+// schemes are always included unless overridden by a configuration with the same prefix.
+func NewIDSchemesFromConfig(configs []IDSchemeConfig) (*IDSchemes, error) {
+	var (
+		defaults   = DefaultIDSchemes()
+		configured = make(map[string]bool, len(configs))
+		schemes    = make([]IDScheme, 0, len(defaults.schemes)+len(configs))
+	)
+
+	for _, c := range configs {
+		scheme, err := c.NewIDScheme()
+		if err != nil {
+			return nil, err
+		}
+
+		configured[scheme.Prefix()] = true
+		schemes = append(schemes, scheme)
+	}
+
+	for _, prefix := range defaults.Prefixes() {
+		if !configured[prefix] {
+			schemes = append(schemes, defaults.schemes[prefix])
+		}
+	}
+
+	return NewIDSchemes(schemes...)
+}
@@ -0,0 +1,213 @@
+package device
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLuhnValid(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(luhnValid("490154203237518"))
+	assert.True(luhnValid("79927398713"))
+	assert.False(luhnValid("490154203237519"))
+	assert.False(luhnValid("79927398710"))
+}
+
+func TestNewIDSchemes(t *testing.T) {
+	t.Run("InvalidPrefix", func(t *testing.T) {
+		s, err := NewIDSchemes(OpaqueScheme("has space"))
+		assert.Nil(t, s)
+		assert.Equal(t, ErrorInvalidIDScheme, err)
+	})
+
+	t.Run("Nil", func(t *testing.T) {
+		s, err := NewIDSchemes(nil)
+		assert.Nil(t, s)
+		assert.Equal(t, ErrorInvalidIDScheme, err)
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		s, err := NewIDSchemes(OpaqueScheme("event"), OpaqueScheme("EVENT"))
+		assert.Nil(t, s)
+		assert.True(t, errors.Is(err, ErrorDuplicateIDScheme))
+	})
+
+	t.Run("Default", func(t *testing.T) {
+		assert.Equal(t, []string{"dns", "mac", "serial", "uuid"}, DefaultIDSchemes().Prefixes())
+	})
+}
+
+func TestIDSchemesParseID(t *testing.T) {
+	schemes, err := NewIDSchemes(
+		MACScheme(),
+		IMEIScheme("imei"),
+		OpaqueScheme("event"),
+		PatternScheme("acme", regexp.MustCompile(`^ACME[0-9]{6}$`), strings.ToUpper),
+	)
+
+	require.NoError(t, err)
+	testData := []struct {
+		deviceName   string
+		expected     ID
+		expectsError bool
+	}{
+		{"mac:11:22:33:44:55:66", "mac:112233445566", false},
+		{"IMEI:490154203237518", "imei:490154203237518", false},
+		{"imei:49-015420-323751-8/service", "imei:490154203237518", false},
+		{"imei:490154203237519", "", true},
+		{"imei:4901542032375", "", true},
+		{"event:device-status", "event:device-status", false},
+		{"event:device-status/foo/bar", "event:device-status", false},
+		{"acme:acme123456", "acme:ACME123456", false},
+		{"acme:ACME12345", "", true},
+		{"uuid:1234", "", true},
+		{"event:", "", true},
+		{"event:/foo", "", true},
+		{":1234", "", true},
+		{"event", "", true},
+	}
+
+	for _, record := range testData {
+		t.Run(record.deviceName, func(t *testing.T) {
+			id, err := schemes.ParseID(record.deviceName)
+			assert.Equal(t, record.expected, id)
+			assert.Equal(t, record.expectsError, err != nil)
+		})
+	}
+}
+
+func TestSetIDSchemes(t *testing.T) {
+	defer SetIDSchemes(nil)
+
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	_, err := ParseID("imei:490154203237518")
+	assert.Equal(ErrorInvalidDeviceName, err)
+
+	schemes, err := NewIDSchemesFromConfig([]IDSchemeConfig{{Prefix: "imei", Type: "imei"}})
+	require.NoError(err)
+	SetIDSchemes(schemes)
+	assert.Equal(schemes, CurrentIDSchemes())
+
+	id, err := ParseID("imei:490154203237518")
+	assert.Equal(ID("imei:490154203237518"), id)
+	assert.NoError(err)
+
+	request := httptest.NewRequest("GET", "http://burrito-sightings.net", nil)
+	request.Header.Set(DeviceNameHeader, "imei:4901-5420-3237-518")
+	key, err := IDHashParser(request)
+	assert.Equal([]byte("imei:490154203237518"), key)
+	assert.NoError(err)
+
+	// the default schemes are retained
+	id, err = ParseID("mac:11-22-33-44-55-66")
+	assert.Equal(ID("mac:112233445566"), id)
+	assert.NoError(err)
+
+	SetIDSchemes(nil)
+	_, err = ParseID("imei:490154203237518")
+	assert.Equal(ErrorInvalidDeviceName, err)
+}
+
+func TestIDSchemeConfig(t *testing.T) {
+	testData := []struct {
+		config       IDSchemeConfig
+		value        string
+		expected     string
+		expectsError bool
+	}{
+		{IDSchemeConfig{Prefix: "hw", Type: "mac"}, "AA-BB-CC-DD-EE-FF", "aabbccddeeff", false},
+		{IDSchemeConfig{Prefix: "sn"}, "Anything", "Anything", false},
+		{IDSchemeConfig{Prefix: "sn", Pattern: "[a-z]+", Case: "lower"}, "ABC", "abc", false},
+		{IDSchemeConfig{Prefix: "sn", Type: "pattern", Pattern: "[A-Z]+", Case: "upper"}, "abc1", "", true},
+		{IDSchemeConfig{Prefix: "sn", Pattern: "[a-z]+"}, "abcd/", "", true},
+	}
+
+	for _, record := range testData {
+		t.Run(record.config.Prefix+":"+record.value, func(t *testing.T) {
+			scheme, err := record.config.NewIDScheme()
+			require.NoError(t, err)
+			assert.Equal(t, record.config.Prefix, scheme.Prefix())
+
+			actual, err := scheme.Canonicalize(record.value)
+			assert.Equal(t, record.expected, actual)
+			assert.Equal(t, record.expectsError, err != nil)
+		})
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, c := range []IDSchemeConfig{
+			{Prefix: "x", Type: "nosuch"},
+			{Prefix: "x", Pattern: "("},
+			{Prefix: "x", Pattern: "a", Case: "title"},
+		} {
+			scheme, err := c.NewIDScheme()
+			assert.Nil(t, scheme)
+			assert.True(t, errors.Is(err, ErrorInvalidIDScheme))
+		}
+	})
+}
+
+func TestNewIDSchemesFromConfig(t *testing.T) {
+	t.Run("OverrideDefault", func(t *testing.T) {
+		schemes, err := NewIDSchemesFromConfig([]IDSchemeConfig{{Prefix: "serial", Pattern: "[0-9]+"}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"dns", "mac", "serial", "uuid"}, schemes.Prefixes())
+
+		_, err = schemes.ParseID("serial:abc")
+		assert.Error(t, err)
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		schemes, err := NewIDSchemesFromConfig([]IDSchemeConfig{{Prefix: "imei"}, {Prefix: "imei"}})
+		assert.Nil(t, schemes)
+		assert.Error(t, err)
+	})
+}
+
+func TestNewIDSchemesFromViper(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		require       = require.New(t)
+		configuration = `{
+			"device": {
+				"idSchemes": [
+					{"prefix": "imei", "type": "imei"},
+					{"prefix": "event", "pattern": "[a-z-]+"}
+				]
+			}
+		}`
+
+		v = viper.New()
+	)
+
+	schemes, err := NewIDSchemesFromViper(nil, IDSchemesKey)
+	require.NoError(err)
+	assert.Equal(DefaultIDSchemes().Prefixes(), schemes.Prefixes())
+
+	schemes, err = NewIDSchemesFromViper(v, IDSchemesKey)
+	require.NoError(err)
+	assert.Equal(DefaultIDSchemes().Prefixes(), schemes.Prefixes())
+
+	v.SetConfigType("json")
+	require.NoError(v.ReadConfig(bytes.NewBufferString(configuration)))
+
+	schemes, err = NewIDSchemesFromViper(v, IDSchemesKey)
+	require.NoError(err)
+	assert.Equal([]string{"dns", "event", "imei", "mac", "serial", "uuid"}, schemes.Prefixes())
+
+	id, err := schemes.ParseID("event:device-status")
+	assert.Equal(ID("event:device-status"), id)
+	assert.NoError(err)
+}
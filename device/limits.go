@@ -0,0 +1,57 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/xmidt-org/wrp-go/v3"
+)
+
+// Directions for oversize messages, used as the direction label of the oversize message counter
+const (
+	DirectionInbound  = "inbound"
+	DirectionOutbound = "outbound"
+)
+
+// MessageTooLargeCloseText is the close reason text used when a device is disconnected
+// for sending a frame larger than the configured maximum
+const MessageTooLargeCloseText = "message-too-large"
+
+var ErrorMessageTooLarge = errors.New("The message exceeds the maximum allowed size")
+
+// MessageTooLargeError is returned when a request's payload exceeds the configured maximum.
+// All MessageTooLargeErrors match ErrorMessageTooLarge with errors.Is.
+type MessageTooLargeError struct {
+	// Size is the size, in bytes, of the rejected payload
+	Size int
+
+	// Max is the configured maximum payload size in bytes
+	Max int
+}
+
+func (mtle *MessageTooLargeError) Error() string {
+	return fmt.Sprintf("%s: %d bytes exceeds the limit of %d bytes", ErrorMessageTooLarge, mtle.Size, mtle.Max)
+}
+
+func (mtle *MessageTooLargeError) Is(target error) bool {
+	return target == ErrorMessageTooLarge
+}
+
+// readLimiter is implemented by connections, such as *websocket.Conn, which can enforce
+// a maximum inbound frame size.  When a frame exceeds the limit, the connection is expected to
+// send a close frame to the peer and return websocket.ErrReadLimit.
+type readLimiter interface {
+	SetReadLimit(int64)
+}
+
+// payloadSize returns the size of the payload a request will deliver to a device.  When the request
+// carries a decoded WRP message, the size of that message's payload is used.  Otherwise, the size of
+// the request's encoded contents is used.
+func payloadSize(request *Request) int {
+	// nolint: typecheck
+	if message, ok := request.Message.(*wrp.Message); ok {
+		return len(message.Payload)
+	}
+
+	return len(request.Contents)
+}
@@ -0,0 +1,64 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/wrp-go/v3"
+)
+
+func TestMessageTooLargeError(t *testing.T) {
+	var (
+		assert       = assert.New(t)
+		err    error = &MessageTooLargeError{Size: 100, Max: 10}
+	)
+
+	assert.True(errors.Is(err, ErrorMessageTooLarge))
+	assert.Contains(err.Error(), ErrorMessageTooLarge.Error())
+	assert.Contains(err.Error(), "100")
+	assert.Contains(err.Error(), "10")
+}
+
+func TestPayloadSize(t *testing.T) {
+	assert := assert.New(t)
+
+	// nolint: typecheck
+	assert.Equal(3, payloadSize(&Request{Message: &wrp.Message{Payload: []byte("abc")}, Contents: []byte("much longer contents")}))
+	assert.Equal(8, payloadSize(&Request{Contents: []byte("contents")}))
+	assert.Zero(payloadSize(&Request{}))
+}
+
+func TestManagerRouteOversize(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		oversize = newTestCounter()
+
+		m = NewManager(&Options{MaxOutboundPayloadSize: 4}).(*manager)
+	)
+
+	m.measures.Oversize = oversize
+	d, _, err := m.newDevice(WithID(context.Background(), "mac:112233445566"), nil)
+	require.NoError(err)
+	require.NoError(m.devices.add(d))
+
+	response, err := m.Route(&Request{
+		// nolint: typecheck
+		Message: &wrp.Message{
+			Type:        wrp.SimpleEventMessageType,
+			Destination: "mac:112233445566/config",
+			Payload:     []byte("too large"),
+		},
+	})
+
+	assert.Nil(response)
+	var mtle *MessageTooLargeError
+	require.True(errors.As(err, &mtle))
+	assert.Equal(MessageTooLargeError{Size: 9, Max: 4}, *mtle)
+	assert.Equal(1.0, oversize.count)
+	assert.Equal(DirectionOutbound, oversize.labelPairs["direction"])
+	assert.Zero(d.Pending())
+}
@@ -1,7 +1,9 @@
 package device
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -66,6 +68,16 @@ type Connector interface {
 	GetFilter() Filter
 }
 
+// Attacher is implemented by connectors which can manage a device over a connection that has
+// already been established, bypassing the HTTP upgrade.  This is primarily useful for tests,
+// which can attach in-memory connections.  The Manager returned by NewManager implements this interface.
+type Attacher interface {
+	// Attach begins concurrent management of a device over the given connection.  The context must
+	// carry the device's ID, as with WithID, and may carry its Metadata.  The header supplies any
+	// convey information, just as the HTTP request headers do in Connect.
+	Attach(ctx context.Context, c Connection, header http.Header) (Interface, error)
+}
+
 // Router handles dispatching messages to devices.
 type Router interface {
 	// Route dispatches a WRP request to exactly one device, identified by the ID
@@ -150,11 +162,17 @@ func NewManager(o *Options) Manager {
 
 		deviceMessageQueueSize: o.deviceMessageQueueSize(),
 		pingPeriod:             o.pingPeriod(),
+		maxInboundFrameSize:    o.maxInboundFrameSize(),
+		maxOutboundPayloadSize: o.maxOutboundPayloadSize(),
 
 		listeners:             o.listeners(),
 		measures:              measures,
 		enforceWRPSourceCheck: wrpCheck.Type == CheckTypeEnforce,
 		filter:                o.filter(),
+		recorders:             o.recorders(),
+		newTicker:             o.newTicker(),
+		authorizer:            o.authorizer(),
+		capabilities:          o.capabilityExtractor(),
 	}
 }
 
@@ -172,19 +190,25 @@ type manager struct {
 
 	deviceMessageQueueSize int
 	pingPeriod             time.Duration
+	maxInboundFrameSize    int64
+	maxOutboundPayloadSize int
 
 	listeners             []Listener
 	measures              Measures
 	enforceWRPSourceCheck bool
 
-	filter Filter
+	filter    Filter
+	recorders []FrameRecorder
+	newTicker func(time.Duration) (<-chan time.Time, func())
+
+	authorizer   Authorizer
+	capabilities *CapabilityExtractor
 }
 
 func (m *manager) Connect(response http.ResponseWriter, request *http.Request, responseHeader http.Header) (Interface, error) {
 	m.logger.Debug("device connect", zap.Any("url", request.URL))
 	ctx := request.Context()
-	id, ok := GetID(ctx)
-	if !ok {
+	if _, ok := GetID(ctx); !ok {
 		xhttp.WriteError(
 			response,
 			http.StatusInternalServerError,
@@ -194,12 +218,58 @@ func (m *manager) Connect(response http.ResponseWriter, request *http.Request, r
 		return nil, ErrorMissingDeviceNameContext
 	}
 
+	d, cr, err := m.newDevice(ctx, request.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := m.upgrader.Upgrade(response, request, responseHeader)
+	if err != nil {
+		d.logger.Error("failed websocket upgrade", zap.Error(err))
+		return nil, err
+	}
+
+	d.logger.Debug("websocket upgrade complete", zap.String("localAddress", c.LocalAddr().String()))
+	if err := m.attach(d, c, cr); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func (m *manager) Attach(ctx context.Context, c Connection, header http.Header) (Interface, error) {
+	if _, ok := GetID(ctx); !ok {
+		return nil, ErrorMissingDeviceNameContext
+	}
+
+	d, cr, err := m.newDevice(ctx, header)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.attach(d, c, cr); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// conveyResult holds the outcome of parsing a device's convey header
+type conveyResult struct {
+	cvy convey.C
+	err error
+}
+
+// newDevice creates the device described by the given context and headers, applying this
+// manager's connection filter.  The context must carry the device's ID.
+func (m *manager) newDevice(ctx context.Context, header http.Header) (*device, conveyResult, error) {
+	id, _ := GetID(ctx)
 	metadata, ok := GetDeviceMetadata(ctx)
 	if !ok {
 		metadata = new(Metadata)
 	}
 
-	cvy, cvyErr := m.conveyTranslator.FromHeader(request.Header)
+	cvy, cvyErr := m.conveyTranslator.FromHeader(header)
 	d := newDevice(deviceOptions{
 		ID:         id,
 		C:          cvy,
@@ -207,11 +277,13 @@ func (m *manager) Connect(response http.ResponseWriter, request *http.Request, r
 		QueueSize:  m.deviceMessageQueueSize,
 		Metadata:   metadata,
 		Logger:     m.logger,
+
+		MaxPayloadSize: m.maxOutboundPayloadSize,
 	})
 
 	if allow, matchResults := m.filter.AllowConnection(d); !allow {
 		d.logger.Info("filter match found", zap.String("location", matchResults.Location), zap.String("key", matchResults.Key))
-		return nil, ErrorDeviceFilteredOut
+		return nil, conveyResult{}, ErrorDeviceFilteredOut
 	}
 
 	if len(metadata.Claims()) < 1 {
@@ -224,25 +296,23 @@ func (m *manager) Connect(response http.ResponseWriter, request *http.Request, r
 		d.logger.Error("bad or missing convey data", zap.Error(cvyErr))
 	}
 
-	c, err := m.upgrader.Upgrade(response, request, responseHeader)
-	if err != nil {
-		d.logger.Error("failed websocket upgrade", zap.Error(err))
-		return nil, err
-	}
-
-	d.logger.Debug("websocket upgrade complete", zap.String("localAddress", c.LocalAddr().String()))
+	return d, conveyResult{cvy: cvy, err: cvyErr}, nil
+}
 
+// attach registers a device with its established connection and starts the device's pumps
+func (m *manager) attach(d *device, c Connection, cr conveyResult) error {
+	cvy, cvyErr := cr.cvy, cr.err
 	pinger, err := NewPinger(c, m.measures.Ping, []byte(d.ID()), m.writeDeadline)
 	if err != nil {
 		d.logger.Error("unable to create pinger", zap.Error(err))
 		c.Close()
-		return nil, err
+		return err
 	}
 
 	if err := m.devices.add(d); err != nil {
 		d.logger.Error("unable to register device", zap.Error(err))
 		c.Close()
-		return nil, err
+		return err
 	}
 
 	event := &Event{
@@ -260,6 +330,8 @@ func (m *manager) Connect(response http.ResponseWriter, request *http.Request, r
 			d.logger.Error("unable to marshal the convey header", zap.Error(err))
 		}
 	}
+
+	metadata := d.Metadata()
 	metricClosure, err := m.conveyHWMetric.Update(cvy, "partnerid", metadata.PartnerIDClaim(), "trust", strconv.Itoa(metadata.TrustClaim()))
 	if err != nil {
 		d.logger.Error("failed to update convey metrics", zap.Error(err))
@@ -269,13 +341,29 @@ func (m *manager) Connect(response http.ResponseWriter, request *http.Request, r
 	m.dispatch(event)
 
 	SetPongHandler(c, m.measures.Pong, m.readDeadline)
+	if m.maxInboundFrameSize > 0 {
+		if rl, ok := c.(readLimiter); ok {
+			rl.SetReadLimit(m.maxInboundFrameSize)
+		} else {
+			d.logger.Warn("connection does not support a read limit", zap.Int64("maxInboundFrameSize", m.maxInboundFrameSize))
+		}
+	}
+
 	closeOnce := new(sync.Once)
 	go m.readPump(d, InstrumentReader(c, d.statistics), closeOnce)
 	go m.writePump(d, InstrumentWriter(c, d.statistics), pinger, closeOnce)
 
 	d.logger.Debug("Connection metadata", zap.String("conveyCompliance", convey.GetCompliance(cvyErr).String()), zap.Strings("conveyHeaderKeys", maps.Keys(cvy)), zap.Any("conveyHeader", cvy))
 
-	return d, nil
+	return nil
+}
+
+// record hands a frame to each configured FrameRecorder.  Each recorder decides
+// whether it is interested in the device.
+func (m *manager) record(d *device, direction TraceDirection, frame []byte) {
+	for _, r := range m.recorders {
+		r.Record(d.id, direction, frame)
+	}
 }
 
 func (m *manager) dispatch(e *Event) {
@@ -295,6 +383,12 @@ func (m *manager) pumpClose(d *device, c io.Closer, reason CloseReason) {
 	// remove will invoke requestClose()
 	m.devices.remove(d.id, reason)
 
+	// if this device had already been replaced in the registry, make sure it has a close reason
+	d.requestClose(reason)
+	closeReason := d.CloseReason()
+	m.measures.ConnectionDuration.With("reason", closeReason.Text).Observe(d.Statistics().UpTime().Seconds())
+	m.measures.CloseReasons.With("reason", closeReason.Text).Add(1.0)
+
 	closeError := c.Close()
 
 	d.logger.Error("Closed device connection",
@@ -372,6 +466,7 @@ func (m *manager) readPump(d *device, r ReadCloser, closeOnce *sync.Once) {
 
 	var (
 		readError error
+		closeText = "readerror"
 		// nolint: typecheck
 		decoder = wrp.NewDecoder(nil, wrp.Msgpack)
 		// nolint: typecheck
@@ -381,12 +476,22 @@ func (m *manager) readPump(d *device, r ReadCloser, closeOnce *sync.Once) {
 	// all the read pump has to do is ensure the device and the connection are closed
 	// it is the write pump's responsibility to do further cleanup
 	defer func() {
-		closeOnce.Do(func() { m.pumpClose(d, r, CloseReason{Err: readError, Text: "readerror"}) })
+		closeOnce.Do(func() { m.pumpClose(d, r, CloseReason{Err: readError, Text: closeText}) })
 	}()
 
 	for {
-		messageType, data, readError := r.ReadMessage()
-		if readError != nil {
+		var (
+			messageType int
+			data        []byte
+		)
+
+		messageType, data, readError = r.ReadMessage()
+		if errors.Is(readError, websocket.ErrReadLimit) {
+			d.logger.Error("frame exceeds the maximum inbound size", zap.Int64("maxInboundFrameSize", m.maxInboundFrameSize))
+			m.measures.Oversize.With("direction", DirectionInbound).Add(1.0)
+			closeText = MessageTooLargeCloseText
+			return
+		} else if readError != nil {
 			d.logger.Error("read error", zap.Error(readError))
 			return
 		}
@@ -396,6 +501,8 @@ func (m *manager) readPump(d *device, r ReadCloser, closeOnce *sync.Once) {
 			continue
 		}
 
+		m.record(d, TraceInbound, data)
+
 		var (
 			// nolint: typecheck
 			message = new(wrp.Message)
@@ -433,6 +540,7 @@ func (m *manager) readPump(d *device, r ReadCloser, closeOnce *sync.Once) {
 		}
 
 		addDeviceMetadataContext(message, d.Metadata())
+		m.extractCapabilities(d, message)
 
 		// nolint: typecheck
 		if message.Type == wrp.SimpleRequestResponseMessageType {
@@ -485,14 +593,14 @@ func (m *manager) writePump(d *device, w WriteCloser, pinger func() error, close
 		encoder    = wrp.NewEncoder(nil, wrp.Msgpack)
 		writeError error
 
-		pingTicker = time.NewTicker(m.pingPeriod)
+		pings, stopPings = m.newTicker(m.pingPeriod)
 	)
 
 	// cleanup: we not only ensure that the device and connection are closed but also
 	// ensure that any messages that were waiting and/or failed are dispatched to
 	// the configured listener
 	defer func() {
-		pingTicker.Stop()
+		stopPings()
 		closeOnce.Do(func() { m.pumpClose(d, w, CloseReason{Err: writeError, Text: "write-error"}) })
 
 		// notify listener of any message that just now failed
@@ -558,6 +666,10 @@ func (m *manager) writePump(d *device, w WriteCloser, pinger func() error, close
 				writeError = w.WriteMessage(websocket.BinaryMessage, frameContents)
 			}
 
+			if writeError == nil {
+				m.record(d, TraceOutbound, frameContents)
+			}
+
 			event := Event{
 				Device:   d,
 				Message:  envelope.request.Message,
@@ -576,7 +688,7 @@ func (m *manager) writePump(d *device, w WriteCloser, pinger func() error, close
 			close(envelope.complete)
 			m.dispatch(&event)
 
-		case <-pingTicker.C:
+		case <-pings:
 			writeError = pinger()
 		}
 	}
@@ -621,11 +733,49 @@ func (m *manager) VisitAll(visitor func(Interface) bool) int {
 	})
 }
 
+// authorize applies the configured Authorizer, if any, to a request for the given device
+func (m *manager) authorize(d *device, request *Request) error {
+	if m.authorizer == nil {
+		return nil
+	}
+
+	err := m.authorizer.Authorize(request.Context(), d, request)
+	if err == nil {
+		return nil
+	}
+
+	reason := DenyReasonError
+	var ae *AuthorizationError
+	if errors.As(err, &ae) {
+		reason = ae.Reason
+	}
+
+	m.measures.RouteDenied.With("reason", reason).Add(1.0)
+	d.logger.Error("request not authorized", zap.String("reason", reason), zap.Error(err))
+	return err
+}
+
 func (m *manager) Route(request *Request) (*Response, error) {
 	if destination, err := request.ID(); err != nil {
 		return nil, err
 	} else if d, ok := m.devices.get(destination); ok {
-		return d.Send(request)
+		if err := m.authorize(d, request); err != nil {
+			return nil, err
+		}
+
+		if m.capabilities != nil {
+			if err := m.capabilities.Allow(d, request); err != nil {
+				return nil, err
+			}
+		}
+
+		response, err := d.Send(request)
+		if errors.Is(err, ErrorMessageTooLarge) {
+			d.logger.Error("request rejected", zap.Error(err))
+			m.measures.Oversize.With("direction", DirectionOutbound).Add(1.0)
+		}
+
+		return response, err
 	} else {
 		return nil, ErrorDeviceNotFound
 	}
@@ -16,6 +16,7 @@ import (
 	"github.com/xmidt-org/webpa-common/v2/convey"
 	"github.com/xmidt-org/webpa-common/v2/xmetrics"
 
+	"github.com/gorilla/websocket"
 	"github.com/justinas/alice"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -386,6 +387,59 @@ func testManagerConnectIncludesConvey(t *testing.T) {
 	assert.Equal("WebPA-1.6", convey["webpa-protocol"])
 }
 
+func testManagerTrace(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		received = make(chan struct{}, 1)
+		tracer   = NewTracer(TracerOptions{Logger: zap.NewNop()})
+
+		options = &Options{
+			Logger: zap.NewNop(),
+			Tracer: tracer,
+			Listeners: []Listener{
+				func(event *Event) {
+					if event.Type == MessageReceived {
+						received <- struct{}{}
+					}
+				},
+			},
+		}
+
+		_, server, connectURL = startWebsocketServer(options)
+	)
+
+	defer server.Close()
+	tracer.Add(testDeviceIDs[0], time.Minute)
+
+	testDevices := connectTestDevices(t, DefaultDialer(), connectURL)
+	defer closeTestDevices(assert, testDevices)
+
+	for _, id := range testDeviceIDs[:2] {
+		// nolint: typecheck
+		require.NoError(testDevices[id].WriteMessage(websocket.BinaryMessage, encodeTestMessage(t, &wrp.Message{
+			Type:        wrp.SimpleEventMessageType,
+			Source:      string(id),
+			Destination: "event:test",
+		})))
+
+		select {
+		case <-received:
+		case <-time.After(10 * time.Second):
+			require.Fail("No message received within the timeout")
+		}
+	}
+
+	frames, ok := tracer.Capture(testDeviceIDs[0])
+	require.True(ok)
+	require.Len(frames, 1)
+	assert.Equal(TraceInbound, frames[0].Direction)
+	assert.Equal(string(testDeviceIDs[0]), frames[0].Message.Source)
+
+	_, ok = tracer.Capture(testDeviceIDs[1])
+	assert.False(ok)
+}
+
 func TestManager(t *testing.T) {
 	t.Run("Connect", func(t *testing.T) {
 		t.Run("MissingDeviceContext", testManagerConnectMissingDeviceContext)
@@ -402,6 +456,7 @@ func TestManager(t *testing.T) {
 
 	t.Run("Disconnect", testManagerDisconnect)
 	t.Run("DisconnectIf", testManagerDisconnectIf)
+	t.Run("Trace", testManagerTrace)
 }
 
 func TestGaugeCardinality(t *testing.T) {
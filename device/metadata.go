@@ -26,7 +26,7 @@ const (
 )
 
 var reservedMetadataKeys = map[string]bool{
-	JWTClaimsKey: true, SessionIDKey: true,
+	JWTClaimsKey: true, SessionIDKey: true, CapabilitiesKey: true,
 }
 
 func init() {
@@ -19,8 +19,16 @@ const (
 	DeviceLimitReachedCounter = "device_limit_reached_count"
 	ModelGauge                = "hardware_model"
 	WRPSourceCheck            = "wrp_source_check"
+	RouteDeniedCounter        = "route_denied_count"
+	ConnectionDuration        = "connection_duration_seconds"
+	CloseReasonCounter        = "close_reason_count"
+	OversizeMessageCounter    = "oversize_message_count"
 )
 
+// ConnectionDurationBuckets are the histogram buckets, in seconds, for connection lifetimes.
+// They range from a second to a week.
+var ConnectionDurationBuckets = []float64{1, 10, 60, 300, 900, 3600, 4 * 3600, 12 * 3600, 24 * 3600, 3 * 24 * 3600, 7 * 24 * 3600}
+
 // Metrics is the device module function that adds default device metrics
 func Metrics() []xmetrics.Metric {
 	return []xmetrics.Metric{
@@ -66,6 +74,29 @@ func Metrics() []xmetrics.Metric {
 			Type:       "counter",
 			LabelNames: []string{"outcome", "reason"},
 		},
+		{
+			Name:       RouteDeniedCounter,
+			Type:       "counter",
+			LabelNames: []string{"reason"},
+		},
+		{
+			Name:       ConnectionDuration,
+			Type:       "histogram",
+			Help:       "The lifetime of device connections, labelled by close reason",
+			LabelNames: []string{"reason"},
+			Buckets:    ConnectionDurationBuckets,
+		},
+		{
+			Name:       CloseReasonCounter,
+			Type:       "counter",
+			LabelNames: []string{"reason"},
+		},
+		{
+			Name:       OversizeMessageCounter,
+			Type:       "counter",
+			Help:       "The number of messages rejected for exceeding the configured size limits",
+			LabelNames: []string{"direction"},
+		},
 	}
 }
 
@@ -81,6 +112,11 @@ type Measures struct {
 	Disconnect      xmetrics.Adder
 	Models          metrics.Gauge
 	WRPSourceCheck  metrics.Counter
+	RouteDenied     metrics.Counter
+
+	ConnectionDuration metrics.Histogram
+	CloseReasons       metrics.Counter
+	Oversize           metrics.Counter
 }
 
 // NewMeasures constructs a Measures given a go-kit metrics Provider
@@ -96,5 +132,10 @@ func NewMeasures(p provider.Provider) Measures {
 		Disconnect:      p.NewCounter(DisconnectCounter),
 		Models:          p.NewGauge(ModelGauge),
 		WRPSourceCheck:  p.NewCounter(WRPSourceCheck),
+		RouteDenied:     p.NewCounter(RouteDeniedCounter),
+
+		ConnectionDuration: p.NewHistogram(ConnectionDuration, len(ConnectionDurationBuckets)),
+		CloseReasons:       p.NewCounter(CloseReasonCounter),
+		Oversize:           p.NewCounter(OversizeMessageCounter),
 	}
 }
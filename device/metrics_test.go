@@ -44,4 +44,6 @@ func TestNewMeasures(t *testing.T) {
 	assert.NotNil(m.Pong)
 	assert.NotNil(m.Connect)
 	assert.NotNil(m.Disconnect)
+	assert.NotNil(m.RouteDenied)
+	assert.NotNil(m.Oversize)
 }
@@ -66,6 +66,16 @@ type Options struct {
 	// DefaultWriteTimeout is used.
 	WriteTimeout time.Duration
 
+	// MaxInboundFrameSize is the largest frame, in bytes, a device may send.  A device that sends a larger
+	// frame is sent a close frame with status 1009 (message too big) and disconnected.  If unset, frame
+	// sizes are not limited.
+	MaxInboundFrameSize int64
+
+	// MaxOutboundPayloadSize is the largest WRP payload, in bytes, that may be sent to a device.  Larger
+	// requests are rejected with a *MessageTooLargeError before being queued.  If unset, payload sizes
+	// are not limited.
+	MaxOutboundPayloadSize int
+
 	// Listeners contains the event sinks for managers created using these options
 	Listeners []Listener
 
@@ -79,6 +89,10 @@ type Options struct {
 	// Now is the closure used to determine the current time.  If not set, time.Now is used.
 	Now func() time.Time
 
+	// NewTicker is the factory for the ticker that drives each device's pings.  The returned
+	// function stops the ticker.  If not set, a time.Ticker is used.
+	NewTicker func(time.Duration) (<-chan time.Time, func())
+
 	// WRPSourceCheck defines behavior around checking the Source field in WRP messages originating
 	// from devices. All the following are cases of an invalid WRP wrt the source:
 	// 1) Source is empty.
@@ -90,6 +104,22 @@ type Options struct {
 
 	// Filter determines whether or not a device should be able to connect to talaria based on the filters in place
 	Filter Filter
+
+	// Tracer is the optional trace list consulted for every frame read from or written to a device.
+	// Frames for traced devices are logged and captured by the Tracer.  If unset, no tracing is done.
+	Tracer *Tracer
+
+	// Authorizer, if set, is consulted before each request is routed to a device.  Requests it
+	// denies are not delivered.  If unset, every request is routed.
+	Authorizer Authorizer
+
+	// CapabilityExtractor, if set, records the capabilities devices advertise in their metadata and
+	// can refuse requests for services a device has not advertised
+	CapabilityExtractor *CapabilityExtractor
+
+	// Recorders are additional sinks for every frame read from or written to a device, such as
+	// a traffic capture.  Recorders are invoked from the device pumps, so they must not block.
+	Recorders []FrameRecorder
 }
 
 func (o *Options) upgrader() *websocket.Upgrader {
@@ -117,6 +147,22 @@ func (o *Options) maxDevices() int {
 	return 0
 }
 
+func (o *Options) maxInboundFrameSize() int64 {
+	if o != nil && o.MaxInboundFrameSize > 0 {
+		return o.MaxInboundFrameSize
+	}
+
+	return 0
+}
+
+func (o *Options) maxOutboundPayloadSize() int {
+	if o != nil && o.MaxOutboundPayloadSize > 0 {
+		return o.MaxOutboundPayloadSize
+	}
+
+	return 0
+}
+
 func (o *Options) idlePeriod() time.Duration {
 	if o != nil && o.IdlePeriod > 0 {
 		return o.IdlePeriod
@@ -174,6 +220,35 @@ func (o *Options) now() func() time.Time {
 	return time.Now
 }
 
+func defaultNewTicker(d time.Duration) (<-chan time.Time, func()) {
+	ticker := time.NewTicker(d)
+	return ticker.C, ticker.Stop
+}
+
+func (o *Options) newTicker() func(time.Duration) (<-chan time.Time, func()) {
+	if o != nil && o.NewTicker != nil {
+		return o.NewTicker
+	}
+
+	return defaultNewTicker
+}
+
+func (o *Options) authorizer() Authorizer {
+	if o != nil {
+		return o.Authorizer
+	}
+
+	return nil
+}
+
+func (o *Options) capabilityExtractor() *CapabilityExtractor {
+	if o != nil {
+		return o.CapabilityExtractor
+	}
+
+	return nil
+}
+
 func (o *Options) filter() Filter {
 	if o != nil && o.Filter != nil {
 		return o.Filter
@@ -182,6 +257,26 @@ func (o *Options) filter() Filter {
 	return defaultFilterFunc()
 }
 
+// recorders returns the FrameRecorders configured in these options, with the Tracer first if set
+func (o *Options) recorders() []FrameRecorder {
+	if o == nil {
+		return nil
+	}
+
+	recorders := make([]FrameRecorder, 0, 1+len(o.Recorders))
+	if o.Tracer != nil {
+		recorders = append(recorders, o.Tracer)
+	}
+
+	for _, r := range o.Recorders {
+		if r != nil {
+			recorders = append(recorders, r)
+		}
+	}
+
+	return recorders
+}
+
 func (o *Options) wrpCheck() wrpSourceCheckConfig {
 	if o != nil && oneOf(o.WRPSourceCheck.Type, CheckTypeEnforce, CheckTypeMonitor) {
 		return o.WRPSourceCheck
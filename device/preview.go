@@ -0,0 +1,81 @@
+package device
+
+const (
+	// DefaultPreviewSampleSize is the number of device IDs kept by a Preview when no sample size is supplied
+	DefaultPreviewSampleSize = 20
+
+	// UnknownModel is the group used in a Preview for devices that have no hw-model convey field
+	UnknownModel = "unknown"
+)
+
+// Preview summarizes the devices that an operation, such as a drain or a rehash, would disconnect.
+// Building a Preview never disconnects any device.  Instances are not safe for concurrent updates.
+type Preview struct {
+	// Visited is the number of devices examined
+	Visited int `json:"visited"`
+
+	// Count is the number of examined devices that would be disconnected
+	Count int `json:"count"`
+
+	// Reasons is the count of devices that would be disconnected, keyed by close reason text
+	Reasons map[string]int `json:"reasons"`
+
+	// Partners is the count of devices that would be disconnected, keyed by partner id
+	Partners map[string]int `json:"partners"`
+
+	// Models is the count of devices that would be disconnected, keyed by the hw-model convey field
+	Models map[string]int `json:"models"`
+
+	// Sample holds the IDs of the first devices that would be disconnected
+	Sample []ID `json:"sample"`
+
+	sampleSize int
+}
+
+// NewPreview creates an empty Preview which samples at most sampleSize device IDs.  If sampleSize is
+// nonpositive, DefaultPreviewSampleSize is used.
+func NewPreview(sampleSize int) *Preview {
+	if sampleSize < 1 {
+		sampleSize = DefaultPreviewSampleSize
+	}
+
+	return &Preview{
+		Reasons:    make(map[string]int),
+		Partners:   make(map[string]int),
+		Models:     make(map[string]int),
+		Sample:     make([]ID, 0, sampleSize),
+		sampleSize: sampleSize,
+	}
+}
+
+// Visit records that a device was examined but would not be disconnected
+func (p *Preview) Visit() {
+	p.Visited++
+}
+
+// Add records a device that would be disconnected for the given reason
+func (p *Preview) Add(d Interface, reason string) {
+	p.Visited++
+	p.Count++
+	p.Reasons[reason]++
+
+	partner := UnknownPartner
+	if m := d.Metadata(); m != nil {
+		partner = m.PartnerIDClaim()
+	}
+
+	p.Partners[partner]++
+
+	model := UnknownModel
+	if c := d.Convey(); c != nil {
+		if hwModel, ok := c.GetString("hw-model"); ok && len(hwModel) > 0 {
+			model = hwModel
+		}
+	}
+
+	p.Models[model]++
+
+	if len(p.Sample) < p.sampleSize {
+		p.Sample = append(p.Sample, d.ID())
+	}
+}
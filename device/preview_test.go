@@ -0,0 +1,45 @@
+package device
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/webpa-common/v2/convey"
+)
+
+func TestPreview(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		p      = NewPreview(2)
+
+		withPartner = new(Metadata)
+	)
+
+	withPartner.SetClaims(map[string]interface{}{PartnerIDClaimKey: "comcast"})
+	for i, record := range []struct {
+		metadata *Metadata
+		convey   convey.Interface
+		reason   string
+	}{
+		{metadata: withPartner, convey: convey.C{"hw-model": "abc"}, reason: "drained"},
+		{metadata: withPartner, convey: convey.C{"hw-model": "abc"}, reason: "drained"},
+		{metadata: nil, convey: nil, reason: "rehash-other-instance"},
+	} {
+		d := new(MockDevice)
+		d.On("ID").Return(IntToMAC(uint64(i)))
+		d.On("Metadata").Return(record.metadata)
+		d.On("Convey").Return(record.convey)
+		p.Add(d, record.reason)
+	}
+
+	p.Visit()
+	assert.Equal(4, p.Visited)
+	assert.Equal(3, p.Count)
+	assert.Equal(map[string]int{"drained": 2, "rehash-other-instance": 1}, p.Reasons)
+	assert.Equal(map[string]int{"comcast": 2, UnknownPartner: 1}, p.Partners)
+	assert.Equal(map[string]int{"abc": 2, UnknownModel: 1}, p.Models)
+	assert.Equal([]ID{IntToMAC(0), IntToMAC(1)}, p.Sample)
+
+	p = NewPreview(0)
+	assert.Equal(DefaultPreviewSampleSize, cap(p.Sample))
+}
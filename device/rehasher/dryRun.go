@@ -0,0 +1,55 @@
+package rehasher
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/xmidt-org/sallust"
+	"github.com/xmidt-org/webpa-common/v2/xhttp"
+	"go.uber.org/zap"
+)
+
+// DryRun is an HTTP handler that previews which devices a rehash would disconnect.  The service form parameter
+// is required.  Zero or more instance form parameters may be supplied to preview a rehash against a hypothetical
+// set of instances; otherwise, the most recently discovered instances are used.
+type DryRun struct {
+	Rehasher Interface
+}
+
+func (dr *DryRun) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	logger := sallust.Get(request.Context())
+	if err := request.ParseForm(); err != nil {
+		logger.Error("unable to parse form", zap.Error(err))
+		xhttp.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+
+	svc := request.Form.Get("service")
+	if len(svc) == 0 {
+		xhttp.WriteErrorf(response, http.StatusBadRequest, "a service is required")
+		return
+	}
+
+	preview, err := dr.Rehasher.DryRun(svc, request.Form["instance"])
+	switch err {
+	case nil:
+	case ErrUnknownService:
+		xhttp.WriteError(response, http.StatusNotFound, err)
+		return
+
+	default:
+		logger.Error("unable to preview rehash", zap.Error(err))
+		xhttp.WriteError(response, http.StatusConflict, err)
+		return
+	}
+
+	data, err := json.Marshal(preview)
+	if err != nil {
+		logger.Error("unable to marshal response", zap.Error(err))
+		xhttp.WriteError(response, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.Header().Set("Content-Type", "application/json")
+	response.Write(data)
+}
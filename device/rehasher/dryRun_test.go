@@ -0,0 +1,78 @@
+package rehasher
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xmidt-org/webpa-common/v2/device"
+	"github.com/xmidt-org/webpa-common/v2/service/monitor"
+)
+
+type mockRehasher struct {
+	mock.Mock
+}
+
+func (m *mockRehasher) MonitorEvent(e monitor.Event) {
+	m.Called(e)
+}
+
+func (m *mockRehasher) DryRun(svc string, instances []string) (*device.Preview, error) {
+	arguments := m.Called(svc, instances)
+	first, _ := arguments.Get(0).(*device.Preview)
+	return first, arguments.Error(1)
+}
+
+func TestDryRun(t *testing.T) {
+	testData := []struct {
+		uri          string
+		instances    []string
+		err          error
+		expectedCode int
+	}{
+		{uri: "/foo", expectedCode: http.StatusBadRequest},
+		{uri: "/foo?service=talaria", expectedCode: http.StatusOK},
+		{uri: "/foo?service=talaria&instance=a&instance=b", instances: []string{"a", "b"}, expectedCode: http.StatusOK},
+		{uri: "/foo?service=talaria", err: ErrUnknownService, expectedCode: http.StatusNotFound},
+		{uri: "/foo?service=talaria", err: ErrNoInstances, expectedCode: http.StatusConflict},
+		{uri: "/foo?service=talaria", err: errors.New("expected"), expectedCode: http.StatusConflict},
+	}
+
+	for _, record := range testData {
+		t.Run(record.uri, func(t *testing.T) {
+			var (
+				assert = assert.New(t)
+
+				r      = new(mockRehasher)
+				dryRun = DryRun{r}
+
+				response = httptest.NewRecorder()
+				request  = httptest.NewRequest("GET", record.uri, nil)
+			)
+
+			if record.expectedCode != http.StatusBadRequest {
+				var preview *device.Preview
+				if record.err == nil {
+					preview = device.NewPreview(0)
+				}
+
+				r.On("DryRun", "talaria", record.instances).Return(preview, record.err).Once()
+			}
+
+			dryRun.ServeHTTP(response, request)
+			assert.Equal(record.expectedCode, response.Code)
+			if record.expectedCode == http.StatusOK {
+				assert.Equal("application/json", response.Header().Get("Content-Type"))
+				assert.JSONEq(
+					`{"visited": 0, "count": 0, "reasons": {}, "partners": {}, "models": {}, "sample": []}`,
+					response.Body.String(),
+				)
+			}
+
+			r.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package rehasher
+
+import (
+	"time"
+
+	"github.com/xmidt-org/webpa-common/v2/device"
+	"github.com/xmidt-org/webpa-common/v2/service"
+	"go.uber.org/zap"
+)
+
+// failure describes a kind of service discovery failure
+type failure struct {
+	// message describes the failure in logs
+	message string
+
+	// reason is the close reason used when the failure disconnects all devices
+	reason device.CloseReason
+
+	// label is the ReasonLabel value used in metrics
+	label string
+}
+
+// failureStreak tracks consecutive service discovery failures for a single service
+type failureStreak struct {
+	count   int
+	started time.Time
+	stop    func() bool
+
+	// last is the most recent failure in this streak
+	last failure
+
+	// disconnected is true once all devices have been disconnected during this streak
+	disconnected bool
+}
+
+func defaultAfterFunc(d time.Duration, f func()) func() bool {
+	return time.AfterFunc(d, f).Stop
+}
+
+// ready tests if a failure streak has lasted long enough to disconnect all devices.  The failuresLock must be held.
+func (r *rehasher) ready(streak *failureStreak, now time.Time) bool {
+	return streak.count >= r.threshold && now.Sub(streak.started) >= r.grace
+}
+
+// disconnectAll disconnects every device because of a service discovery failure, superseding any paced rehash
+func (r *rehasher) disconnectAll(svc string, logger *zap.Logger, f failure) {
+	r.supersede(svc, logger)
+	logger.Error("disconnecting all devices: "+f.message, zap.Error(f.reason.Err))
+	r.connector.DisconnectAll(f.reason)
+	r.disconnectAllCounter.With(service.ServiceLabel, svc, ReasonLabel, f.label).Add(1.0)
+}
+
+// failed handles a service discovery failure, disconnecting all devices once the failure has lasted beyond
+// the configured threshold and grace period
+func (r *rehasher) failed(svc string, logger *zap.Logger, f failure) {
+	r.failuresLock.Lock()
+	defer r.failuresLock.Unlock()
+
+	now := r.now()
+	streak, ok := r.failures[svc]
+	if !ok {
+		streak = &failureStreak{started: now}
+		r.failures[svc] = streak
+		if r.grace > 0 {
+			streak.stop = r.afterFunc(r.grace, func() {
+				r.graceExpired(svc, streak, logger)
+			})
+		}
+	}
+
+	streak.count++
+	streak.last = f
+	if r.ready(streak, now) {
+		streak.disconnected = true
+		r.disconnectAll(svc, logger, f)
+		return
+	}
+
+	logger.Error("suppressing disconnect of all devices: "+f.message,
+		zap.Error(f.reason.Err),
+		zap.Int("failures", streak.count),
+		zap.Time("since", streak.started),
+	)
+
+	r.suppressedCounter.With(service.ServiceLabel, svc, ReasonLabel, f.label).Add(1.0)
+}
+
+// graceExpired disconnects all devices if a failure streak is still in progress when its grace period ends,
+// since a stopped monitor or a dead discovery backend may never produce another event
+func (r *rehasher) graceExpired(svc string, streak *failureStreak, logger *zap.Logger) {
+	r.failuresLock.Lock()
+	defer r.failuresLock.Unlock()
+
+	if r.failures[svc] != streak || streak.disconnected || !r.ready(streak, r.now()) {
+		return
+	}
+
+	streak.disconnected = true
+	r.disconnectAll(svc, logger, streak.last)
+}
+
+// recovered ends any failure streak for a service, because discovery has produced a trusted instance list
+func (r *rehasher) recovered(svc string, logger *zap.Logger) {
+	r.failuresLock.Lock()
+	defer r.failuresLock.Unlock()
+
+	if streak, ok := r.failures[svc]; ok {
+		if streak.stop != nil {
+			streak.stop()
+		}
+
+		delete(r.failures, svc)
+		if !streak.disconnected {
+			logger.Info("service discovery recovered within grace period", zap.Int("failures", streak.count))
+		}
+	}
+}
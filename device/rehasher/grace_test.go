@@ -0,0 +1,160 @@
+package rehasher
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/webpa-common/v2/device"
+	"github.com/xmidt-org/webpa-common/v2/service"
+	"github.com/xmidt-org/webpa-common/v2/service/monitor"
+	"github.com/xmidt-org/webpa-common/v2/xmetrics/xmetricstest"
+)
+
+// manualClock lets tests control the rehasher's time and grace timers
+type manualClock struct {
+	now     time.Time
+	pending []func()
+	stopped int
+}
+
+func (mc *manualClock) afterFunc(d time.Duration, f func()) func() bool {
+	mc.pending = append(mc.pending, f)
+	return func() bool {
+		mc.stopped++
+		return true
+	}
+}
+
+func newGraceRehasher(t *testing.T, connector device.Connector, provider xmetricstest.Provider, o ...Option) (*rehasher, *manualClock) {
+	var (
+		clock = &manualClock{now: time.Now()}
+		r     = New(
+			connector,
+			[]string{"talaria"},
+			append([]Option{
+				WithIsRegistered(func(string) bool { return true }),
+				WithMetricsProvider(provider),
+			}, o...)...,
+		).(*rehasher)
+	)
+
+	r.now = func() time.Time { return clock.now }
+	r.afterFunc = clock.afterFunc
+	return r, clock
+}
+
+func testGraceExpires(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		require   = require.New(t)
+		provider  = xmetricstest.NewProvider(nil, Metrics)
+		connector = new(device.MockConnector)
+
+		r, clock = newGraceRehasher(t, connector, provider, WithDisconnectAllGrace(time.Minute))
+	)
+
+	r.MonitorEvent(monitor.Event{Key: "test", Service: "talaria", EventCount: 2, Stopped: true})
+	provider.Assert(t, RehashDisconnectAllSuppressedCounter, service.ServiceLabel, "talaria", ReasonLabel, DisconnectAllServiceDiscoveryStopped)(xmetricstest.Value(1.0))
+	provider.Assert(t, RehashDisconnectAllCounter, service.ServiceLabel, "talaria", ReasonLabel, DisconnectAllServiceDiscoveryStopped)(xmetricstest.Value(0.0))
+	require.Len(clock.pending, 1)
+
+	// a stopped monitor produces no more events, so the grace timer must disconnect the devices
+	connector.On("DisconnectAll", device.CloseReason{Text: ServiceDiscoveryStopped}).Return(10).Once()
+	clock.now = clock.now.Add(time.Minute)
+	clock.pending[0]()
+	provider.Assert(t, RehashDisconnectAllCounter, service.ServiceLabel, "talaria", ReasonLabel, DisconnectAllServiceDiscoveryStopped)(xmetricstest.Value(1.0))
+
+	// the timer only disconnects once per streak
+	clock.pending[0]()
+	connector.AssertExpectations(t)
+	assert.Len(clock.pending, 1)
+}
+
+func testGraceRecovered(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		require   = require.New(t)
+		provider  = xmetricstest.NewProvider(nil, Metrics)
+		connector = new(device.MockConnector)
+		sdError   = errors.New("expected")
+
+		r, clock = newGraceRehasher(t, connector, provider, WithDisconnectAllGrace(time.Minute))
+	)
+
+	r.MonitorEvent(monitor.Event{Key: "test", Service: "talaria", EventCount: 2, Err: sdError})
+	clock.now = clock.now.Add(30 * time.Second)
+	r.MonitorEvent(monitor.Event{Key: "test", Service: "talaria", EventCount: 3})
+	provider.Assert(t, RehashDisconnectAllSuppressedCounter, service.ServiceLabel, "talaria", ReasonLabel, DisconnectAllServiceDiscoveryError)(xmetricstest.Value(1.0))
+	provider.Assert(t, RehashDisconnectAllSuppressedCounter, service.ServiceLabel, "talaria", ReasonLabel, DisconnectAllServiceDiscoveryNoInstances)(xmetricstest.Value(1.0))
+
+	// a blip that recovers before the grace period ends never disconnects any device
+	connector.On("DisconnectIf", mock.Anything).Return(0).Once()
+	r.MonitorEvent(monitor.Event{Key: "test", Service: "talaria", EventCount: 4, Instances: []string{"talaria-1"}})
+	assert.Equal(1, clock.stopped)
+	assert.Empty(r.failures)
+
+	require.Len(clock.pending, 1)
+	clock.now = clock.now.Add(time.Hour)
+	clock.pending[0]()
+	connector.AssertExpectations(t)
+
+	// a failure that persists beyond the grace period disconnects devices on the next event
+	r.MonitorEvent(monitor.Event{Key: "test", Service: "talaria", EventCount: 5, Err: sdError})
+	clock.now = clock.now.Add(2 * time.Minute)
+	connector.On("DisconnectAll", device.CloseReason{Err: sdError, Text: ServiceDiscoveryError}).Return(10).Twice()
+	r.MonitorEvent(monitor.Event{Key: "test", Service: "talaria", EventCount: 6, Err: sdError})
+	r.MonitorEvent(monitor.Event{Key: "test", Service: "talaria", EventCount: 7, Err: sdError})
+	provider.Assert(t, RehashDisconnectAllCounter, service.ServiceLabel, "talaria", ReasonLabel, DisconnectAllServiceDiscoveryError)(xmetricstest.Value(2.0))
+	connector.AssertExpectations(t)
+}
+
+func testGraceThreshold(t *testing.T) {
+	var (
+		provider  = xmetricstest.NewProvider(nil, Metrics)
+		connector = new(device.MockConnector)
+
+		r, clock = newGraceRehasher(t, connector, provider, WithDisconnectAllThreshold(3))
+	)
+
+	r.MonitorEvent(monitor.Event{Key: "test", Service: "talaria", EventCount: 2})
+	r.MonitorEvent(monitor.Event{Key: "test", Service: "talaria", EventCount: 3})
+	provider.Assert(t, RehashDisconnectAllSuppressedCounter, service.ServiceLabel, "talaria", ReasonLabel, DisconnectAllServiceDiscoveryNoInstances)(xmetricstest.Value(2.0))
+
+	connector.On("DisconnectAll", device.CloseReason{Text: ServiceDiscoveryNoInstances}).Return(10).Once()
+	r.MonitorEvent(monitor.Event{Key: "test", Service: "talaria", EventCount: 4})
+	provider.Assert(t, RehashDisconnectAllCounter, service.ServiceLabel, "talaria", ReasonLabel, DisconnectAllServiceDiscoveryNoInstances)(xmetricstest.Value(1.0))
+	assert.Empty(t, clock.pending, "no timer is needed without a grace period")
+	connector.AssertExpectations(t)
+}
+
+func testGraceMinInstances(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		provider  = xmetricstest.NewProvider(nil, Metrics)
+		connector = new(device.MockConnector)
+
+		r, _ = newGraceRehasher(t, connector, provider, WithMinInstances(3), WithRegistry(new(device.MockRegistry)))
+	)
+
+	// too few instances neither rehash nor disconnect, and are not remembered for dry runs
+	r.MonitorEvent(monitor.Event{Key: "test", Service: "talaria", EventCount: 2, Instances: []string{"talaria-1", "talaria-2"}})
+	provider.Assert(t, RehashDisconnectAllSuppressedCounter, service.ServiceLabel, "talaria", ReasonLabel, RehashTooFewInstances)(xmetricstest.Value(1.0))
+
+	_, err := r.DryRun("talaria", nil)
+	assert.Equal(ErrNoInstances, err)
+
+	connector.On("DisconnectIf", mock.Anything).Return(0).Once()
+	r.MonitorEvent(monitor.Event{Key: "test", Service: "talaria", EventCount: 3, Instances: []string{"talaria-1", "talaria-2", "talaria-3"}})
+	connector.AssertExpectations(t)
+}
+
+func TestGrace(t *testing.T) {
+	t.Run("Expires", testGraceExpires)
+	t.Run("Recovered", testGraceRecovered)
+	t.Run("Threshold", testGraceThreshold)
+	t.Run("MinInstances", testGraceMinInstances)
+}
@@ -13,12 +13,17 @@ const (
 	RehashDisconnectAllCounter = "rehash_disconnect_all_count"
 	RehashTimestamp            = "rehash_timestamp"
 	RehashDurationMilliseconds = "rehash_duration_ms"
+	RehashPendingDevice        = "rehash_pending_device"
+	RehashSupersededCounter    = "rehash_superseded_count"
+
+	RehashDisconnectAllSuppressedCounter = "rehash_disconnect_all_suppressed_count"
 
 	ReasonLabel = "reason"
 
 	DisconnectAllServiceDiscoveryError       = "sd_error"
 	DisconnectAllServiceDiscoveryStopped     = "sd_stopped"
 	DisconnectAllServiceDiscoveryNoInstances = "sd_no_instances"
+	RehashTooFewInstances                    = "sd_too_few_instances"
 )
 
 // Metrics is the device module function that adds default device metrics
@@ -49,5 +54,20 @@ func Metrics() []xmetrics.Metric {
 			Type:       "gauge",
 			LabelNames: []string{service.ServiceLabel},
 		},
+		{
+			Name:       RehashPendingDevice,
+			Type:       "gauge",
+			LabelNames: []string{service.ServiceLabel},
+		},
+		{
+			Name:       RehashSupersededCounter,
+			Type:       "counter",
+			LabelNames: []string{service.ServiceLabel},
+		},
+		{
+			Name:       RehashDisconnectAllSuppressedCounter,
+			Type:       "counter",
+			LabelNames: []string{service.ServiceLabel, ReasonLabel},
+		},
 	}
 }
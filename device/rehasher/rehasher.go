@@ -1,6 +1,8 @@
 package rehasher
 
 import (
+	"errors"
+	"sync"
 	"time"
 
 	"github.com/go-kit/kit/metrics"
@@ -22,6 +24,26 @@ const (
 	ServiceDiscoveryNoInstances = "service-discovery-no-instances"
 )
 
+// DefaultPacingTick is the time unit used for a paced rehash when no tick is supplied
+const DefaultPacingTick = time.Second
+
+var (
+	ErrNoRegistry     = errors.New("a device registry is required for dry runs")
+	ErrUnknownService = errors.New("that service is not rehashed")
+	ErrNoInstances    = errors.New("no instances are known for that service")
+)
+
+// Interface is the monitor.Listener created by New.  In addition to rehashing devices in response to
+// service discovery events, it can preview a rehash without disconnecting any device.
+type Interface interface {
+	monitor.Listener
+
+	// DryRun reports the devices that a rehash of the given service against the given instances would
+	// disconnect, without disconnecting any of them.  If instances is empty, the instances from the most
+	// recent service discovery event for that service are used.
+	DryRun(svc string, instances []string) (*device.Preview, error)
+}
+
 // Option is a configuration option for a rehasher
 type Option func(*rehasher)
 
@@ -41,9 +63,22 @@ func WithLogger(l *zap.Logger) Option {
 func WithAccessorFactory(af service.AccessorFactory) Option {
 	return func(r *rehasher) {
 		if af == nil {
-			r.accessorFactory = service.DefaultAccessorFactory
+			r.accessorFactory = service.DefaultWeightedAccessorFactory
 		} else {
-			r.accessorFactory = af
+			r.accessorFactory = af.Weighted()
+		}
+	}
+}
+
+// WithWeightedAccessorFactory configures a rehasher with a specific factory for service.Accessor objects which
+// takes the instance weights from service discovery events into account.  If waf is nil, the default weighted
+// accessor factory is used.
+func WithWeightedAccessorFactory(waf service.WeightedAccessorFactory) Option {
+	return func(r *rehasher) {
+		if waf == nil {
+			r.accessorFactory = service.DefaultWeightedAccessorFactory
+		} else {
+			r.accessorFactory = waf
 		}
 	}
 }
@@ -56,6 +91,67 @@ func WithIsRegistered(f func(string) bool) Option {
 	}
 }
 
+// WithRegistry configures a rehasher with the registry of devices walked during dry runs.  There is no default,
+// and dry runs return ErrNoRegistry if no registry is configured.
+func WithRegistry(registry device.Registry) Option {
+	return func(r *rehasher) {
+		r.registry = registry
+	}
+}
+
+// WithPacing configures a rehasher to disconnect the devices that hash to other instances gradually, at rate
+// devices per tick, rather than all at once, so that their new owners do not see a reconnect storm.  A nonpositive
+// rate, the default, disconnects them all at once.  A nonpositive tick uses DefaultPacingTick.
+//
+// A paced rehash that is still disconnecting devices when the next service discovery event for the same service
+// arrives is superseded: its remaining disconnects are abandoned in favor of those computed from the new event.
+func WithPacing(rate int, tick time.Duration) Option {
+	return func(r *rehasher) {
+		r.rate = rate
+		if tick > 0 {
+			r.tick = tick
+		} else {
+			r.tick = DefaultPacingTick
+		}
+	}
+}
+
+// WithDisconnectAllGrace configures how long a service discovery failure, i.e. an error, a stopped monitor, or an
+// empty instance list, must persist before all devices are disconnected.  A failure persists until an event with
+// enough instances arrives.  When the grace period expires, all devices are disconnected even if no further event
+// has arrived.  The default of zero disconnects devices as soon as the failure threshold is met.
+func WithDisconnectAllGrace(grace time.Duration) Option {
+	return func(r *rehasher) {
+		if grace > 0 {
+			r.grace = grace
+		} else {
+			r.grace = 0
+		}
+	}
+}
+
+// WithDisconnectAllThreshold configures the number of consecutive service discovery failure events required before
+// all devices are disconnected.  A nonpositive threshold uses the default of 1, which disconnects devices on the
+// first failure event.
+func WithDisconnectAllThreshold(threshold int) Option {
+	return func(r *rehasher) {
+		if threshold > 1 {
+			r.threshold = threshold
+		} else {
+			r.threshold = 1
+		}
+	}
+}
+
+// WithMinInstances configures the smallest instance list that is trusted enough to rehash against.  Nonempty lists
+// with fewer instances are ignored, neither rehashing nor disconnecting any device, on the assumption that discovery
+// has only partially failed.  Empty lists are always treated as failures.  The default of zero trusts any nonempty list.
+func WithMinInstances(n int) Option {
+	return func(r *rehasher) {
+		r.minInstances = n
+	}
+}
+
 // WithMetricsProvider configures a metrics subsystem the resulting rehasher will use to track things.
 // A nil provider passed to this option means to discard all metrics.
 func WithMetricsProvider(p provider.Provider) Option {
@@ -69,6 +165,9 @@ func WithMetricsProvider(p provider.Provider) Option {
 		r.disconnectAllCounter = p.NewCounter(RehashDisconnectAllCounter)
 		r.timestamp = p.NewGauge(RehashTimestamp)
 		r.duration = p.NewGauge(RehashDurationMilliseconds)
+		r.pending = p.NewGauge(RehashPendingDevice)
+		r.supersededCounter = p.NewCounter(RehashSupersededCounter)
+		r.suppressedCounter = p.NewCounter(RehashDisconnectAllSuppressedCounter)
 	}
 }
 
@@ -80,7 +179,7 @@ func WithMetricsProvider(p provider.Provider) Option {
 // If the returned listener encounters any service discovery error, all devices are disconnected.  Otherwise,
 // the IsRegistered strategy is used to determine which devices should still be connected to the Connector.  Devices
 // that hash to instances not registered in this environment are disconnected.
-func New(connector device.Connector, services []string, options ...Option) monitor.Listener {
+func New(connector device.Connector, services []string, options ...Option) Interface {
 	if connector == nil {
 		panic("A device Connector is required.")
 	}
@@ -94,16 +193,27 @@ func New(connector device.Connector, services []string, options ...Option) monit
 
 		r = &rehasher{
 			logger:          sallust.Default(),
-			accessorFactory: service.DefaultAccessorFactory,
+			accessorFactory: service.DefaultWeightedAccessorFactory,
 			connector:       connector,
 			now:             time.Now,
+			newTicker:       defaultNewTicker,
+			tick:            DefaultPacingTick,
 			services:        make(map[string]bool),
+			instances:       make(map[string][]string),
+			weights:         make(map[string]service.Weights),
+			paced:           make(map[string]*pacedRehash),
+			afterFunc:       defaultAfterFunc,
+			threshold:       1,
+			failures:        make(map[string]*failureStreak),
 
 			keep:                 defaultProvider.NewGauge(RehashKeepDevice),
 			disconnect:           defaultProvider.NewGauge(RehashDisconnectDevice),
 			disconnectAllCounter: defaultProvider.NewCounter(RehashDisconnectAllCounter),
 			timestamp:            defaultProvider.NewGauge(RehashTimestamp),
 			duration:             defaultProvider.NewGauge(RehashDurationMilliseconds),
+			pending:              defaultProvider.NewGauge(RehashPendingDevice),
+			supersededCounter:    defaultProvider.NewCounter(RehashSupersededCounter),
+			suppressedCounter:    defaultProvider.NewCounter(RehashDisconnectAllSuppressedCounter),
 		}
 	)
 
@@ -127,16 +237,93 @@ func New(connector device.Connector, services []string, options ...Option) monit
 type rehasher struct {
 	logger          *zap.Logger
 	services        map[string]bool
-	accessorFactory service.AccessorFactory
+	accessorFactory service.WeightedAccessorFactory
 	isRegistered    func(string) bool
 	connector       device.Connector
+	registry        device.Registry
 	now             func() time.Time
+	newTicker       func(time.Duration) (<-chan time.Time, func())
+	rate            int
+	tick            time.Duration
+
+	instancesLock sync.RWMutex
+	instances     map[string][]string
+	weights       map[string]service.Weights
+
+	pacedLock sync.Mutex
+	paced     map[string]*pacedRehash
+
+	afterFunc    func(time.Duration, func()) func() bool
+	grace        time.Duration
+	threshold    int
+	minInstances int
+	failuresLock sync.Mutex
+	failures     map[string]*failureStreak
 
 	keep                 metrics.Gauge
 	disconnect           metrics.Gauge
 	disconnectAllCounter metrics.Counter
 	timestamp            metrics.Gauge
 	duration             metrics.Gauge
+	pending              metrics.Gauge
+	supersededCounter    metrics.Counter
+	suppressedCounter    metrics.Counter
+}
+
+// move is a device that a paced rehash will disconnect
+type move struct {
+	id     device.ID
+	reason device.CloseReason
+}
+
+// pacedRehash is a paced rehash that is disconnecting devices in the background
+type pacedRehash struct {
+	cancel chan struct{}
+	done   chan struct{}
+
+	// completed is set before done is closed, and is false if the rehash was canceled with devices still pending
+	completed bool
+}
+
+func defaultNewTicker(d time.Duration) (<-chan time.Time, func()) {
+	ticker := time.NewTicker(d)
+	return ticker.C, ticker.Stop
+}
+
+// check hashes a device with the given accessor, returning the instance it hashed to along with whether
+// the device should be disconnected and why
+func (r *rehasher) check(accessor service.Accessor, id device.ID) (string, device.CloseReason, bool) {
+	instance, err := accessor.Get(id.Bytes())
+	switch {
+	case err != nil:
+		return instance, device.CloseReason{Err: err, Text: RehashError}, true
+
+	case !r.isRegistered(instance):
+		return instance, device.CloseReason{Text: RehashOtherInstance}, true
+
+	default:
+		return instance, device.CloseReason{}, false
+	}
+}
+
+// logCheck logs the outcome of hashing a device during a rehash
+func (r *rehasher) logCheck(logger *zap.Logger, candidate device.ID, instance string, reason device.CloseReason, disconnect bool) {
+	switch {
+	case reason.Err != nil:
+		logger.Error("disconnecting device: error during rehash",
+			zap.Error(reason.Err),
+			zap.String("id", string(candidate)),
+		)
+
+	case disconnect:
+		logger.Info("disconnecting device: rehashed to another instance",
+			zap.String("instance", instance),
+			zap.String("id", string(candidate)),
+		)
+
+	default:
+		logger.Debug("device hashed to this instance", zap.String("id", string(candidate)))
+	}
 }
 
 func (r *rehasher) rehash(svc string, logger *zap.Logger, accessor service.Accessor) {
@@ -147,42 +334,115 @@ func (r *rehasher) rehash(svc string, logger *zap.Logger, accessor service.Acces
 
 	var (
 		keepCount = 0
+		moves     []move
 
 		disconnectCount = r.connector.DisconnectIf(func(candidate device.ID) (device.CloseReason, bool) {
-			instance, err := accessor.Get(candidate.Bytes())
-			switch {
-			case err != nil:
-				logger.Error("disconnecting device: error during rehash",
-					zap.Error(err),
-					zap.String("id", string(candidate)),
-				)
-
-				return device.CloseReason{Err: err, Text: RehashError}, true
-
-			case !r.isRegistered(instance):
-				logger.Info("disconnecting device: rehashed to another instance",
-					zap.String("instance", instance),
-					zap.String("id", string(candidate)),
-				)
-
-				return device.CloseReason{Text: RehashOtherInstance}, true
-
-			default:
-				logger.Debug("device hashed to this instance", zap.String("id", string(candidate)))
+			instance, reason, disconnect := r.check(accessor, candidate)
+			r.logCheck(logger, candidate, instance, reason, disconnect)
+			if !disconnect {
 				keepCount++
-				return device.CloseReason{}, false
+			} else if r.rate > 0 {
+				// paced devices are disconnected later, so they are only collected here
+				moves = append(moves, move{id: candidate, reason: reason})
+				return reason, false
 			}
+
+			return reason, disconnect
 		})
 
 		duration = r.now().Sub(start)
 	)
 
+	if r.rate > 0 {
+		disconnectCount = len(moves)
+		r.startPaced(svc, logger, moves)
+	}
+
 	r.keep.With(service.ServiceLabel, svc).Set(float64(keepCount))
 	r.disconnect.With(service.ServiceLabel, svc).Set(float64(disconnectCount))
 	r.duration.With(service.ServiceLabel, svc).Set(float64(duration / time.Millisecond))
 	logger.Info("rehash complete", zap.Int("disconnectCount", disconnectCount), zap.Duration("duration", duration))
 }
 
+// supersede halts any paced rehash of the given service that is still disconnecting devices and waits for it to exit
+func (r *rehasher) supersede(svc string, logger *zap.Logger) {
+	r.pacedLock.Lock()
+	p := r.paced[svc]
+	delete(r.paced, svc)
+	r.pacedLock.Unlock()
+
+	if p == nil {
+		return
+	}
+
+	close(p.cancel)
+	<-p.done
+
+	if !p.completed {
+		logger.Info("paced rehash superseded")
+		r.supersededCounter.With(service.ServiceLabel, svc).Add(1.0)
+		r.pending.With(service.ServiceLabel, svc).Set(0.0)
+	}
+}
+
+// startPaced begins disconnecting the given moves in the background, at the configured rate
+func (r *rehasher) startPaced(svc string, logger *zap.Logger, moves []move) {
+	pending := r.pending.With(service.ServiceLabel, svc)
+	pending.Set(float64(len(moves)))
+	if len(moves) == 0 {
+		return
+	}
+
+	p := &pacedRehash{
+		cancel: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	r.pacedLock.Lock()
+	r.paced[svc] = p
+	r.pacedLock.Unlock()
+
+	go r.disconnectPaced(svc, logger, p, pending, moves)
+}
+
+func (r *rehasher) disconnectPaced(svc string, logger *zap.Logger, p *pacedRehash, pending metrics.Gauge, moves []move) {
+	defer close(p.done)
+	ticker, stop := r.newTicker(r.tick)
+	defer stop()
+
+	for {
+		batch := r.rate
+		if batch > len(moves) {
+			batch = len(moves)
+		}
+
+		for _, m := range moves[:batch] {
+			r.connector.Disconnect(m.id, m.reason)
+		}
+
+		moves = moves[batch:]
+		pending.Set(float64(len(moves)))
+		if len(moves) == 0 {
+			break
+		}
+
+		select {
+		case <-p.cancel:
+			return
+		case <-ticker:
+		}
+	}
+
+	r.pacedLock.Lock()
+	if r.paced[svc] == p {
+		delete(r.paced, svc)
+	}
+
+	r.pacedLock.Unlock()
+	p.completed = true
+	logger.Info("paced rehash complete")
+}
+
 func (r *rehasher) MonitorEvent(e monitor.Event) {
 	if !r.services[e.Service] {
 		return
@@ -191,26 +451,90 @@ func (r *rehasher) MonitorEvent(e monitor.Event) {
 	logger := r.logger.With(
 		zap.Int(monitor.EventCountKey(), e.EventCount), zap.Any(e.Service, e.Instancer))
 
+	healthy := e.Err == nil && !e.Stopped && len(e.Instances) > 0 && len(e.Instances) >= r.minInstances
+	if healthy {
+		// remember the instances, so that dry runs can preview against them
+		r.instancesLock.Lock()
+		r.instances[e.Service] = e.Instances
+		r.weights[e.Service] = e.Weights
+		r.instancesLock.Unlock()
+		r.recovered(e.Service, logger)
+	}
+
 	switch {
 	case e.Err != nil:
-		logger.Error("disconnecting all devices: service discovery error", zap.Error(e.Err))
-		r.connector.DisconnectAll(device.CloseReason{Err: e.Err, Text: ServiceDiscoveryError})
-		r.disconnectAllCounter.With(service.ServiceLabel, e.Service, ReasonLabel, DisconnectAllServiceDiscoveryError).Add(1.0)
+		r.failed(e.Service, logger, failure{
+			message: "service discovery error",
+			reason:  device.CloseReason{Err: e.Err, Text: ServiceDiscoveryError},
+			label:   DisconnectAllServiceDiscoveryError,
+		})
 
 	case e.Stopped:
-		logger.Error("disconnecting all devices: service discovery monitor being stopped")
-		r.connector.DisconnectAll(device.CloseReason{Text: ServiceDiscoveryStopped})
-		r.disconnectAllCounter.With(service.ServiceLabel, e.Service, ReasonLabel, DisconnectAllServiceDiscoveryStopped).Add(1.0)
+		r.failed(e.Service, logger, failure{
+			message: "service discovery monitor being stopped",
+			reason:  device.CloseReason{Text: ServiceDiscoveryStopped},
+			label:   DisconnectAllServiceDiscoveryStopped,
+		})
 
 	case e.EventCount == 1:
 		logger.Info("ignoring initial instances")
 
+	case healthy:
+		r.supersede(e.Service, logger)
+		r.rehash(e.Service, logger, r.accessorFactory(e.Instances, e.Weights))
+
 	case len(e.Instances) > 0:
-		r.rehash(e.Service, logger, r.accessorFactory(e.Instances))
+		logger.Error("ignoring instances: fewer than the minimum",
+			zap.Int("instances", len(e.Instances)),
+			zap.Int("minInstances", r.minInstances),
+		)
+
+		r.suppressedCounter.With(service.ServiceLabel, e.Service, ReasonLabel, RehashTooFewInstances).Add(1.0)
 
 	default:
-		logger.Error("disconnecting all devices: service discovery updated with no instances")
-		r.connector.DisconnectAll(device.CloseReason{Text: ServiceDiscoveryNoInstances})
-		r.disconnectAllCounter.With(service.ServiceLabel, e.Service, ReasonLabel, DisconnectAllServiceDiscoveryNoInstances).Add(1.0)
+		r.failed(e.Service, logger, failure{
+			message: "service discovery updated with no instances",
+			reason:  device.CloseReason{Text: ServiceDiscoveryNoInstances},
+			label:   DisconnectAllServiceDiscoveryNoInstances,
+		})
+	}
+}
+
+func (r *rehasher) DryRun(svc string, instances []string) (*device.Preview, error) {
+	if !r.services[svc] {
+		return nil, ErrUnknownService
 	}
+
+	if r.registry == nil {
+		return nil, ErrNoRegistry
+	}
+
+	r.instancesLock.RLock()
+	weights := r.weights[svc]
+	if len(instances) == 0 {
+		instances = r.instances[svc]
+	}
+
+	r.instancesLock.RUnlock()
+
+	if len(instances) == 0 {
+		return nil, ErrNoInstances
+	}
+
+	var (
+		accessor = r.accessorFactory(instances, weights)
+		preview  = device.NewPreview(0)
+	)
+
+	r.registry.VisitAll(func(d device.Interface) bool {
+		if _, reason, disconnect := r.check(accessor, d.ID()); disconnect {
+			preview.Add(d, reason.Text)
+		} else {
+			preview.Visit()
+		}
+
+		return true
+	})
+
+	return preview, nil
 }
@@ -387,6 +387,214 @@ func testRehasherRehash(t *testing.T) {
 	provider.AssertExpectations(t)
 }
 
+func testRehasherDryRun(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		keepNode   = "keep.xfinity.net"
+		rehashNode = "rehash.xfinity.net"
+
+		accessorFactory = service.AccessorFactory(func(instances []string) service.Accessor {
+			return service.AccessorFunc(func(key []byte) (string, error) {
+				// the last instance gets every device except "keep"
+				if string(key) == "keep" {
+					return keepNode, nil
+				}
+
+				return instances[len(instances)-1], nil
+			})
+		})
+
+		devices = []device.Interface{
+			newDryRunDevice("keep"),
+			newDryRunDevice("first"),
+			newDryRunDevice("second"),
+		}
+
+		connector = new(device.MockConnector)
+		registry  = new(device.MockRegistry)
+		r         = New(
+			connector,
+			[]string{"talaria"},
+			WithIsRegistered(func(v string) bool { return v == keepNode }),
+			WithAccessorFactory(accessorFactory),
+		)
+	)
+
+	registry.On("VisitAll", mock.Anything).Run(func(arguments mock.Arguments) {
+		f := arguments.Get(0).(func(device.Interface) bool)
+		for _, d := range devices {
+			f(d)
+		}
+	}).Return(len(devices))
+
+	_, err := r.DryRun("talaria", nil)
+	assert.Equal(ErrNoRegistry, err)
+
+	r = New(
+		connector,
+		[]string{"talaria"},
+		WithIsRegistered(func(v string) bool { return v == keepNode }),
+		WithAccessorFactory(accessorFactory),
+		WithRegistry(registry),
+	)
+
+	_, err = r.DryRun("caduceus", nil)
+	assert.Equal(ErrUnknownService, err)
+
+	_, err = r.DryRun("talaria", nil)
+	assert.Equal(ErrNoInstances, err)
+
+	// the initial event is not rehashed, but its instances are remembered
+	r.MonitorEvent(monitor.Event{Key: "test", Service: "talaria", EventCount: 1, Instances: []string{keepNode}})
+	preview, err := r.DryRun("talaria", nil)
+	require.NoError(err)
+	assert.Equal(3, preview.Visited)
+	assert.Zero(preview.Count)
+
+	preview, err = r.DryRun("talaria", []string{keepNode, rehashNode})
+	require.NoError(err)
+	assert.Equal(3, preview.Visited)
+	assert.Equal(2, preview.Count)
+	assert.Equal(map[string]int{RehashOtherInstance: 2}, preview.Reasons)
+	assert.Equal([]device.ID{"first", "second"}, preview.Sample)
+
+	connector.AssertExpectations(t)
+	registry.AssertExpectations(t)
+}
+
+func newDryRunDevice(id device.ID) device.Interface {
+	d := new(device.MockDevice)
+	d.On("ID").Return(id)
+	d.On("Metadata").Return(nil)
+	d.On("Convey").Return(nil)
+	return d
+}
+
+// newPacedRehasher creates a rehasher that paces disconnects of every device except "keep" at the given rate per
+// minute, driven by the returned ticker
+func newPacedRehasher(t *testing.T, rate int, connector device.Connector, provider xmetricstest.Provider) (*rehasher, chan time.Time) {
+	var (
+		ticker = make(chan time.Time)
+		r      = New(
+			connector,
+			[]string{"talaria"},
+			WithIsRegistered(func(instance string) bool { return instance == "keep" }),
+			WithAccessorFactory(func([]string) service.Accessor {
+				return service.AccessorFunc(func(key []byte) (string, error) {
+					if string(key) == "keep" {
+						return "keep", nil
+					}
+
+					return "other", nil
+				})
+			}),
+			WithPacing(rate, time.Minute),
+			WithMetricsProvider(provider),
+		).(*rehasher)
+	)
+
+	r.newTicker = func(d time.Duration) (<-chan time.Time, func()) {
+		assert.Equal(t, time.Minute, d)
+		return ticker, func() {}
+	}
+
+	return r, ticker
+}
+
+// expectCandidates sets up a DisconnectIf that offers the given devices and expects none to be disconnected
+func expectCandidates(t *testing.T, connector *device.MockConnector, ids ...device.ID) {
+	connector.On("DisconnectIf", mock.Anything).
+		Run(func(arguments mock.Arguments) {
+			f := arguments.Get(0).(func(device.ID) (device.CloseReason, bool))
+			for _, id := range ids {
+				_, closed := f(id)
+				assert.False(t, closed, "paced devices must not be disconnected during the rehash")
+			}
+		}).
+		Return(0).Once()
+}
+
+func testRehasherPaced(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		require   = require.New(t)
+		provider  = xmetricstest.NewProvider(nil, Metrics)
+		connector = new(device.MockConnector)
+
+		r, ticker = newPacedRehasher(t, 2, connector, provider)
+	)
+
+	expectCandidates(t, connector, "keep", "a", "b", "c", "d", "e")
+	for _, id := range []device.ID{"a", "b", "c", "d", "e"} {
+		connector.On("Disconnect", id, device.CloseReason{Text: RehashOtherInstance}).Return(true).Once()
+	}
+
+	r.MonitorEvent(monitor.Event{Key: "test", Service: "talaria", EventCount: 2, Instances: []string{"keep", "other"}})
+	provider.Assert(t, RehashKeepDevice, service.ServiceLabel, "talaria")(xmetricstest.Value(1.0))
+	provider.Assert(t, RehashDisconnectDevice, service.ServiceLabel, "talaria")(xmetricstest.Value(5.0))
+
+	r.pacedLock.Lock()
+	p := r.paced["talaria"]
+	r.pacedLock.Unlock()
+	require.NotNil(p)
+
+	ticker <- time.Now()
+	ticker <- time.Now()
+	select {
+	case <-p.done:
+	case <-time.After(5 * time.Second):
+		assert.Fail("the paced rehash did not complete")
+	}
+
+	assert.True(p.completed)
+	assert.Empty(r.paced)
+	provider.Assert(t, RehashPendingDevice, service.ServiceLabel, "talaria")(xmetricstest.Value(0.0))
+	provider.Assert(t, RehashSupersededCounter, service.ServiceLabel, "talaria")(xmetricstest.Value(0.0))
+	connector.AssertExpectations(t)
+}
+
+func testRehasherPacedSuperseded(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		require   = require.New(t)
+		provider  = xmetricstest.NewProvider(nil, Metrics)
+		connector = new(device.MockConnector)
+		first     = make(chan struct{})
+
+		r, _ = newPacedRehasher(t, 1, connector, provider)
+	)
+
+	expectCandidates(t, connector, "a", "b", "c")
+	connector.On("Disconnect", device.ID("a"), device.CloseReason{Text: RehashOtherInstance}).
+		Run(func(mock.Arguments) { close(first) }).
+		Return(true).Once()
+
+	r.MonitorEvent(monitor.Event{Key: "test", Service: "talaria", EventCount: 2, Instances: []string{"keep", "other"}})
+	select {
+	case <-first:
+	case <-time.After(5 * time.Second):
+		require.Fail("the first paced disconnect did not happen")
+	}
+
+	// the next discovery event abandons the remaining disconnects in favor of its own
+	expectCandidates(t, connector, "b", "c")
+	connector.On("Disconnect", device.ID("b"), device.CloseReason{Text: RehashOtherInstance}).Return(true).Once()
+	r.MonitorEvent(monitor.Event{Key: "test", Service: "talaria", EventCount: 3, Instances: []string{"keep", "other"}})
+
+	provider.Assert(t, RehashSupersededCounter, service.ServiceLabel, "talaria")(xmetricstest.Value(1.0))
+
+	// a disconnect-all supersedes a paced rehash, too
+	connector.On("DisconnectAll", device.CloseReason{Text: ServiceDiscoveryStopped}).Return(1).Once()
+	r.MonitorEvent(monitor.Event{Key: "test", Service: "talaria", EventCount: 4, Stopped: true})
+
+	provider.Assert(t, RehashSupersededCounter, service.ServiceLabel, "talaria")(xmetricstest.Value(2.0))
+	provider.Assert(t, RehashPendingDevice, service.ServiceLabel, "talaria")(xmetricstest.Value(0.0))
+	assert.Empty(r.paced)
+	connector.AssertExpectations(t)
+}
+
 func TestRehasher(t *testing.T) {
 	t.Run("ServiceDiscoveryError", testRehasherServiceDiscoveryError)
 	t.Run("ServiceDiscoveryStopped", testRehasherServiceDiscoveryStopped)
@@ -394,4 +602,42 @@ func TestRehasher(t *testing.T) {
 	t.Run("NoInstances", testRehasherNoInstances)
 	t.Run("Rehash", testRehasherRehash)
 	t.Run("SkippedServicee", testRehasherSkippedService)
+	t.Run("DryRun", testRehasherDryRun)
+	t.Run("Paced", testRehasherPaced)
+	t.Run("PacedSuperseded", testRehasherPacedSuperseded)
+}
+
+func TestWithWeightedAccessorFactory(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		expectedWeights = service.Weights{"talaria-1": 3}
+		actualWeights   []service.Weights
+
+		connector = new(device.MockConnector)
+		registry  = new(device.MockRegistry)
+		r         = New(
+			connector,
+			[]string{"talaria"},
+			WithIsRegistered(func(string) bool { return true }),
+			WithRegistry(registry),
+			WithWeightedAccessorFactory(func(instances []string, weights service.Weights) service.Accessor {
+				actualWeights = append(actualWeights, weights)
+				return service.DefaultWeightedAccessorFactory(instances, weights)
+			}),
+		)
+	)
+
+	require.NotNil(r)
+	connector.On("DisconnectIf", mock.Anything).Return(0).Once()
+	registry.On("VisitAll", mock.Anything).Return(0).Once()
+
+	r.MonitorEvent(monitor.Event{Key: "test", Service: "talaria", EventCount: 2, Instances: []string{"talaria-1", "talaria-2"}, Weights: expectedWeights})
+	_, err := r.DryRun("talaria", []string{"talaria-1"})
+	require.NoError(err)
+
+	assert.Equal([]service.Weights{expectedWeights, expectedWeights}, actualWeights)
+	connector.AssertExpectations(t)
+	registry.AssertExpectations(t)
 }
@@ -0,0 +1,133 @@
+package device
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/xmidt-org/sallust"
+	"github.com/xmidt-org/webpa-common/v2/xhttp"
+	"go.uber.org/zap"
+)
+
+// TraceExpiryParameter is the form parameter holding the duration for which a device should be traced
+const TraceExpiryParameter = "expiry"
+
+// traceID extracts the device ID from the gorilla path variable with the given name
+func traceID(logger *zap.Logger, variable string, response http.ResponseWriter, request *http.Request) (ID, bool) {
+	name := mux.Vars(request)[variable]
+	if len(name) == 0 {
+		logger.Error("missing path variable", zap.String("variable", variable))
+		xhttp.WriteError(response, http.StatusBadRequest, ErrorMissingDeviceNameVar)
+		return invalidID, false
+	}
+
+	id, err := ParseID(name)
+	if err != nil {
+		logger.Error("unable to parse identifier", zap.Error(err), zap.String("deviceName", name))
+		xhttp.WriteError(response, http.StatusBadRequest, err)
+		return invalidID, false
+	}
+
+	return id, true
+}
+
+func writeJSON(logger *zap.Logger, response http.ResponseWriter, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		logger.Error("unable to marshal response", zap.Error(err))
+		xhttp.WriteError(response, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.Header().Set("Content-Type", "application/json")
+	response.Write(data)
+}
+
+// TraceAddHandler is an http.Handler that adds the device named by a gorilla path variable
+// to a Tracer.  The optional expiry form parameter is a duration, e.g. 30m.
+type TraceAddHandler struct {
+	Tracer   *Tracer
+	Variable string
+}
+
+func (th *TraceAddHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	logger := sallust.Get(request.Context())
+	id, ok := traceID(logger, th.Variable, response, request)
+	if !ok {
+		return
+	}
+
+	if err := request.ParseForm(); err != nil {
+		logger.Error("unable to parse form", zap.Error(err))
+		xhttp.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+
+	var expiry time.Duration
+	if v := request.Form.Get(TraceExpiryParameter); len(v) > 0 {
+		var err error
+		if expiry, err = time.ParseDuration(v); err != nil {
+			logger.Error("unable to parse trace expiry", zap.Error(err))
+			xhttp.WriteError(response, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	writeJSON(logger, response, th.Tracer.Add(id, expiry))
+}
+
+// TraceRemoveHandler is an http.Handler that removes the device named by a gorilla path variable
+// from a Tracer, discarding anything captured for that device.
+type TraceRemoveHandler struct {
+	Tracer   *Tracer
+	Variable string
+}
+
+func (th *TraceRemoveHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	id, ok := traceID(sallust.Get(request.Context()), th.Variable, response, request)
+	if !ok {
+		return
+	}
+
+	if !th.Tracer.Remove(id) {
+		response.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// TraceListHandler is an http.Handler that returns the devices currently being traced
+type TraceListHandler struct {
+	Tracer *Tracer
+}
+
+func (th *TraceListHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	writeJSON(
+		sallust.Get(request.Context()),
+		response,
+		map[string]interface{}{"devices": th.Tracer.List()},
+	)
+}
+
+// TraceCaptureHandler is an http.Handler that returns the frames captured for the device
+// named by a gorilla path variable.
+type TraceCaptureHandler struct {
+	Tracer   *Tracer
+	Variable string
+}
+
+func (th *TraceCaptureHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	logger := sallust.Get(request.Context())
+	id, ok := traceID(logger, th.Variable, response, request)
+	if !ok {
+		return
+	}
+
+	frames, ok := th.Tracer.Capture(id)
+	if !ok {
+		response.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	writeJSON(logger, response, map[string]interface{}{"id": id, "frames": frames})
+}
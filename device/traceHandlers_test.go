@@ -0,0 +1,91 @@
+package device
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTraceRouter(tracer *Tracer) *mux.Router {
+	r := mux.NewRouter()
+	r.Handle("/trace", &TraceListHandler{Tracer: tracer}).Methods("GET")
+	r.Handle("/trace/{id}", &TraceAddHandler{Tracer: tracer, Variable: "id"}).Methods("PUT")
+	r.Handle("/trace/{id}", &TraceRemoveHandler{Tracer: tracer, Variable: "id"}).Methods("DELETE")
+	r.Handle("/trace/{id}", &TraceCaptureHandler{Tracer: tracer, Variable: "id"}).Methods("GET")
+	return r
+}
+
+func TestTraceHandlers(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		clock   = &testClock{current: time.Now().UTC()}
+		tracer  = NewTracer(TracerOptions{Logger: zap.NewNop(), Now: clock.now})
+		router  = newTraceRouter(tracer)
+	)
+
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(method, target, nil))
+		return response
+	}
+
+	response := serve("PUT", "/trace/MAC:11-22-33-44-55-66?expiry=10m")
+	require.Equal(http.StatusOK, response.Code)
+
+	var entry TraceEntry
+	require.NoError(json.Unmarshal(response.Body.Bytes(), &entry))
+	assert.Equal(ID("mac:112233445566"), entry.ID)
+	assert.True(clock.current.Add(10 * time.Minute).Equal(entry.Expires))
+
+	assert.Equal(http.StatusBadRequest, serve("PUT", "/trace/mac:112233445566?expiry=notaduration").Code)
+	assert.Equal(http.StatusBadRequest, serve("PUT", "/trace/nosuch:112233445566").Code)
+
+	response = serve("GET", "/trace")
+	require.Equal(http.StatusOK, response.Code)
+	assert.Equal("application/json", response.Header().Get("Content-Type"))
+
+	var list struct {
+		Devices []TraceEntry `json:"devices"`
+	}
+
+	require.NoError(json.Unmarshal(response.Body.Bytes(), &list))
+	require.Len(list.Devices, 1)
+	assert.Equal(ID("mac:112233445566"), list.Devices[0].ID)
+
+	tracer.Record("mac:112233445566", TraceInbound, []byte{0xc1})
+	response = serve("GET", "/trace/mac:112233445566")
+	require.Equal(http.StatusOK, response.Code)
+
+	var capture struct {
+		ID     ID            `json:"id"`
+		Frames []TracedFrame `json:"frames"`
+	}
+
+	require.NoError(json.Unmarshal(response.Body.Bytes(), &capture))
+	assert.Equal(ID("mac:112233445566"), capture.ID)
+	assert.Len(capture.Frames, 1)
+
+	assert.Equal(http.StatusNotFound, serve("GET", "/trace/mac:000000000001").Code)
+	assert.Equal(http.StatusOK, serve("DELETE", "/trace/mac:112233445566").Code)
+	assert.Equal(http.StatusNotFound, serve("DELETE", "/trace/mac:112233445566").Code)
+	assert.Equal(http.StatusNotFound, serve("GET", "/trace/mac:112233445566").Code)
+}
+
+func TestTraceIDMissingVariable(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		handler  = &TraceCaptureHandler{Tracer: NewTracer(TracerOptions{}), Variable: "id"}
+		response = httptest.NewRecorder()
+	)
+
+	handler.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(http.StatusBadRequest, response.Code)
+}
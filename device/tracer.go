@@ -0,0 +1,293 @@
+package device
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/xmidt-org/sallust"
+	"github.com/xmidt-org/wrp-go/v3"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultTraceExpiry is the length of time a device is traced when no expiry is supplied
+	DefaultTraceExpiry time.Duration = 15 * time.Minute
+
+	// DefaultMaxTraceExpiry is the longest time a device may be traced when TracerOptions.MaxExpiry is unset
+	DefaultMaxTraceExpiry time.Duration = 24 * time.Hour
+
+	// DefaultTraceCapacity is the number of frames retained per traced device when TracerOptions.Capacity is unset
+	DefaultTraceCapacity = 100
+)
+
+// TraceDirection indicates whether a traced frame was read from or written to a device
+type TraceDirection string
+
+const (
+	TraceInbound  TraceDirection = "inbound"
+	TraceOutbound TraceDirection = "outbound"
+)
+
+// FrameRecorder is a sink for the raw websocket frames exchanged with devices.  A Manager
+// invokes each configured FrameRecorder from the device pumps, so implementations must be
+// fast and must not retain the frame slice after Record returns.
+type FrameRecorder interface {
+	// Record observes a single binary frame read from (TraceInbound) or written to (TraceOutbound)
+	// the given device.
+	Record(id ID, direction TraceDirection, frame []byte)
+}
+
+// TracedFrame is a single websocket frame captured for a traced device
+type TracedFrame struct {
+	// Timestamp is the UTC time at which the frame was read or written
+	Timestamp time.Time `json:"timestamp"`
+
+	// Direction indicates whether the frame came from or went to the device
+	Direction TraceDirection `json:"direction"`
+
+	// Length is the size in bytes of the raw frame
+	Length int `json:"length"`
+
+	// Message is the decoded WRP message.  This field is nil if the frame could not be decoded.
+	// nolint: typecheck
+	Message *wrp.Message `json:"message,omitempty"`
+
+	// Error holds the decoding error, if any
+	Error string `json:"error,omitempty"`
+}
+
+// TraceEntry describes a device on the trace list
+type TraceEntry struct {
+	ID       ID        `json:"id"`
+	Expires  time.Time `json:"expires"`
+	Captured int       `json:"captured"`
+}
+
+// TracerOptions configures a Tracer
+type TracerOptions struct {
+	// Logger is the sink for traced frames, which are always logged at debug level.  This logger
+	// is normally configured separately from the manager's logger so that debug output is enabled
+	// only for traced devices.  If unset, sallust.Default() is used.
+	Logger *zap.Logger
+
+	// Capacity is the number of frames retained for each traced device.  Once full, the oldest
+	// frames are overwritten.  If unset, DefaultTraceCapacity is used.
+	Capacity int
+
+	// MaxExpiry is the upper bound on how long any device may be traced.  If unset,
+	// DefaultMaxTraceExpiry is used.
+	MaxExpiry time.Duration
+
+	// Now is the closure used to determine the current time.  If not set, time.Now is used.
+	Now func() time.Time
+}
+
+// trace is the bounded ring buffer of frames for a single device
+type trace struct {
+	expires time.Time
+	frames  []TracedFrame
+	next    int
+	full    bool
+}
+
+func (tr *trace) add(f TracedFrame) {
+	tr.frames[tr.next] = f
+	tr.next++
+	if tr.next == len(tr.frames) {
+		tr.next = 0
+		tr.full = true
+	}
+}
+
+func (tr *trace) len() int {
+	if tr.full {
+		return len(tr.frames)
+	}
+
+	return tr.next
+}
+
+// snapshot returns a copy of the captured frames, oldest first
+func (tr *trace) snapshot() []TracedFrame {
+	output := make([]TracedFrame, 0, tr.len())
+	if tr.full {
+		output = append(output, tr.frames[tr.next:]...)
+	}
+
+	return append(output, tr.frames[:tr.next]...)
+}
+
+var _ FrameRecorder = (*Tracer)(nil)
+
+// Tracer maintains a list of devices for which every frame is logged and captured.  Each device
+// is traced until its expiry, after which it is silently dropped from the list.  A Tracer is
+// safe for concurrent use, and is attached to a Manager through Options.Tracer.
+type Tracer struct {
+	logger    *zap.Logger
+	capacity  int
+	maxExpiry time.Duration
+	now       func() time.Time
+
+	lock   sync.RWMutex
+	traces map[ID]*trace
+}
+
+// NewTracer creates an empty Tracer
+func NewTracer(o TracerOptions) *Tracer {
+	t := &Tracer{
+		logger:    o.Logger,
+		capacity:  o.Capacity,
+		maxExpiry: o.MaxExpiry,
+		now:       o.Now,
+		traces:    make(map[ID]*trace),
+	}
+
+	if t.logger == nil {
+		t.logger = sallust.Default()
+	}
+
+	if t.capacity < 1 {
+		t.capacity = DefaultTraceCapacity
+	}
+
+	if t.maxExpiry <= 0 {
+		t.maxExpiry = DefaultMaxTraceExpiry
+	}
+
+	if t.now == nil {
+		t.now = time.Now
+	}
+
+	return t
+}
+
+// purge removes expired traces.  The write lock must be held.
+func (t *Tracer) purge(now time.Time) {
+	for id, tr := range t.traces {
+		if !now.Before(tr.expires) {
+			delete(t.traces, id)
+		}
+	}
+}
+
+// Add begins tracing the given device for the given length of time.  A nonpositive expiry
+// results in DefaultTraceExpiry, and expiries are capped at the configured maximum.  Adding a
+// device that is already traced extends its expiry without discarding any captured frames.
+func (t *Tracer) Add(id ID, expiry time.Duration) TraceEntry {
+	if expiry <= 0 {
+		expiry = DefaultTraceExpiry
+	} else if expiry > t.maxExpiry {
+		expiry = t.maxExpiry
+	}
+
+	now := t.now().UTC()
+	defer t.lock.Unlock()
+	t.lock.Lock()
+	t.purge(now)
+
+	tr, ok := t.traces[id]
+	if !ok {
+		tr = &trace{frames: make([]TracedFrame, t.capacity)}
+		t.traces[id] = tr
+	}
+
+	tr.expires = now.Add(expiry)
+	t.logger.Info("device trace added", zap.String("id", string(id)), zap.Time("expires", tr.expires))
+	return TraceEntry{ID: id, Expires: tr.expires, Captured: tr.len()}
+}
+
+// Remove stops tracing the given device and discards its captured frames.  This method
+// returns false if the device was not being traced.
+func (t *Tracer) Remove(id ID) bool {
+	defer t.lock.Unlock()
+	t.lock.Lock()
+
+	_, ok := t.traces[id]
+	delete(t.traces, id)
+	if ok {
+		t.logger.Info("device trace removed", zap.String("id", string(id)))
+	}
+
+	return ok
+}
+
+// List returns the devices currently being traced, sorted by ID
+func (t *Tracer) List() []TraceEntry {
+	now := t.now().UTC()
+	defer t.lock.Unlock()
+	t.lock.Lock()
+	t.purge(now)
+
+	entries := make([]TraceEntry, 0, len(t.traces))
+	for id, tr := range t.traces {
+		entries = append(entries, TraceEntry{ID: id, Expires: tr.expires, Captured: tr.len()})
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries
+}
+
+// Capture returns the frames captured for the given device, oldest first.  The boolean
+// return is false if the device is not being traced.
+func (t *Tracer) Capture(id ID) ([]TracedFrame, bool) {
+	now := t.now().UTC()
+	defer t.lock.Unlock()
+	t.lock.Lock()
+	t.purge(now)
+
+	tr, ok := t.traces[id]
+	if !ok {
+		return nil, false
+	}
+
+	return tr.snapshot(), true
+}
+
+// Traced tests if the given device is currently being traced
+func (t *Tracer) Traced(id ID) bool {
+	now := t.now()
+	t.lock.RLock()
+	tr, ok := t.traces[id]
+	ok = ok && now.Before(tr.expires)
+	t.lock.RUnlock()
+
+	return ok
+}
+
+// Record logs and captures a single frame for the given device, if that device is being traced.
+// The frame is decoded as Msgpack WRP.  The frame itself is not retained.
+func (t *Tracer) Record(id ID, direction TraceDirection, frame []byte) {
+	if !t.Traced(id) {
+		return
+	}
+
+	var (
+		// nolint: typecheck
+		message = new(wrp.Message)
+		f       = TracedFrame{
+			Timestamp: t.now().UTC(),
+			Direction: direction,
+			Length:    len(frame),
+		}
+	)
+
+	// decode from a copy, so that nothing in the captured message aliases the frame
+	// nolint: typecheck
+	if err := wrp.NewDecoderBytes(append([]byte(nil), frame...), wrp.Msgpack).Decode(message); err != nil {
+		f.Error = err.Error()
+		t.logger.Debug("traced frame", zap.String("id", string(id)), zap.String("direction", string(direction)),
+			zap.Int("length", len(frame)), zap.Error(err))
+	} else {
+		f.Message = message
+		t.logger.Debug("traced frame", zap.String("id", string(id)), zap.String("direction", string(direction)),
+			zap.Int("length", len(frame)), zap.Any("message", message))
+	}
+
+	t.lock.Lock()
+	if tr, ok := t.traces[id]; ok {
+		tr.add(f)
+	}
+
+	t.lock.Unlock()
+}
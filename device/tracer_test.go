@@ -0,0 +1,149 @@
+package device
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/wrp-go/v3"
+	"go.uber.org/zap"
+)
+
+type testClock struct {
+	current time.Time
+}
+
+func (tc *testClock) now() time.Time {
+	return tc.current
+}
+
+func (tc *testClock) advance(d time.Duration) {
+	tc.current = tc.current.Add(d)
+}
+
+func encodeTestMessage(t *testing.T, m *wrp.Message) []byte {
+	var frame []byte
+	// nolint: typecheck
+	require.NoError(t, wrp.NewEncoderBytes(&frame, wrp.Msgpack).Encode(m))
+	return frame
+}
+
+func testTracerDefaults(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		clock  = &testClock{current: time.Now().UTC()}
+		tracer = NewTracer(TracerOptions{Now: clock.now})
+	)
+
+	assert.Equal(DefaultTraceCapacity, tracer.capacity)
+	assert.Equal(DefaultMaxTraceExpiry, tracer.maxExpiry)
+
+	entry := tracer.Add("mac:112233445566", 0)
+	assert.Equal(clock.current.Add(DefaultTraceExpiry), entry.Expires)
+
+	entry = tracer.Add("mac:112233445566", 1000*time.Hour)
+	assert.Equal(clock.current.Add(DefaultMaxTraceExpiry), entry.Expires)
+}
+
+func testTracerExpiry(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		clock  = &testClock{current: time.Now().UTC()}
+		tracer = NewTracer(TracerOptions{Logger: zap.NewNop(), Now: clock.now})
+		id     = ID("mac:112233445566")
+	)
+
+	assert.False(tracer.Traced(id))
+	tracer.Add(id, time.Minute)
+	tracer.Add("mac:000000000001", time.Hour)
+	assert.True(tracer.Traced(id))
+	assert.Len(tracer.List(), 2)
+
+	clock.advance(time.Minute)
+	assert.False(tracer.Traced(id))
+
+	entries := tracer.List()
+	assert.Len(entries, 1)
+	assert.Equal(ID("mac:000000000001"), entries[0].ID)
+
+	frames, ok := tracer.Capture(id)
+	assert.Nil(frames)
+	assert.False(ok)
+}
+
+func testTracerRecord(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		clock   = &testClock{current: time.Now().UTC()}
+		tracer  = NewTracer(TracerOptions{Logger: zap.NewNop(), Capacity: 3, Now: clock.now})
+		id      = ID("mac:112233445566")
+	)
+
+	// untraced devices are ignored
+	tracer.Record(id, TraceInbound, []byte("ignored"))
+	tracer.Add(id, time.Hour)
+
+	tracer.Record(id, TraceInbound, []byte("this is not msgpack"))
+	for i, destination := range []string{"event:one", "event:two", "event:three"} {
+		clock.advance(time.Second)
+		direction := TraceInbound
+		if i%2 == 1 {
+			direction = TraceOutbound
+		}
+
+		// nolint: typecheck
+		tracer.Record(id, direction, encodeTestMessage(t, &wrp.Message{
+			Type:        wrp.SimpleEventMessageType,
+			Source:      string(id),
+			Destination: destination,
+		}))
+	}
+
+	frames, ok := tracer.Capture(id)
+	require.True(ok)
+	require.Len(frames, 3)
+
+	// the undecodable frame was the oldest, and has been overwritten
+	assert.Equal("event:one", frames[0].Message.Destination)
+	assert.Equal(TraceInbound, frames[0].Direction)
+	assert.Equal("event:two", frames[1].Message.Destination)
+	assert.Equal(TraceOutbound, frames[1].Direction)
+	assert.Equal("event:three", frames[2].Message.Destination)
+	assert.Equal(clock.current, frames[2].Timestamp)
+	assert.True(frames[0].Timestamp.Before(frames[1].Timestamp))
+
+	entries := tracer.List()
+	require.Len(entries, 1)
+	assert.Equal(3, entries[0].Captured)
+
+	assert.True(tracer.Remove(id))
+	assert.False(tracer.Remove(id))
+	assert.False(tracer.Traced(id))
+}
+
+func testTracerRecordUndecodable(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		tracer = NewTracer(TracerOptions{Logger: zap.NewNop()})
+		id     = ID("mac:112233445566")
+	)
+
+	tracer.Add(id, time.Hour)
+	tracer.Record(id, TraceInbound, []byte{0xc1})
+
+	frames, ok := tracer.Capture(id)
+	assert.True(ok)
+	assert.Len(frames, 1)
+	assert.Nil(frames[0].Message)
+	assert.NotEmpty(frames[0].Error)
+	assert.Equal(1, frames[0].Length)
+}
+
+func TestTracer(t *testing.T) {
+	t.Run("Defaults", testTracerDefaults)
+	t.Run("Expiry", testTracerExpiry)
+	t.Run("Record", testTracerRecord)
+	t.Run("RecordUndecodable", testTracerRecordUndecodable)
+}
@@ -18,6 +18,19 @@ const (
 	//     }
 	//   }
 	DeviceManagerKey = "device.manager"
+
+	// IDSchemesKey is the Viper key under which a list of IDSchemeConfig objects is typically stored.
+	// In a JSON configuration file, this will be expressed as:
+	//
+	//   {
+	//     "device": {
+	//       "idSchemes": [
+	//         {"prefix": "imei", "type": "imei"},
+	//         {"prefix": "event", "pattern": "[^/]+"}
+	//       ]
+	//     }
+	//   }
+	IDSchemesKey = "device.idSchemes"
 )
 
 // NewOptions unmarshals a device.Options from a Viper environment.  Listeners
@@ -31,3 +44,19 @@ func NewOptions(logger *zap.Logger, v *viper.Viper) (o *Options, err error) {
 	o.Logger = logger
 	return
 }
+
+// NewIDSchemesFromViper unmarshals the IDSchemeConfig list stored under the given key and builds
+// the corresponding IDSchemes.  If v is nil or the key is unset, DefaultIDSchemes is returned.
+// The result is typically passed to SetIDSchemes.
+func NewIDSchemesFromViper(v *viper.Viper, key string) (*IDSchemes, error) {
+	if v == nil || !v.IsSet(key) {
+		return DefaultIDSchemes(), nil
+	}
+
+	var configs []IDSchemeConfig
+	if err := v.UnmarshalKey(key, &configs); err != nil {
+		return nil, err
+	}
+
+	return NewIDSchemesFromConfig(configs)
+}
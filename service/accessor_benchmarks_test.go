@@ -74,3 +74,50 @@ func BenchmarkAccessor(b *testing.B) {
 		}
 	})
 }
+
+func BenchmarkAlgorithms(b *testing.B) {
+	b.Log("-addressCounts set to", addressCounts)
+
+	var (
+		random    = rand.New(rand.NewSource(addressSeed))
+		addresses = generateAddresses(random, addressCounts[len(addressCounts)-1], 32)
+	)
+
+	for _, algorithm := range Algorithms() {
+		af, err := NewAlgorithmAccessorFactory(algorithm, HashOptions{})
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(algorithm, func(b *testing.B) {
+			b.Run("Create", func(b *testing.B) {
+				for _, addressCount := range addressCounts {
+					testAddresses := addresses[:addressCount]
+					b.Run(fmt.Sprintf("(addressCount=%d)", addressCount), func(b *testing.B) {
+						b.ResetTimer()
+						for i := 0; i < b.N; i++ {
+							af(testAddresses)
+						}
+					})
+				}
+			})
+
+			b.Run("Get", func(b *testing.B) {
+				for _, addressCount := range addressCounts {
+					var (
+						accessor = af(addresses[:addressCount])
+						key      = make([]byte, 32)
+					)
+
+					random.Read(key)
+					b.Run(fmt.Sprintf("(addressCount=%d)", addressCount), func(b *testing.B) {
+						b.ResetTimer()
+						for i := 0; i < b.N; i++ {
+							accessor.Get(key)
+						}
+					})
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// The hashing algorithms that can be used to build Accessors
+const (
+	ConsistentHashAlgorithm = "consistentHash"
+	RendezvousAlgorithm     = "rendezvous"
+	JumpHashAlgorithm       = "jumpHash"
+	MaglevAlgorithm         = "maglev"
+	BoundedLoadAlgorithm    = "boundedLoad"
+)
+
+// ErrUnknownAlgorithm indicates that a hashing algorithm name was not recognized
+var ErrUnknownAlgorithm = errors.New("unknown hashing algorithm")
+
+// Algorithms returns the names of all the supported hashing algorithms
+func Algorithms() []string {
+	return []string{
+		ConsistentHashAlgorithm,
+		RendezvousAlgorithm,
+		JumpHashAlgorithm,
+		MaglevAlgorithm,
+		BoundedLoadAlgorithm,
+	}
+}
+
+// HashOptions holds the tuning parameters for the hashing algorithms.  Each algorithm only uses the
+// parameters relevant to it, and nonpositive values select that algorithm's default.
+type HashOptions struct {
+	// VnodeCount is the number of virtual nodes per instance for ConsistentHashAlgorithm and BoundedLoadAlgorithm
+	VnodeCount int
+
+	// MaglevTableSize is the size of the lookup table for MaglevAlgorithm
+	MaglevTableSize int
+
+	// LoadBalance is the factor by which an instance may exceed the average load for BoundedLoadAlgorithm
+	LoadBalance float64
+}
+
+// NewAlgorithmAccessorFactory produces an AccessorFactory for the named hashing algorithm.  An empty
+// name selects ConsistentHashAlgorithm, which is what DefaultAccessorFactory uses.
+func NewAlgorithmAccessorFactory(algorithm string, o HashOptions) (AccessorFactory, error) {
+	switch algorithm {
+	case "", ConsistentHashAlgorithm:
+		return NewConsistentAccessorFactory(o.VnodeCount), nil
+
+	case RendezvousAlgorithm:
+		return NewRendezvousAccessorFactory(), nil
+
+	case JumpHashAlgorithm:
+		return NewJumpHashAccessorFactory(), nil
+
+	case MaglevAlgorithm:
+		return NewMaglevAccessorFactory(o.MaglevTableSize), nil
+
+	case BoundedLoadAlgorithm:
+		return NewBoundedLoadAccessorFactory(o.VnodeCount, o.LoadBalance), nil
+
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAlgorithm, algorithm)
+	}
+}
+
+// uniqueInstances returns a sorted copy of instances with duplicates removed, so that accessors which
+// depend on the order of their instances hash identically regardless of the order discovery returns them in
+func uniqueInstances(instances []string) []string {
+	unique := make([]string, 0, len(instances))
+	unique = append(unique, instances...)
+	sort.Strings(unique)
+
+	n := 0
+	for i, instance := range unique {
+		if i == 0 || instance != unique[n-1] {
+			unique[n] = instance
+			n++
+		}
+	}
+
+	return unique[:n]
+}
+
+// mix64 is the splitmix64 finalizer, used to spread combined hashes evenly over 64 bits
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testAlgorithmEmpty(t *testing.T, af AccessorFactory) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	for _, i := range [][]string{nil, []string{}} {
+		a := af(i)
+		require.NotNil(a)
+		i, err := a.Get([]byte("test"))
+		assert.Empty(i)
+		assert.Error(err)
+	}
+}
+
+func testAlgorithmSingle(t *testing.T, af AccessorFactory) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		a = af([]string{"an instance", "an instance"})
+	)
+
+	require.NotNil(a)
+	for _, k := range []string{"a", "alsdkjfa;lksehjuro8iwurjhf", "asdf8974", "875kjh4", "928375hjdfgkyu9832745kjshdfgoi873465"} {
+		i, err := a.Get([]byte(k))
+		assert.Equal("an instance", i)
+		assert.NoError(err)
+	}
+}
+
+func testAlgorithmSpread(t *testing.T, af AccessorFactory) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		random    = rand.New(rand.NewSource(addressSeed))
+		instances = generateAddresses(random, 10, 16)
+		keys      = generateKeys(random, 10000)
+
+		first  = af(instances)
+		second = af([]string{instances[9], instances[3], instances[0], instances[5], instances[1], instances[8], instances[2], instances[7], instances[4], instances[6]})
+		counts = make(map[string]int)
+	)
+
+	for _, k := range keys {
+		i, err := first.Get(k)
+		require.NoError(err)
+		counts[i]++
+
+		// the order of instances must not matter
+		j, err := second.Get(k)
+		require.NoError(err)
+		assert.Equal(i, j)
+	}
+
+	assert.Len(counts, len(instances))
+	for _, c := range counts {
+		assert.InDelta(len(keys)/len(instances), c, float64(len(keys)/len(instances))/2.0)
+	}
+}
+
+func testAlgorithmMovement(t *testing.T, af AccessorFactory, scaling bool) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		random    = rand.New(rand.NewSource(addressSeed))
+		instances = generateAddresses(random, 10, 16)
+		keys      = generateKeys(random, 10000)
+	)
+
+	m, err := MeasureMovement(af, instances, instances[:9], keys)
+	require.NoError(err)
+	assert.Equal(len(keys), m.Keys)
+	assert.InDelta(m.Ideal(), m.Fraction(), 0.05)
+
+	if scaling {
+		m, err = MeasureMovement(af, instances[:9], instances, keys)
+		require.NoError(err)
+		assert.InDelta(m.Ideal(), m.Fraction(), 0.05)
+	}
+}
+
+func generateKeys(random *rand.Rand, count int) [][]byte {
+	keys := make([][]byte, count)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("mac:%012x", random.Int63n(1<<48)))
+	}
+
+	return keys
+}
+
+func TestAlgorithms(t *testing.T) {
+	for _, algorithm := range Algorithms() {
+		t.Run(algorithm, func(t *testing.T) {
+			af, err := NewAlgorithmAccessorFactory(algorithm, HashOptions{})
+			require.NoError(t, err)
+			require.NotNil(t, af)
+
+			t.Run("Empty", func(t *testing.T) { testAlgorithmEmpty(t, af) })
+			t.Run("Single", func(t *testing.T) { testAlgorithmSingle(t, af) })
+			t.Run("Spread", func(t *testing.T) { testAlgorithmSpread(t, af) })
+
+			// jump hash only moves keys minimally when the last instances in sorted order change
+			if algorithm != JumpHashAlgorithm {
+				t.Run("Movement", func(t *testing.T) { testAlgorithmMovement(t, af, true) })
+			}
+		})
+	}
+}
+
+func TestNewAlgorithmAccessorFactory(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	af, err := NewAlgorithmAccessorFactory("", HashOptions{})
+	require.NoError(err)
+	require.NotNil(af)
+
+	af, err = NewAlgorithmAccessorFactory("nosuch", HashOptions{})
+	assert.Nil(af)
+	assert.ErrorIs(err, ErrUnknownAlgorithm)
+}
+
+func TestUniqueInstances(t *testing.T) {
+	assert := assert.New(t)
+	assert.Empty(uniqueInstances(nil))
+	assert.Equal([]string{"a", "b", "c"}, uniqueInstances([]string{"c", "a", "b", "a", "c"}))
+
+	original := []string{"b", "a"}
+	uniqueInstances(original)
+	assert.Equal([]string{"b", "a"}, original)
+}
+
+func TestJumpHash(t *testing.T) {
+	assert := assert.New(t)
+	for key := uint64(0); key < 1000; key++ {
+		assert.Zero(jumpHash(key, 1))
+
+		// growing the number of buckets only ever moves a key to the new bucket
+		b := jumpHash(key, 10)
+		assert.True(b >= 0 && b < 10)
+		if c := jumpHash(key, 11); c != b {
+			assert.Equal(10, c)
+		}
+	}
+}
+
+func TestNextPrime(t *testing.T) {
+	assert := assert.New(t)
+	for n, expected := range map[int]int{1: 2, 2: 2, 3: 3, 4: 5, 90: 97, 65536: 65537, 65537: 65537} {
+		assert.Equal(expected, nextPrime(n), "n=%d", n)
+	}
+}
+
+func TestBoundedLoadAccessor(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		random    = rand.New(rand.NewSource(addressSeed))
+		instances = generateAddresses(random, 5, 16)
+		keys      = generateKeys(random, 10000)
+
+		// a single vnode per instance spreads keys very unevenly without a bound
+		af       = NewBoundedLoadAccessorFactory(1, 1.1)
+		capacity = int(1.1*float64(boundedLoadTableSize)/float64(len(instances))) + 1
+
+		first  = af(instances)
+		second = af([]string{instances[3], instances[1], instances[4], instances[0], instances[2]})
+		slots  = make(map[string]int)
+		counts = make(map[string]int)
+	)
+
+	// the table depends only on the set of instances
+	require.IsType(&boundedLoadAccessor{}, first)
+	require.IsType(&boundedLoadAccessor{}, second)
+	assert.Equal(first, second)
+
+	bla := first.(*boundedLoadAccessor)
+	for _, i := range bla.table {
+		slots[bla.instances[i]]++
+	}
+
+	assert.Len(slots, len(instances))
+	for _, s := range slots {
+		assert.LessOrEqual(s, capacity)
+	}
+
+	for _, k := range keys {
+		i, err := first.Get(k)
+		require.NoError(err)
+		counts[i]++
+	}
+
+	for _, c := range counts {
+		assert.LessOrEqual(c, int(1.15*float64(len(keys))/float64(len(instances))))
+	}
+}
+
+func TestMovement(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		keys    = [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d")}
+	)
+
+	assert.Zero(Movement{}.Fraction())
+	assert.Zero(Movement{}.Ideal())
+	assert.Zero(Movement{}.Imbalance())
+
+	m, err := MeasureMovement(
+		func(instances []string) Accessor {
+			if len(instances) == 1 {
+				return MapAccessor{"a": "x", "b": "x", "c": "x", "d": "x"}
+			}
+
+			return MapAccessor{"a": "x", "b": "x", "c": "x", "d": "y"}
+		},
+		[]string{"x"},
+		[]string{"x", "y"},
+		keys,
+	)
+
+	require.NoError(err)
+	assert.Equal(4, m.Keys)
+	assert.Equal(1, m.Moved)
+	assert.Equal(map[string]int{"x": 4}, m.Before)
+	assert.Equal(map[string]int{"x": 3, "y": 1}, m.After)
+	assert.Equal(0.25, m.Fraction())
+	assert.Equal(0.5, m.Ideal())
+	assert.Equal(1.5, m.Imbalance())
+
+	m, err = MeasureMovement(DefaultAccessorFactory, []string{"x"}, nil, keys)
+	assert.Error(err)
+	assert.Zero(m.Keys)
+}
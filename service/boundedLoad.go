@@ -0,0 +1,166 @@
+package service
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/spaolacci/murmur3"
+)
+
+const (
+	// DefaultLoadBalance is the factor by which an instance may exceed the average load when no factor is supplied
+	DefaultLoadBalance = 1.25
+
+	// boundedLoadTableSize is the number of slots keys are spread over by a bounded load accessor
+	boundedLoadTableSize = 1 << 16
+)
+
+// boundedLoadAccessor approximates consistent hashing with bounded loads without remembering any keys.
+// Rather than bounding the keys each instance has been handed, it bounds a fixed table of slots: each
+// slot, in order, goes to the first instance along the ring from the slot's own position which holds
+// fewer than the balance factor times the average number of slots.  Keys then hash to a slot.  Since the
+// table depends only on the set of instances, every process with the same instances agrees on where each
+// key goes, and an instance's share of keys is capped as long as keys spread evenly over the slots.
+type boundedLoadAccessor struct {
+	instances []string
+	table     []int
+}
+
+// boundedLoadRing is the ring of vnodes used to fill a bounded load table.  Once an instance is full, its
+// vnodes are skipped by pointing each of them at the next vnode along the ring, as in a disjoint set.
+type boundedLoadRing struct {
+	tokens []uint64
+	owners []int
+	next   []int
+}
+
+func newBoundedLoadRing(vnodeCount int, instances []string) *boundedLoadRing {
+	var (
+		size = vnodeCount * len(instances)
+		r    = &boundedLoadRing{
+			tokens: make([]uint64, 0, size),
+			owners: make([]int, 0, size),
+			next:   make([]int, size),
+		}
+
+		order = make([]int, size)
+	)
+
+	for i, instance := range instances {
+		for v := 0; v < vnodeCount; v++ {
+			r.tokens = append(r.tokens, murmur3.Sum64([]byte(instance+"-"+strconv.Itoa(v))))
+			r.owners = append(r.owners, i)
+		}
+	}
+
+	for p := range order {
+		order[p] = p
+	}
+
+	// ties are broken by owner, so the ring never depends on the order instances were supplied in
+	sort.Slice(order, func(a, b int) bool {
+		ta, tb := r.tokens[order[a]], r.tokens[order[b]]
+		return ta < tb || (ta == tb && r.owners[order[a]] < r.owners[order[b]])
+	})
+
+	tokens, owners := make([]uint64, size), make([]int, size)
+	for p, o := range order {
+		tokens[p], owners[p] = r.tokens[o], r.owners[o]
+		r.next[p] = p
+	}
+
+	r.tokens, r.owners = tokens, owners
+	return r
+}
+
+// find returns the first vnode at or after p, wrapping around the ring, whose instance is not full
+func (r *boundedLoadRing) find(p int) int {
+	root := p
+	for r.next[root] != root {
+		root = r.next[root]
+	}
+
+	for r.next[p] != root {
+		r.next[p], p = root, r.next[p]
+	}
+
+	return root
+}
+
+// closest returns the first vnode, whose instance is not full, at or after the given token
+func (r *boundedLoadRing) closest(token uint64) int {
+	p := sort.Search(len(r.tokens), func(p int) bool { return r.tokens[p] >= token })
+	if p == len(r.tokens) {
+		p = 0
+	}
+
+	return r.find(p)
+}
+
+// full removes every vnode of the given instance from the ring
+func (r *boundedLoadRing) full(instance int) {
+	for p, owner := range r.owners {
+		if owner == instance {
+			r.next[p] = (p + 1) % len(r.next)
+		}
+	}
+}
+
+func newBoundedLoadAccessor(vnodeCount int, balance float64, instances []string) Accessor {
+	if len(instances) == 0 {
+		return emptyAccessor{}
+	}
+
+	var (
+		unique   = uniqueInstances(instances)
+		ring     = newBoundedLoadRing(vnodeCount, unique)
+		loads    = make([]int, len(unique))
+		capacity = int(math.Ceil(balance * float64(boundedLoadTableSize) / float64(len(unique))))
+
+		bla = &boundedLoadAccessor{
+			instances: unique,
+			table:     make([]int, boundedLoadTableSize),
+		}
+
+		slotKey = make([]byte, 8)
+	)
+
+	// the capacity leaves room for every slot, so the ring always has an instance that is not full
+	for slot := range bla.table {
+		binary.BigEndian.PutUint64(slotKey, uint64(slot))
+		i := ring.owners[ring.closest(murmur3.Sum64(slotKey))]
+		bla.table[slot] = i
+		if loads[i]++; loads[i] == capacity {
+			ring.full(i)
+		}
+	}
+
+	return bla
+}
+
+func (bla *boundedLoadAccessor) Get(key []byte) (string, error) {
+	return bla.instances[bla.table[murmur3.Sum64(key)%boundedLoadTableSize]], nil
+}
+
+// NewBoundedLoadAccessorFactory produces a factory which uses consistent hashing with bounded loads.  The
+// returned factory does not modify instances passed to it.  Loads are bounded over a fixed table of slots
+// rather than the keys actually in use, so the Accessors it creates are stateless and deterministic, but
+// the bound only holds for the share of keys each instance can receive.
+//
+// If vnodeCount is nonpositive, DefaultVnodeCount is used.  If balance is not greater than 1,
+// DefaultLoadBalance is used.
+func NewBoundedLoadAccessorFactory(vnodeCount int, balance float64) AccessorFactory {
+	if vnodeCount < 1 {
+		vnodeCount = DefaultVnodeCount
+	}
+
+	if balance <= 1.0 {
+		balance = DefaultLoadBalance
+	}
+
+	return func(instances []string) Accessor {
+		return newBoundedLoadAccessor(vnodeCount, balance, instances)
+	}
+}
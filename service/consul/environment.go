@@ -111,6 +111,9 @@ func newInstancer(l *zap.Logger, c Client, w Watch) sd.Instancer {
 			Tags:         w.Tags,
 			PassingOnly:  w.PassingOnly,
 			QueryOptions: w.QueryOptions,
+
+			WeightMeta:      w.WeightMeta,
+			WeightTagPrefix: w.WeightTagPrefix,
 		}),
 		map[string]interface{}{
 			"service":     w.Service,
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,6 +14,7 @@ import (
 	"github.com/go-kit/kit/util/conn"
 	"github.com/hashicorp/consul/api"
 	"github.com/xmidt-org/webpa-common/v2/adapter"
+	"github.com/xmidt-org/webpa-common/v2/service"
 	"go.uber.org/zap"
 )
 
@@ -28,6 +31,13 @@ type InstancerOptions struct {
 	Tags         []string
 	PassingOnly  bool
 	QueryOptions api.QueryOptions
+
+	// WeightMeta is the service meta key whose integer value is an instance's weight
+	WeightMeta string
+
+	// WeightTagPrefix is the prefix of a service tag whose integer remainder is an instance's weight,
+	// e.g. "weight=" for a tag of "weight=2".  WeightMeta takes precedence when both are present.
+	WeightTagPrefix string
 }
 
 func NewInstancer(o InstancerOptions) sd.Instancer {
@@ -41,8 +51,11 @@ func NewInstancer(o InstancerOptions) sd.Instancer {
 		service:      o.Service,
 		passingOnly:  o.PassingOnly,
 		queryOptions: o.QueryOptions,
+		weightMeta:   o.WeightMeta,
+		weightPrefix: o.WeightTagPrefix,
 		stop:         make(chan struct{}),
 		registry:     make(map[chan<- sd.Event]bool),
+		described:    make(map[chan<- service.DescribedEvent]bool),
 	}
 	if len(o.Tags) > 0 {
 		i.tag = o.Tags[0]
@@ -51,14 +64,14 @@ func NewInstancer(o InstancerOptions) sd.Instancer {
 		}
 	}
 	// grab the initial set of instances
-	instances, index, err := i.getInstances(0, nil)
+	instances, details, index, err := i.getInstances(0, nil)
 	if err == nil {
 		i.logger.Info("instances", zap.Int("instances", len(instances)))
 	} else {
 		i.logger.Error(err.Error(), zap.Error(err))
 	}
 
-	i.update(sd.Event{Instances: instances, Err: err})
+	i.update(sd.Event{Instances: instances, Err: err}, details)
 	go i.loop(index)
 	return i
 }
@@ -72,36 +85,65 @@ type instancer struct {
 	filterTags   []string
 	passingOnly  bool
 	queryOptions api.QueryOptions
+	weightMeta   string
+	weightPrefix string
 	stop         chan struct{}
 	registerLock sync.Mutex
 	state        sd.Event
 	registry     map[chan<- sd.Event]bool
+	described    map[chan<- service.DescribedEvent]bool
+
+	// details are only changed while holding both registerLock and detailsLock.  Weights and Describe
+	// only take detailsLock, since they may be called by the same goroutines that update blocks sending to.
+	detailsLock sync.RWMutex
+	details     map[string]service.Instance
 }
 
-func (i *instancer) update(e sd.Event) {
+func (i *instancer) update(e sd.Event, details map[string]service.Instance) {
 	sort.Strings(e.Instances)
 	defer i.registerLock.Unlock()
 	i.registerLock.Lock()
 
-	if reflect.DeepEqual(i.state, e) {
+	if reflect.DeepEqual(i.state, e) && reflect.DeepEqual(i.details, details) {
 		return
 	}
 
 	i.state = e
+	i.detailsLock.Lock()
+	i.details = details
+	i.detailsLock.Unlock()
+
 	for c := range i.registry {
 		c <- i.state
 	}
+
+	if len(i.described) > 0 {
+		de := i.describedState()
+		for c := range i.described {
+			c <- de
+		}
+	}
+}
+
+// describedState returns the current state along with the details of its instances.  The caller must
+// hold registerLock, which keeps the details from changing.
+func (i *instancer) describedState() service.DescribedEvent {
+	return service.DescribedEvent{
+		Event:   i.state,
+		Details: i.describe(i.state.Instances),
+	}
 }
 
 func (i *instancer) loop(lastIndex uint64) {
 	var (
 		instances []string
+		details   map[string]service.Instance
 		err       error
 		d         time.Duration = 10 * time.Millisecond
 	)
 
 	for {
-		instances, lastIndex, err = i.getInstances(lastIndex, i.stop)
+		instances, details, lastIndex, err = i.getInstances(lastIndex, i.stop)
 		switch {
 		case errors.Is(err, errStopped):
 			return
@@ -115,10 +157,10 @@ func (i *instancer) loop(lastIndex uint64) {
 			d = conn.Exponential(d)
 			if !api.IsRetryableError(err) && !errors.Is(err, errIndexUnderflow) && !errors.Is(err, errIndexZero) {
 				// this is a true error that should command the attention of application code
-				i.update(sd.Event{Err: err})
+				i.update(sd.Event{Err: err}, nil)
 			}
 		default:
-			i.update(sd.Event{Instances: instances})
+			i.update(sd.Event{Instances: instances}, details)
 			d = 10 * time.Millisecond
 		}
 	}
@@ -126,9 +168,10 @@ func (i *instancer) loop(lastIndex uint64) {
 
 // getInstances is implemented similarly to go-kits sd/consul version, albeit with support for
 // arbitrary query options
-func (i *instancer) getInstances(lastIndex uint64, stop <-chan struct{}) ([]string, uint64, error) {
+func (i *instancer) getInstances(lastIndex uint64, stop <-chan struct{}) ([]string, map[string]service.Instance, uint64, error) {
 	type response struct {
 		instances []string
+		details   map[string]service.Instance
 		index     uint64
 		err       error
 	}
@@ -154,6 +197,7 @@ func (i *instancer) getInstances(lastIndex uint64, stop <-chan struct{}) ([]stri
 				}
 
 				resp.instances = makeInstances(entries)
+				resp.details = i.makeDetails(entries)
 				resp.index = meta.LastIndex
 			}
 		}
@@ -163,9 +207,9 @@ func (i *instancer) getInstances(lastIndex uint64, stop <-chan struct{}) ([]stri
 
 	select {
 	case r := <-result:
-		return r.instances, r.index, r.err
+		return r.instances, r.details, r.index, r.err
 	case <-stop:
-		return nil, 0, errStopped
+		return nil, nil, 0, errStopped
 	}
 
 }
@@ -209,6 +253,110 @@ func makeInstances(entries []*api.ServiceEntry) []string {
 	return instances
 }
 
+// makeDetails describes entries, keyed by the same instance strings as makeInstances produces.  Node and
+// service meta are merged into each instance's metadata, with service meta taking precedence.  Entries without
+// a datacenter are assumed to be in the datacenter being queried.
+func (i *instancer) makeDetails(entries []*api.ServiceEntry) map[string]service.Instance {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	var (
+		instances = makeInstances(entries)
+		details   = make(map[string]service.Instance, len(entries))
+	)
+
+	for ix, entry := range entries {
+		instance := service.Instance{
+			Address:    instances[ix],
+			Tags:       append([]string(nil), entry.Service.Tags...),
+			Datacenter: i.queryOptions.Datacenter,
+		}
+
+		if len(entry.Node.Datacenter) > 0 {
+			instance.Datacenter = entry.Node.Datacenter
+		}
+
+		if len(entry.Node.Meta)+len(entry.Service.Meta) > 0 {
+			instance.Metadata = make(map[string]string, len(entry.Node.Meta)+len(entry.Service.Meta))
+			for k, v := range entry.Node.Meta {
+				instance.Metadata[k] = v
+			}
+
+			for k, v := range entry.Service.Meta {
+				instance.Metadata[k] = v
+			}
+		}
+
+		instance.Weight, _ = entryWeight(entry, i.weightMeta, i.weightPrefix)
+		details[instance.Address] = instance
+	}
+
+	return details
+}
+
+func entryWeight(entry *api.ServiceEntry, metaKey, tagPrefix string) (int, bool) {
+	if len(metaKey) > 0 {
+		if value, ok := entry.Service.Meta[metaKey]; ok {
+			if weight, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && weight > 0 {
+				return weight, true
+			}
+		}
+	}
+
+	if len(tagPrefix) > 0 {
+		for _, tag := range entry.Service.Tags {
+			if value, ok := strings.CutPrefix(tag, tagPrefix); ok {
+				if weight, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && weight > 0 {
+					return weight, true
+				}
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// Weights returns the weights of the most recently discovered instances
+func (i *instancer) Weights() service.Weights {
+	defer i.detailsLock.RUnlock()
+	i.detailsLock.RLock()
+
+	var weights service.Weights
+	for address, instance := range i.details {
+		if instance.Weight > 0 {
+			if weights == nil {
+				weights = make(service.Weights, len(i.details))
+			}
+
+			weights[address] = instance.Weight
+		}
+	}
+
+	return weights
+}
+
+// Describe returns the details of the given instances, as of the most recent discovery
+func (i *instancer) Describe(addresses []string) service.Instances {
+	defer i.detailsLock.RUnlock()
+	i.detailsLock.RLock()
+	return i.describe(addresses)
+}
+
+// describe returns the details of the given instances.  The caller must hold either lock.
+func (i *instancer) describe(addresses []string) service.Instances {
+	is := make(service.Instances, len(addresses))
+	for ix, address := range addresses {
+		if instance, ok := i.details[address]; ok {
+			is[ix] = instance
+		} else {
+			is[ix] = service.Instance{Address: address}
+		}
+	}
+
+	return is
+}
+
 func (i *instancer) Register(ch chan<- sd.Event) {
 	defer i.registerLock.Unlock()
 	i.registerLock.Lock()
@@ -223,6 +371,21 @@ func (i *instancer) Deregister(ch chan<- sd.Event) {
 	delete(i.registry, ch)
 }
 
+// RegisterDescribed works like Register, but sends each event along with the details of its instances
+func (i *instancer) RegisterDescribed(ch chan<- service.DescribedEvent) {
+	defer i.registerLock.Unlock()
+	i.registerLock.Lock()
+	i.described[ch] = true
+	// push the current state to the new channel
+	ch <- i.describedState()
+}
+
+func (i *instancer) DeregisterDescribed(ch chan<- service.DescribedEvent) {
+	defer i.registerLock.Unlock()
+	i.registerLock.Lock()
+	delete(i.described, ch)
+}
+
 func (i *instancer) Stop() {
 	// this isn't idempotent, but mimics go-kit's behavior
 	close(i.stop)
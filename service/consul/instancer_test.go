@@ -3,9 +3,12 @@ package consul
 import (
 	"strconv"
 	"testing"
+	"time"
 
+	"github.com/go-kit/kit/sd"
 	"github.com/hashicorp/consul/api"
 	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/webpa-common/v2/service"
 )
 
 // newServiceEntry creates a consul ServiceEntry with a service address
@@ -130,3 +133,174 @@ func TestMakeInstances(t *testing.T) {
 		})
 	}
 }
+
+// weightsOf returns the weights an instancer would report for the given entries
+func weightsOf(entries []*api.ServiceEntry, metaKey, tagPrefix string) service.Weights {
+	i := &instancer{weightMeta: metaKey, weightPrefix: tagPrefix}
+	i.details = i.makeDetails(entries)
+	return i.Weights()
+}
+
+func TestMakeWeights(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		metaEntry = newServiceEntry("meta.com", 8080, "weight=7")
+		entries   = []*api.ServiceEntry{
+			metaEntry,
+			newServiceEntry("tagged.com", 8080, "foo", "weight=4"),
+			newServiceEntry("invalid.com", 8080, "weight=-1", "weight=bar"),
+			newServiceEntryNode("node.com", 901),
+		}
+	)
+
+	metaEntry.Service.Meta = map[string]string{"weight": " 3 "}
+
+	assert.Nil(weightsOf(entries, "", ""))
+	assert.Nil(weightsOf(entries[2:], "weight", "weight="))
+	assert.Equal(service.Weights{"meta.com:8080": 3}, weightsOf(entries, "weight", ""))
+	assert.Equal(service.Weights{"meta.com:8080": 7, "tagged.com:8080": 4}, weightsOf(entries, "", "weight="))
+	assert.Equal(service.Weights{"meta.com:8080": 3, "tagged.com:8080": 4}, weightsOf(entries, "weight", "weight="))
+}
+
+func TestDescribe(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		local  = newServiceEntry("local.com", 8080, "foo", "weight=2")
+		remote = newServiceEntryNode("remote.com", 901, "bar")
+		i      = &instancer{
+			weightPrefix: "weight=",
+			queryOptions: api.QueryOptions{Datacenter: "dc1"},
+		}
+	)
+
+	local.Node.Meta = map[string]string{"rack": "a", "role": "node"}
+	local.Service.Meta = map[string]string{"role": "service"}
+	remote.Node.Datacenter = "dc2"
+
+	assert.Nil(i.makeDetails(nil))
+	i.details = i.makeDetails([]*api.ServiceEntry{local, remote})
+	assert.Equal(
+		service.Instances{
+			{
+				Address:    "local.com:8080",
+				Tags:       []string{"foo", "weight=2"},
+				Metadata:   map[string]string{"rack": "a", "role": "service"},
+				Datacenter: "dc1",
+				Weight:     2,
+			},
+			{
+				Address: "unknown.com:80",
+			},
+			{
+				Address:    "remote.com:901",
+				Tags:       []string{"bar"},
+				Datacenter: "dc2",
+			},
+		},
+		i.Describe([]string{"local.com:8080", "unknown.com:80", "remote.com:901"}),
+	)
+
+	assert.Empty(i.Describe(nil))
+}
+
+// sequenceClient is a Client whose Service method reports a different weight for a single instance
+// on each of its first count calls, then blocks until released
+type sequenceClient struct {
+	Client
+	count   int
+	calls   int
+	release chan struct{}
+}
+
+func (sc *sequenceClient) Service(string, string, bool, *api.QueryOptions) ([]*api.ServiceEntry, *api.QueryMeta, error) {
+	sc.calls++
+	if sc.calls > sc.count {
+		<-sc.release
+		return nil, nil, errStopped
+	}
+
+	return []*api.ServiceEntry{newServiceEntry("weighted.com", 8080, "weight="+strconv.Itoa(sc.calls))},
+		&api.QueryMeta{LastIndex: uint64(sc.calls)},
+		nil
+}
+
+func TestWeightsWhileDispatching(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		client = &sequenceClient{count: 50, release: make(chan struct{})}
+		i      = NewInstancer(InstancerOptions{Client: client, Service: "test", WeightTagPrefix: "weight="})
+
+		// like a monitor, read events from a small buffer and ask for weights as each one arrives
+		events = make(chan sd.Event, 10)
+		done   = make(chan struct{})
+		quit   = make(chan struct{})
+	)
+
+	defer close(quit)
+	defer close(client.release)
+	defer i.Stop()
+
+	i.Register(events)
+	go func() {
+		for {
+			select {
+			case <-events:
+				if i.(service.WeightedInstancer).Weights()["weighted.com:8080"] == client.count {
+					select {
+					case <-done:
+					default:
+						close(done)
+					}
+				}
+
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+		assert.Equal(service.Weights{"weighted.com:8080": client.count}, i.(service.WeightedInstancer).Weights())
+	case <-time.After(5 * time.Second):
+		assert.Fail("reading weights while events are dispatched deadlocked")
+	}
+}
+
+func TestRegisterDescribed(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		i = &instancer{
+			registry:  make(map[chan<- sd.Event]bool),
+			described: make(map[chan<- service.DescribedEvent]bool),
+		}
+
+		described = make(chan service.DescribedEvent, 3)
+		instances = []string{"instance.com:8080"}
+	)
+
+	i.RegisterDescribed(described)
+	assert.Equal(service.DescribedEvent{Details: service.Instances{}}, <-described)
+
+	i.update(sd.Event{Instances: instances}, map[string]service.Instance{"instance.com:8080": {Address: "instance.com:8080", Weight: 2}})
+	i.update(sd.Event{Instances: instances}, map[string]service.Instance{"instance.com:8080": {Address: "instance.com:8080", Weight: 3}})
+
+	// each event carries the details of its own discovery, even once those details have changed
+	assert.Equal(service.Instances{{Address: "instance.com:8080", Weight: 3}}, i.Describe(instances))
+	assert.Equal(
+		service.DescribedEvent{Event: sd.Event{Instances: instances}, Details: service.Instances{{Address: "instance.com:8080", Weight: 2}}},
+		<-described,
+	)
+
+	assert.Equal(
+		service.DescribedEvent{Event: sd.Event{Instances: instances}, Details: service.Instances{{Address: "instance.com:8080", Weight: 3}}},
+		<-described,
+	)
+
+	i.DeregisterDescribed(described)
+	i.update(sd.Event{Err: errStopped}, nil)
+	assert.Empty(described)
+}
@@ -15,6 +15,10 @@ type Watch struct {
 	PassingOnly     bool             `json:"passingOnly"`
 	CrossDatacenter bool             `json:"crossDatacenter"`
 	QueryOptions    api.QueryOptions `json:"queryOptions"`
+
+	// WeightMeta and WeightTagPrefix select the service meta key or tag prefix that carries each instance's weight
+	WeightMeta      string `json:"weightMeta,omitempty"`
+	WeightTagPrefix string `json:"weightTagPrefix,omitempty"`
 }
 
 type Options struct {
@@ -45,6 +45,10 @@ type Environment interface {
 	// Typically, this factory is set via configuration by some external source.
 	AccessorFactory() AccessorFactory
 
+	// WeightedAccessorFactory returns the creation strategy for Accessors which take instance weights
+	// into account.  Without weights, it creates the same Accessors as AccessorFactory.
+	WeightedAccessorFactory() WeightedAccessorFactory
+
 	// Closed returns a channel that is closed when this Environment in closed.
 	Closed() <-chan struct{}
 
@@ -93,8 +97,26 @@ func WithAccessorFactory(af AccessorFactory) Option {
 	return func(e *environment) {
 		if af == nil {
 			e.accessorFactory = DefaultAccessorFactory
+			e.weightedAccessorFactory = DefaultWeightedAccessorFactory
 		} else {
 			e.accessorFactory = af
+			e.weightedAccessorFactory = af.Weighted()
+		}
+	}
+}
+
+// WithWeightedAccessorFactory configures the creation strategy for Accessor objects which take instance
+// weights into account.  This option also sets the AccessorFactory, which uses the same strategy with
+// every instance at DefaultWeight.  Passing nil via this option resets the environment back to using
+// the DefaultWeightedAccessorFactory and DefaultAccessorFactory.
+func WithWeightedAccessorFactory(waf WeightedAccessorFactory) Option {
+	return func(e *environment) {
+		if waf == nil {
+			e.accessorFactory = DefaultAccessorFactory
+			e.weightedAccessorFactory = DefaultWeightedAccessorFactory
+		} else {
+			e.accessorFactory = waf.Unweighted()
+			e.weightedAccessorFactory = waf
 		}
 	}
 }
@@ -125,10 +147,11 @@ func WithProvider(p xmetrics.Registry) Option {
 // an environment without any Registrars or Instancers, which essentially makes a no-op environment.
 func NewEnvironment(options ...Option) Environment {
 	e := &environment{
-		defaultScheme:   DefaultScheme,
-		accessorFactory: DefaultAccessorFactory,
-		closer:          NopCloser,
-		closed:          make(chan struct{}),
+		defaultScheme:           DefaultScheme,
+		accessorFactory:         DefaultAccessorFactory,
+		weightedAccessorFactory: DefaultWeightedAccessorFactory,
+		closer:                  NopCloser,
+		closed:                  make(chan struct{}),
 	}
 
 	for _, o := range options {
@@ -145,6 +168,8 @@ type environment struct {
 	accessorFactory AccessorFactory
 	provider        xmetrics.Registry
 
+	weightedAccessorFactory WeightedAccessorFactory
+
 	lock      sync.RWMutex
 	closeOnce sync.Once
 	closer    func() error
@@ -194,6 +219,10 @@ func (e *environment) AccessorFactory() AccessorFactory {
 	return e.accessorFactory
 }
 
+func (e *environment) WeightedAccessorFactory() WeightedAccessorFactory {
+	return e.weightedAccessorFactory
+}
+
 func (e *environment) Register() {
 	e.registrars.Register()
 }
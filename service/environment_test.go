@@ -30,6 +30,7 @@ func testNewEnvironmentNoOptions(t *testing.T) {
 	assert.NotPanics(e.Register)
 	assert.NotPanics(e.Deregister)
 	assert.NotNil(e.AccessorFactory())
+	assert.NotNil(e.WeightedAccessorFactory())
 
 	select {
 	case <-e.Closed():
@@ -155,6 +156,36 @@ func testNewEnvironmentExplicitDefaultAccessorFactory(t *testing.T) {
 
 	require.NotNil(e)
 	assert.NotNil(e.AccessorFactory())
+	assert.NotNil(e.WeightedAccessorFactory())
+}
+
+func testNewEnvironmentWeightedAccessorFactory(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		actualWeights   Weights
+		expectedWeights = Weights{"instance1": 2}
+
+		e = NewEnvironment(
+			WithWeightedAccessorFactory(func(instances []string, weights Weights) Accessor {
+				actualWeights = weights
+				return EmptyAccessor()
+			}),
+		)
+	)
+
+	require.NotNil(e)
+	e.WeightedAccessorFactory()([]string{"instance1"}, expectedWeights)
+	assert.Equal(expectedWeights, actualWeights)
+
+	e.AccessorFactory()([]string{"instance1"})
+	assert.Nil(actualWeights)
+
+	e = NewEnvironment(WithWeightedAccessorFactory(nil))
+	require.NotNil(e)
+	assert.NotNil(e.AccessorFactory())
+	assert.NotNil(e.WeightedAccessorFactory())
 }
 
 func testNewEnvironmentExplicitNopCloser(t *testing.T) {
@@ -191,6 +222,7 @@ func TestNewEnvironment(t *testing.T) {
 	t.Run("NoOptions", testNewEnvironmentNoOptions)
 	t.Run("WithOptions", testNewEnvironmentWithOptions)
 	t.Run("ExplicitDefaultAccessorFactory", testNewEnvironmentExplicitDefaultAccessorFactory)
+	t.Run("WeightedAccessorFactory", testNewEnvironmentWeightedAccessorFactory)
 	t.Run("ExplicitNopCloser", testNewEnvironmentExplicitNopCloser)
 	t.Run("ExplicitDefaultScheme", testNewEnvironmentExplicitDefaultScheme)
 }
@@ -6,6 +6,8 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+
+	"github.com/go-kit/kit/sd"
 )
 
 // DefaultScheme is the default URI scheme assumed by this service discovery infrastructure
@@ -86,3 +88,121 @@ func NormalizeInstance(defaultScheme, instance string) (string, error) {
 
 	return FormatInstance(scheme, address, port), nil
 }
+
+// Instance describes a discovered service instance.  Address holds the same string that is used
+// for the instance everywhere else, so code which only needs that string can keep using it.
+type Instance struct {
+	// Address is the instance's string form, e.g. "https://talaria-1.example.com:8080"
+	Address string `json:"address"`
+
+	// Tags are the tags service discovery associates with the instance, if any
+	Tags []string `json:"tags,omitempty"`
+
+	// Metadata is any key/value information service discovery associates with the instance
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// Datacenter is the datacenter the instance runs in, if known
+	Datacenter string `json:"datacenter,omitempty"`
+
+	// Weight is the instance's weight, or zero if it has none.  See Weights.
+	Weight int `json:"weight,omitempty"`
+}
+
+// String returns the instance's Address
+func (i Instance) String() string {
+	return i.Address
+}
+
+// Instances is a set of described service instances
+type Instances []Instance
+
+// NewInstances describes instances that are known only by their addresses and, optionally, their weights
+func NewInstances(addresses []string, weights Weights) Instances {
+	if len(addresses) == 0 {
+		return nil
+	}
+
+	is := make(Instances, len(addresses))
+	for i, address := range addresses {
+		is[i] = Instance{Address: address, Weight: weights[address]}
+	}
+
+	return is
+}
+
+// Addresses returns the string form of each instance
+func (is Instances) Addresses() []string {
+	if len(is) == 0 {
+		return nil
+	}
+
+	addresses := make([]string, len(is))
+	for i, instance := range is {
+		addresses[i] = instance.Address
+	}
+
+	return addresses
+}
+
+// Weights returns the weights of the instances that have one, or nil if none do
+func (is Instances) Weights() Weights {
+	var weights Weights
+	for _, instance := range is {
+		if instance.Weight > 0 {
+			if weights == nil {
+				weights = make(Weights, len(is))
+			}
+
+			weights[instance.Address] = instance.Weight
+		}
+	}
+
+	return weights
+}
+
+// InstanceDescriber is implemented by sd.Instancers which know more about the instances they discover than
+// their addresses
+type InstanceDescriber interface {
+	sd.Instancer
+
+	// Describe returns the details of each of the given addresses, in the same order.  Addresses this
+	// Instancer knows nothing about are described by their address alone.
+	Describe(addresses []string) Instances
+}
+
+// DescribedEvent is an sd.Event along with the details of its instances, taken from the same discovery
+type DescribedEvent struct {
+	sd.Event
+
+	// Details describes each of the event's Instances, in the same order
+	Details Instances
+}
+
+// EventDescriber is implemented by sd.Instancers which can send the details of the instances they discover
+// along with each event.  Unlike asking an InstanceDescriber once an event has arrived, the details are
+// guaranteed to describe the same discovery as the event's instances.
+type EventDescriber interface {
+	sd.Instancer
+
+	// RegisterDescribed works like Register, but sends described events to the given channel
+	RegisterDescribed(chan<- DescribedEvent)
+
+	// DeregisterDescribed removes a channel added with RegisterDescribed
+	DeregisterDescribed(chan<- DescribedEvent)
+}
+
+// InstanceAccessorFactory defines the behavior of functions which can take a set of described nodes
+// and turn them into an Accessor
+type InstanceAccessorFactory func(Instances) Accessor
+
+// Instances returns an InstanceAccessorFactory which passes the address and weight of each instance to this factory
+func (waf WeightedAccessorFactory) Instances() InstanceAccessorFactory {
+	return func(is Instances) Accessor {
+		return waf(is.Addresses(), is.Weights())
+	}
+}
+
+// DefaultInstanceAccessorFactory is the InstanceAccessorFactory counterpart of DefaultWeightedAccessorFactory
+func DefaultInstanceAccessorFactory(is Instances) Accessor {
+	return DefaultWeightedAccessorFactory(is.Addresses(), is.Weights())
+}
@@ -79,3 +79,77 @@ func TestNormalizeInstance(t *testing.T) {
 		})
 	}
 }
+
+func TestInstances(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		is = NewInstances([]string{"instance1", "instance2"}, Weights{"instance2": 3})
+	)
+
+	assert.Nil(NewInstances(nil, Weights{"instance1": 2}))
+	assert.Nil(Instances{}.Addresses())
+	assert.Nil(Instances{{Address: "instance1"}}.Weights())
+
+	assert.Equal(Instances{{Address: "instance1"}, {Address: "instance2", Weight: 3}}, is)
+	assert.Equal([]string{"instance1", "instance2"}, is.Addresses())
+	assert.Equal(Weights{"instance2": 3}, is.Weights())
+	assert.Equal("instance2", is[1].String())
+}
+
+func TestInstanceAccessorFactory(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		actualInstances []string
+		actualWeights   Weights
+
+		is = Instances{
+			{Address: "instance1", Tags: []string{"tag1"}, Weight: 2},
+			{Address: "instance2", Datacenter: "dc1"},
+		}
+
+		iaf = WeightedAccessorFactory(func(instances []string, weights Weights) Accessor {
+			actualInstances = instances
+			actualWeights = weights
+			return EmptyAccessor()
+		}).Instances()
+	)
+
+	assert.NotNil(iaf(is))
+	assert.Equal([]string{"instance1", "instance2"}, actualInstances)
+	assert.Equal(Weights{"instance1": 2}, actualWeights)
+
+	a := DefaultInstanceAccessorFactory(is[1:])
+	i, err := a.Get([]byte("key"))
+	assert.Equal("instance2", i)
+	assert.NoError(err)
+}
+
+type describingInstancer struct {
+	*MockInstancer
+	details Instances
+}
+
+func (di describingInstancer) Describe([]string) Instances {
+	return di.details
+}
+
+func TestContextualInstancerDescribe(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		expected = Instances{{Address: "instance1", Datacenter: "dc1"}}
+	)
+
+	assert.Nil(ContextualInstancer{Instancer: new(MockInstancer)}.Describe(nil))
+	assert.Equal(
+		Instances{{Address: "instance1"}},
+		ContextualInstancer{Instancer: new(MockInstancer)}.Describe([]string{"instance1"}),
+	)
+
+	assert.Equal(
+		expected,
+		NewContextualInstancer(describingInstancer{MockInstancer: new(MockInstancer), details: expected}, map[string]interface{}{"service": "test"}).(InstanceDescriber).Describe([]string{"instance1"}),
+	)
+}
@@ -13,6 +13,25 @@ func (ci ContextualInstancer) Metadata() map[string]interface{} {
 	return ci.m
 }
 
+// Weights returns the weights reported by the enriched sd.Instancer, if it is a WeightedInstancer
+func (ci ContextualInstancer) Weights() Weights {
+	if wi, ok := ci.Instancer.(WeightedInstancer); ok {
+		return wi.Weights()
+	}
+
+	return nil
+}
+
+// Describe returns the details reported by the enriched sd.Instancer, if it is an InstanceDescriber.
+// Otherwise, instances are described by their addresses and weights alone.
+func (ci ContextualInstancer) Describe(addresses []string) Instances {
+	if d, ok := ci.Instancer.(InstanceDescriber); ok {
+		return d.Describe(addresses)
+	}
+
+	return NewInstances(addresses, ci.Weights())
+}
+
 // NewContextualInstancer returns an sd.Instancer that has been enriched with metadata.
 // This metadata allows infrastructure to carry configuration information about the instancer
 // across API boundaries so that it can be logged or otherwise processed.
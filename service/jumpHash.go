@@ -0,0 +1,43 @@
+package service
+
+import "github.com/spaolacci/murmur3"
+
+// jumpHashAccessor implements Lamping and Veach's jump consistent hash.  It needs no memory beyond the
+// instances themselves and spreads keys almost perfectly evenly, but it maps keys onto bucket numbers
+// rather than names.  Instances are sorted to number them, so movement is only minimal when instances
+// are added or removed at the end of that order.
+type jumpHashAccessor struct {
+	instances []string
+}
+
+func newJumpHashAccessor(instances []string) Accessor {
+	if len(instances) == 0 {
+		return emptyAccessor{}
+	}
+
+	return &jumpHashAccessor{
+		instances: uniqueInstances(instances),
+	}
+}
+
+func (jha *jumpHashAccessor) Get(key []byte) (string, error) {
+	return jha.instances[jumpHash(murmur3.Sum64(key), len(jha.instances))], nil
+}
+
+// jumpHash returns the bucket in [0, buckets) for a key
+func jumpHash(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+
+	return int(b)
+}
+
+// NewJumpHashAccessorFactory produces a factory which uses jump consistent hashing of server nodes.
+// The returned factory does not modify instances passed to it.
+func NewJumpHashAccessorFactory() AccessorFactory {
+	return newJumpHashAccessor
+}
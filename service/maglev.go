@@ -0,0 +1,105 @@
+package service
+
+import "github.com/spaolacci/murmur3"
+
+// DefaultMaglevTableSize is the size of a Maglev lookup table when no size is supplied.  It should be
+// prime and much larger than the number of instances.
+const DefaultMaglevTableSize = 65537
+
+// maglevAccessor implements the lookup table from Google's Maglev load balancer.  Each instance fills
+// the table in the order of its own permutation of the slots, which gives an even spread and constant
+// time lookups with only slightly more movement than a ring when instances change.
+type maglevAccessor struct {
+	instances []string
+	table     []int
+}
+
+func newMaglevAccessor(tableSize int, instances []string) Accessor {
+	if len(instances) == 0 {
+		return emptyAccessor{}
+	}
+
+	ma := &maglevAccessor{
+		instances: uniqueInstances(instances),
+		table:     make([]int, tableSize),
+	}
+
+	var (
+		size    = uint64(tableSize)
+		offsets = make([]uint64, len(ma.instances))
+		skips   = make([]uint64, len(ma.instances))
+		next    = make([]uint64, len(ma.instances))
+	)
+
+	for i, instance := range ma.instances {
+		h1, h2 := murmur3.Sum128([]byte(instance))
+		offsets[i] = h1 % size
+		skips[i] = h2%(size-1) + 1
+	}
+
+	for i := range ma.table {
+		ma.table[i] = -1
+	}
+
+	for filled := 0; filled < tableSize; {
+		for i := 0; i < len(ma.instances) && filled < tableSize; i++ {
+			slot := (offsets[i] + next[i]*skips[i]) % size
+			for ma.table[slot] >= 0 {
+				next[i]++
+				slot = (offsets[i] + next[i]*skips[i]) % size
+			}
+
+			ma.table[slot] = i
+			next[i]++
+			filled++
+		}
+	}
+
+	return ma
+}
+
+func (ma *maglevAccessor) Get(key []byte) (string, error) {
+	return ma.instances[ma.table[murmur3.Sum64(key)%uint64(len(ma.table))]], nil
+}
+
+// nextPrime returns the smallest prime that is at least n
+func nextPrime(n int) int {
+	if n <= 2 {
+		return 2
+	}
+
+	if n%2 == 0 {
+		n++
+	}
+
+	for ; ; n += 2 {
+		prime := true
+		for d := 3; d*d <= n; d += 2 {
+			if n%d == 0 {
+				prime = false
+				break
+			}
+		}
+
+		if prime {
+			return n
+		}
+	}
+}
+
+// NewMaglevAccessorFactory produces a factory which uses Maglev hashing of server nodes.  The returned
+// factory does not modify instances passed to it.
+//
+// If tableSize is nonpositive, DefaultMaglevTableSize is used.  Otherwise, tableSize is rounded up to
+// the next prime, since the table must have a prime size for every instance to be able to reach every slot.
+func NewMaglevAccessorFactory(tableSize int) AccessorFactory {
+	if tableSize < 1 {
+		tableSize = DefaultMaglevTableSize
+	} else {
+		tableSize = nextPrime(tableSize)
+	}
+
+	return func(instances []string) Accessor {
+		return newMaglevAccessor(tableSize, instances)
+	}
+}
@@ -91,6 +91,10 @@ func (m *MockEnvironment) AccessorFactory() AccessorFactory {
 	return m.Called().Get(0).(AccessorFactory)
 }
 
+func (m *MockEnvironment) WeightedAccessorFactory() WeightedAccessorFactory {
+	return m.Called().Get(0).(WeightedAccessorFactory)
+}
+
 func (m *MockEnvironment) Closed() <-chan struct{} {
 	return m.Called().Get(0).(<-chan struct{})
 }
@@ -34,6 +34,14 @@ type Event struct {
 	// Err will be nil.
 	Instances []string
 
+	// Weights are the weights of the instances, keyed by filtered instance, if the sd.Instancer reported
+	// any.  Instances with no weight have service.DefaultWeight.
+	Weights service.Weights
+
+	// Details describe the same instances as Instances, sorted by address, with whatever else the sd.Instancer
+	// knows about them.  Instances is kept for backward compatibility.
+	Details service.Instances
+
 	// Err is any service discovery error that occurred.  If this is set, Instances will be empty.
 	Err error
 
@@ -42,6 +50,16 @@ type Event struct {
 	Stopped bool
 }
 
+// Describe returns this event's Details, or if there are none, describes its Instances by their
+// addresses and weights alone.  This allows listeners to handle events that were not produced by a monitor.
+func (e Event) Describe() service.Instances {
+	if len(e.Details) > 0 {
+		return e.Details
+	}
+
+	return service.NewInstances(e.Instances, e.Weights)
+}
+
 type Listener interface {
 	MonitorEvent(Event)
 }
@@ -102,13 +120,52 @@ func NewAccessorListener(f service.AccessorFactory, next func(service.Accessor,
 		f = service.DefaultAccessorFactory
 	}
 
+	return NewWeightedAccessorListener(f.Weighted(), next)
+}
+
+// NewWeightedAccessorListener is like NewAccessorListener, except that each event's instance weights are
+// passed to the WeightedAccessorFactory.  If the WeightedAccessorFactory is nil, DefaultWeightedAccessorFactory is used.
+func NewWeightedAccessorListener(f service.WeightedAccessorFactory, next func(service.Accessor, error)) Listener {
+	if next == nil {
+		panic("A next closure is required to receive Accessors")
+	}
+
+	if f == nil {
+		f = service.DefaultWeightedAccessorFactory
+	}
+
+	return ListenerFunc(func(e Event) {
+		switch {
+		case e.Err != nil:
+			next(nil, e.Err)
+
+		case len(e.Instances) > 0:
+			next(f(e.Instances, e.Weights), nil)
+
+		default:
+			next(service.EmptyAccessor(), nil)
+		}
+	})
+}
+
+// NewInstanceAccessorListener is like NewAccessorListener, except that each event's described instances are
+// passed to the InstanceAccessorFactory.  If the InstanceAccessorFactory is nil, DefaultInstanceAccessorFactory is used.
+func NewInstanceAccessorListener(f service.InstanceAccessorFactory, next func(service.Accessor, error)) Listener {
+	if next == nil {
+		panic("A next closure is required to receive Accessors")
+	}
+
+	if f == nil {
+		f = service.DefaultInstanceAccessorFactory
+	}
+
 	return ListenerFunc(func(e Event) {
 		switch {
 		case e.Err != nil:
 			next(nil, e.Err)
 
 		case len(e.Instances) > 0:
-			next(f(e.Instances), nil)
+			next(f(e.Describe()), nil)
 
 		default:
 			next(service.EmptyAccessor(), nil)
@@ -125,13 +182,52 @@ func NewKeyAccessorListener(f service.AccessorFactory, key string, next func(str
 		f = service.DefaultAccessorFactory
 	}
 
+	return NewWeightedKeyAccessorListener(f.Weighted(), key, next)
+}
+
+// NewWeightedKeyAccessorListener is like NewKeyAccessorListener, except that each event's instance weights are
+// passed to the WeightedAccessorFactory.  If the WeightedAccessorFactory is nil, DefaultWeightedAccessorFactory is used.
+func NewWeightedKeyAccessorListener(f service.WeightedAccessorFactory, key string, next func(string, service.Accessor, error)) Listener {
+	if next == nil {
+		panic("A next closure is required to receive Accessors")
+	}
+
+	if f == nil {
+		f = service.DefaultWeightedAccessorFactory
+	}
+
+	return ListenerFunc(func(e Event) {
+		switch {
+		case e.Err != nil:
+			next(key, nil, e.Err)
+
+		case len(e.Instances) > 0:
+			next(key, f(e.Instances, e.Weights), nil)
+
+		default:
+			next(key, service.EmptyAccessor(), nil)
+		}
+	})
+}
+
+// NewInstanceKeyAccessorListener is like NewKeyAccessorListener, except that each event's described instances are
+// passed to the InstanceAccessorFactory.  If the InstanceAccessorFactory is nil, DefaultInstanceAccessorFactory is used.
+func NewInstanceKeyAccessorListener(f service.InstanceAccessorFactory, key string, next func(string, service.Accessor, error)) Listener {
+	if next == nil {
+		panic("A next closure is required to receive Accessors")
+	}
+
+	if f == nil {
+		f = service.DefaultInstanceAccessorFactory
+	}
+
 	return ListenerFunc(func(e Event) {
 		switch {
 		case e.Err != nil:
 			next(key, nil, e.Err)
 
 		case len(e.Instances) > 0:
-			next(key, f(e.Instances), nil)
+			next(key, f(e.Describe()), nil)
 
 		default:
 			next(key, service.EmptyAccessor(), nil)
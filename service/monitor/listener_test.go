@@ -355,3 +355,128 @@ func TestNewRegistrarListener(t *testing.T) {
 		})
 	})
 }
+
+func TestNewWeightedAccessorListener(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		expectedWeights = service.Weights{"instance1": 2}
+		actualWeights   service.Weights
+		accessor        service.Accessor
+
+		f = service.WeightedAccessorFactory(func(instances []string, weights service.Weights) service.Accessor {
+			actualWeights = weights
+			return service.DefaultWeightedAccessorFactory(instances, weights)
+		})
+	)
+
+	assert.Panics(func() {
+		NewWeightedAccessorListener(f, nil)
+	})
+
+	assert.Panics(func() {
+		NewWeightedKeyAccessorListener(f, "test", nil)
+	})
+
+	l := NewWeightedAccessorListener(f, func(a service.Accessor, err error) {
+		accessor = a
+		assert.NoError(err)
+	})
+
+	require.NotNil(l)
+	l.MonitorEvent(Event{Instances: []string{"instance1", "instance2"}, Weights: expectedWeights})
+	assert.Equal(expectedWeights, actualWeights)
+	assert.NotNil(accessor)
+
+	actualWeights = nil
+	l = NewWeightedKeyAccessorListener(f, "test", func(key string, a service.Accessor, err error) {
+		assert.Equal("test", key)
+		accessor = a
+		assert.NoError(err)
+	})
+
+	require.NotNil(l)
+	l.MonitorEvent(Event{Instances: []string{"instance1", "instance2"}, Weights: expectedWeights})
+	assert.Equal(expectedWeights, actualWeights)
+
+	l = NewWeightedAccessorListener(nil, func(a service.Accessor, err error) {
+		accessor = a
+	})
+
+	l.MonitorEvent(Event{Instances: []string{"instance1"}})
+	i, err := accessor.Get([]byte("key"))
+	assert.Equal("instance1", i)
+	assert.NoError(err)
+}
+
+func TestNewInstanceAccessorListener(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		expected = service.Instances{
+			{Address: "instance1", Tags: []string{"tag1"}, Datacenter: "dc1", Weight: 2},
+			{Address: "instance2"},
+		}
+
+		actual   service.Instances
+		accessor service.Accessor
+
+		f = service.InstanceAccessorFactory(func(is service.Instances) service.Accessor {
+			actual = is
+			return service.DefaultInstanceAccessorFactory(is)
+		})
+	)
+
+	assert.Panics(func() {
+		NewInstanceAccessorListener(f, nil)
+	})
+
+	assert.Panics(func() {
+		NewInstanceKeyAccessorListener(f, "test", nil)
+	})
+
+	l := NewInstanceAccessorListener(f, func(a service.Accessor, err error) {
+		accessor = a
+		assert.NoError(err)
+	})
+
+	require.NotNil(l)
+	l.MonitorEvent(Event{Instances: expected.Addresses(), Details: expected})
+	assert.Equal(expected, actual)
+	assert.NotNil(accessor)
+
+	// events without details are described by their instances and weights
+	l.MonitorEvent(Event{Instances: []string{"instance1"}, Weights: service.Weights{"instance1": 3}})
+	assert.Equal(service.Instances{{Address: "instance1", Weight: 3}}, actual)
+
+	actual = nil
+	l = NewInstanceKeyAccessorListener(f, "test", func(key string, a service.Accessor, err error) {
+		assert.Equal("test", key)
+		accessor = a
+		assert.NoError(err)
+	})
+
+	require.NotNil(l)
+	l.MonitorEvent(Event{Instances: expected.Addresses(), Details: expected})
+	assert.Equal(expected, actual)
+
+	expectedError := errors.New("expected")
+	l = NewInstanceAccessorListener(nil, func(a service.Accessor, err error) {
+		accessor = a
+		assert.Equal(expectedError, err)
+	})
+
+	l.MonitorEvent(Event{Err: expectedError})
+	assert.Nil(accessor)
+
+	l = NewInstanceAccessorListener(nil, func(a service.Accessor, err error) {
+		accessor = a
+	})
+
+	l.MonitorEvent(Event{Instances: []string{"instance1"}})
+	i, err := accessor.Get([]byte("key"))
+	assert.Equal("instance1", i)
+	assert.NoError(err)
+}
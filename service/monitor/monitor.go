@@ -2,6 +2,7 @@ package monitor
 
 import (
 	"errors"
+	"sort"
 	"sync"
 
 	"github.com/go-kit/kit/sd"
@@ -151,52 +152,152 @@ func (m *monitor) start() error {
 	return nil
 }
 
+// weights returns the instance weights reported by an sd.Instancer, if any, rekeyed by filtered instance
+// so that they match the instances in events
+func (m *monitor) weights(i sd.Instancer) service.Weights {
+	wi, ok := i.(service.WeightedInstancer)
+	if !ok {
+		return nil
+	}
+
+	weights := wi.Weights()
+	if len(weights) == 0 {
+		return nil
+	}
+
+	filtered := make(service.Weights, len(weights))
+	for instance, weight := range weights {
+		if f := m.filter([]string{instance}); len(f) == 1 {
+			filtered[f[0]] = weight
+		}
+	}
+
+	return filtered
+}
+
+// describe returns the details of the given, unfiltered instances, as far as the sd.Instancer can describe them.
+// The details are filtered and sorted to match the instances in events.
+func (m *monitor) describe(i sd.Instancer, instances []string, weights service.Weights) service.Instances {
+	var details service.Instances
+	if d, ok := i.(service.InstanceDescriber); ok {
+		details = d.Describe(instances)
+	} else {
+		details = service.NewInstances(instances, nil)
+	}
+
+	return m.filterDetails(details, weights)
+}
+
+// filterDetails filters and sorts the details of unfiltered instances to match the instances in events.  Any instance
+// without a weight of its own is given its weight, if any, from weights.
+func (m *monitor) filterDetails(details service.Instances, weights service.Weights) service.Instances {
+	filtered := make(service.Instances, 0, len(details))
+	for _, instance := range details {
+		if f := m.filter([]string{instance.Address}); len(f) == 1 {
+			instance.Address = f[0]
+			if instance.Weight < 1 {
+				instance.Weight = weights[instance.Address]
+			}
+
+			filtered = append(filtered, instance)
+		}
+	}
+
+	sort.SliceStable(filtered, func(i, j int) bool {
+		return filtered[i].Address < filtered[j].Address
+	})
+
+	return filtered
+}
+
+// eventDescriber returns the service.EventDescriber behind an sd.Instancer, if any, looking through any
+// metadata it was enriched with
+func eventDescriber(i sd.Instancer) (service.EventDescriber, bool) {
+	if ci, ok := i.(service.ContextualInstancer); ok {
+		i = ci.Instancer
+	}
+
+	ed, ok := i.(service.EventDescriber)
+	return ed, ok
+}
+
+// newEvent logs an sd.Event and begins the Event dispatched for it
+func (m *monitor) newEvent(key, svc string, l *zap.Logger, i sd.Instancer, eventCount int, sdEvent sd.Event) Event {
+	event := Event{
+		Key:        key,
+		Service:    svc,
+		Instancer:  i,
+		EventCount: eventCount,
+	}
+
+	if sdEvent.Err != nil {
+		l.Error("service discovery error", zap.Error(sdEvent.Err), zap.Int(EventCountKey(), eventCount))
+		event.Err = sdEvent.Err
+	} else {
+		l.Error("service discovery update", zap.Strings("instances", sdEvent.Instances), zap.Int(EventCountKey(), eventCount))
+	}
+
+	return event
+}
+
 // dispatchEvents is a goroutine that consumes service discovery events from an sd.Instancer
 // and dispatches those events zero or more Listeners.  If configured, the filter is used to
 // preprocess the set of instances sent to the listener.
-func (m *monitor) dispatchEvents(key, service string, l *zap.Logger, i sd.Instancer) {
+func (m *monitor) dispatchEvents(key, svc string, l *zap.Logger, i sd.Instancer) {
 	var (
 		eventCount = 0
 		events     = make(chan sd.Event, 10)
+		described  chan service.DescribedEvent
 	)
 
 	l.Info("subscription monitor starting", zap.Int(EventCountKey(), eventCount))
 
-	defer i.Deregister(events)
-	i.Register(events)
+	// instancers that send details along with their events are subscribed to those instead, so that each
+	// event's details and weights describe the same discovery as its instances
+	if ed, ok := eventDescriber(i); ok {
+		described = make(chan service.DescribedEvent, 10)
+		defer ed.DeregisterDescribed(described)
+		ed.RegisterDescribed(described)
+	} else {
+		defer i.Deregister(events)
+		i.Register(events)
+	}
 
 	for {
 		select {
 		case sdEvent := <-events:
 			eventCount++
-			event := Event{
-				Key:        key,
-				Service:    service,
-				Instancer:  i,
-				EventCount: eventCount,
-			}
-
-			if sdEvent.Err != nil {
-				l.Error("service discovery error", zap.Error(sdEvent.Err), zap.Int(EventCountKey(), eventCount))
-				event.Err = sdEvent.Err
-			} else {
-				l.Error("service discovery update", zap.Strings("instances", sdEvent.Instances), zap.Int(EventCountKey(), eventCount))
+			event := m.newEvent(key, svc, l, i, eventCount, sdEvent)
+			if sdEvent.Err == nil {
+				event.Weights = m.weights(i)
 				if len(sdEvent.Instances) > 0 {
 					event.Instances = m.filter(sdEvent.Instances)
+					event.Details = m.describe(i, sdEvent.Instances, event.Weights)
 				}
 			}
 
 			m.listeners.MonitorEvent(event)
 
+		case de := <-described:
+			eventCount++
+			event := m.newEvent(key, svc, l, i, eventCount, de.Event)
+			if de.Err == nil && len(de.Instances) > 0 {
+				event.Instances = m.filter(de.Instances)
+				event.Details = m.filterDetails(de.Details, nil)
+				event.Weights = event.Details.Weights()
+			}
+
+			m.listeners.MonitorEvent(event)
+
 		case <-m.stopped:
 			l.Info("subscription monitor was stopped", zap.Int(EventCountKey(), eventCount))
-			m.listeners.MonitorEvent(Event{Key: key, Service: service, Instancer: i, EventCount: eventCount, Stopped: true})
+			m.listeners.MonitorEvent(Event{Key: key, Service: svc, Instancer: i, EventCount: eventCount, Stopped: true})
 			return
 
 		case <-m.closed:
 			l.Info("subscription monitor exiting due to external closure", zap.Int(EventCountKey(), eventCount))
 			m.Stop() // ensure that the Stopped state is correct
-			m.listeners.MonitorEvent(Event{Key: key, Service: service, Instancer: i, EventCount: eventCount, Stopped: true})
+			m.listeners.MonitorEvent(Event{Key: key, Service: svc, Instancer: i, EventCount: eventCount, Stopped: true})
 			return
 		}
 	}
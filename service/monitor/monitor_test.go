@@ -276,3 +276,197 @@ func TestNew(t *testing.T) {
 	t.Run("Stop", testNewStop)
 	t.Run("WithEnvironment", testNewWithEnvironment)
 }
+
+type weightedInstancer struct {
+	*service.MockInstancer
+	weights service.Weights
+}
+
+func (wi weightedInstancer) Weights() service.Weights {
+	return wi.weights
+}
+
+func TestMonitorWeights(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		m      = &monitor{filter: DefaultFilter()}
+	)
+
+	assert.Nil(m.weights(new(service.MockInstancer)))
+	assert.Nil(m.weights(weightedInstancer{MockInstancer: new(service.MockInstancer)}))
+	assert.Nil(m.weights(service.NewContextualInstancer(new(service.MockInstancer), map[string]interface{}{"service": "test"})))
+
+	// weights are rekeyed to match the filtered instances, dropping any that cannot be filtered
+	assert.Equal(
+		service.Weights{"https://instance1.com:8080": 3},
+		m.weights(service.NewContextualInstancer(
+			weightedInstancer{
+				MockInstancer: new(service.MockInstancer),
+				weights:       service.Weights{"instance1.com:8080": 3, "bad:instance:": 2},
+			},
+			map[string]interface{}{"service": "test"},
+		)),
+	)
+}
+
+type describingInstancer struct {
+	weightedInstancer
+	details map[string]service.Instance
+}
+
+func (di describingInstancer) Describe(addresses []string) service.Instances {
+	is := make(service.Instances, len(addresses))
+	for i, address := range addresses {
+		if instance, ok := di.details[address]; ok {
+			is[i] = instance
+		} else {
+			is[i] = service.Instance{Address: address}
+		}
+	}
+
+	return is
+}
+
+func TestMonitorDescribe(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		instancer = describingInstancer{
+			weightedInstancer: weightedInstancer{
+				MockInstancer: new(service.MockInstancer),
+				weights:       service.Weights{"instance2.com:8080": 3},
+			},
+			details: map[string]service.Instance{
+				"instance1.com:8080": {Address: "instance1.com:8080", Datacenter: "dc1", Weight: 2},
+			},
+		}
+
+		addresses = []string{"instance2.com:8080", "bad:instance:", "instance1.com:8080"}
+	)
+
+	m := &monitor{filter: NopFilter}
+	assert.Equal(
+		service.Instances{
+			{Address: "bad:instance:"},
+			{Address: "instance1.com:8080"},
+			{Address: "instance2.com:8080"},
+		},
+		m.describe(new(service.MockInstancer), addresses, nil),
+	)
+
+	// details are kept, and weights fill in for instances without one
+	assert.Equal(
+		service.Instances{
+			{Address: "bad:instance:"},
+			{Address: "instance1.com:8080", Datacenter: "dc1", Weight: 2},
+			{Address: "instance2.com:8080", Weight: 3},
+		},
+		m.describe(instancer, addresses, m.weights(instancer)),
+	)
+
+	// addresses are filtered individually, dropping any that cannot be filtered
+	m = &monitor{filter: DefaultFilter()}
+	assert.Equal(
+		service.Instances{
+			{Address: "https://instance1.com:8080", Datacenter: "dc1", Weight: 2},
+			{Address: "https://instance2.com:8080", Weight: 3},
+		},
+		m.describe(instancer, addresses, m.weights(instancer)),
+	)
+}
+
+// eventDescribingInstancer sends described events, while reporting stale weights and details when asked
+type eventDescribingInstancer struct {
+	describingInstancer
+	registerQueue chan chan<- service.DescribedEvent
+}
+
+func (edi eventDescribingInstancer) RegisterDescribed(ch chan<- service.DescribedEvent) {
+	edi.registerQueue <- ch
+}
+
+func (edi eventDescribingInstancer) DeregisterDescribed(ch chan<- service.DescribedEvent) {
+	edi.registerQueue <- ch
+}
+
+func TestMonitorDescribedEvents(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		instancer = eventDescribingInstancer{
+			describingInstancer: describingInstancer{
+				weightedInstancer: weightedInstancer{
+					MockInstancer: new(service.MockInstancer),
+					weights:       service.Weights{"instance1.com:8080": 5},
+				},
+				details: map[string]service.Instance{
+					"instance1.com:8080": {Address: "instance1.com:8080", Datacenter: "stale", Weight: 5},
+				},
+			},
+			registerQueue: make(chan chan<- service.DescribedEvent, 1),
+		}
+
+		listener      = new(mockListener)
+		monitorEvents = make(chan Event, 5)
+		described     chan<- service.DescribedEvent
+	)
+
+	listener.On("MonitorEvent", mock.MatchedBy(func(Event) bool { return true })).Run(func(arguments mock.Arguments) {
+		monitorEvents <- arguments.Get(0).(Event)
+	})
+
+	m, err := New(
+		WithListeners(listener),
+		WithInstancers(service.Instancers{"test": service.NewContextualInstancer(instancer, map[string]interface{}{"service": "test"})}),
+	)
+
+	require.NoError(err)
+	require.NotNil(m)
+	defer m.Stop()
+
+	select {
+	case described = <-instancer.registerQueue:
+	case <-time.After(5 * time.Second):
+		require.Fail("Failed to receive registered described event channel")
+	}
+
+	// the details and weights come from the event rather than from the instancer
+	described <- service.DescribedEvent{
+		Event: sd.Event{Instances: []string{"instance2.com:8080", "instance1.com:8080"}},
+		Details: service.Instances{
+			{Address: "instance2.com:8080"},
+			{Address: "instance1.com:8080", Datacenter: "dc1", Weight: 2},
+		},
+	}
+
+	select {
+	case event := <-monitorEvents:
+		assert.NoError(event.Err)
+		assert.Equal([]string{"https://instance1.com:8080", "https://instance2.com:8080"}, event.Instances)
+		assert.Equal(
+			service.Instances{
+				{Address: "https://instance1.com:8080", Datacenter: "dc1", Weight: 2},
+				{Address: "https://instance2.com:8080"},
+			},
+			event.Details,
+		)
+
+		assert.Equal(service.Weights{"https://instance1.com:8080": 2}, event.Weights)
+
+	case <-time.After(5 * time.Second):
+		assert.Fail("Failed to receive monitor event")
+	}
+
+	described <- service.DescribedEvent{Event: sd.Event{Err: errors.New("expected")}}
+	select {
+	case event := <-monitorEvents:
+		assert.Error(event.Err)
+		assert.Empty(event.Instances)
+		assert.Empty(event.Details)
+		assert.Empty(event.Weights)
+
+	case <-time.After(5 * time.Second):
+		assert.Fail("Failed to receive monitor event")
+	}
+}
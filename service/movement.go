@@ -0,0 +1,113 @@
+package service
+
+// Movement describes how a set of keys is redistributed when an Accessor is rebuilt for a new set of instances
+type Movement struct {
+	// Keys is the number of keys that were hashed
+	Keys int
+
+	// Moved is the number of keys that hash to a different instance afterward
+	Moved int
+
+	// Before is the number of keys held by each instance beforehand
+	Before map[string]int
+
+	// After is the number of keys held by each instance afterward
+	After map[string]int
+}
+
+// Fraction returns the fraction of keys that moved
+func (m Movement) Fraction() float64 {
+	if m.Keys == 0 {
+		return 0.0
+	}
+
+	return float64(m.Moved) / float64(m.Keys)
+}
+
+// Ideal returns the smallest fraction of keys that could have moved, assuming keys are spread evenly.
+// Every key held by a removed instance has to move, as does each added instance's fair share.
+func (m Movement) Ideal() float64 {
+	if m.Keys == 0 || len(m.After) == 0 {
+		return 0.0
+	}
+
+	var removed, added int
+	for instance, count := range m.Before {
+		if _, ok := m.After[instance]; !ok {
+			removed += count
+		}
+	}
+
+	for instance := range m.After {
+		if _, ok := m.Before[instance]; !ok {
+			added++
+		}
+	}
+
+	var (
+		remaining = float64(m.Keys-removed) / float64(m.Keys)
+		share     = float64(added) / float64(len(m.After))
+	)
+
+	return float64(removed)/float64(m.Keys) + remaining*share
+}
+
+// Imbalance returns the ratio of the most heavily loaded instance's keys afterward to the average
+func (m Movement) Imbalance() float64 {
+	if m.Keys == 0 || len(m.After) == 0 {
+		return 0.0
+	}
+
+	var most int
+	for _, count := range m.After {
+		if count > most {
+			most = count
+		}
+	}
+
+	return float64(most) * float64(len(m.After)) / float64(m.Keys)
+}
+
+// MeasureMovement hashes each key with Accessors built by the factory for the before and after instances,
+// and reports how many keys changed instances.  This is useful for comparing how much device movement each
+// hashing algorithm causes when a cluster scales.
+func MeasureMovement(af AccessorFactory, before, after []string, keys [][]byte) (Movement, error) {
+	var (
+		m = Movement{
+			Keys:   len(keys),
+			Before: make(map[string]int, len(before)),
+			After:  make(map[string]int, len(after)),
+		}
+
+		beforeAccessor = af(before)
+		afterAccessor  = af(after)
+	)
+
+	for _, instance := range after {
+		m.After[instance] = 0
+	}
+
+	for _, instance := range before {
+		m.Before[instance] = 0
+	}
+
+	for _, key := range keys {
+		b, err := beforeAccessor.Get(key)
+		if err != nil {
+			return Movement{}, err
+		}
+
+		a, err := afterAccessor.Get(key)
+		if err != nil {
+			return Movement{}, err
+		}
+
+		m.Before[b]++
+		m.After[a]++
+		if a != b {
+			m.Moved++
+		}
+	}
+
+	return m, nil
+}
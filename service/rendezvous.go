@@ -0,0 +1,51 @@
+package service
+
+import "github.com/spaolacci/murmur3"
+
+// rendezvousAccessor implements highest random weight hashing.  Every instance is scored against
+// each key, and the instance with the highest score wins.  Removing an instance only moves the keys
+// it owned, and adding one only takes keys from the others, at the cost of Get being linear in the
+// number of instances.
+type rendezvousAccessor struct {
+	instances []string
+	seeds     []uint64
+}
+
+func newRendezvousAccessor(instances []string) Accessor {
+	if len(instances) == 0 {
+		return emptyAccessor{}
+	}
+
+	ra := &rendezvousAccessor{
+		instances: uniqueInstances(instances),
+	}
+
+	ra.seeds = make([]uint64, len(ra.instances))
+	for i, instance := range ra.instances {
+		ra.seeds[i] = murmur3.Sum64([]byte(instance))
+	}
+
+	return ra
+}
+
+func (ra *rendezvousAccessor) Get(key []byte) (string, error) {
+	var (
+		hash = murmur3.Sum64(key)
+		best = 0
+		high = mix64(hash ^ ra.seeds[0])
+	)
+
+	for i := 1; i < len(ra.seeds); i++ {
+		if score := mix64(hash ^ ra.seeds[i]); score > high {
+			best, high = i, score
+		}
+	}
+
+	return ra.instances[best], nil
+}
+
+// NewRendezvousAccessorFactory produces a factory which uses rendezvous, or highest random weight,
+// hashing of server nodes.  The returned factory does not modify instances passed to it.
+func NewRendezvousAccessorFactory() AccessorFactory {
+	return newRendezvousAccessor
+}
@@ -28,10 +28,14 @@ func NewEnvironment(l *adapter.Logger, u xviper.Unmarshaler, options ...service.
 	if err := u.Unmarshal(&o); err != nil {
 		return nil, err
 	}
+
+	af, err := o.accessorFactory()
+	if err != nil {
+		return nil, err
+	}
+
 	eo := []service.Option{
-		service.WithAccessorFactory(
-			service.NewConsistentAccessorFactory(o.vnodeCount()),
-		),
+		service.WithWeightedAccessorFactory(af),
 		service.WithDefaultScheme(o.defaultScheme()),
 	}
 	eo = append(eo, options...)
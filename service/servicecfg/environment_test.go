@@ -214,10 +214,34 @@ func testNewEnvironmentConsul(t *testing.T) {
 	assert.Equal(expectedEnvironment, actualEnvironment)
 	assert.NoError(actualEnvironment.Close())
 }
+func testNewEnvironmentUnknownAlgorithm(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		v = viper.New()
+
+		configuration = strings.NewReader(`
+			{
+				"algorithm": "nosuch",
+				"fixed": ["instance1.com:1234"]
+			}
+		`)
+	)
+
+	v.SetConfigType("json")
+	require.NoError(v.ReadConfig(configuration))
+
+	e, err := NewEnvironment(nil, v)
+	assert.Nil(e)
+	assert.ErrorIs(err, service.ErrUnknownAlgorithm)
+}
+
 func TestNewEnvironment(t *testing.T) {
 	t.Run("Empty", testNewEnvironmentEmpty)
 	t.Run("UnmarshalError", testNewEnvironmentUnmarshalError)
 	t.Run("Fixed", testNewEnvironmentFixed)
+	t.Run("UnknownAlgorithm", testNewEnvironmentUnknownAlgorithm)
 	t.Run("Zookeeper", testNewEnvironmentZookeeper)
 	t.Run("Consul", testNewEnvironmentConsul)
 }
@@ -12,6 +12,15 @@ type Options struct {
 	DisableFilter bool   `json:"disableFilter"`
 	DefaultScheme string `json:"defaultScheme"`
 
+	// Algorithm is the hashing algorithm used to assign keys to instances.  See service.Algorithms.
+	Algorithm       string  `json:"algorithm,omitempty"`
+	MaglevTableSize int     `json:"maglevTableSize,omitempty"`
+	LoadBalance     float64 `json:"loadBalance,omitempty"`
+
+	// Weights are static instance weights, used for any instance that service discovery gives no weight to.
+	// Weights only affect the consistentHash and rendezvous algorithms.
+	Weights map[string]int `json:"weights,omitempty"`
+
 	Fixed     []string        `json:"fixed,omitempty"`
 	Zookeeper *zk.Options     `json:"zookeeper,omitempty"`
 	Consul    *consul.Options `json:"consul,omitempty"`
@@ -25,6 +34,43 @@ func (o *Options) vnodeCount() int {
 	return service.DefaultVnodeCount
 }
 
+func (o *Options) accessorFactory() (service.WeightedAccessorFactory, error) {
+	if o == nil {
+		return service.DefaultWeightedAccessorFactory, nil
+	}
+
+	waf, err := service.NewWeightedAlgorithmAccessorFactory(
+		o.Algorithm,
+		service.HashOptions{
+			VnodeCount:      o.vnodeCount(),
+			MaglevTableSize: o.MaglevTableSize,
+			LoadBalance:     o.LoadBalance,
+		},
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return waf.WithStaticWeights(o.weights()), nil
+}
+
+// weights returns the static weights keyed by normalized instance, so that they match discovered instances
+func (o *Options) weights() service.Weights {
+	if o == nil || len(o.Weights) == 0 {
+		return nil
+	}
+
+	weights := make(service.Weights, len(o.Weights))
+	for instance, weight := range o.Weights {
+		if normalized, err := service.NormalizeInstance(o.defaultScheme(), instance); err == nil {
+			weights[normalized] = weight
+		}
+	}
+
+	return weights
+}
+
 func (o *Options) disableFilter() bool {
 	if o != nil {
 		return o.DisableFilter
@@ -1,9 +1,11 @@
 package servicecfg
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/xmidt-org/webpa-common/v2/service"
 )
 
@@ -12,6 +14,10 @@ func testOptionsDefault(t *testing.T, o *Options) {
 	assert.Equal(service.DefaultVnodeCount, o.vnodeCount())
 	assert.False(o.disableFilter())
 	assert.Equal(service.DefaultScheme, o.defaultScheme())
+
+	af, err := o.accessorFactory()
+	assert.NoError(err)
+	assert.NotNil(af)
 }
 
 func testOptionsCustom(t *testing.T) {
@@ -30,6 +36,72 @@ func testOptionsCustom(t *testing.T) {
 	assert.Equal("ftp", o.defaultScheme())
 }
 
+func testOptionsAlgorithm(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	for _, algorithm := range service.Algorithms() {
+		o := Options{
+			Algorithm:       algorithm,
+			MaglevTableSize: 101,
+			LoadBalance:     1.5,
+		}
+
+		af, err := o.accessorFactory()
+		require.NoError(err)
+		require.NotNil(af)
+
+		i, err := af([]string{"an instance"}, nil).Get([]byte("test"))
+		assert.Equal("an instance", i)
+		assert.NoError(err)
+	}
+
+	o := Options{Algorithm: "nosuch"}
+	af, err := o.accessorFactory()
+	assert.Nil(af)
+	assert.ErrorIs(err, service.ErrUnknownAlgorithm)
+}
+
+func testOptionsWeights(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		o = Options{
+			Algorithm: service.RendezvousAlgorithm,
+			Weights: map[string]int{
+				"heavy.com:8080": 5,
+				"bad:instance:":  2,
+			},
+		}
+	)
+
+	assert.Equal(service.Weights{"https://heavy.com:8080": 5}, o.weights())
+
+	af, err := o.accessorFactory()
+	require.NoError(err)
+
+	var (
+		a         = af([]string{"https://heavy.com:8080", "https://light.com:8080"}, nil)
+		heavy     = 0
+		keyCount  = 1000
+		keyFormat = "mac:%012d"
+	)
+
+	for k := 0; k < keyCount; k++ {
+		i, err := a.Get([]byte(fmt.Sprintf(keyFormat, k)))
+		require.NoError(err)
+		if i == "https://heavy.com:8080" {
+			heavy++
+		}
+	}
+
+	// the heavy instance should take about 5/6 of the keys
+	assert.InDelta(keyCount*5/6, heavy, float64(keyCount)/10.0)
+}
+
 func TestOptions(t *testing.T) {
 	t.Run("Default", func(t *testing.T) {
 		testOptionsDefault(t, nil)
@@ -37,4 +109,6 @@ func TestOptions(t *testing.T) {
 	})
 
 	t.Run("Custom", testOptionsCustom)
+	t.Run("Algorithm", testOptionsAlgorithm)
+	t.Run("Weights", testOptionsWeights)
 }
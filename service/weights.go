@@ -0,0 +1,263 @@
+package service
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/go-kit/kit/sd"
+	"github.com/spaolacci/murmur3"
+)
+
+const (
+	// DefaultWeight is the weight of any instance that has not been given one
+	DefaultWeight = 1
+
+	// MaxWeight is the largest weight an instance can have.  Larger weights are reduced to this value,
+	// which bounds the size of weighted hash rings.
+	MaxWeight = 100
+)
+
+// Weights holds the relative weights of instances, keyed by instance.  An instance with weight 2 is
+// assigned roughly twice as many keys as an instance with weight 1.
+type Weights map[string]int
+
+// Weight returns the weight of the given instance.  Instances that are missing or have a nonpositive
+// weight have DefaultWeight.
+func (w Weights) Weight(instance string) int {
+	switch weight := w[instance]; {
+	case weight < 1:
+		return DefaultWeight
+	case weight > MaxWeight:
+		return MaxWeight
+	default:
+		return weight
+	}
+}
+
+// uniform tests if all the given instances have the same weight, in which case weights have no effect
+func (w Weights) uniform(instances []string) bool {
+	for i := 1; i < len(instances); i++ {
+		if w.Weight(instances[i]) != w.Weight(instances[0]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// WeightedInstancer is implemented by sd.Instancers which can report the weights of the instances they discover
+type WeightedInstancer interface {
+	sd.Instancer
+
+	// Weights returns the weights of the most recently discovered instances.  Instances with no weight may be omitted.
+	Weights() Weights
+}
+
+// WeightedAccessorFactory defines the behavior of functions which can take a set of nodes, along with their
+// weights, and turn them into an Accessor.  The weights may be nil.
+type WeightedAccessorFactory func([]string, Weights) Accessor
+
+// Weighted returns a WeightedAccessorFactory which ignores weights and delegates to this factory
+func (af AccessorFactory) Weighted() WeightedAccessorFactory {
+	return func(instances []string, _ Weights) Accessor {
+		return af(instances)
+	}
+}
+
+// Unweighted returns an AccessorFactory which delegates to this factory with every instance at DefaultWeight
+func (waf WeightedAccessorFactory) Unweighted() AccessorFactory {
+	return func(instances []string) Accessor {
+		return waf(instances, nil)
+	}
+}
+
+// WithStaticWeights returns a WeightedAccessorFactory which uses the static weights for any instance
+// that has no weight of its own.  Weights supplied by service discovery take precedence.
+func (waf WeightedAccessorFactory) WithStaticWeights(static Weights) WeightedAccessorFactory {
+	if len(static) == 0 {
+		return waf
+	}
+
+	return func(instances []string, weights Weights) Accessor {
+		merged := make(Weights, len(static)+len(weights))
+		for instance, weight := range static {
+			merged[instance] = weight
+		}
+
+		for instance, weight := range weights {
+			if weight > 0 {
+				merged[instance] = weight
+			}
+		}
+
+		return waf(instances, merged)
+	}
+}
+
+// weightedConsistentAccessor is a hash ring on which each instance has a number of vnodes proportional
+// to its weight.  Vnodes are hashed the same way as consistentHash's, so an instance with DefaultWeight
+// occupies exactly the positions it would on an unweighted ring.
+type weightedConsistentAccessor struct {
+	tokens []uint64
+	owners []string
+}
+
+func newWeightedConsistentAccessor(vnodeCount int, instances []string, weights Weights) Accessor {
+	if len(instances) == 0 {
+		return emptyAccessor{}
+	}
+
+	unique := uniqueInstances(instances)
+	if weights.uniform(unique) {
+		return newConsistentAccessor(vnodeCount, unique)
+	}
+
+	type vnode struct {
+		token uint64
+		owner string
+	}
+
+	var vnodes []vnode
+	for _, instance := range unique {
+		for i := 0; i < vnodeCount*weights.Weight(instance); i++ {
+			vnodes = append(vnodes, vnode{
+				token: murmur3.Sum64([]byte(strconv.Itoa(i) + "=" + instance)),
+				owner: instance,
+			})
+		}
+	}
+
+	sort.Slice(vnodes, func(i, j int) bool {
+		if vnodes[i].token == vnodes[j].token {
+			return vnodes[i].owner < vnodes[j].owner
+		}
+
+		return vnodes[i].token < vnodes[j].token
+	})
+
+	wca := &weightedConsistentAccessor{
+		tokens: make([]uint64, len(vnodes)),
+		owners: make([]string, len(vnodes)),
+	}
+
+	for i, vn := range vnodes {
+		wca.tokens[i] = vn.token
+		wca.owners[i] = vn.owner
+	}
+
+	return wca
+}
+
+func (wca *weightedConsistentAccessor) Get(key []byte) (string, error) {
+	token := murmur3.Sum64(key)
+	i := sort.Search(len(wca.tokens), func(i int) bool {
+		return wca.tokens[i] >= token
+	})
+
+	if i == len(wca.tokens) {
+		i = 0
+	}
+
+	return wca.owners[i], nil
+}
+
+// weightedRendezvousAccessor implements weighted rendezvous hashing, where each instance's score is
+// -weight / ln(h) for a hash h uniform on (0, 1).  Every instance still competes for every key, so changing
+// one instance's weight only moves keys to or from that instance.
+type weightedRendezvousAccessor struct {
+	rendezvousAccessor
+	weights []float64
+}
+
+func newWeightedRendezvousAccessor(instances []string, weights Weights) Accessor {
+	if len(instances) == 0 {
+		return emptyAccessor{}
+	}
+
+	ra := newRendezvousAccessor(instances).(*rendezvousAccessor)
+	if weights.uniform(ra.instances) {
+		return ra
+	}
+
+	wra := &weightedRendezvousAccessor{
+		rendezvousAccessor: *ra,
+		weights:            make([]float64, len(ra.instances)),
+	}
+
+	for i, instance := range ra.instances {
+		wra.weights[i] = float64(weights.Weight(instance))
+	}
+
+	return wra
+}
+
+// unitInterval maps a hash onto the open interval (0, 1)
+func unitInterval(h uint64) float64 {
+	return (float64(h>>11) + 0.5) / (1 << 53)
+}
+
+func (wra *weightedRendezvousAccessor) Get(key []byte) (string, error) {
+	var (
+		hash = murmur3.Sum64(key)
+		best = -1
+		high float64
+	)
+
+	for i, seed := range wra.seeds {
+		if score := -wra.weights[i] / math.Log(unitInterval(mix64(hash^seed))); best < 0 || score > high {
+			best, high = i, score
+		}
+	}
+
+	return wra.instances[best], nil
+}
+
+// NewWeightedConsistentAccessorFactory produces a factory which uses consistent hashing of server nodes,
+// with each node given vnodeCount vnodes per unit of weight.  When all instances have the same weight,
+// the Accessors it creates are identical to those of NewConsistentAccessorFactory.
+//
+// If vnodeCount is nonpositive, DefaultVnodeCount is used.
+func NewWeightedConsistentAccessorFactory(vnodeCount int) WeightedAccessorFactory {
+	if vnodeCount < 1 {
+		vnodeCount = DefaultVnodeCount
+	}
+
+	return func(instances []string, weights Weights) Accessor {
+		return newWeightedConsistentAccessor(vnodeCount, instances, weights)
+	}
+}
+
+// NewWeightedRendezvousAccessorFactory produces a factory which uses weighted rendezvous hashing of
+// server nodes.  When all instances have the same weight, the Accessors it creates are identical to those
+// of NewRendezvousAccessorFactory.
+func NewWeightedRendezvousAccessorFactory() WeightedAccessorFactory {
+	return newWeightedRendezvousAccessor
+}
+
+// DefaultWeightedAccessorFactory is the weighted counterpart of DefaultAccessorFactory.  With no weights,
+// it creates the same Accessors.
+func DefaultWeightedAccessorFactory(instances []string, weights Weights) Accessor {
+	return newWeightedConsistentAccessor(DefaultVnodeCount, instances, weights)
+}
+
+// NewWeightedAlgorithmAccessorFactory produces a WeightedAccessorFactory for the named hashing algorithm.
+// Only ConsistentHashAlgorithm and RendezvousAlgorithm take weights into account.  The other algorithms
+// ignore them.
+func NewWeightedAlgorithmAccessorFactory(algorithm string, o HashOptions) (WeightedAccessorFactory, error) {
+	switch algorithm {
+	case "", ConsistentHashAlgorithm:
+		return NewWeightedConsistentAccessorFactory(o.VnodeCount), nil
+
+	case RendezvousAlgorithm:
+		return NewWeightedRendezvousAccessorFactory(), nil
+
+	default:
+		af, err := NewAlgorithmAccessorFactory(algorithm, o)
+		if err != nil {
+			return nil, err
+		}
+
+		return af.Weighted(), nil
+	}
+}
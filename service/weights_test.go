@@ -0,0 +1,196 @@
+package service
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWeights(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		w      = Weights{"zero": 0, "negative": -5, "two": 2, "huge": 1000000}
+	)
+
+	assert.Equal(DefaultWeight, w.Weight("missing"))
+	assert.Equal(DefaultWeight, w.Weight("zero"))
+	assert.Equal(DefaultWeight, w.Weight("negative"))
+	assert.Equal(2, w.Weight("two"))
+	assert.Equal(MaxWeight, w.Weight("huge"))
+	assert.Equal(DefaultWeight, Weights(nil).Weight("missing"))
+
+	assert.True(w.uniform(nil))
+	assert.True(w.uniform([]string{"missing", "zero", "negative"}))
+	assert.False(w.uniform([]string{"missing", "two"}))
+}
+
+func testWeightedUniform(t *testing.T, weighted WeightedAccessorFactory, unweighted AccessorFactory) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		random    = rand.New(rand.NewSource(addressSeed))
+		instances = generateAddresses(random, 5, 16)
+		keys      = generateKeys(random, 1000)
+
+		expected = unweighted(instances)
+		actual   = []Accessor{
+			weighted(instances, nil),
+			weighted(instances, Weights{instances[0]: 1}),
+			weighted(instances, Weights{instances[0]: 3, instances[1]: 3, instances[2]: 3, instances[3]: 3, instances[4]: 3}),
+		}
+	)
+
+	for _, k := range keys {
+		e, err := expected.Get(k)
+		require.NoError(err)
+		for _, a := range actual {
+			i, err := a.Get(k)
+			require.NoError(err)
+			assert.Equal(e, i)
+		}
+	}
+}
+
+func testWeightedSpread(t *testing.T, weighted WeightedAccessorFactory) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		random    = rand.New(rand.NewSource(addressSeed))
+		instances = generateAddresses(random, 4, 16)
+		keys      = generateKeys(random, 20000)
+		weights   = Weights{instances[0]: 4, instances[1]: 2}
+
+		a      = weighted(instances, weights)
+		counts = make(map[string]int)
+	)
+
+	testAlgorithmEmpty(t, weighted.WithStaticWeights(weights).Unweighted())
+	for _, k := range keys {
+		i, err := a.Get(k)
+		require.NoError(err)
+		counts[i]++
+	}
+
+	// the total weight is 4 + 2 + 1 + 1 = 8
+	for _, instance := range instances {
+		expected := float64(len(keys)*weights.Weight(instance)) / 8.0
+		assert.InDelta(expected, counts[instance], expected*0.2, instance)
+	}
+}
+
+func testWeightedMovement(t *testing.T, weighted WeightedAccessorFactory) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		random    = rand.New(rand.NewSource(addressSeed))
+		instances = generateAddresses(random, 4, 16)
+		keys      = generateKeys(random, 10000)
+
+		before = weighted(instances, nil)
+		after  = weighted(instances, Weights{instances[0]: 2})
+	)
+
+	// doubling one instance's weight only moves keys to that instance
+	for _, k := range keys {
+		b, err := before.Get(k)
+		require.NoError(err)
+
+		a, err := after.Get(k)
+		require.NoError(err)
+
+		if a != b {
+			assert.Equal(instances[0], a)
+		}
+	}
+}
+
+func TestWeightedAccessorFactories(t *testing.T) {
+	testData := []struct {
+		name       string
+		weighted   WeightedAccessorFactory
+		unweighted AccessorFactory
+	}{
+		{"Default", DefaultWeightedAccessorFactory, DefaultAccessorFactory},
+		{"Consistent", NewWeightedConsistentAccessorFactory(50), NewConsistentAccessorFactory(50)},
+		{"ConsistentDefaultVnodes", NewWeightedConsistentAccessorFactory(-1), DefaultAccessorFactory},
+		{"Rendezvous", NewWeightedRendezvousAccessorFactory(), NewRendezvousAccessorFactory()},
+	}
+
+	for _, record := range testData {
+		t.Run(record.name, func(t *testing.T) {
+			t.Run("Empty", func(t *testing.T) { testAlgorithmEmpty(t, record.weighted.Unweighted()) })
+			t.Run("Uniform", func(t *testing.T) { testWeightedUniform(t, record.weighted, record.unweighted) })
+			t.Run("Spread", func(t *testing.T) { testWeightedSpread(t, record.weighted) })
+			t.Run("Movement", func(t *testing.T) { testWeightedMovement(t, record.weighted) })
+		})
+	}
+}
+
+func TestNewWeightedAlgorithmAccessorFactory(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	for _, algorithm := range Algorithms() {
+		waf, err := NewWeightedAlgorithmAccessorFactory(algorithm, HashOptions{})
+		require.NoError(err)
+		require.NotNil(waf)
+
+		i, err := waf([]string{"an instance"}, Weights{"an instance": 2}).Get([]byte("test"))
+		assert.Equal("an instance", i)
+		assert.NoError(err)
+	}
+
+	waf, err := NewWeightedAlgorithmAccessorFactory("nosuch", HashOptions{})
+	assert.Nil(waf)
+	assert.ErrorIs(err, ErrUnknownAlgorithm)
+}
+
+func TestWithStaticWeights(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		actual Weights
+		waf    = WeightedAccessorFactory(func(instances []string, weights Weights) Accessor {
+			actual = weights
+			return EmptyAccessor()
+		})
+	)
+
+	static := waf.WithStaticWeights(nil)
+	static([]string{"a"}, Weights{"a": 2})
+	assert.Equal(Weights{"a": 2}, actual)
+
+	static = waf.WithStaticWeights(Weights{"a": 3, "b": 4})
+	static([]string{"a", "b"}, Weights{"a": 2, "b": 0})
+	assert.Equal(Weights{"a": 2, "b": 4}, actual)
+
+	static([]string{"a", "b"}, nil)
+	assert.Equal(Weights{"a": 3, "b": 4}, actual)
+}
+
+func TestAccessorFactoryWeighted(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		actual []string
+		af     = AccessorFactory(func(instances []string) Accessor {
+			actual = instances
+			return EmptyAccessor()
+		})
+	)
+
+	af.Weighted()([]string{"a"}, Weights{"a": 2})
+	assert.Equal([]string{"a"}, actual)
+}
+
+func TestContextualInstancerWeights(t *testing.T) {
+	assert := assert.New(t)
+	assert.Nil(ContextualInstancer{Instancer: new(MockInstancer)}.Weights())
+}
@@ -137,6 +137,14 @@ func testNewEnvironmentFull(t *testing.T) {
 	require.NoError(err)
 	require.NotNil(e)
 
+	i, ok := e.Instancers().Get("/test1")
+	require.True(ok)
+	require.Implements((*service.InstanceDescriber)(nil), i)
+	assert.Equal(
+		service.Instances{{Address: "instance1", Metadata: map[string]string{PathMetadataKey: "/test1"}}},
+		i.(service.InstanceDescriber).Describe([]string{"instance1"}),
+	)
+
 	e.Register()
 	e.Deregister()
 	assert.NoError(e.Close())
@@ -0,0 +1,25 @@
+package zk
+
+import (
+	"github.com/go-kit/kit/sd"
+	"github.com/xmidt-org/webpa-common/v2/service"
+)
+
+// PathMetadataKey is the instance metadata key that holds the zookeeper path an instance was discovered under
+const PathMetadataKey = "path"
+
+// instancer enriches a go-kit zookeeper Instancer with descriptions of the instances it discovers.
+// Zookeeper nodes carry only the instance string, so the watched path is all that can be added to it.
+type instancer struct {
+	sd.Instancer
+	path string
+}
+
+func (i instancer) Describe(addresses []string) service.Instances {
+	is := service.NewInstances(addresses, nil)
+	for ix := range is {
+		is[ix].Metadata = map[string]string{PathMetadataKey: i.path}
+	}
+
+	return is
+}
@@ -21,7 +21,7 @@ import (
 type ServiceEndpoints struct {
 	lock            sync.RWMutex
 	keyFunc         servicehttp.KeyFunc
-	accessorFactory service.AccessorFactory
+	accessorFactory service.InstanceAccessorFactory
 	accessors       map[string]service.Accessor
 }
 
@@ -52,10 +52,10 @@ func (se *ServiceEndpoints) FanoutURLs(original *http.Request) ([]*url.URL, erro
 	return xhttp.ApplyURLParser(url.Parse, endpoints...)
 }
 
-// MonitorEvent supplies the monitor.Listener behavior.  An accessor is created and stored under
-// the event Key.
+// MonitorEvent supplies the monitor.Listener behavior.  An accessor is created from the event's described
+// instances and stored under the event Key.
 func (se *ServiceEndpoints) MonitorEvent(e monitor.Event) {
-	accessor := se.accessorFactory(e.Instances)
+	accessor := se.accessorFactory(e.Describe())
 	se.lock.Lock()
 	se.accessors[e.Key] = accessor
 	se.lock.Unlock()
@@ -81,19 +81,33 @@ func WithKeyFunc(kf servicehttp.KeyFunc) ServiceEndpointsOption {
 func WithAccessorFactory(af service.AccessorFactory) ServiceEndpointsOption {
 	return func(se *ServiceEndpoints) {
 		if af != nil {
-			se.accessorFactory = af
+			se.accessorFactory = af.Weighted().Instances()
 		} else {
-			se.accessorFactory = service.DefaultAccessorFactory
+			se.accessorFactory = service.DefaultInstanceAccessorFactory
+		}
+	}
+}
+
+// WithInstanceAccessorFactory configures an accessor factory for the given service endpoints which receives
+// everything service discovery knows about each instance, such as its tags, metadata, datacenter, and weight.
+// If nil, then service.DefaultInstanceAccessorFactory is used.
+func WithInstanceAccessorFactory(iaf service.InstanceAccessorFactory) ServiceEndpointsOption {
+	return func(se *ServiceEndpoints) {
+		if iaf != nil {
+			se.accessorFactory = iaf
+		} else {
+			se.accessorFactory = service.DefaultInstanceAccessorFactory
 		}
 	}
 }
 
 // NewServiceEndpoints creates a ServiceEndpoints instance.  By default, device.IDHashParser is used as the KeyFunc
-// and service.DefaultAccessorFactory is used as the accessor factory.
+// and service.DefaultInstanceAccessorFactory, which behaves like service.DefaultAccessorFactory when instances
+// have no weights, is used as the accessor factory.
 func NewServiceEndpoints(options ...ServiceEndpointsOption) *ServiceEndpoints {
 	se := &ServiceEndpoints{
 		keyFunc:         device.IDHashParser,
-		accessorFactory: service.DefaultAccessorFactory,
+		accessorFactory: service.DefaultInstanceAccessorFactory,
 		accessors:       make(map[string]service.Accessor),
 	}
 
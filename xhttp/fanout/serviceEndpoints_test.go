@@ -167,6 +167,41 @@ func testNewServiceEndpointsCustom(t *testing.T) {
 	assert.NoError(err)
 }
 
+func testNewServiceEndpointsInstanceAccessorFactory(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		request = httptest.NewRequest("GET", "/", nil)
+
+		actual   service.Instances
+		expected = service.Instances{
+			{Address: "http://localhost:8080", Tags: []string{"tag1"}, Datacenter: "dc1", Weight: 2},
+		}
+
+		se = NewServiceEndpoints(WithInstanceAccessorFactory(func(is service.Instances) service.Accessor {
+			actual = is
+			return service.DefaultInstanceAccessorFactory(is)
+		}))
+	)
+
+	require.NotNil(se)
+	request.Header.Set(device.DeviceNameHeader, "mac:112233445566")
+
+	se.MonitorEvent(monitor.Event{Key: "key1", Instances: expected.Addresses(), Details: expected})
+	assert.Equal(expected, actual)
+
+	urls, err := se.FanoutURLs(request)
+	assert.Equal([]*url.URL{{Scheme: "http", Host: "localhost:8080"}}, urls)
+	assert.NoError(err)
+
+	// events without details are described by their instances and weights
+	se.MonitorEvent(monitor.Event{Key: "key1", Instances: []string{"http://foobar.net:1234"}, Weights: service.Weights{"http://foobar.net:1234": 3}})
+	assert.Equal(service.Instances{{Address: "http://foobar.net:1234", Weight: 3}}, actual)
+
+	testNewServiceEndpointsDefault(t, NewServiceEndpoints(WithInstanceAccessorFactory(nil)))
+}
+
 func TestNewServiceEndpoints(t *testing.T) {
 	t.Run("KeyFuncError", testNewServiceEndpointsKeyFuncError)
 	t.Run("HashError", testNewServiceEndpointsHashError)
@@ -175,6 +210,7 @@ func TestNewServiceEndpoints(t *testing.T) {
 		testNewServiceEndpointsDefault(t, NewServiceEndpoints(WithAccessorFactory(nil), WithKeyFunc(nil)))
 	})
 	t.Run("Custom", testNewServiceEndpointsCustom)
+	t.Run("InstanceAccessorFactory", testNewServiceEndpointsInstanceAccessorFactory)
 }
 
 func TestServiceEndpointsAlternate(t *testing.T) {